@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/magenx/hek3ster/internal/cluster"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyConfigPath  string
+	applyAutoApprove bool
+	applyPlan        bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconcile worker pools with the configuration",
+	Long: `Compare the worker pools in the configuration file with the running cluster and converge them.
+Pools are scaled up and down (nodes are drained before deletion), workers of another instance type
+are replaced one at a time and node labels and taints are synced.
+A plan is shown and must be confirmed before any change is made.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printBanner()
+
+		if applyConfigPath == "" {
+			return fmt.Errorf("configuration file path is required")
+		}
+
+		fmt.Printf("Loading configuration from: %s\n", applyConfigPath)
+
+		// Load configuration
+		loader, err := config.NewLoader(applyConfigPath, "", true)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+
+		// Validate configuration for apply
+		if err := loader.Validate("apply"); err != nil {
+			if loader.HasErrors() {
+				loader.PrintErrors()
+			}
+			return err
+		}
+
+		// Run comprehensive validator
+		validator := config.NewValidator(loader.Settings)
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("configuration validation failed: %w", err)
+		}
+
+		fmt.Println("\n\x1b[32mConfiguration validated successfully\x1b[0m")
+		fmt.Printf("Cluster Name: %s\n\n", loader.Settings.ClusterName)
+
+		// Create Hetzner client
+		hetznerClient := newHetznerClient(loader.Settings)
+
+		// Create worker pool reconciler
+		applier, err := cluster.NewApplier(loader.Settings, hetznerClient, applyAutoApprove, applyPlan)
+		if err != nil {
+			return fmt.Errorf("failed to create applier: %w", err)
+		}
+		defer applier.SSHClient.Close()
+
+		if err := applier.Run(); err != nil {
+			return fmt.Errorf("apply failed: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	applyCmd.Flags().StringVarP(&applyConfigPath, "config", "c", "", "Path to the YAML configuration file (required)")
+	applyCmd.Flags().BoolVar(&applyAutoApprove, "auto-approve", false, "Apply the plan without asking for confirmation")
+	applyCmd.Flags().BoolVar(&applyPlan, "plan", false, "Only show the plan without making any changes")
+	applyCmd.MarkFlagRequired("config")
+}
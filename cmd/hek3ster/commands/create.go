@@ -6,13 +6,15 @@ import (
 	"github.com/magenx/hek3ster/internal/cluster"
 	"github.com/magenx/hek3ster/internal/config"
 	"github.com/magenx/hek3ster/internal/util"
-	"github.com/magenx/hek3ster/pkg/hetzner"
 	"github.com/spf13/cobra"
 )
 
 var (
 	createConfigPath string
 	createQuiet      bool
+	createPlan       bool
+	createResume     bool
+	createRollback   bool
 )
 
 var createCmd = &cobra.Command{
@@ -42,6 +44,23 @@ var createCmd = &cobra.Command{
 			return err
 		}
 
+		// Plan mode only reads from the Hetzner API, so skip tool installation and creation
+		if createPlan {
+			validator := config.NewValidator(loader.Settings)
+			if err := validator.Validate(); err != nil {
+				return fmt.Errorf("configuration validation failed: %w", err)
+			}
+
+			fmt.Println("\n\033[32mConfiguration validated successfully\033[0m")
+
+			hetznerClient := newHetznerClient(loader.Settings)
+			planner := cluster.NewPlanner(loader.Settings, hetznerClient)
+			if err := planner.Run(); err != nil {
+				return fmt.Errorf("failed to build plan: %w", err)
+			}
+			return nil
+		}
+
 		// Ensure required tools are installed (using k3s version from config)
 		fmt.Println("\nChecking for required tools:")
 		installer, err := util.NewToolInstaller(loader.Settings.K3sVersion)
@@ -66,13 +85,14 @@ var createCmd = &cobra.Command{
 		fmt.Printf("Workers: %d pools\n\n", len(loader.Settings.WorkerNodePools))
 
 		// Create Hetzner client
-		hetznerClient := hetzner.NewClient(loader.Settings.HetznerToken)
+		hetznerClient := newHetznerClient(loader.Settings)
 
 		// Create cluster creator
-		creator, err := cluster.NewCreatorEnhanced(loader.Settings, hetznerClient)
+		creator, err := cluster.NewCreatorEnhanced(loader.Settings, hetznerClient, createResume, createRollback)
 		if err != nil {
 			return fmt.Errorf("failed to create cluster creator: %w", err)
 		}
+		defer creator.SSHClient.Close()
 
 		// Run cluster creation
 		if err := creator.Run(); err != nil {
@@ -90,5 +110,8 @@ var createCmd = &cobra.Command{
 func init() {
 	createCmd.Flags().StringVarP(&createConfigPath, "config", "c", "", "Path to the YAML configuration file (required)")
 	createCmd.Flags().BoolVarP(&createQuiet, "quiet", "q", false, "Suppress the sponsor message")
+	createCmd.Flags().BoolVar(&createPlan, "plan", false, "Show which resources would be created, changed or reused without making any changes")
+	createCmd.Flags().BoolVar(&createResume, "resume", false, "Resume a failed creation, skipping steps that already completed")
+	createCmd.Flags().BoolVar(&createRollback, "rollback-on-failure", false, "Delete the resources created by this run if creation fails")
 	createCmd.MarkFlagRequired("config")
 }
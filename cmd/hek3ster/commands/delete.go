@@ -5,7 +5,6 @@ import (
 
 	"github.com/magenx/hek3ster/internal/cluster"
 	"github.com/magenx/hek3ster/internal/config"
-	"github.com/magenx/hek3ster/pkg/hetzner"
 	"github.com/spf13/cobra"
 )
 
@@ -46,7 +45,7 @@ var deleteCmd = &cobra.Command{
 		fmt.Printf("Cluster Name: %s\n\n", loader.Settings.ClusterName)
 
 		// Create Hetzner client
-		hetznerClient := hetzner.NewClient(loader.Settings.HetznerToken)
+		hetznerClient := newHetznerClient(loader.Settings)
 
 		// Create cluster deleter
 		deleter := cluster.NewDeleter(loader.Settings, hetznerClient, deleteForce)
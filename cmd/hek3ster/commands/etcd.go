@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/magenx/hek3ster/internal/cluster"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	etcdConfigPath        string
+	etcdOutput            string
+	etcdSnapshotName      string
+	etcdSnapshotNode      string
+	etcdSnapshotRetention int64
+	etcdRestoreSnapshot   string
+	etcdRestoreForce      bool
+)
+
+var etcdCmd = &cobra.Command{
+	Use:   "etcd",
+	Short: "Manage the embedded etcd datastore",
+	Long:  `Manage the embedded etcd datastore of an existing cluster.`,
+}
+
+var etcdSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage etcd snapshots",
+	Long: `Save, list, delete and prune embedded etcd snapshots.
+
+Commands run k3s etcd-snapshot on the masters over SSH. When S3 is configured
+under datastore.embedded_etcd, S3 snapshots are included.`,
+}
+
+var etcdSnapshotSaveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "Take an on-demand etcd snapshot",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout := redirectOutputForJSON()
+		defer func() { os.Stdout = stdout }()
+
+		manager, err := newEtcdSnapshotManager()
+		if err != nil {
+			return err
+		}
+		defer manager.SSHClient.Close()
+
+		snapshot, node, err := manager.Save(etcdSnapshotName, etcdSnapshotNode)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot %s saved on %s\n\n", snapshot, node)
+
+		snapshots, err := manager.List()
+		if err != nil {
+			return err
+		}
+		var saved []cluster.EtcdSnapshot
+		for _, s := range snapshots {
+			if s.Name == snapshot {
+				saved = append(saved, s)
+			}
+		}
+		return printEtcdSnapshots(stdout, saved)
+	},
+}
+
+var etcdSnapshotListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List etcd snapshots with their size, location and age",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout := redirectOutputForJSON()
+		defer func() { os.Stdout = stdout }()
+
+		manager, err := newEtcdSnapshotManager()
+		if err != nil {
+			return err
+		}
+		defer manager.SSHClient.Close()
+
+		snapshots, err := manager.List()
+		if err != nil {
+			return err
+		}
+		return printEtcdSnapshots(stdout, snapshots)
+	},
+}
+
+var etcdSnapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <snapshot>...",
+	Short: "Delete etcd snapshots by name",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := newEtcdSnapshotManager()
+		if err != nil {
+			return err
+		}
+		defer manager.SSHClient.Close()
+		return manager.Delete(args)
+	},
+}
+
+var etcdSnapshotPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the oldest etcd snapshots beyond the retention count",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := newEtcdSnapshotManager()
+		if err != nil {
+			return err
+		}
+		defer manager.SSHClient.Close()
+
+		retention := etcdSnapshotRetention
+		if retention == 0 {
+			retention = cluster.DefaultEtcdSnapshotRetention
+			if etcd := manager.Config.Datastore.EmbeddedEtcd; etcd != nil && etcd.SnapshotRetention > 0 {
+				retention = etcd.SnapshotRetention
+			}
+		}
+		return manager.Prune(etcdSnapshotName, retention)
+	},
+}
+
+var etcdRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore the cluster datastore from an etcd snapshot",
+	Long: `Restore the cluster datastore from an etcd snapshot.
+
+k3s is stopped on every master and etcd is reset to the snapshot on the first
+master. The other masters then wipe their etcd data and rejoin one at a time,
+and the agents are restarted. The snapshot must be stored on the first master
+or in S3.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := newEtcdSnapshotManager()
+		if err != nil {
+			return err
+		}
+		defer manager.SSHClient.Close()
+		return manager.Restore(etcdRestoreSnapshot, etcdRestoreForce)
+	},
+}
+
+// newEtcdSnapshotManager loads and validates the configuration and creates a snapshot manager
+func newEtcdSnapshotManager() (*cluster.EtcdSnapshotManager, error) {
+	printBanner()
+
+	if etcdConfigPath == "" {
+		return nil, fmt.Errorf("configuration file path is required")
+	}
+	if etcdOutput != "table" && etcdOutput != "json" {
+		return nil, fmt.Errorf("unsupported output format %q, use table or json", etcdOutput)
+	}
+
+	fmt.Printf("Loading configuration from: %s\n", etcdConfigPath)
+
+	// Load configuration
+	loader, err := config.NewLoader(etcdConfigPath, "", true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Validate configuration for etcd
+	if err := loader.Validate("etcd"); err != nil {
+		if loader.HasErrors() {
+			loader.PrintErrors()
+		}
+		return nil, err
+	}
+
+	fmt.Println("\n\x1b[32mConfiguration validated successfully\x1b[0m")
+	fmt.Printf("Cluster Name: %s\n\n", loader.Settings.ClusterName)
+
+	// Create Hetzner client
+	hetznerClient := newHetznerClient(loader.Settings)
+
+	manager, err := cluster.NewEtcdSnapshotManager(loader.Settings, hetznerClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd snapshot manager: %w", err)
+	}
+	return manager, nil
+}
+
+// redirectOutputForJSON sends progress output to stderr in JSON mode
+// so that stdout only holds the JSON document. It returns the original stdout.
+func redirectOutputForJSON() *os.File {
+	stdout := os.Stdout
+	if etcdOutput == "json" {
+		os.Stdout = os.Stderr
+	}
+	return stdout
+}
+
+// printEtcdSnapshots writes snapshots in the selected output format
+func printEtcdSnapshots(w io.Writer, snapshots []cluster.EtcdSnapshot) error {
+	if etcdOutput == "json" {
+		if snapshots == nil {
+			snapshots = []cluster.EtcdSnapshot{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(snapshots)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Fprintln(w, "No snapshots found")
+		return nil
+	}
+	cluster.FormatEtcdSnapshots(w, snapshots, time.Now())
+	return nil
+}
+
+func init() {
+	etcdCmd.PersistentFlags().StringVarP(&etcdConfigPath, "config", "c", "", "Path to the YAML configuration file (required)")
+	etcdCmd.MarkPersistentFlagRequired("config")
+
+	etcdSnapshotSaveCmd.Flags().StringVar(&etcdSnapshotName, "name", cluster.DefaultEtcdSnapshotName, "Snapshot name prefix")
+	etcdSnapshotSaveCmd.Flags().StringVar(&etcdSnapshotNode, "node", "", "Master to take the snapshot on (default: first master)")
+	etcdSnapshotSaveCmd.Flags().StringVarP(&etcdOutput, "output", "o", "table", "Output format: table or json")
+
+	etcdSnapshotListCmd.Flags().StringVarP(&etcdOutput, "output", "o", "table", "Output format: table or json")
+
+	etcdSnapshotPruneCmd.Flags().StringVar(&etcdSnapshotName, "name", cluster.DefaultEtcdSnapshotName, "Name prefix of the snapshots to prune")
+	etcdSnapshotPruneCmd.Flags().Int64Var(&etcdSnapshotRetention, "retention", 0, "Number of snapshots to keep (default: snapshot_retention from the configuration)")
+
+	etcdRestoreCmd.Flags().StringVar(&etcdRestoreSnapshot, "snapshot", "", "Name of the snapshot to restore (required)")
+	etcdRestoreCmd.Flags().BoolVar(&etcdRestoreForce, "force", false, "Restore without confirmation prompt")
+	etcdRestoreCmd.MarkFlagRequired("snapshot")
+
+	etcdSnapshotCmd.AddCommand(etcdSnapshotSaveCmd)
+	etcdSnapshotCmd.AddCommand(etcdSnapshotListCmd)
+	etcdSnapshotCmd.AddCommand(etcdSnapshotDeleteCmd)
+	etcdSnapshotCmd.AddCommand(etcdSnapshotPruneCmd)
+	etcdCmd.AddCommand(etcdSnapshotCmd)
+	etcdCmd.AddCommand(etcdRestoreCmd)
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/magenx/hek3ster/internal/cluster"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	imageConfigPath   string
+	imageServerType   string
+	imageLocation     string
+	imageK3sBinary    bool
+	imageAirgapImages bool
+	imageSetConfig    string
+)
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage node images",
+	Long:  `Manage custom node images (snapshots) built from the configuration.`,
+}
+
+var imageBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a node image snapshot",
+	Long: `Build a node image snapshot from the configuration.
+
+A temporary server is booted from image, additional_packages are installed and
+additional_pre_k3s_commands are run. With --k3s-binary and --airgap-images the
+k3s binary and airgap images of k3s_version are downloaded into the image, so
+nodes created from it skip those downloads. The server is then snapshotted and
+deleted.
+
+The snapshot has the architecture of the build server type. With --set-config
+its ID is written to autoscaling_image or image in the configuration file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printBanner()
+
+		if imageConfigPath == "" {
+			return fmt.Errorf("configuration file path is required")
+		}
+		if imageSetConfig != "" && imageSetConfig != "autoscaling_image" && imageSetConfig != "image" {
+			return fmt.Errorf("unsupported setting %q for --set-config, use autoscaling_image or image", imageSetConfig)
+		}
+
+		fmt.Printf("Loading configuration from: %s\n", imageConfigPath)
+
+		// Load configuration
+		loader, err := config.NewLoader(imageConfigPath, "", true)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+
+		// Validate configuration for image builds
+		if err := loader.Validate("image"); err != nil {
+			if loader.HasErrors() {
+				loader.PrintErrors()
+			}
+			return err
+		}
+
+		fmt.Println("\n\x1b[32mConfiguration validated successfully\x1b[0m")
+		fmt.Printf("Cluster Name: %s\n\n", loader.Settings.ClusterName)
+
+		// Create Hetzner client
+		hetznerClient := newHetznerClient(loader.Settings)
+
+		builder, err := cluster.NewImageBuilder(loader.Settings, hetznerClient, cluster.ImageBuildOptions{
+			ServerType:   imageServerType,
+			Location:     imageLocation,
+			K3sBinary:    imageK3sBinary,
+			AirgapImages: imageAirgapImages,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create image builder: %w", err)
+		}
+		defer builder.SSHClient.Close()
+
+		image, err := builder.Build()
+		if err != nil {
+			return fmt.Errorf("image build failed: %w", err)
+		}
+
+		imageID := strconv.FormatInt(image.ID, 10)
+		if imageSetConfig == "" {
+			fmt.Printf("\nSnapshot %s is ready. To use it, set in %s:\n\n  autoscaling_image: \"%s\"\n\nor image: \"%s\" for all nodes.\n", imageID, imageConfigPath, imageID, imageID)
+			return nil
+		}
+
+		if err := loader.SetValue(imageSetConfig, imageID); err != nil {
+			return fmt.Errorf("failed to update configuration: %w", err)
+		}
+		fmt.Printf("\n\x1b[32mSet %s to snapshot %s in %s\x1b[0m\n", imageSetConfig, imageID, imageConfigPath)
+		return nil
+	},
+}
+
+func init() {
+	imageCmd.PersistentFlags().StringVarP(&imageConfigPath, "config", "c", "", "Path to the YAML configuration file (required)")
+	imageCmd.MarkPersistentFlagRequired("config")
+
+	imageBuildCmd.Flags().StringVar(&imageServerType, "server-type", "", "Server type of the build server, decides the image architecture (default: masters instance type)")
+	imageBuildCmd.Flags().StringVar(&imageLocation, "location", "", "Location of the build server (default: first masters location)")
+	imageBuildCmd.Flags().BoolVar(&imageK3sBinary, "k3s-binary", false, "Download the k3s binary of k3s_version into the image")
+	imageBuildCmd.Flags().BoolVar(&imageAirgapImages, "airgap-images", false, "Download the k3s airgap images of k3s_version into the image")
+	imageBuildCmd.Flags().StringVar(&imageSetConfig, "set-config", "", "Write the snapshot ID to this setting of the configuration file: autoscaling_image or image")
+
+	imageCmd.AddCommand(imageBuildCmd)
+}
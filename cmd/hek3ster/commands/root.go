@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/pkg/hetzner"
 	"github.com/spf13/cobra"
 )
 
@@ -30,10 +32,15 @@ on Hetzner Cloud in minutes. No Terraform, Packer, or Ansible required.`,
 
 func init() {
 	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(upgradeCmd)
 	rootCmd.AddCommand(releasesCmd)
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(sshCmd)
+	rootCmd.AddCommand(etcdCmd)
+	rootCmd.AddCommand(imageCmd)
 	rootCmd.AddCommand(completionCmd)
 	rootCmd.AddCommand(versionCmd)
 
@@ -50,6 +57,14 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+// newHetznerClient creates a Hetzner client with the API settings of a configuration
+func newHetznerClient(settings *config.Main) *hetzner.Client {
+	return hetzner.NewClient(settings.HetznerToken,
+		hetzner.WithEndpoint(settings.HetznerEndpoint),
+		hetzner.WithLocationFallback(settings.LocationFallback),
+	)
+}
+
 func printBanner() {
 	green := "\033[32m"
 	blue := "\033[34m"
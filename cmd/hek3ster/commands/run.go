@@ -5,7 +5,6 @@ import (
 
 	"github.com/magenx/hek3ster/internal/cluster"
 	"github.com/magenx/hek3ster/internal/config"
-	"github.com/magenx/hek3ster/pkg/hetzner"
 	"github.com/spf13/cobra"
 )
 
@@ -59,13 +58,14 @@ var runCmd = &cobra.Command{
 		fmt.Printf("Cluster Name: %s\n\n", loader.Settings.ClusterName)
 
 		// Create Hetzner client
-		hetznerClient := hetzner.NewClient(loader.Settings.HetznerToken)
+		hetznerClient := newHetznerClient(loader.Settings)
 
 		// Create enhanced runner with parallel execution
 		runner, err := cluster.NewRunnerEnhanced(loader.Settings, hetznerClient)
 		if err != nil {
 			return fmt.Errorf("failed to create runner: %w", err)
 		}
+		defer runner.SSHClient.Close()
 
 		// Run command or script
 		if commandProvided {
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/magenx/hek3ster/internal/cluster"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sshConfigPath  string
+	sshPrintConfig bool
+)
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh [instance-name | role | pool]",
+	Short: "Open an interactive shell on a cluster node",
+	Long: `Open an interactive shell on a cluster node.
+
+The node is found through the labels of the cluster servers, by instance name,
+role (master, worker, nat-gateway) or pool name. If several servers match, the
+first one by name is used. Nodes behind the NAT gateway are reached through it
+as bastion, with the configured key, certificate or agent and the pinned host keys.
+
+With --print-config, an OpenSSH config snippet with an entry for every node is
+written to stdout instead, so plain ssh, scp and rsync can reach the nodes:
+
+  hek3ster ssh --config cluster.yaml --print-config > ~/.ssh/config.d/my-cluster`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sshConfigPath == "" {
+			return fmt.Errorf("configuration file path is required")
+		}
+		if !sshPrintConfig && len(args) == 0 {
+			return fmt.Errorf("please specify an instance name, role or pool, or use --print-config")
+		}
+		if sshPrintConfig && len(args) > 0 {
+			return fmt.Errorf("--print-config writes every node and takes no instance name, role or pool")
+		}
+
+		// The config snippet is written to stdout, so nothing else is printed
+		if !sshPrintConfig {
+			printBanner()
+			fmt.Printf("Loading configuration from: %s\n", sshConfigPath)
+		}
+
+		// Load configuration
+		loader, err := config.NewLoader(sshConfigPath, "", true)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+
+		// Validate configuration for ssh
+		if err := loader.Validate("ssh"); err != nil {
+			if loader.HasErrors() {
+				loader.PrintErrors()
+			}
+			return err
+		}
+
+		// Create Hetzner client
+		hetznerClient := newHetznerClient(loader.Settings)
+
+		shell, err := cluster.NewNodeShell(loader.Settings, hetznerClient)
+		if err != nil {
+			return fmt.Errorf("failed to create SSH client: %w", err)
+		}
+		defer shell.SSHClient.Close()
+
+		if sshPrintConfig {
+			return shell.WriteSSHConfig(os.Stdout)
+		}
+
+		fmt.Printf("Cluster Name: %s\n\n", loader.Settings.ClusterName)
+		return shell.Open(args[0])
+	},
+}
+
+func init() {
+	sshCmd.Flags().StringVarP(&sshConfigPath, "config", "c", "", "Path to the YAML configuration file (required)")
+	sshCmd.Flags().BoolVar(&sshPrintConfig, "print-config", false, "Print an OpenSSH config snippet for every node instead of opening a shell")
+	sshCmd.MarkFlagRequired("config")
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/magenx/hek3ster/internal/cluster"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusConfigPath string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show cluster inventory and health",
+	Long:  `Show every server of an existing cluster with its role, pool, location, IP addresses, k3s version and Ready state.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printBanner()
+
+		if statusConfigPath == "" {
+			return fmt.Errorf("configuration file path is required")
+		}
+
+		fmt.Printf("Loading configuration from: %s\n", statusConfigPath)
+
+		// Load configuration
+		loader, err := config.NewLoader(statusConfigPath, "", true)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+
+		// Validate configuration for status
+		if err := loader.Validate("status"); err != nil {
+			if loader.HasErrors() {
+				loader.PrintErrors()
+			}
+			return err
+		}
+
+		fmt.Println("\n\x1b[32mConfiguration validated successfully\x1b[0m")
+		fmt.Printf("Cluster Name: %s\n\n", loader.Settings.ClusterName)
+
+		// Create Hetzner client
+		hetznerClient := newHetznerClient(loader.Settings)
+
+		// Create status reporter
+		reporter, err := cluster.NewStatusReporter(loader.Settings, hetznerClient)
+		if err != nil {
+			return fmt.Errorf("failed to create status reporter: %w", err)
+		}
+		defer reporter.SSHClient.Close()
+
+		if err := reporter.Run(); err != nil {
+			return fmt.Errorf("failed to get cluster status: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	statusCmd.Flags().StringVarP(&statusConfigPath, "config", "c", "", "Path to the YAML configuration file (required)")
+	statusCmd.MarkFlagRequired("config")
+}
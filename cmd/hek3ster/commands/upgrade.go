@@ -2,11 +2,11 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/magenx/hek3ster/internal/cluster"
 	"github.com/magenx/hek3ster/internal/config"
 	"github.com/magenx/hek3ster/internal/util"
-	"github.com/magenx/hek3ster/pkg/hetzner"
 	"github.com/spf13/cobra"
 )
 
@@ -15,12 +15,17 @@ var (
 	upgradeNewK3sVersion string
 	upgradeForce         bool
 	upgradeQuiet         bool
+	upgradeRollback      bool
+	upgradeTimeout       time.Duration
 )
 
 var upgradeCmd = &cobra.Command{
 	Use:   "upgrade",
 	Short: "Upgrade a cluster to a newer version of k3s",
-	Long:  `Upgrade an existing k3s cluster to a new version of k3s.`,
+	Long: `Upgrade an existing k3s cluster to a new version of k3s.
+
+An etcd snapshot is taken before the upgrade starts. Use --rollback to move
+the nodes back to the version recorded before the last upgrade.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		printBanner()
 
@@ -28,7 +33,7 @@ var upgradeCmd = &cobra.Command{
 			return fmt.Errorf("configuration file path is required")
 		}
 
-		if upgradeNewK3sVersion == "" {
+		if upgradeNewK3sVersion == "" && !upgradeRollback {
 			return fmt.Errorf("new k3s version is required")
 		}
 
@@ -40,17 +45,31 @@ var upgradeCmd = &cobra.Command{
 			return fmt.Errorf("failed to load configuration: %w", err)
 		}
 
-		// Validate configuration for upgrade
-		if err := loader.Validate("upgrade"); err != nil {
+		// Validate configuration for upgrade or rollback
+		action := "upgrade"
+		if upgradeRollback {
+			action = "rollback"
+		}
+		if err := loader.Validate(action); err != nil {
 			if loader.HasErrors() {
 				loader.PrintErrors()
 			}
 			return err
 		}
 
+		// Run comprehensive validator
+		validator := config.NewValidator(loader.Settings)
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("configuration validation failed: %w", err)
+		}
+
 		// Ensure required tools are installed (using the NEW k3s version for kubectl)
+		toolsVersion := upgradeNewK3sVersion
+		if upgradeRollback {
+			toolsVersion = loader.Settings.K3sVersion
+		}
 		fmt.Println("\nChecking for required tools (kubectl, helm, kubectl-ai)")
-		installer, err := util.NewToolInstaller(upgradeNewK3sVersion)
+		installer, err := util.NewToolInstaller(toolsVersion)
 		if err != nil {
 			return fmt.Errorf("failed to initialize tool installer: %w", err)
 		}
@@ -61,16 +80,23 @@ var upgradeCmd = &cobra.Command{
 
 		fmt.Println("\n\x1b[32mConfiguration validated successfully\x1b[0m")
 		fmt.Printf("Cluster Name: %s\n", loader.Settings.ClusterName)
-		fmt.Printf("New k3s version: %s\n\n", upgradeNewK3sVersion)
+		if upgradeRollback {
+			fmt.Print("Rolling back the last upgrade\n\n")
+		} else {
+			fmt.Printf("New k3s version: %s\n\n", upgradeNewK3sVersion)
+		}
 
 		// Create Hetzner client
-		hetznerClient := hetzner.NewClient(loader.Settings.HetznerToken)
+		hetznerClient := newHetznerClient(loader.Settings)
 
 		// Create cluster upgrader
 		upgrader, err := cluster.NewUpgraderEnhanced(loader.Settings, hetznerClient, upgradeNewK3sVersion, upgradeForce)
 		if err != nil {
 			return fmt.Errorf("failed to create cluster upgrader: %w", err)
 		}
+		defer upgrader.SSHClient.Close()
+		upgrader.Timeout = upgradeTimeout
+		upgrader.Rollback = upgradeRollback
 
 		// Run cluster upgrade
 		fmt.Println("Starting cluster upgrade")
@@ -88,9 +114,11 @@ var upgradeCmd = &cobra.Command{
 
 func init() {
 	upgradeCmd.Flags().StringVarP(&upgradeConfigPath, "config", "c", "", "Path to the YAML configuration file (required)")
-	upgradeCmd.Flags().StringVar(&upgradeNewK3sVersion, "new-k3s-version", "", "The new version of k3s to upgrade to (required)")
+	upgradeCmd.Flags().StringVar(&upgradeNewK3sVersion, "new-k3s-version", "", "The new version of k3s to upgrade to (required unless --rollback)")
 	upgradeCmd.Flags().BoolVar(&upgradeForce, "force", false, "Force upgrade without confirmation prompts")
+	upgradeCmd.Flags().DurationVar(&upgradeTimeout, "timeout", cluster.DefaultUpgradeTimeout, "Maximum time to wait for the masters and for the workers to upgrade")
+	upgradeCmd.Flags().BoolVar(&upgradeRollback, "rollback", false, "Roll back the last upgrade to the recorded pre-upgrade k3s version")
 	upgradeCmd.Flags().BoolVarP(&upgradeQuiet, "quiet", "q", false, "Suppress the sponsor message")
 	upgradeCmd.MarkFlagRequired("config")
-	upgradeCmd.MarkFlagRequired("new-k3s-version")
+	upgradeCmd.MarkFlagsMutuallyExclusive("new-k3s-version", "rollback")
 }
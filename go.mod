@@ -8,6 +8,7 @@ require (
 	github.com/hetznercloud/hcloud-go/v2 v2.34.0
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/crypto v0.47.0
+	golang.org/x/term v0.39.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
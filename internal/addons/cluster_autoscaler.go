@@ -27,7 +27,10 @@ type ClusterAutoscalerInstaller struct {
 	Config        *config.Main
 	SSHClient     *util.SSH
 	KubectlClient *util.KubectlClient
-	ctx           context.Context
+	// NodeImage reports whether the autoscaling image is a node image built by hek3ster,
+	// which already contains the root-level packages and pre-k3s commands
+	NodeImage bool
+	ctx       context.Context
 }
 
 // NewClusterAutoscalerInstaller creates a new cluster autoscaler installer
@@ -287,10 +290,6 @@ func (c *ClusterAutoscalerInstaller) buildEnvironmentVariables(firstMaster *hclo
 			"name":  "HCLOUD_FIREWALL",
 			"value": c.Config.ClusterName,
 		},
-		{
-			"name":  "HCLOUD_SSH_KEY",
-			"value": c.Config.ClusterName,
-		},
 		{
 			"name":  "HCLOUD_NETWORK",
 			"value": networkName,
@@ -303,6 +302,10 @@ func (c *ClusterAutoscalerInstaller) buildEnvironmentVariables(firstMaster *hclo
 			"name":  "HCLOUD_PUBLIC_IPV6",
 			"value": fmt.Sprintf("%t", enablePublicIPv6),
 		},
+		{
+			"name":  "HCLOUD_SSH_KEY",
+			"value": c.Config.ClusterName,
+		},
 	}
 
 	return env, nil
@@ -365,11 +368,15 @@ func (c *ClusterAutoscalerInstaller) buildNodeConfig(pool config.WorkerNodePool,
 		})
 	}
 
-	return map[string]interface{}{
+	nodeConfig := map[string]interface{}{
 		"cloudInit": cloudInitData,
 		"labels":    labels,
 		"taints":    taints,
-	}, nil
+	}
+	if pool.SpreadPlacement() {
+		nodeConfig["placementGroup"] = pool.PlacementGroupName(c.Config.ClusterName)
+	}
+	return nodeConfig, nil
 }
 
 // generateCloudInitForPool generates cloud-init data for a worker pool
@@ -380,13 +387,18 @@ func (c *ClusterAutoscalerInstaller) generateCloudInitForPool(pool config.Worker
 		return "", fmt.Errorf("failed to generate worker install script: %w", err)
 	}
 
-	// Combine all packages
-	allPackages := append([]string{}, c.Config.AdditionalPackages...)
+	// Combine all packages; a node image already has the root-level ones
+	allPackages := []string{}
+	if !c.NodeImage {
+		allPackages = append(allPackages, c.Config.AdditionalPackages...)
+	}
 	allPackages = append(allPackages, pool.AdditionalPackages...)
 
-	// Combine pre-k3s commands
+	// Combine pre-k3s commands; a node image already ran the root-level ones
 	initCommands := []string{}
-	initCommands = append(initCommands, c.Config.AdditionalPreK3sCommands...)
+	if !c.NodeImage {
+		initCommands = append(initCommands, c.Config.AdditionalPreK3sCommands...)
+	}
 	initCommands = append(initCommands, pool.AdditionalPreK3sCommands...)
 	// Add the worker install script as the main init command
 	initCommands = append(initCommands, workerScript)
@@ -397,9 +409,25 @@ func (c *ClusterAutoscalerInstaller) generateCloudInitForPool(pool config.Worker
 	// Determine if local firewall should be used
 	useLocalFirewall := !c.Config.Networking.PrivateNetwork.Enabled && c.Config.Networking.PublicNetwork.UseLocalFirewall
 
+	// Autoscaled nodes get the same login user as the other nodes
+	var sshUser *cloudinit.SSHUser
+	if c.Config.Networking.SSH.NonRootUser() {
+		publicKey, err := c.Config.Networking.SSH.AuthorizedKey()
+		if err != nil {
+			return "", fmt.Errorf("failed to read public key: %w", err)
+		}
+		sshUser = cloudinit.NewSSHUser(c.Config.Networking.SSH.User, publicKey)
+	}
+	userCAKey, err := c.Config.Networking.SSH.UserCAPublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to read SSH user CA public key: %w", err)
+	}
+
 	// Generate cloud-init with init commands
 	generator := cloudinit.NewGenerator(&cloudinit.Config{
 		SSHPort:                  c.Config.Networking.SSH.Port,
+		SSHUser:                  sshUser,
+		SSHUserCAKey:             userCAKey,
 		Packages:                 allPackages,
 		InitCommands:             initCommands,
 		UseLocalFirewall:         useLocalFirewall,
@@ -409,6 +437,7 @@ func (c *ClusterAutoscalerInstaller) generateCloudInitForPool(pool config.Worker
 		ServiceCIDR:              c.Config.Networking.ServiceCIDR,
 		AllowedNetworksSSH:       c.Config.Networking.AllowedNetworks.SSH,
 		AllowedNetworksAPI:       c.Config.Networking.AllowedNetworks.API,
+		Volumes:                  c.poolVolumes(pool),
 	})
 
 	cloudInit, err := generator.Generate()
@@ -419,6 +448,38 @@ func (c *ClusterAutoscalerInstaller) generateCloudInitForPool(pool config.Worker
 	return cloudInit, nil
 }
 
+// poolVolumes returns the volume settings of an autoscaled pool, or nil if it has none
+// The autoscaler creates the servers, so the nodes claim a detached volume of their pool
+// or create one themselves on first boot.
+func (c *ClusterAutoscalerInstaller) poolVolumes(pool config.WorkerNodePool) *cloudinit.Volumes {
+	if len(pool.Volumes) == 0 {
+		return nil
+	}
+	poolName := "default"
+	if pool.Name != nil {
+		poolName = *pool.Name
+	}
+	volumes := &cloudinit.Volumes{
+		HetznerEndpoint: c.Config.HetznerEndpoint,
+		Labels: map[string]string{
+			"cluster": c.Config.ClusterName,
+			"role":    "worker",
+			"pool":    poolName,
+			"managed": "hek3ster",
+		},
+		CreateMissing: true,
+	}
+	for _, spec := range pool.Volumes {
+		volumes.Mounts = append(volumes.Mounts, cloudinit.VolumeMount{
+			Name:      spec.Name,
+			Size:      spec.Size,
+			Format:    spec.Format,
+			MountPath: spec.MountPath,
+		})
+	}
+	return volumes
+}
+
 // generateWorkerInstallScript generates the worker install script from template
 func (c *ClusterAutoscalerInstaller) generateWorkerInstallScript(masterIP string, pool config.WorkerNodePool, k3sToken string) (string, error) {
 	// Build node labels
@@ -401,3 +401,119 @@ func TestNATGatewayEnvironmentVariables(t *testing.T) {
 		})
 	}
 }
+
+// TestBuildClusterConfigPlacementGroups verifies that spread pools reference their placement group
+func TestBuildClusterConfigPlacementGroups(t *testing.T) {
+	cfg := &config.Main{
+		ClusterName: "test-cluster",
+		K3sVersion:  "v1.32.0+k3s1",
+		Image:       "ubuntu-24.04",
+		Networking:  config.Networking{SSH: config.SSH{Port: 22}},
+	}
+
+	spreadName, packedName := "spread", "packed"
+	pools := []config.WorkerNodePool{
+		{NodePool: config.NodePool{Name: &spreadName, InstanceType: "cpx32", IncludeClusterNameAsPrefix: true, PlacementGroup: "spread",
+			Autoscaling: &config.Autoscaling{Enabled: true, MinInstances: 1, MaxInstances: 3}}, Location: "nbg1"},
+		{NodePool: config.NodePool{Name: &packedName, InstanceType: "cpx32", IncludeClusterNameAsPrefix: true,
+			Autoscaling: &config.Autoscaling{Enabled: true, MinInstances: 1, MaxInstances: 3}}, Location: "nbg1"},
+	}
+
+	installer := NewClusterAutoscalerInstaller(cfg, nil)
+	firstMaster := &hcloud.Server{Name: "test-master-1"}
+	clusterConfig, err := installer.buildClusterConfig(firstMaster, []*hcloud.Server{firstMaster}, pools, "10.0.0.1", "token")
+	if err != nil {
+		t.Fatalf("buildClusterConfig() error = %v", err)
+	}
+
+	var parsed struct {
+		NodeConfigs map[string]struct {
+			PlacementGroup string `json:"placementGroup"`
+		} `json:"nodeConfigs"`
+	}
+	if err := json.Unmarshal([]byte(clusterConfig), &parsed); err != nil {
+		t.Fatalf("cluster config is not valid JSON: %v", err)
+	}
+
+	if got := parsed.NodeConfigs["test-cluster-spread"].PlacementGroup; got != "test-cluster-spread" {
+		t.Errorf("spread pool placementGroup = %q, want test-cluster-spread", got)
+	}
+	if got := parsed.NodeConfigs["test-cluster-packed"].PlacementGroup; got != "" {
+		t.Errorf("packed pool placementGroup = %q, want none", got)
+	}
+}
+
+// TestPoolVolumes verifies that nodes of autoscaled pools create their own volumes
+func TestPoolVolumes(t *testing.T) {
+	cfg := &config.Main{ClusterName: "test-cluster", HetznerEndpoint: "http://127.0.0.1:8080/v1"}
+	installer := NewClusterAutoscalerInstaller(cfg, nil)
+
+	dbName := "db"
+	pool := config.WorkerNodePool{NodePool: config.NodePool{Name: &dbName}}
+	if volumes := installer.poolVolumes(pool); volumes != nil {
+		t.Errorf("poolVolumes() without volumes = %+v, want nil", volumes)
+	}
+
+	pool.Volumes = []config.Volume{{Name: "data", Size: 50, Format: "ext4", MountPath: "/var/lib/data"}}
+	volumes := installer.poolVolumes(pool)
+	if volumes == nil || !volumes.CreateMissing || volumes.HetznerEndpoint != cfg.HetznerEndpoint {
+		t.Fatalf("poolVolumes() = %+v, want nodes to create missing volumes through the configured endpoint", volumes)
+	}
+	if volumes.Labels["cluster"] != "test-cluster" || volumes.Labels["pool"] != "db" {
+		t.Errorf("volume labels = %v, want the cluster and pool", volumes.Labels)
+	}
+	if len(volumes.Mounts) != 1 || volumes.Mounts[0].MountPath != "/var/lib/data" || volumes.Mounts[0].Size != 50 {
+		t.Errorf("volume mounts = %+v, want the data volume", volumes.Mounts)
+	}
+}
+
+// TestCloudInitForPoolNodeImage verifies that nodes booting a hek3ster node image skip
+// the root-level packages and pre-k3s commands baked into it
+func TestCloudInitForPoolNodeImage(t *testing.T) {
+	cfg := &config.Main{
+		ClusterName:              "test-cluster",
+		K3sVersion:               "v1.32.0+k3s1",
+		AdditionalPackages:       []string{"nfs-common"},
+		AdditionalPreK3sCommands: []string{"echo prepared > /etc/prepared"},
+		Networking:               config.Networking{SSH: config.SSH{Port: 22}},
+	}
+	poolName := "php"
+	pool := config.WorkerNodePool{NodePool: config.NodePool{
+		Name:                     &poolName,
+		AdditionalPackages:       []string{"php-cli"},
+		AdditionalPreK3sCommands: []string{"echo pool"},
+	}}
+	firstMaster := &hcloud.Server{Name: "test-master-1"}
+
+	tests := []struct {
+		name      string
+		nodeImage bool
+		wantBaked bool
+		wantInits int
+	}{
+		{name: "system image", wantBaked: true, wantInits: 3},
+		{name: "node image", nodeImage: true, wantInits: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installer := NewClusterAutoscalerInstaller(cfg, nil)
+			installer.NodeImage = tt.nodeImage
+
+			cloudInit, err := installer.generateCloudInitForPool(pool, firstMaster, []*hcloud.Server{firstMaster}, "10.0.0.1", "token")
+			if err != nil {
+				t.Fatalf("generateCloudInitForPool() error = %v", err)
+			}
+			if got := strings.Contains(cloudInit, "nfs-common"); got != tt.wantBaked {
+				t.Errorf("root-level package in cloud-init = %v, want %v", got, tt.wantBaked)
+			}
+			if !strings.Contains(cloudInit, "php-cli") {
+				t.Error("pool package missing from cloud-init")
+			}
+			// The pool's pre-k3s command and the install script always run
+			if got := strings.Count(cloudInit, "path: /etc/init-"); got != tt.wantInits {
+				t.Errorf("init scripts = %d, want %d", got, tt.wantInits)
+			}
+		})
+	}
+}
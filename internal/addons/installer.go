@@ -13,7 +13,9 @@ import (
 type Installer struct {
 	Config    *config.Main
 	SSHClient *util.SSH
-	ctx       context.Context
+	// AutoscalingNodeImage reports whether autoscaled nodes boot a node image built by hek3ster
+	AutoscalingNodeImage bool
+	ctx                  context.Context
 }
 
 // NewInstaller creates a new addon installer
@@ -129,6 +131,7 @@ func (i *Installer) installSystemUpgradeController(firstMaster *hcloud.Server, m
 // installClusterAutoscaler installs the cluster autoscaler
 func (i *Installer) installClusterAutoscaler(firstMaster *hcloud.Server, masters []*hcloud.Server, autoscalingPools []config.WorkerNodePool, masterSSHIP string, masterClusterIP string, k3sToken string) error {
 	installer := NewClusterAutoscalerInstaller(i.Config, i.SSHClient)
+	installer.NodeImage = i.AutoscalingNodeImage
 	if err := installer.Install(firstMaster, masters, autoscalingPools, masterSSHIP, masterClusterIP, k3sToken); err != nil {
 		return err
 	}
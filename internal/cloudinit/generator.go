@@ -5,7 +5,9 @@ import (
 	"compress/gzip"
 	_ "embed"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 )
@@ -18,6 +20,9 @@ var sshListenConfTemplate string
 //go:embed templates/ssh_configure.sh
 var sshConfigureScript string
 
+//go:embed templates/sshd_hardening.conf
+var sshdHardeningTemplate string
+
 //go:embed templates/cloud_init.yaml
 var cloudInitTemplate string
 
@@ -45,6 +50,31 @@ var k3sInstallWorkerTemplate string
 //go:embed templates/k3s/test_connectivity.sh
 var k3sTestConnectivityTemplate string
 
+//go:embed templates/floating_ip/failover.sh
+var floatingIPFailoverScript string
+
+//go:embed templates/floating_ip/failover.service
+var floatingIPFailoverService string
+
+//go:embed templates/floating_ip/install_failover.sh
+var floatingIPFailoverInstallTemplate string
+
+//go:embed templates/image/prepare_image.sh
+var prepareImageTemplate string
+
+//go:embed templates/volumes/mount_volumes.sh
+var mountVolumesScript string
+
+// mountVolumesPath is where the volume setup script is written on the node
+const mountVolumesPath = "/usr/local/bin/mount-volumes.sh"
+
+// sshdHardeningPath is the sshd drop-in that restricts logins to keys
+// The 00- prefix makes it win over drop-ins of the image, as sshd uses the first value it reads.
+const sshdHardeningPath = "/etc/ssh/sshd_config.d/00-hek3ster.conf"
+
+// sshUserCAPath is where the public key of the SSH user CA is written on the node
+const sshUserCAPath = "/etc/ssh/hek3ster_user_ca.pub"
+
 // Config holds configuration for cloud-init generation
 type Config struct {
 	SSHPort                   int
@@ -59,6 +89,44 @@ type Config struct {
 	ServiceCIDR               string
 	AllowedNetworksSSH        []string
 	AllowedNetworksAPI        []string
+	Volumes                   *Volumes // Volumes formatted and mounted at boot
+	SSHUser                   *SSHUser // Non-root login user, nil when hek3ster logs in as root
+	SSHUserCAKey              string   // Public key of the SSH user CA sshd trusts, empty to trust keys only
+}
+
+// SSHUser is the non-root user hek3ster logs in to the nodes as
+// The user is authorized with the cluster key and may run any command through sudo
+// without a password; root logins are refused.
+type SSHUser struct {
+	Name      string
+	PublicKey string // authorized_keys line of the cluster key, empty when certificates are trusted
+}
+
+// NewSSHUser returns the login user of the nodes, nil for root
+func NewSSHUser(name, publicKey string) *SSHUser {
+	if name == "" || name == "root" {
+		return nil
+	}
+	return &SSHUser{Name: name, PublicKey: publicKey}
+}
+
+// Volumes configures the volumes a node formats and mounts at boot
+// A node finds its volumes by name, <hostname>-<volume name>, through the Hetzner
+// Cloud API. With CreateMissing set, a node without volumes claims a detached
+// volume carrying Labels or creates a new one.
+type Volumes struct {
+	HetznerEndpoint string
+	Labels          map[string]string
+	CreateMissing   bool
+	Mounts          []VolumeMount
+}
+
+// VolumeMount describes a single volume of a node
+type VolumeMount struct {
+	Name      string
+	Size      int // Size in GB, used when the node creates the volume
+	Format    string
+	MountPath string
 }
 
 // Generator generates cloud-init YAML
@@ -87,6 +155,18 @@ func (g *Generator) Generate() (string, error) {
 		return "", fmt.Errorf("failed to generate firewall files: %w", err)
 	}
 
+	// Generate volume files section
+	volumeFiles, err := g.generateVolumeFiles()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate volume files: %w", err)
+	}
+
+	// Generate users section
+	usersStr, err := generateUsersStr(g.config.SSHUser)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate users: %w", err)
+	}
+
 	// Generate packages string
 	packagesStr := g.generatePackagesStr()
 
@@ -103,10 +183,12 @@ func (g *Generator) Generate() (string, error) {
 	}
 
 	data := map[string]interface{}{
+		"users_str":                usersStr,
 		"growpart_str":             "",
 		"growroot_disabled_file":   "",
 		"eth1_str":                 "",
 		"firewall_files":           firewallFiles,
+		"volume_files":             volumeFiles,
 		"ssh_files":                sshFiles,
 		"init_files":               initFiles,
 		"packages_str":             packagesStr,
@@ -135,9 +217,16 @@ func (g *Generator) generateSSHFiles() (string, error) {
 		return "", fmt.Errorf("failed to render configure_ssh.sh: %w", err)
 	}
 
-	// Encode both files
+	// Generate sshd hardening drop-in content
+	hardeningContent, err := renderSSHDHardening(g.config.SSHUser, g.config.SSHUserCAKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to render sshd hardening: %w", err)
+	}
+
+	// Encode the files
 	listenConfEncoded := g.encodeAndFormat(listenConfContent)
 	configureScriptEncoded := g.encodeAndFormat(configureScriptContent)
+	hardeningEncoded := g.encodeAndFormat(hardeningContent)
 
 	// Build the YAML section
 	sshFiles := fmt.Sprintf(`- content: %s
@@ -146,7 +235,17 @@ func (g *Generator) generateSSHFiles() (string, error) {
 - content: %s
   permissions: '0755'
   path: /etc/configure_ssh.sh
-  encoding: gzip+base64`, listenConfEncoded, configureScriptEncoded)
+  encoding: gzip+base64
+- content: %s
+  path: %s
+  encoding: gzip+base64`, listenConfEncoded, configureScriptEncoded, hardeningEncoded, sshdHardeningPath)
+
+	if g.config.SSHUserCAKey != "" {
+		sshFiles += fmt.Sprintf(`
+- content: %s
+  path: %s
+  encoding: gzip+base64`, g.encodeAndFormat(g.config.SSHUserCAKey+"\n"), sshUserCAPath)
+	}
 
 	return sshFiles, nil
 }
@@ -185,6 +284,80 @@ func (g *Generator) renderSSHConfigureScript() (string, error) {
 	return buf.String(), nil
 }
 
+// renderSSHDHardening renders the sshd drop-in that restricts logins to keys
+// With a user CA key, sshd also trusts certificates signed by the CA.
+func renderSSHDHardening(user *SSHUser, userCAKey string) (string, error) {
+	tmpl, err := template.New("sshd_hardening.conf").Parse(sshdHardeningTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}{
+		"non_root_user": user != nil,
+		"user_ca_path":  userCAPath(userCAKey),
+	}); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// WithHostKey adds the SSH host key of a single server to its cloud-init configuration
+// cloud-init deletes the host keys of the image and installs the ed25519 key instead of
+// generating new ones, so the node presents the key hek3ster pinned for it.
+func WithHostKey(cloudInit, privateKey, publicKey string) string {
+	return fmt.Sprintf(`%s
+ssh_deletekeys: true
+ssh_keys:
+  ed25519_private: |
+%s
+  ed25519_public: %s
+`, strings.TrimRight(cloudInit, "\n"), indent(strings.TrimSpace(privateKey), "    "), strings.TrimSpace(publicKey))
+}
+
+// userCAPath returns the path sshd reads the user CA key from, empty without a user CA
+func userCAPath(userCAKey string) string {
+	if userCAKey == "" {
+		return ""
+	}
+	return sshUserCAPath
+}
+
+// indent prefixes every line of text, e.g. to embed a file in a YAML block scalar
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
+
+// generateUsersStr generates the users section creating the non-root login user
+func generateUsersStr(user *SSHUser) (string, error) {
+	if user == nil {
+		return "", nil
+	}
+	if user.PublicKey == "" {
+		// Certificates signed by the user CA log in; no key is authorized
+		return fmt.Sprintf(`users:
+  - name: %s
+    shell: /bin/bash
+    sudo: "ALL=(ALL) NOPASSWD:ALL"
+    lock_passwd: true`, user.Name), nil
+	}
+
+	// JSON strings are valid YAML scalars, so key comments cannot break the document
+	publicKey, err := json.Marshal(user.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`users:
+  - name: %s
+    shell: /bin/bash
+    sudo: "ALL=(ALL) NOPASSWD:ALL"
+    lock_passwd: true
+    ssh_authorized_keys:
+      - %s`, user.Name, publicKey), nil
+}
+
 // encodeAndFormat encodes content with gzip+base64 and formats it for YAML
 func (g *Generator) encodeAndFormat(content string) string {
 	var buf bytes.Buffer
@@ -204,7 +377,8 @@ func (g *Generator) encodeAndFormat(content string) string {
 // generatePackagesStr generates the packages string for cloud-init
 func (g *Generator) generatePackagesStr() string {
 	basePackages := []string{"fail2ban", "wireguard"}
-	allPackages := append(basePackages, g.config.Packages...)
+	allPackages := append(basePackages, g.volumePackages()...)
+	allPackages = append(allPackages, g.config.Packages...)
 
 	// Format as quoted strings separated by commas
 	quotedPackages := make([]string, len(allPackages))
@@ -231,6 +405,11 @@ func (g *Generator) generatePostCreateCommandsStr() string {
 	}
 	allCommands = append(allCommands, mandatoryCommands...)
 
+	// Mount volumes before anything else uses their mount paths
+	if g.hasVolumes() {
+		allCommands = append(allCommands, mountVolumesPath)
+	}
+
 	// Add firewall setup commands if local firewall is enabled
 	if g.config.UseLocalFirewall {
 		allCommands = append(allCommands,
@@ -341,6 +520,80 @@ func (g *Generator) generateFirewallFiles() (string, error) {
 	return strings.Join(files, "\n"), nil
 }
 
+// hasVolumes reports whether the node mounts volumes
+func (g *Generator) hasVolumes() bool {
+	return g.config.Volumes != nil && len(g.config.Volumes.Mounts) > 0
+}
+
+// volumePackages returns the packages the volume setup script needs
+func (g *Generator) volumePackages() []string {
+	if !g.hasVolumes() {
+		return nil
+	}
+	packages := []string{"jq"}
+	for _, mount := range g.config.Volumes.Mounts {
+		if mount.Format == "xfs" {
+			return append(packages, "xfsprogs")
+		}
+	}
+	return packages
+}
+
+// generateVolumeFiles generates the volume setup script file section
+func (g *Generator) generateVolumeFiles() (string, error) {
+	if !g.hasVolumes() {
+		return "", nil
+	}
+
+	script, err := g.renderMountVolumesScript()
+	if err != nil {
+		return "", fmt.Errorf("failed to render volume setup script: %w", err)
+	}
+
+	// The script holds the Hetzner token, so only root may read it
+	return fmt.Sprintf("- path: %s\n  permissions: '0700'\n  content: %s\n  encoding: gzip+base64",
+		mountVolumesPath, g.encodeAndFormat(script)), nil
+}
+
+// renderMountVolumesScript renders the volume setup script template
+func (g *Generator) renderMountVolumesScript() (string, error) {
+	tmpl, err := template.New("mount_volumes.sh").Parse(mountVolumesScript)
+	if err != nil {
+		return "", err
+	}
+
+	volumes := g.config.Volumes
+	labelsJSON, err := json.Marshal(volumes.Labels)
+	if err != nil {
+		return "", err
+	}
+	selector := make([]string, 0, len(volumes.Labels))
+	for key, value := range volumes.Labels {
+		selector = append(selector, key+"="+value)
+	}
+	sort.Strings(selector)
+
+	endpoint := volumes.HetznerEndpoint
+	if endpoint == "" {
+		endpoint = "https://api.hetzner.cloud/v1"
+	}
+
+	var buf bytes.Buffer
+	data := map[string]interface{}{
+		"hetzner_token":    g.config.HetznerToken,
+		"hetzner_endpoint": strings.TrimSuffix(endpoint, "/"),
+		"labels_json":      string(labelsJSON),
+		"label_selector":   strings.Join(selector, ","),
+		"create_missing":   volumes.CreateMissing,
+		"volumes":          volumes.Mounts,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
 // renderFirewallScript renders the firewall script template with configuration
 func (g *Generator) renderFirewallScript() (string, error) {
 	tmpl, err := template.New("firewall.sh").Parse(firewallScript)
@@ -382,15 +635,31 @@ func (g *Generator) renderFirewallStatusScript() (string, error) {
 }
 
 // GenerateNATGatewayCloudInit generates cloud-init configuration for NAT gateway
-func GenerateNATGatewayCloudInit(subnet string) (string, error) {
+// The gateway is the SSH bastion of the nodes, so it gets the same login user, sshd hardening
+// and user CA.
+func GenerateNATGatewayCloudInit(subnet string, sshUser *SSHUser, userCAKey string) (string, error) {
 	tmpl, err := template.New("nat_gateway_cloud_init.yaml").Parse(natGatewayCloudInitTemplate)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse NAT gateway cloud-init template: %w", err)
 	}
 
+	users, err := generateUsersStr(sshUser)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate NAT gateway users: %w", err)
+	}
+	hardening, err := renderSSHDHardening(sshUser, userCAKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to render NAT gateway sshd hardening: %w", err)
+	}
+
 	var buf bytes.Buffer
 	data := map[string]interface{}{
-		"Subnet": subnet,
+		"Subnet":           subnet,
+		"Users":            users,
+		"SSHHardeningPath": sshdHardeningPath,
+		"SSHHardening":     indent(strings.TrimSpace(hardening), "      "),
+		"UserCAPath":       userCAPath(userCAKey),
+		"UserCAKey":        userCAKey,
 	}
 	if err := tmpl.Execute(&buf, data); err != nil {
 		return "", fmt.Errorf("failed to execute NAT gateway cloud-init template: %w", err)
@@ -474,3 +743,76 @@ func GenerateInternetConnectivityTestCommand() (string, error) {
 
 	return strings.TrimSpace(buf.String()), nil
 }
+
+// FloatingIPFailover holds the settings of the floating IP failover agent of a master
+type FloatingIPFailover struct {
+	HetznerToken    string
+	HetznerEndpoint string
+	FloatingIPID    int64
+	FloatingIP      string
+	Priority        int // 0 claims the IP first, higher values wait longer
+	CheckInterval   int // Seconds between health checks
+}
+
+// GenerateFloatingIPFailoverInstallCommand generates the command installing the floating IP failover agent on a master
+func GenerateFloatingIPFailoverInstallCommand(failover FloatingIPFailover) (string, error) {
+	scriptTmpl, err := template.New("failover.sh").Parse(floatingIPFailoverScript)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse floating IP failover template: %w", err)
+	}
+
+	endpoint := failover.HetznerEndpoint
+	if endpoint == "" {
+		endpoint = "https://api.hetzner.cloud/v1"
+	}
+
+	var script bytes.Buffer
+	data := map[string]interface{}{
+		"hetzner_token":    failover.HetznerToken,
+		"hetzner_endpoint": strings.TrimSuffix(endpoint, "/"),
+		"floating_ip_id":   failover.FloatingIPID,
+		"floating_ip":      failover.FloatingIP,
+		"priority":         failover.Priority,
+		"check_interval":   failover.CheckInterval,
+	}
+	if err := scriptTmpl.Execute(&script, data); err != nil {
+		return "", fmt.Errorf("failed to execute floating IP failover template: %w", err)
+	}
+
+	installTmpl, err := template.New("install_failover.sh").Parse(floatingIPFailoverInstallTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse floating IP failover install template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := installTmpl.Execute(&buf, map[string]interface{}{
+		"Script":  base64.StdEncoding.EncodeToString(script.Bytes()),
+		"Service": base64.StdEncoding.EncodeToString([]byte(floatingIPFailoverService)),
+	}); err != nil {
+		return "", fmt.Errorf("failed to execute floating IP failover install template: %w", err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// ImagePreparation holds the settings of a server that is prepared for a node image
+type ImagePreparation struct {
+	K3sVersion   string
+	K3sBinary    bool // Download the k3s binary of K3sVersion
+	AirgapImages bool // Download the airgap images of K3sVersion
+}
+
+// GenerateImagePrepareCommand generates the command preparing a server to be snapshotted as a node image
+func GenerateImagePrepareCommand(preparation ImagePreparation) (string, error) {
+	tmpl, err := template.New("prepare_image.sh").Parse(prepareImageTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image preparation template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, preparation); err != nil {
+		return "", fmt.Errorf("failed to execute image preparation template: %w", err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,145 @@
+#!/bin/bash
+set -uo pipefail
+
+# =============================================================================
+# Floating IP failover agent for hek3ster
+# Keeps the Kubernetes API floating IP on a healthy master, like keepalived, but
+# moves the IP through the Hetzner Cloud API instead of VRRP
+# =============================================================================
+
+# Configuration (injected via Go text/template)
+readonly HETZNER_TOKEN="{{ .hetzner_token }}"
+readonly HETZNER_API="{{ .hetzner_endpoint }}"
+readonly FLOATING_IP_ID="{{ .floating_ip_id }}"
+readonly FLOATING_IP="{{ .floating_ip }}"
+readonly PRIORITY="{{ .priority }}"
+readonly CHECK_INTERVAL="{{ .check_interval }}"
+
+# Constants
+readonly API_PORT=6443
+readonly API_TIMEOUT=10
+readonly FAILURE_THRESHOLD=3
+readonly METADATA_URL="http://169.254.169.254/hetzner/v1/metadata"
+
+# =============================================================================
+# Utility Functions
+# =============================================================================
+
+log() {
+    echo "$*"
+}
+
+hetzner_api() {
+    local method=$1 path=$2 body=${3:-}
+    local args=(-sf -m "$API_TIMEOUT" -X "$method" -H "Authorization: Bearer $HETZNER_TOKEN")
+    if [ -n "$body" ]; then
+        args+=(-H "Content-Type: application/json" -d "$body")
+    fi
+    curl "${args[@]}" "$HETZNER_API$path"
+}
+
+# =============================================================================
+# Floating IP
+# =============================================================================
+
+# configure_address adds the floating IP to the public interface, so the master
+# accepts traffic for it as soon as the IP is routed to it
+configure_address() {
+    local iface
+    iface=$(ip -4 route show default | awk '{print $5; exit}')
+    if ! ip -4 addr show dev "$iface" | grep -q " $FLOATING_IP/32 "; then
+        ip addr add "$FLOATING_IP/32" dev "$iface"
+        log "Added $FLOATING_IP to $iface"
+    fi
+}
+
+# holder prints the ID of the server the floating IP is assigned to, if any
+holder() {
+    local response
+    response=$(hetzner_api GET "/floating_ips/$FLOATING_IP_ID") || return 1
+    echo "$response" | jq -r '.floating_ip.server // empty'
+}
+
+assign() {
+    hetzner_api POST "/floating_ips/$FLOATING_IP_ID/actions/assign" "{\"server\": $SERVER_ID}" >/dev/null
+}
+
+unassign() {
+    hetzner_api POST "/floating_ips/$FLOATING_IP_ID/actions/unassign" >/dev/null
+}
+
+# =============================================================================
+# Health Checks
+# =============================================================================
+
+# local_healthy checks the Kubernetes API of this master
+local_healthy() {
+    timeout "$API_TIMEOUT" k3s kubectl get --raw=/readyz >/dev/null 2>&1
+}
+
+# peer_healthy checks that k3s answers on another master
+peer_healthy() {
+    local server_id=$1 response ip
+    response=$(hetzner_api GET "/servers/$server_id") || return 1
+    ip=$(echo "$response" | jq -r '.server.private_net[0].ip // .server.public_net.ipv4.ip // empty')
+    [ -n "$ip" ] && curl -sk -m "$API_TIMEOUT" "https://$ip:$API_PORT/ping" | grep -q pong
+}
+
+# =============================================================================
+# Main
+# =============================================================================
+
+main() {
+    SERVER_ID=$(curl -sf -m "$API_TIMEOUT" "$METADATA_URL/instance-id") || true
+    if [ -z "$SERVER_ID" ]; then
+        log "Failed to read the server ID from the metadata service"
+        exit 1
+    fi
+    configure_address
+
+    local current failures=0 local_failures=0
+    while true; do
+        sleep "$CHECK_INTERVAL"
+
+        if local_healthy; then
+            local_failures=0
+        else
+            local_failures=$((local_failures + 1))
+        fi
+
+        if ! current=$(holder); then
+            log "Failed to query floating IP $FLOATING_IP"
+            continue
+        fi
+
+        # The holder gives the IP up when its own API stays unhealthy
+        if [ "$current" = "$SERVER_ID" ]; then
+            failures=0
+            if [ "$local_failures" -ge "$FAILURE_THRESHOLD" ]; then
+                log "Kubernetes API is unhealthy, releasing $FLOATING_IP"
+                unassign || log "Failed to release $FLOATING_IP"
+            fi
+            continue
+        fi
+
+        # Only a healthy master takes over from a missing or unhealthy holder
+        if [ "$local_failures" -gt 0 ] || { [ -n "$current" ] && peer_healthy "$current"; }; then
+            failures=0
+            continue
+        fi
+
+        # Masters with a lower priority wait longer, so a single master claims the IP
+        failures=$((failures + 1))
+        if [ "$failures" -ge $((FAILURE_THRESHOLD + PRIORITY)) ]; then
+            log "Claiming $FLOATING_IP from ${current:-no server}"
+            if assign; then
+                log "Claimed $FLOATING_IP"
+            else
+                log "Failed to claim $FLOATING_IP"
+            fi
+            failures=0
+        fi
+    done
+}
+
+main "$@"
@@ -0,0 +1,12 @@
+#!/bin/bash
+# Installs the floating IP failover agent on a master
+# The agent script holds the Hetzner token, so it is only readable by root
+
+set -e
+command -v jq >/dev/null 2>&1 || (apt-get update -qq && apt-get install -y -qq jq)
+echo '{{ .Script }}' | base64 -d > /usr/local/bin/floating-ip-failover.sh
+chmod 0700 /usr/local/bin/floating-ip-failover.sh
+echo '{{ .Service }}' | base64 -d > /etc/systemd/system/floating-ip-failover.service
+systemctl daemon-reload
+systemctl enable floating-ip-failover.service
+systemctl restart floating-ip-failover.service
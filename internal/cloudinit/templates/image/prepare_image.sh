@@ -0,0 +1,27 @@
+#!/bin/bash
+# Prepares a server before it is snapshotted as a node image
+# The k3s installer skips its download when the installed binary matches the release,
+# and k3s imports the airgap images from the agent images directory on first start.
+
+set -e
+{{- if or .K3sBinary .AirgapImages }}
+RELEASE_URL="https://github.com/k3s-io/k3s/releases/download/$(echo '{{ .K3sVersion }}' | sed 's/+/%2B/g')"
+case "$(uname -m)" in
+  aarch64|arm64) ARCH=arm64; BINARY=k3s-arm64 ;;
+  *) ARCH=amd64; BINARY=k3s ;;
+esac
+{{- end }}
+{{- if .K3sBinary }}
+curl -sfL --retry 3 -o /usr/local/bin/k3s "$RELEASE_URL/$BINARY"
+chmod 0755 /usr/local/bin/k3s
+{{- end }}
+{{- if .AirgapImages }}
+mkdir -p /var/lib/rancher/k3s/agent/images
+curl -sfL --retry 3 -o "/var/lib/rancher/k3s/agent/images/k3s-airgap-images-$ARCH.tar.zst" "$RELEASE_URL/k3s-airgap-images-$ARCH.tar.zst"
+{{- end }}
+
+# Reset the instance state so cloud-init runs again on servers created from the image
+apt-get clean
+cloud-init clean --logs --machine-id 2>/dev/null || cloud-init clean --logs
+rm -f /etc/ssh/ssh_host_*
+sync
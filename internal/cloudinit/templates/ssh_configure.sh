@@ -10,4 +10,10 @@ else
   # OpenSSH is not using socket activation
   sed -i 's/^#*Port .*/Port {{ .ssh_port }}/' /etc/ssh/sshd_config
 fi
+
+# Make sure sshd reads the drop-in with the login hardening
+if ! grep -q '^Include /etc/ssh/sshd_config.d/\*.conf' /etc/ssh/sshd_config
+then
+  sed -i '1i Include /etc/ssh/sshd_config.d/*.conf' /etc/ssh/sshd_config
+fi
 systemctl restart ssh
@@ -0,0 +1,160 @@
+#!/bin/bash
+set -euo pipefail
+
+# =============================================================================
+# Volume setup for hek3ster worker nodes
+# Finds the volumes of this node through the Hetzner Cloud API, formats them on
+# first use and mounts them. Nodes hek3ster does not create itself, like those of
+# autoscaled pools, claim a detached volume of their pool or create a new one.
+# =============================================================================
+
+# Configuration (injected via Go text/template)
+readonly HETZNER_TOKEN="{{ .hetzner_token }}"
+readonly HETZNER_API="{{ .hetzner_endpoint }}"
+readonly LABELS='{{ .labels_json }}'
+readonly LABEL_SELECTOR="{{ .label_selector }}"
+readonly CREATE_MISSING="{{ .create_missing }}"
+
+# Constants
+readonly API_TIMEOUT=30
+readonly METADATA_URL="http://169.254.169.254/hetzner/v1/metadata"
+
+SERVER_ID=$(curl -sf "$METADATA_URL/instance-id")
+NODE_NAME=$(curl -sf "$METADATA_URL/hostname")
+LOCATION=$(curl -sf "$METADATA_URL/availability-zone" | sed 's/-dc[0-9]*$//')
+readonly SERVER_ID NODE_NAME LOCATION
+
+# =============================================================================
+# Utility Functions
+# =============================================================================
+
+log() {
+    echo "$*"
+}
+
+hetzner_api() {
+    local method=$1 path=$2 body=${3:-}
+    local args=(-sf -m "$API_TIMEOUT" -X "$method" -H "Authorization: Bearer $HETZNER_TOKEN")
+    if [ -n "$body" ]; then
+        args+=(-H "Content-Type: application/json" -d "$body")
+    fi
+    curl "${args[@]}" "$HETZNER_API$path"
+}
+
+hetzner_list() {
+    local path=$1 param=$2
+    curl -sf -m "$API_TIMEOUT" -G -H "Authorization: Bearer $HETZNER_TOKEN" --data-urlencode "$param" "$HETZNER_API$path"
+}
+
+# wait_for_action waits until a Hetzner Cloud action has finished
+wait_for_action() {
+    local id=$1 status
+    for _ in $(seq 1 60); do
+        status=$(hetzner_api GET "/actions/$id" | jq -r '.action.status')
+        case "$status" in
+            success) return 0 ;;
+            error) return 1 ;;
+        esac
+        sleep 2
+    done
+    return 1
+}
+
+# =============================================================================
+# Volumes
+# =============================================================================
+
+# attach attaches a detached volume to this server
+attach() {
+    local id=$1 action
+    action=$(hetzner_api POST "/volumes/$id/actions/attach" "{\"server\": $SERVER_ID, \"automount\": false}" | jq -r '.action.id') || return 1
+    wait_for_action "$action"
+}
+
+# claim attaches a detached volume of the pool in this location, printing its ID
+claim() {
+    local volume=$1 id
+    for id in $(hetzner_list /volumes "label_selector=$LABEL_SELECTOR,volume=$volume" |
+        jq -r --arg location "$LOCATION" '.volumes[] | select(.server == null and .location.name == $location) | .id'); do
+        # Another node may claim the same volume at the same time
+        if attach "$id"; then
+            echo "$id"
+            return 0
+        fi
+    done
+    return 1
+}
+
+# create creates a volume attached to this server, printing its ID
+create() {
+    local volume=$1 size=$2 name=$3 labels body response
+    labels=$(echo "$LABELS" | jq -c --arg volume "$volume" '. + {volume: $volume}')
+    body=$(jq -nc --arg name "$name" --argjson size "$size" --argjson server "$SERVER_ID" --argjson labels "$labels" \
+        '{name: $name, size: $size, server: $server, labels: $labels, automount: false}')
+    response=$(hetzner_api POST /volumes "$body")
+    wait_for_action "$(echo "$response" | jq -r '.action.id')"
+    echo "$response" | jq -r '.volume.id'
+}
+
+# volume_id prints the ID of the volume attached to this server, attaching or
+# creating it first if needed
+volume_id() {
+    local volume=$1 size=$2 name="$NODE_NAME-$1" found id server
+    found=$(hetzner_list /volumes "name=$name" | jq -r '.volumes[0] // empty | "\(.id) \(.server // 0)"')
+    if [ -n "$found" ]; then
+        read -r id server <<< "$found"
+        if [ "$server" = "$SERVER_ID" ]; then
+            echo "$id"
+            return 0
+        fi
+        if [ "$server" != "0" ]; then
+            log "Volume $name is attached to server $server" >&2
+            return 1
+        fi
+        attach "$id" && echo "$id"
+        return
+    fi
+
+    if [ "$CREATE_MISSING" != "true" ]; then
+        log "Volume $name not found" >&2
+        return 1
+    fi
+    claim "$volume" || create "$volume" "$size" "$name"
+}
+
+# mount_volume formats a volume if it has no file system yet and mounts it
+mount_volume() {
+    local id=$1 format=$2 path=$3
+    local device="/dev/disk/by-id/scsi-0HC_Volume_$id"
+
+    for _ in $(seq 1 30); do
+        [ -e "$device" ] && break
+        sleep 2
+    done
+    if [ ! -e "$device" ]; then
+        log "Device $device of volume $id not found" >&2
+        return 1
+    fi
+
+    if ! blkid "$device" > /dev/null 2>&1; then
+        log "Formatting volume $id with $format"
+        "mkfs.$format" "$device"
+    fi
+
+    mkdir -p "$path"
+    if ! grep -q "^$device " /etc/fstab; then
+        echo "$device $path $format discard,nofail,defaults 0 0" >> /etc/fstab
+    fi
+    if ! mountpoint -q "$path"; then
+        mount "$path"
+    fi
+    log "Mounted volume $id at $path"
+}
+
+# =============================================================================
+# Main
+# =============================================================================
+{{ range .volumes }}
+id=$(volume_id "{{ .Name }}" "{{ .Size }}")
+mount_volume "$id" "{{ .Format }}" "{{ .MountPath }}"
+{{ end }}
@@ -1,13 +1,16 @@
 package cloudinit
 
 import (
+	"encoding/base64"
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestGenerateNATGatewayCloudInit(t *testing.T) {
 	subnet := "10.0.0.0/16"
-	cloudInit, err := GenerateNATGatewayCloudInit(subnet)
+	cloudInit, err := GenerateNATGatewayCloudInit(subnet, nil, "")
 	if err != nil {
 		t.Fatalf("Failed to generate NAT gateway cloud-init: %v", err)
 	}
@@ -28,6 +31,76 @@ func TestGenerateNATGatewayCloudInit(t *testing.T) {
 	}
 }
 
+func TestGenerateNATGatewayCloudInit_SSHUser(t *testing.T) {
+	cloudInit, err := GenerateNATGatewayCloudInit("10.0.0.0/16", &SSHUser{Name: "ops", PublicKey: "ssh-ed25519 AAAAC3 admin #1"}, "")
+	if err != nil {
+		t.Fatalf("Failed to generate NAT gateway cloud-init: %v", err)
+	}
+
+	var parsed struct {
+		Users []struct {
+			Name              string   `yaml:"name"`
+			Sudo              string   `yaml:"sudo"`
+			SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys"`
+		} `yaml:"users"`
+		WriteFiles []struct {
+			Path    string `yaml:"path"`
+			Content string `yaml:"content"`
+		} `yaml:"write_files"`
+	}
+	if err := yaml.Unmarshal([]byte(cloudInit), &parsed); err != nil {
+		t.Fatalf("NAT gateway cloud-init is not valid YAML: %v\n%s", err, cloudInit)
+	}
+
+	if len(parsed.Users) != 1 || parsed.Users[0].Name != "ops" || parsed.Users[0].Sudo != "ALL=(ALL) NOPASSWD:ALL" {
+		t.Fatalf("unexpected users: %+v", parsed.Users)
+	}
+	if keys := parsed.Users[0].SSHAuthorizedKeys; len(keys) != 1 || keys[0] != "ssh-ed25519 AAAAC3 admin #1" {
+		t.Errorf("unexpected authorized keys: %v", keys)
+	}
+
+	var hardening string
+	for _, file := range parsed.WriteFiles {
+		if file.Path == sshdHardeningPath {
+			hardening = file.Content
+		}
+	}
+	for _, expected := range []string{"PermitRootLogin no", "PasswordAuthentication no"} {
+		if !strings.Contains(hardening, expected) {
+			t.Errorf("sshd hardening doesn't contain %q:\n%s", expected, hardening)
+		}
+	}
+}
+
+func TestGenerateNATGatewayCloudInit_UserCA(t *testing.T) {
+	caKey := "ssh-ed25519 AAAAC3 user-ca"
+	cloudInit, err := GenerateNATGatewayCloudInit("10.0.0.0/16", nil, caKey)
+	if err != nil {
+		t.Fatalf("Failed to generate NAT gateway cloud-init: %v", err)
+	}
+
+	var parsed struct {
+		WriteFiles []struct {
+			Path    string `yaml:"path"`
+			Content string `yaml:"content"`
+		} `yaml:"write_files"`
+	}
+	if err := yaml.Unmarshal([]byte(cloudInit), &parsed); err != nil {
+		t.Fatalf("NAT gateway cloud-init is not valid YAML: %v\n%s", err, cloudInit)
+	}
+
+	files := make(map[string]string)
+	for _, file := range parsed.WriteFiles {
+		files[file.Path] = file.Content
+	}
+	if got := strings.TrimSpace(files[sshUserCAPath]); got != caKey {
+		t.Errorf("expected user CA key %q, got %q", caKey, got)
+	}
+	if !strings.Contains(files[sshdHardeningPath], "TrustedUserCAKeys "+sshUserCAPath) {
+		t.Errorf("sshd hardening doesn't trust the user CA:\n%s", files[sshdHardeningPath])
+	}
+}
+
 func TestGenerateK3sInstallFirstMasterCommand(t *testing.T) {
 	k3sVersion := "v1.28.5+k3s1"
 	k3sToken := "test-token-123"
@@ -148,3 +221,92 @@ func TestGenerateInternetConnectivityTestCommand(t *testing.T) {
 		t.Error("Expected command to echo 'connected' on success")
 	}
 }
+
+func TestGenerateFloatingIPFailoverInstallCommand(t *testing.T) {
+	cmd, err := GenerateFloatingIPFailoverInstallCommand(FloatingIPFailover{
+		HetznerToken:  "test-token-123",
+		FloatingIPID:  42,
+		FloatingIP:    "198.51.100.1",
+		Priority:      2,
+		CheckInterval: 5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate floating IP failover install command: %v", err)
+	}
+
+	if !strings.Contains(cmd, "systemctl enable floating-ip-failover.service") {
+		t.Error("Expected command to enable the failover service")
+	}
+
+	// The agent script is embedded base64 encoded
+	start := strings.Index(cmd, "echo '") + len("echo '")
+	end := strings.Index(cmd[start:], "'") + start
+	script, err := base64.StdEncoding.DecodeString(cmd[start:end])
+	if err != nil {
+		t.Fatalf("Failed to decode the failover script: %v", err)
+	}
+
+	for _, want := range []string{
+		`HETZNER_TOKEN="test-token-123"`,
+		`HETZNER_API="https://api.hetzner.cloud/v1"`,
+		`FLOATING_IP_ID="42"`,
+		`FLOATING_IP="198.51.100.1"`,
+		`PRIORITY="2"`,
+		`CHECK_INTERVAL="5"`,
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("Expected failover script to contain %s", want)
+		}
+	}
+}
+
+func TestGenerateImagePrepareCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		preparation ImagePreparation
+		want        []string
+		notWant     []string
+	}{
+		{
+			name:        "packages only",
+			preparation: ImagePreparation{K3sVersion: "v1.32.0+k3s1"},
+			want:        []string{"cloud-init clean --logs", "rm -f /etc/ssh/ssh_host_*"},
+			notWant:     []string{"RELEASE_URL", "/usr/local/bin/k3s", "k3s-airgap-images"},
+		},
+		{
+			name:        "k3s binary and airgap images",
+			preparation: ImagePreparation{K3sVersion: "v1.32.0+k3s1", K3sBinary: true, AirgapImages: true},
+			want: []string{
+				"echo 'v1.32.0+k3s1' | sed 's/+/%2B/g'",
+				`-o /usr/local/bin/k3s "$RELEASE_URL/$BINARY"`,
+				"/var/lib/rancher/k3s/agent/images/k3s-airgap-images-$ARCH.tar.zst",
+				"cloud-init clean --logs",
+			},
+		},
+		{
+			name:        "airgap images only",
+			preparation: ImagePreparation{K3sVersion: "v1.32.0+k3s1", AirgapImages: true},
+			want:        []string{"RELEASE_URL=", "k3s-airgap-images-$ARCH.tar.zst"},
+			notWant:     []string{"/usr/local/bin/k3s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := GenerateImagePrepareCommand(tt.preparation)
+			if err != nil {
+				t.Fatalf("Failed to generate image preparation command: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(cmd, want) {
+					t.Errorf("Expected command to contain %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(cmd, notWant) {
+					t.Errorf("Expected command not to contain %q", notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,821 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+const (
+	// managedLabelsAnnotation records the node label keys applied from the configuration
+	// so that labels removed from the configuration can be removed from the node
+	managedLabelsAnnotation = "hek3ster/managed-labels"
+	// managedTaintsAnnotation records the node taints (key:effect) applied from the configuration
+	managedTaintsAnnotation = "hek3ster/managed-taints"
+	// k3sServerTokenReadCmd is the command to read the cluster join token from a master
+	k3sServerTokenReadCmd = "sudo cat /var/lib/rancher/k3s/server/token"
+	// nodeDrainTimeout is the maximum time to wait for a node to drain before deletion
+	nodeDrainTimeout = 5 * time.Minute
+)
+
+// Applier reconciles the static worker pools of a running cluster with the configuration
+type Applier struct {
+	Config        *config.Main
+	HetznerClient hetzner.API
+	SSHClient     *util.SSH
+	AutoApprove   bool
+	PlanOnly      bool
+	ctx           context.Context
+}
+
+// plannedWorker is a worker server that apply will create
+type plannedWorker struct {
+	Name string
+	Pool config.WorkerNodePool
+	// Replaces is the server of another instance type deleted before the worker is created
+	Replaces *hcloud.Server
+}
+
+// nodeUpdate describes the label and taint changes for an existing node
+type nodeUpdate struct {
+	Node          string
+	SetLabels     map[string]string
+	RemoveLabels  []string
+	SetTaints     []config.Taint
+	RemoveTaints  []config.Taint
+	ManagedLabels string
+	ManagedTaints string
+	Annotate      bool
+}
+
+// hasChanges reports whether the update changes labels or taints
+func (u nodeUpdate) hasChanges() bool {
+	return len(u.SetLabels) > 0 || len(u.RemoveLabels) > 0 || len(u.SetTaints) > 0 || len(u.RemoveTaints) > 0
+}
+
+// applyPlan is the set of operations needed to converge the worker pools
+type applyPlan struct {
+	Create   []plannedWorker
+	Delete   []*hcloud.Server
+	Replace  []plannedWorker
+	Updates  []nodeUpdate
+	Changes  []PlanChange
+	Warnings []string
+	// DeleteVolumes and DeletePrimaryIPs hold, by server name, the resources of
+	// deleted workers that their pool does not keep
+	DeleteVolumes    map[string][]*hcloud.Volume
+	DeletePrimaryIPs map[string]*hcloud.PrimaryIP
+}
+
+// hasChanges reports whether the plan adds, removes or modifies anything
+func (p applyPlan) hasChanges() bool {
+	for _, change := range p.Changes {
+		if change.Action != PlanActionReuse {
+			return true
+		}
+	}
+	return false
+}
+
+// NewApplier creates a new worker pool reconciler
+func NewApplier(cfg *config.Main, hetznerClient hetzner.API, autoApprove, planOnly bool) (*Applier, error) {
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
+	if err != nil {
+		return nil, err
+	}
+
+	applier := &Applier{
+		Config:        cfg,
+		HetznerClient: hetznerClient,
+		SSHClient:     sshClient,
+		AutoApprove:   autoApprove,
+		PlanOnly:      planOnly,
+		ctx:           ctx,
+	}
+
+	// Configure NAT gateway as bastion host if enabled
+	if err := setupNATGatewayBastion(applier.ctx, cfg, hetznerClient, applier.SSHClient, "apply"); err != nil {
+		return nil, fmt.Errorf("failed to configure NAT gateway bastion: %w", err)
+	}
+
+	return applier, nil
+}
+
+// Run plans the worker pool changes, asks for confirmation and applies them
+func (a *Applier) Run() error {
+	util.LogInfo("Starting cluster reconciliation", a.Config.ClusterName)
+
+	spinner := util.NewSpinner("Reading cluster state", "apply")
+	spinner.Start()
+	servers, err := listClusterServers(a.ctx, a.HetznerClient, a.Config)
+	if err != nil {
+		spinner.Stop(true)
+		return err
+	}
+
+	master := firstMasterServer(servers)
+	if master == nil {
+		spinner.Stop(true)
+		return fmt.Errorf("no master found for cluster %s, run create first", a.Config.ClusterName)
+	}
+
+	nodes, err := fetchKubeNodes(a.ctx, a.Config, a.SSHClient, master)
+	if err != nil {
+		spinner.Stop(true)
+		return fmt.Errorf("failed to read node state from %s: %w", master.Name, err)
+	}
+
+	clusterLabel := fmt.Sprintf("cluster=%s", a.Config.ClusterName)
+	volumes, err := a.HetznerClient.ListVolumes(a.ctx, hcloud.VolumeListOpts{ListOpts: hcloud.ListOpts{LabelSelector: clusterLabel}})
+	if err != nil {
+		spinner.Stop(true)
+		return fmt.Errorf("failed to list volumes: %w", err)
+	}
+	primaryIPs, err := a.HetznerClient.ListPrimaryIPs(a.ctx, hcloud.PrimaryIPListOpts{ListOpts: hcloud.ListOpts{LabelSelector: clusterLabel}})
+	spinner.Stop(true)
+	if err != nil {
+		return fmt.Errorf("failed to list primary IPs: %w", err)
+	}
+
+	plan := buildApplyPlan(a.Config, servers, nodes)
+	planWorkerResources(a.Config, &plan, volumes, primaryIPs)
+	for _, warning := range plan.Warnings {
+		util.LogWarning(warning, "apply")
+	}
+	fmt.Print(FormatPlan(plan.Changes))
+
+	if !plan.hasChanges() {
+		fmt.Println()
+		util.LogSuccess("Worker pools match the configuration, nothing to apply", a.Config.ClusterName)
+		return nil
+	}
+
+	if a.PlanOnly {
+		return nil
+	}
+
+	if !a.AutoApprove {
+		if err := confirmApply(); err != nil {
+			return err
+		}
+	}
+
+	// Step 1: Scale down, draining nodes before their servers are deleted, then
+	// delete the volumes and primary IPs their pool does not keep
+	for _, server := range plan.Delete {
+		_, joined := nodes[server.Name]
+		if err := a.removeWorker(master, server, joined); err != nil {
+			return fmt.Errorf("failed to remove worker %s: %w", server.Name, err)
+		}
+		if err := a.deleteWorkerResources(server.Name, plan); err != nil {
+			return fmt.Errorf("failed to delete resources of worker %s: %w", server.Name, err)
+		}
+	}
+
+	// Step 2: Scale up
+	if len(plan.Create) > 0 {
+		newUpdates, err := a.addWorkers(master, plan.Create)
+		if err != nil {
+			return err
+		}
+		plan.Updates = append(plan.Updates, newUpdates...)
+	}
+
+	// Step 3: Replace workers of another instance type one at a time, so the
+	// pool loses at most one node of capacity
+	for _, pw := range plan.Replace {
+		_, joined := nodes[pw.Name]
+		if err := a.removeWorker(master, pw.Replaces, joined); err != nil {
+			return fmt.Errorf("failed to remove worker %s: %w", pw.Name, err)
+		}
+		newUpdates, err := a.addWorkers(master, []plannedWorker{pw})
+		if err != nil {
+			return err
+		}
+		plan.Updates = append(plan.Updates, newUpdates...)
+	}
+
+	// Step 4: Sync labels, taints and managed annotations
+	if err := a.applyNodeUpdates(master, plan.Updates); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	util.LogSuccess("Cluster reconciliation completed successfully!", a.Config.ClusterName)
+	fmt.Println()
+
+	return nil
+}
+
+// removeWorker drains a worker node, removes it from Kubernetes and deletes its server
+func (a *Applier) removeWorker(master *hcloud.Server, server *hcloud.Server, joined bool) error {
+	masterIP, err := GetServerSSHIP(master)
+	if err != nil {
+		return err
+	}
+
+	if joined {
+		spinner := util.NewSpinner(fmt.Sprintf("Draining node %s", server.Name), "worker")
+		spinner.Start()
+		drainCmd := fmt.Sprintf("sudo k3s kubectl drain %s --ignore-daemonsets --delete-emptydir-data --timeout=%s",
+			util.ShellQuote(server.Name), nodeDrainTimeout)
+		if _, err := a.SSHClient.Run(a.ctx, masterIP, a.Config.Networking.SSH.Port, drainCmd, a.Config.Networking.SSH.UseAgent); err != nil {
+			spinner.Stop(true)
+			return fmt.Errorf("failed to drain node: %w", err)
+		}
+		spinner.Stop(true)
+
+		deleteCmd := fmt.Sprintf("sudo k3s kubectl delete node %s", util.ShellQuote(server.Name))
+		if _, err := a.SSHClient.Run(a.ctx, masterIP, a.Config.Networking.SSH.Port, deleteCmd, a.Config.Networking.SSH.UseAgent); err != nil {
+			return fmt.Errorf("failed to delete node: %w", err)
+		}
+	}
+
+	if err := a.HetznerClient.DeleteServer(a.ctx, server); err != nil {
+		return err
+	}
+	if err := forgetKnownHost(a.SSHClient, server, a.Config.Networking.SSH.Port); err != nil {
+		return err
+	}
+
+	util.LogSuccess(fmt.Sprintf("Worker removed: %s", server.Name), "worker")
+	return nil
+}
+
+// deleteWorkerResources deletes the volumes and primary IP of a removed worker that
+// the plan does not keep
+func (a *Applier) deleteWorkerResources(serverName string, plan applyPlan) error {
+	for _, volume := range plan.DeleteVolumes[serverName] {
+		if err := a.HetznerClient.DeleteVolume(a.ctx, volume); err != nil {
+			return err
+		}
+		util.LogSuccess(fmt.Sprintf("Deleted volume: %s", volume.Name), "volume")
+	}
+	if primaryIP := plan.DeletePrimaryIPs[serverName]; primaryIP != nil {
+		if err := a.HetznerClient.DeletePrimaryIP(a.ctx, primaryIP); err != nil {
+			return err
+		}
+		util.LogSuccess(fmt.Sprintf("Deleted primary IP: %s", primaryIP.Name), "primary ip")
+	}
+	return nil
+}
+
+// addWorkers creates the planned workers, joins them to the cluster and
+// returns the label and taint updates needed for the new nodes
+func (a *Applier) addWorkers(master *hcloud.Server, planned []plannedWorker) ([]nodeUpdate, error) {
+	masterIP, err := GetServerSSHIP(master)
+	if err != nil {
+		return nil, err
+	}
+
+	// New workers join with the token of the existing cluster
+	token, err := a.SSHClient.Run(a.ctx, masterIP, a.Config.Networking.SSH.Port, k3sServerTokenReadCmd, a.Config.Networking.SSH.UseAgent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read k3s token: %w", err)
+	}
+	token = strings.TrimSpace(token)
+
+	sshKeyName := fmt.Sprintf("%s-ssh-key", a.Config.ClusterName)
+	sshKey, err := a.HetznerClient.GetSSHKey(a.ctx, sshKeyName)
+	if err != nil {
+		return nil, err
+	}
+	if sshKey == nil {
+		return nil, fmt.Errorf("SSH key %s not found, run create first", sshKeyName)
+	}
+
+	var network *hcloud.Network
+	if a.Config.Networking.PrivateNetwork.Enabled {
+		network, err = a.HetznerClient.GetNetwork(a.ctx, a.Config.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+		if network == nil {
+			return nil, fmt.Errorf("network %s not found, run create first", a.Config.ClusterName)
+		}
+	}
+
+	staticPools, autoscalingPools := separateWorkerPools(a.Config.WorkerNodePools)
+	creator := &CreatorEnhanced{
+		Config:           a.Config,
+		HetznerClient:    a.HetznerClient,
+		SSHClient:        a.SSHClient,
+		ctx:              a.ctx,
+		k3sToken:         token,
+		staticPools:      staticPools,
+		autoscalingPools: autoscalingPools,
+	}
+
+	specs := &serverSpecs{}
+	specs.addWorkerPools(staticPools, a.Config.Image)
+	if err := specs.resolve(a.ctx, a.HetznerClient); err != nil {
+		return nil, err
+	}
+
+	// Existing workers are reused by the creator, only the planned ones are new
+	util.LogInfo(fmt.Sprintf("Creating %d worker node(s)", len(planned)), "worker")
+	workers, err := creator.createWorkerNodesFromPools(sshKey, network, staticPools)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worker nodes: %w", err)
+	}
+
+	plannedByName := make(map[string]plannedWorker, len(planned))
+	for _, pw := range planned {
+		plannedByName[pw.Name] = pw
+	}
+	newWorkers := make([]*hcloud.Server, 0, len(planned))
+	for _, worker := range workers {
+		if _, ok := plannedByName[worker.Name]; ok {
+			newWorkers = append(newWorkers, worker)
+		}
+	}
+
+	spinner := util.NewSpinner("Waiting for worker nodes to be ready", "worker")
+	spinner.Start()
+	if err := creator.waitForNodes(newWorkers); err != nil {
+		spinner.Stop(true)
+		return nil, fmt.Errorf("failed waiting for workers: %w", err)
+	}
+	spinner.Stop(true)
+
+	spinner = util.NewSpinner("Installing k3s on worker nodes", "worker")
+	spinner.Start()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errors []error
+
+	for _, worker := range newWorkers {
+		wg.Add(1)
+		go func(w *hcloud.Server) {
+			defer wg.Done()
+			if err := creator.installK3sOnWorker(w, master); err != nil {
+				mu.Lock()
+				errors = append(errors, fmt.Errorf("failed to install k3s on worker %s: %w", w.Name, err))
+				mu.Unlock()
+			}
+		}(worker)
+	}
+
+	wg.Wait()
+	hasErrors := len(errors) > 0
+	spinner.Stop(hasErrors)
+
+	if hasErrors {
+		return nil, fmt.Errorf("errors installing k3s on workers: %v", errors)
+	}
+	util.LogSuccess(fmt.Sprintf("Added %d worker node(s)", len(newWorkers)), "worker")
+
+	// Wait for the new nodes to register before syncing their labels and taints
+	for _, worker := range newWorkers {
+		if err := a.waitForNodeRegistration(masterIP, worker.Name, 2*time.Minute); err != nil {
+			return nil, err
+		}
+	}
+
+	nodes, err := fetchKubeNodes(a.ctx, a.Config, a.SSHClient, master)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node state from %s: %w", master.Name, err)
+	}
+
+	var updates []nodeUpdate
+	for _, worker := range newWorkers {
+		node, ok := nodes[worker.Name]
+		if !ok {
+			continue
+		}
+		update := diffNodeLabelsAndTaints(node, plannedByName[worker.Name].Pool.NodePool)
+		if update.hasChanges() || update.Annotate {
+			updates = append(updates, update)
+		}
+	}
+
+	return updates, nil
+}
+
+// waitForNodeRegistration waits until a node is registered with the Kubernetes API
+func (a *Applier) waitForNodeRegistration(masterIP, nodeName string, timeout time.Duration) error {
+	checkCmd := fmt.Sprintf("sudo k3s kubectl get node %s -o name 2>/dev/null", util.ShellQuote(nodeName))
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		output, err := a.SSHClient.Run(a.ctx, masterIP, a.Config.Networking.SSH.Port, checkCmd, a.Config.Networking.SSH.UseAgent)
+		if err == nil && strings.Contains(output, "node/") {
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return fmt.Errorf("node %s did not register within %v", nodeName, timeout)
+}
+
+// applyNodeUpdates applies label, taint and annotation changes to nodes via the first master
+func (a *Applier) applyNodeUpdates(master *hcloud.Server, updates []nodeUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	masterIP, err := GetServerSSHIP(master)
+	if err != nil {
+		return err
+	}
+
+	for _, update := range updates {
+		for _, cmd := range nodeUpdateCommands(update) {
+			if _, err := a.SSHClient.Run(a.ctx, masterIP, a.Config.Networking.SSH.Port, cmd, a.Config.Networking.SSH.UseAgent); err != nil {
+				return fmt.Errorf("failed to update node %s: %w", update.Node, err)
+			}
+		}
+		if update.hasChanges() {
+			util.LogSuccess(fmt.Sprintf("Labels and taints synced on %s", update.Node), "worker")
+		}
+	}
+
+	return nil
+}
+
+// confirmApply asks the user to confirm the plan
+func confirmApply() error {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Print("\nDo you want to apply these changes? Only 'yes' will be accepted: ")
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if strings.TrimSpace(input) != "yes" {
+		util.LogError("Apply cancelled", "")
+		return fmt.Errorf("apply cancelled")
+	}
+
+	return nil
+}
+
+// buildApplyPlan compares the static worker pools in the configuration with the
+// live servers and Kubernetes nodes. Autoscaled servers are left to the cluster autoscaler.
+// Workers of another instance type are replaced, as a server keeps its type for life here.
+func buildApplyPlan(cfg *config.Main, servers []*hcloud.Server, nodes map[string]kubeNode) applyPlan {
+	var plan applyPlan
+
+	masterCount := 0
+	workersByPool := make(map[string][]*hcloud.Server)
+	for _, server := range servers {
+		if _, autoscaled := server.Labels[HCloudNodeGroupLabel]; autoscaled {
+			continue
+		}
+		switch server.Labels["role"] {
+		case "master":
+			masterCount++
+		case "worker":
+			workersByPool[server.Labels["pool"]] = append(workersByPool[server.Labels["pool"]], server)
+		}
+	}
+
+	if masterCount != cfg.MastersPool.InstanceCount {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Cluster has %d master(s) but %d are configured; apply does not change masters", masterCount, cfg.MastersPool.InstanceCount))
+	}
+
+	staticPools, _ := separateWorkerPools(cfg.WorkerNodePools)
+	configuredPools := make(map[string]bool, len(staticPools))
+
+	for poolIdx, pool := range staticPools {
+		poolName := workerPoolName(pool, poolIdx)
+		configuredPools[poolName] = true
+
+		existing := make(map[string]*hcloud.Server, len(workersByPool[poolName]))
+		for _, server := range workersByPool[poolName] {
+			existing[server.Name] = server
+		}
+
+		desired := make(map[string]bool, pool.InstanceCount)
+		for i := 0; i < pool.InstanceCount; i++ {
+			name := fmt.Sprintf("%s-worker-%s-%d", cfg.ClusterName, poolName, i+1)
+			desired[name] = true
+
+			server := existing[name]
+			if server == nil {
+				change := planServer("worker", nil, name, pool.InstanceType, pool.Location)
+				change.Details = append(change.Details, describeLabelsAndTaints(pool.Labels, pool.Taints)...)
+				plan.Create = append(plan.Create, plannedWorker{Name: name, Pool: pool})
+				plan.Changes = append(plan.Changes, change)
+				continue
+			}
+
+			if server.ServerType != nil && server.ServerType.Name != pool.InstanceType {
+				change := PlanChange{
+					Action:   PlanActionReplace,
+					Resource: "worker",
+					Name:     name,
+					Details:  []string{fmt.Sprintf("type %s -> %s (node will be drained, deleted and recreated)", server.ServerType.Name, pool.InstanceType)},
+				}
+				change.Details = append(change.Details, describeLabelsAndTaints(pool.Labels, pool.Taints)...)
+				plan.Replace = append(plan.Replace, plannedWorker{Name: name, Pool: pool, Replaces: server})
+				plan.Changes = append(plan.Changes, change)
+				continue
+			}
+
+			change := PlanChange{Action: PlanActionReuse, Resource: "worker", Name: name}
+			if server.Location != nil && server.Location.Name != pool.Location {
+				change.Details = append(change.Details, fmt.Sprintf("location %s differs from configured %s (not changed by apply)", server.Location.Name, pool.Location))
+			}
+
+			node, joined := nodes[name]
+			if !joined {
+				change.Details = append(change.Details, "not registered in Kubernetes (labels and taints not synced)")
+				plan.Changes = append(plan.Changes, change)
+				continue
+			}
+
+			update := diffNodeLabelsAndTaints(node, pool.NodePool)
+			if update.hasChanges() || update.Annotate {
+				change.Action = PlanActionUpdate
+				change.Details = append(change.Details, describeNodeUpdate(update)...)
+				change.Details = append(change.Details, describeManagedAnnotations(node, update)...)
+				plan.Updates = append(plan.Updates, update)
+			}
+			plan.Changes = append(plan.Changes, change)
+		}
+
+		for _, server := range workersByPool[poolName] {
+			if !desired[server.Name] {
+				plan.Delete = append(plan.Delete, server)
+				plan.Changes = append(plan.Changes, PlanChange{
+					Action:   PlanActionDelete,
+					Resource: "worker",
+					Name:     server.Name,
+					Details:  []string{fmt.Sprintf("pool %s is scaled to %d (node will be drained first)", poolName, pool.InstanceCount)},
+				})
+			}
+		}
+	}
+
+	// Workers of pools that were removed from the configuration
+	removedPools := make([]string, 0)
+	for poolName := range workersByPool {
+		if !configuredPools[poolName] {
+			removedPools = append(removedPools, poolName)
+		}
+	}
+	sort.Strings(removedPools)
+
+	for _, poolName := range removedPools {
+		for _, server := range workersByPool[poolName] {
+			plan.Delete = append(plan.Delete, server)
+			plan.Changes = append(plan.Changes, PlanChange{
+				Action:   PlanActionDelete,
+				Resource: "worker",
+				Name:     server.Name,
+				Details:  []string{fmt.Sprintf("pool %s is not in the configuration (node will be drained first)", poolName)},
+			})
+		}
+	}
+
+	return plan
+}
+
+// planWorkerResources plans the volumes and primary IPs of the workers the plan deletes
+// They follow the delete_policy of their pool, as on cluster deletion: resources the
+// pool keeps are noted on the worker, the others are planned for deletion.
+func planWorkerResources(cfg *config.Main, plan *applyPlan, volumes []*hcloud.Volume, primaryIPs []*hcloud.PrimaryIP) {
+	plan.DeleteVolumes = make(map[string][]*hcloud.Volume)
+	plan.DeletePrimaryIPs = make(map[string]*hcloud.PrimaryIP)
+
+	workerChanges := make(map[string]*PlanChange, len(plan.Delete))
+	for i := range plan.Changes {
+		if plan.Changes[i].Action == PlanActionDelete && plan.Changes[i].Resource == "worker" {
+			workerChanges[plan.Changes[i].Name] = &plan.Changes[i]
+		}
+	}
+
+	var changes []PlanChange
+	for _, server := range plan.Delete {
+		workerChange := workerChanges[server.Name]
+		for _, volume := range volumes {
+			if volume.Name != volumeName(server.Name, volume.Labels["volume"]) {
+				continue
+			}
+			if keepVolume(cfg, volume) {
+				workerChange.Details = append(workerChange.Details, fmt.Sprintf("volume %s is kept (delete_policy keep)", volume.Name))
+				continue
+			}
+			plan.DeleteVolumes[server.Name] = append(plan.DeleteVolumes[server.Name], volume)
+			changes = append(changes, PlanChange{
+				Action:   PlanActionDelete,
+				Resource: "volume",
+				Name:     volume.Name,
+				Details:  []string{fmt.Sprintf("%d GB of removed worker %s", volume.Size, server.Name)},
+			})
+		}
+		for _, primaryIP := range primaryIPs {
+			if primaryIP.Name != primaryIPName(server.Name) {
+				continue
+			}
+			if keepPrimaryIP(cfg, primaryIP) {
+				workerChange.Details = append(workerChange.Details, fmt.Sprintf("primary ip %s is kept (delete_policy keep)", primaryIP.Name))
+				continue
+			}
+			plan.DeletePrimaryIPs[server.Name] = primaryIP
+			changes = append(changes, PlanChange{
+				Action:   PlanActionDelete,
+				Resource: "primary ip",
+				Name:     primaryIP.Name,
+				Details:  []string{fmt.Sprintf("address %s of removed worker %s", primaryIP.IP, server.Name)},
+			})
+		}
+	}
+	plan.Changes = append(plan.Changes, changes...)
+}
+
+// diffNodeLabelsAndTaints compares a node with its pool configuration
+// Only labels and taints previously applied from the configuration are removed.
+func diffNodeLabelsAndTaints(node kubeNode, pool config.NodePool) nodeUpdate {
+	update := nodeUpdate{
+		Node:      node.Name,
+		SetLabels: make(map[string]string),
+	}
+
+	desiredLabels := make(map[string]string, len(pool.Labels))
+	for _, label := range pool.Labels {
+		desiredLabels[label.Key] = label.Value
+	}
+	for key, value := range desiredLabels {
+		if current, ok := node.Labels[key]; !ok || current != value {
+			update.SetLabels[key] = value
+		}
+	}
+	for _, key := range splitAnnotation(node.Annotations[managedLabelsAnnotation]) {
+		if _, wanted := desiredLabels[key]; wanted {
+			continue
+		}
+		if _, ok := node.Labels[key]; ok {
+			update.RemoveLabels = append(update.RemoveLabels, key)
+		}
+	}
+
+	desiredTaints := make(map[string]bool, len(pool.Taints))
+	for _, taint := range pool.Taints {
+		desiredTaints[taintID(taint)] = true
+		found := false
+		for _, current := range node.Taints {
+			if current.Key == taint.Key && current.Effect == taint.Effect && current.Value == taint.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			update.SetTaints = append(update.SetTaints, taint)
+		}
+	}
+	for _, id := range splitAnnotation(node.Annotations[managedTaintsAnnotation]) {
+		if desiredTaints[id] {
+			continue
+		}
+		for _, current := range node.Taints {
+			if taintID(current) == id {
+				update.RemoveTaints = append(update.RemoveTaints, current)
+			}
+		}
+	}
+
+	labelKeys := make([]string, 0, len(desiredLabels))
+	for key := range desiredLabels {
+		labelKeys = append(labelKeys, key)
+	}
+	sort.Strings(labelKeys)
+	taintIDs := make([]string, 0, len(desiredTaints))
+	for id := range desiredTaints {
+		taintIDs = append(taintIDs, id)
+	}
+	sort.Strings(taintIDs)
+	sort.Strings(update.RemoveLabels)
+
+	update.ManagedLabels = strings.Join(labelKeys, ",")
+	update.ManagedTaints = strings.Join(taintIDs, ",")
+	update.Annotate = node.Annotations[managedLabelsAnnotation] != update.ManagedLabels ||
+		node.Annotations[managedTaintsAnnotation] != update.ManagedTaints
+
+	return update
+}
+
+// nodeUpdateCommands builds the kubectl commands that apply a node update
+func nodeUpdateCommands(update nodeUpdate) []string {
+	var commands []string
+	node := util.ShellQuote(update.Node)
+
+	if len(update.SetLabels) > 0 || len(update.RemoveLabels) > 0 {
+		keys := make([]string, 0, len(update.SetLabels))
+		for key := range update.SetLabels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var args []string
+		for _, key := range keys {
+			args = append(args, util.ShellQuote(key+"="+update.SetLabels[key]))
+		}
+		for _, key := range update.RemoveLabels {
+			args = append(args, util.ShellQuote(key+"-"))
+		}
+		commands = append(commands, fmt.Sprintf("sudo k3s kubectl label node %s %s --overwrite", node, strings.Join(args, " ")))
+	}
+
+	if len(update.SetTaints) > 0 || len(update.RemoveTaints) > 0 {
+		var args []string
+		for _, taint := range update.SetTaints {
+			args = append(args, util.ShellQuote(formatTaint(taint)))
+		}
+		for _, taint := range update.RemoveTaints {
+			args = append(args, util.ShellQuote(taintID(taint)+"-"))
+		}
+		commands = append(commands, fmt.Sprintf("sudo k3s kubectl taint node %s %s --overwrite", node, strings.Join(args, " ")))
+	}
+
+	if update.Annotate || update.hasChanges() {
+		commands = append(commands, fmt.Sprintf("sudo k3s kubectl annotate node %s %s %s --overwrite", node,
+			util.ShellQuote(managedLabelsAnnotation+"="+update.ManagedLabels),
+			util.ShellQuote(managedTaintsAnnotation+"="+update.ManagedTaints)))
+	}
+
+	return commands
+}
+
+// describeLabelsAndTaints describes the labels and taints a new node will receive
+func describeLabelsAndTaints(labels []config.Label, taints []config.Taint) []string {
+	var details []string
+	for _, label := range labels {
+		details = append(details, fmt.Sprintf("label %s=%s", label.Key, label.Value))
+	}
+	for _, taint := range taints {
+		details = append(details, fmt.Sprintf("taint %s", formatTaint(taint)))
+	}
+	return details
+}
+
+// describeNodeUpdate describes the label and taint changes of a node update
+func describeNodeUpdate(update nodeUpdate) []string {
+	keys := make([]string, 0, len(update.SetLabels))
+	for key := range update.SetLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var details []string
+	for _, key := range keys {
+		details = append(details, fmt.Sprintf("label %s=%s", key, update.SetLabels[key]))
+	}
+	for _, key := range update.RemoveLabels {
+		details = append(details, fmt.Sprintf("remove label %s", key))
+	}
+	for _, taint := range update.SetTaints {
+		details = append(details, fmt.Sprintf("taint %s", formatTaint(taint)))
+	}
+	for _, taint := range update.RemoveTaints {
+		details = append(details, fmt.Sprintf("remove taint %s", taintID(taint)))
+	}
+	return details
+}
+
+// describeManagedAnnotations describes the managed label and taint annotations an update records
+func describeManagedAnnotations(node kubeNode, update nodeUpdate) []string {
+	var details []string
+	if node.Annotations[managedLabelsAnnotation] != update.ManagedLabels {
+		details = append(details, fmt.Sprintf("annotation %s=%s", managedLabelsAnnotation, update.ManagedLabels))
+	}
+	if node.Annotations[managedTaintsAnnotation] != update.ManagedTaints {
+		details = append(details, fmt.Sprintf("annotation %s=%s", managedTaintsAnnotation, update.ManagedTaints))
+	}
+	return details
+}
+
+// formatTaint renders a taint in kubectl notation (key=value:Effect)
+func formatTaint(taint config.Taint) string {
+	if taint.Value == "" {
+		return taintID(taint)
+	}
+	return fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect)
+}
+
+// taintID identifies a taint by key and effect, as Kubernetes does
+func taintID(taint config.Taint) string {
+	return fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+}
+
+// splitAnnotation splits a comma separated annotation value
+func splitAnnotation(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
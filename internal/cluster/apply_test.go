@@ -0,0 +1,299 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+)
+
+func newTestWorker(name, pool string) *hcloud.Server {
+	return &hcloud.Server{
+		Name:       name,
+		Labels:     map[string]string{"cluster": "test", "role": "worker", "pool": pool},
+		ServerType: &hcloud.ServerType{Name: "cpx21"},
+		Location:   &hcloud.Location{Name: "fsn1"},
+	}
+}
+
+func newTestApplyConfig(pools ...config.WorkerNodePool) *config.Main {
+	return &config.Main{
+		ClusterName:     "test",
+		MastersPool:     config.MasterNodePool{NodePool: config.NodePool{InstanceCount: 1}},
+		WorkerNodePools: pools,
+	}
+}
+
+func newTestPool(name string, count int) config.WorkerNodePool {
+	return config.WorkerNodePool{
+		NodePool: config.NodePool{Name: &name, InstanceType: "cpx21", InstanceCount: count},
+		Location: "fsn1",
+	}
+}
+
+// TestBuildApplyPlanScaling tests scale up, scale down and removed pools
+func TestBuildApplyPlanScaling(t *testing.T) {
+	servers := []*hcloud.Server{
+		{Name: "test-master-1", Labels: map[string]string{"role": "master"}},
+		newTestWorker("test-worker-small-1", "small"),
+		newTestWorker("test-worker-small-2", "small"),
+		newTestWorker("test-worker-small-3", "small"),
+		newTestWorker("test-worker-old-1", "old"),
+		{Name: "test-big-abc", Labels: map[string]string{HCloudNodeGroupLabel: "test-big"}},
+	}
+	nodes := map[string]kubeNode{
+		"test-worker-small-1": {Name: "test-worker-small-1"},
+		"test-worker-small-2": {Name: "test-worker-small-2"},
+	}
+
+	cfg := newTestApplyConfig(newTestPool("small", 2), newTestPool("large", 1))
+	plan := buildApplyPlan(cfg, servers, nodes)
+
+	if len(plan.Create) != 1 || plan.Create[0].Name != "test-worker-large-1" {
+		t.Errorf("Create = %+v, want test-worker-large-1", plan.Create)
+	}
+
+	var deleted []string
+	for _, server := range plan.Delete {
+		deleted = append(deleted, server.Name)
+	}
+	if strings.Join(deleted, ",") != "test-worker-small-3,test-worker-old-1" {
+		t.Errorf("Delete = %v, want [test-worker-small-3 test-worker-old-1]", deleted)
+	}
+
+	if len(plan.Warnings) != 0 {
+		t.Errorf("Warnings = %v, want none", plan.Warnings)
+	}
+	if !plan.hasChanges() {
+		t.Error("hasChanges() = false, want true")
+	}
+}
+
+// TestBuildApplyPlanNoChanges tests that a converged cluster produces no changes
+func TestBuildApplyPlanNoChanges(t *testing.T) {
+	servers := []*hcloud.Server{
+		{Name: "test-master-1", Labels: map[string]string{"role": "master"}},
+		newTestWorker("test-worker-small-1", "small"),
+	}
+	nodes := map[string]kubeNode{
+		"test-worker-small-1": {Name: "test-worker-small-1"},
+	}
+
+	plan := buildApplyPlan(newTestApplyConfig(newTestPool("small", 1)), servers, nodes)
+	if plan.hasChanges() {
+		t.Errorf("hasChanges() = true, want false: %+v", plan.Changes)
+	}
+}
+
+// TestBuildApplyPlanMasterWarning tests that master count drift is reported but not changed
+func TestBuildApplyPlanMasterWarning(t *testing.T) {
+	cfg := newTestApplyConfig()
+	cfg.MastersPool.InstanceCount = 3
+
+	plan := buildApplyPlan(cfg, []*hcloud.Server{{Name: "test-master-1", Labels: map[string]string{"role": "master"}}}, nil)
+	if len(plan.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want one master warning", plan.Warnings)
+	}
+	if plan.hasChanges() {
+		t.Error("hasChanges() = true, want false")
+	}
+}
+
+// TestBuildApplyPlanInstanceType tests that workers of another instance type are replaced
+func TestBuildApplyPlanInstanceType(t *testing.T) {
+	resized := newTestWorker("test-worker-small-2", "small")
+	resized.ServerType = &hcloud.ServerType{Name: "cpx11"}
+	moved := newTestWorker("test-worker-small-1", "small")
+	moved.Location = &hcloud.Location{Name: "nbg1"}
+
+	servers := []*hcloud.Server{
+		{Name: "test-master-1", Labels: map[string]string{"role": "master"}},
+		moved,
+		resized,
+	}
+	nodes := map[string]kubeNode{
+		"test-worker-small-1": {Name: "test-worker-small-1"},
+		"test-worker-small-2": {Name: "test-worker-small-2"},
+	}
+
+	plan := buildApplyPlan(newTestApplyConfig(newTestPool("small", 2)), servers, nodes)
+
+	if len(plan.Replace) != 1 || plan.Replace[0].Name != "test-worker-small-2" || plan.Replace[0].Replaces != resized {
+		t.Fatalf("Replace = %+v, want test-worker-small-2", plan.Replace)
+	}
+	if len(plan.Create) != 0 || len(plan.Delete) != 0 {
+		t.Errorf("Create = %+v, Delete = %+v, want neither", plan.Create, plan.Delete)
+	}
+
+	for _, change := range plan.Changes {
+		switch change.Name {
+		case "test-worker-small-2":
+			if change.Action != PlanActionReplace || !strings.Contains(change.Details[0], "cpx11 -> cpx21") {
+				t.Errorf("resized worker change = %+v, want replace cpx11 -> cpx21", change)
+			}
+		case "test-worker-small-1":
+			if change.Action != PlanActionReuse {
+				t.Errorf("moved worker action = %s, want reuse", change.Action)
+			}
+		}
+		for _, detail := range change.Details {
+			if strings.Contains(detail, "not changed by create") {
+				t.Errorf("apply plan shows create note: %s", detail)
+			}
+		}
+	}
+}
+
+// TestBuildApplyPlanAnnotations tests that recording the managed annotations is a planned change
+func TestBuildApplyPlanAnnotations(t *testing.T) {
+	pool := newTestPool("small", 1)
+	pool.Labels = []config.Label{{Key: "tier", Value: "web"}}
+
+	servers := []*hcloud.Server{
+		{Name: "test-master-1", Labels: map[string]string{"role": "master"}},
+		newTestWorker("test-worker-small-1", "small"),
+	}
+	nodes := map[string]kubeNode{
+		"test-worker-small-1": {Name: "test-worker-small-1", Labels: map[string]string{"tier": "web"}},
+	}
+
+	plan := buildApplyPlan(newTestApplyConfig(pool), servers, nodes)
+	if !plan.hasChanges() {
+		t.Fatal("hasChanges() = false, want true for missing annotations")
+	}
+	if len(plan.Updates) != 1 || plan.Updates[0].hasChanges() || !plan.Updates[0].Annotate {
+		t.Fatalf("Updates = %+v, want one annotation-only update", plan.Updates)
+	}
+	change := plan.Changes[0]
+	if change.Action != PlanActionUpdate || strings.Join(change.Details, ";") != "annotation "+managedLabelsAnnotation+"=tier" {
+		t.Errorf("change = %+v, want update recording the labels annotation", change)
+	}
+}
+
+// TestPlanWorkerResources tests that the volumes and primary IPs of removed workers follow their pool's policy
+func TestPlanWorkerResources(t *testing.T) {
+	pool := newTestPool("small", 1)
+	pool.PrimaryIPs = &config.PrimaryIPs{Enabled: true}
+	pool.Volumes = []config.Volume{{Name: "data", DeletePolicy: config.VolumeDeletePolicyKeep}, {Name: "cache"}}
+	cfg := newTestApplyConfig(pool)
+
+	servers := []*hcloud.Server{
+		{Name: "test-master-1", Labels: map[string]string{"role": "master"}},
+		newTestWorker("test-worker-small-1", "small"),
+		newTestWorker("test-worker-small-2", "small"),
+		newTestWorker("test-worker-old-1", "old"),
+	}
+	nodes := map[string]kubeNode{"test-worker-small-1": {Name: "test-worker-small-1"}}
+	volumes := []*hcloud.Volume{
+		{Name: "test-worker-small-1-cache", Size: 10, Labels: map[string]string{"pool": "small", "volume": "cache"}},
+		{Name: "test-worker-small-2-cache", Size: 10, Labels: map[string]string{"pool": "small", "volume": "cache"}},
+		{Name: "test-worker-small-2-data", Size: 50, Labels: map[string]string{"pool": "small", "volume": "data"}},
+	}
+	primaryIPs := []*hcloud.PrimaryIP{
+		{Name: "test-worker-small-1-ipv4", Labels: map[string]string{"role": "worker", "pool": "small"}},
+		{Name: "test-worker-small-2-ipv4", Labels: map[string]string{"role": "worker", "pool": "small"}},
+		{Name: "test-worker-old-1-ipv4", Labels: map[string]string{"role": "worker", "pool": "old"}},
+	}
+
+	plan := buildApplyPlan(cfg, servers, nodes)
+	planWorkerResources(cfg, &plan, volumes, primaryIPs)
+
+	if got := plan.DeleteVolumes["test-worker-small-2"]; len(got) != 1 || got[0].Name != "test-worker-small-2-cache" {
+		t.Errorf("DeleteVolumes = %v, want only test-worker-small-2-cache", got)
+	}
+	if len(plan.DeleteVolumes["test-worker-small-1"]) != 0 || plan.DeletePrimaryIPs["test-worker-small-1"] != nil {
+		t.Error("resources of a kept worker planned for deletion")
+	}
+	if plan.DeletePrimaryIPs["test-worker-small-2"] == nil || plan.DeletePrimaryIPs["test-worker-old-1"] == nil {
+		t.Errorf("DeletePrimaryIPs = %v, want the IPs of both removed workers", plan.DeletePrimaryIPs)
+	}
+
+	var deleted []string
+	for _, change := range plan.Changes {
+		if change.Action == PlanActionDelete && change.Resource != "worker" {
+			deleted = append(deleted, change.Resource+" "+change.Name)
+		}
+		if change.Name == "test-worker-small-2" && !strings.Contains(strings.Join(change.Details, ";"), "volume test-worker-small-2-data is kept") {
+			t.Errorf("worker change = %+v, want a note about the kept data volume", change)
+		}
+	}
+	want := "volume test-worker-small-2-cache,primary ip test-worker-small-2-ipv4,primary ip test-worker-old-1-ipv4"
+	if strings.Join(deleted, ",") != want {
+		t.Errorf("planned deletions = %v, want %s", deleted, want)
+	}
+}
+
+// TestDiffNodeLabelsAndTaints tests label and taint synchronization
+func TestDiffNodeLabelsAndTaints(t *testing.T) {
+	pool := config.NodePool{
+		Labels: []config.Label{{Key: "tier", Value: "web"}, {Key: "env", Value: "prod"}},
+		Taints: []config.Taint{{Key: "dedicated", Value: "web", Effect: "NoSchedule"}},
+	}
+	node := kubeNode{
+		Name:   "test-worker-web-1",
+		Labels: map[string]string{"tier": "web", "env": "staging", "old": "x", "kubernetes.io/os": "linux"},
+		Annotations: map[string]string{
+			managedLabelsAnnotation: "old,tier",
+			managedTaintsAnnotation: "legacy:NoExecute",
+		},
+		Taints: []config.Taint{
+			{Key: "legacy", Effect: "NoExecute"},
+			{Key: "node.kubernetes.io/unschedulable", Effect: "NoSchedule"},
+		},
+	}
+
+	update := diffNodeLabelsAndTaints(node, pool)
+
+	if len(update.SetLabels) != 1 || update.SetLabels["env"] != "prod" {
+		t.Errorf("SetLabels = %v, want env=prod", update.SetLabels)
+	}
+	if strings.Join(update.RemoveLabels, ",") != "old" {
+		t.Errorf("RemoveLabels = %v, want [old]", update.RemoveLabels)
+	}
+	if len(update.SetTaints) != 1 || update.SetTaints[0].Key != "dedicated" {
+		t.Errorf("SetTaints = %v, want dedicated", update.SetTaints)
+	}
+	if len(update.RemoveTaints) != 1 || update.RemoveTaints[0].Key != "legacy" {
+		t.Errorf("RemoveTaints = %v, want legacy only", update.RemoveTaints)
+	}
+	if update.ManagedLabels != "env,tier" || update.ManagedTaints != "dedicated:NoSchedule" {
+		t.Errorf("managed = %q / %q", update.ManagedLabels, update.ManagedTaints)
+	}
+	if !update.Annotate {
+		t.Error("Annotate = false, want true")
+	}
+}
+
+// TestNodeUpdateCommands tests kubectl command generation for node updates
+func TestNodeUpdateCommands(t *testing.T) {
+	update := nodeUpdate{
+		Node:          "test-worker-web-1",
+		SetLabels:     map[string]string{"env": "prod"},
+		RemoveLabels:  []string{"old"},
+		SetTaints:     []config.Taint{{Key: "dedicated", Value: "web", Effect: "NoSchedule"}},
+		RemoveTaints:  []config.Taint{{Key: "legacy", Effect: "NoExecute"}},
+		ManagedLabels: "env",
+		ManagedTaints: "dedicated:NoSchedule",
+	}
+
+	commands := nodeUpdateCommands(update)
+	if len(commands) != 3 {
+		t.Fatalf("got %d commands, want 3: %v", len(commands), commands)
+	}
+
+	want := []string{
+		`sudo k3s kubectl label node 'test-worker-web-1' 'env=prod' 'old-' --overwrite`,
+		`sudo k3s kubectl taint node 'test-worker-web-1' 'dedicated=web:NoSchedule' 'legacy:NoExecute-' --overwrite`,
+		`sudo k3s kubectl annotate node 'test-worker-web-1' 'hek3ster/managed-labels=env' 'hek3ster/managed-taints=dedicated:NoSchedule' --overwrite`,
+	}
+	for i := range want {
+		if commands[i] != want[i] {
+			t.Errorf("command %d = %q, want %q", i, commands[i], want[i])
+		}
+	}
+
+	if got := nodeUpdateCommands(nodeUpdate{Node: "n"}); len(got) != 0 {
+		t.Errorf("empty update produced commands: %v", got)
+	}
+}
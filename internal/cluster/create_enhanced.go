@@ -25,111 +25,434 @@ const (
 	k3sKubeconfigCheckCmd = "test -f /etc/rancher/k3s/k3s.yaml && echo 'exists'"
 	// k3sKubeconfigReadCmd is the command to read the kubeconfig file
 	k3sKubeconfigReadCmd = "sudo cat /etc/rancher/k3s/k3s.yaml"
+	// k3sServerTokenCheckCmd is the command to check if the cluster token has been written on a master
+	k3sServerTokenCheckCmd = "sudo test -s /var/lib/rancher/k3s/server/token && echo 'exists'"
 )
 
 // CreatorEnhanced handles cluster creation with full implementation
 type CreatorEnhanced struct {
-	Config           *config.Main
-	HetznerClient    *hetzner.Client
-	SSHClient        *util.SSH
-	ctx              context.Context
-	k3sToken         string
-	staticPools      []config.WorkerNodePool
-	autoscalingPools []config.WorkerNodePool
+	Config            *config.Main
+	HetznerClient     hetzner.API
+	SSHClient         *util.SSH
+	ctx               context.Context
+	k3sToken          string
+	staticPools       []config.WorkerNodePool
+	autoscalingPools  []config.WorkerNodePool
+	Resume            bool
+	RollbackOnFailure bool
+	state             *CreateState
 }
 
 // NewCreatorEnhanced creates a new enhanced cluster creator
-func NewCreatorEnhanced(cfg *config.Main, hetznerClient *hetzner.Client) (*CreatorEnhanced, error) {
-	privKeyPath, err := cfg.Networking.SSH.ExpandedPrivateKeyPath()
+// With resume set, progress recorded by a previous run is loaded from the state file.
+// With rollbackOnFailure set, resources created by a failed run are deleted again.
+func NewCreatorEnhanced(cfg *config.Main, hetznerClient hetzner.API, resume, rollbackOnFailure bool) (*CreatorEnhanced, error) {
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to expand private key path: %w", err)
-	}
-
-	pubKeyPath, err := cfg.Networking.SSH.ExpandedPublicKeyPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to expand public key path: %w", err)
+		return nil, err
 	}
 
-	sshClient := util.NewSSH(privKeyPath, pubKeyPath)
-
 	// Generate k3s token
 	token, err := k3s.GenerateToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate k3s token: %w", err)
 	}
 
+	// Load the previous run's progress, or start a new state file
+	var state *CreateState
+	if resume {
+		state, err = LoadCreateState(cfg.ClusterName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load creation state: %w", err)
+		}
+		if state == nil {
+			util.LogWarning("No previous creation state found, starting from the beginning", "resume")
+		} else if state.K3sToken != "" {
+			token = state.K3sToken
+		}
+	}
+	if state == nil {
+		state, err = NewCreateState(cfg.ClusterName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize creation state: %w", err)
+		}
+	}
+	state.K3sToken = token
+
 	// Separate static and autoscaling worker pools
 	staticPools, autoscalingPools := separateWorkerPools(cfg.WorkerNodePools)
 
 	return &CreatorEnhanced{
-		Config:           cfg,
-		HetznerClient:    hetznerClient,
-		SSHClient:        sshClient,
-		ctx:              context.Background(),
-		k3sToken:         token,
-		staticPools:      staticPools,
-		autoscalingPools: autoscalingPools,
+		Config:            cfg,
+		HetznerClient:     hetznerClient,
+		SSHClient:         sshClient,
+		ctx:               ctx,
+		k3sToken:          token,
+		staticPools:       staticPools,
+		autoscalingPools:  autoscalingPools,
+		Resume:            resume,
+		RollbackOnFailure: rollbackOnFailure,
+		state:             state,
 	}, nil
 }
 
+// Creation steps recorded in the state file, in execution order
+const (
+	stepSSHKey             = "ssh_key"
+	stepNetwork            = "network"
+	stepNATGateway         = "nat_gateway"
+	stepMasters            = "masters"
+	stepFirewall           = "firewall"
+	stepAPILoadBalancer    = "api_load_balancer"
+	stepAPIFloatingIP      = "api_floating_ip"
+	stepMastersReady       = "masters_ready"
+	stepFloatingIPFailover = "floating_ip_failover"
+	stepFirstMaster        = "k3s_first_master"
+	stepAdditionalMasters  = "k3s_additional_masters"
+	stepWorkers            = "workers"
+	stepGlobalLoadBalancer = "global_load_balancer"
+	stepKubeconfig         = "kubeconfig"
+	stepAddons             = "addons"
+)
+
 // Run executes the cluster creation process
+// With RollbackOnFailure set, every resource created during this run is deleted
+// again if the run fails. Resources that existed before the run are left untouched.
 func (c *CreatorEnhanced) Run() error {
+	if !c.RollbackOnFailure {
+		return c.run()
+	}
+
+	tracker := hetzner.NewResourceTracker()
+	c.HetznerClient.SetResourceTracker(tracker)
+	err := c.run()
+	c.HetznerClient.SetResourceTracker(nil)
+	if err == nil {
+		return nil
+	}
+
+	created := tracker.Resources()
+	if len(created) == 0 {
+		util.LogInfo("No resources were created during this run, nothing to roll back", "rollback")
+		return err
+	}
+
+	fmt.Println()
+	util.LogWarning(fmt.Sprintf("Cluster creation failed, rolling back %d resource(s) created during this run", len(created)), "rollback")
+	remaining := rollbackResources(c.ctx, c.HetznerClient, created)
+
+	// Recorded steps refer to resources that no longer exist
+	if removeErr := c.state.Remove(); removeErr != nil {
+		util.LogWarning(removeErr.Error(), "rollback")
+	}
+
+	if len(remaining) > 0 {
+		for _, resource := range remaining {
+			util.LogWarning(fmt.Sprintf("Not rolled back: %s %s (ID %d)", resource.Kind, resource.Name, resource.ID), "rollback")
+		}
+		return fmt.Errorf("%w (rollback incomplete, %d resource(s) left behind)", err, len(remaining))
+	}
+
+	util.LogSuccess("Rollback completed", "rollback")
+	return err
+}
+
+// run executes the creation steps
+// Every step records its outcome in the state file. With Resume set, steps recorded
+// as completed are skipped as long as their postconditions still hold.
+func (c *CreatorEnhanced) run() error {
 	util.LogInfo("Starting cluster creation", c.Config.ClusterName)
 	util.LogInfo(fmt.Sprintf("K3s token: %s", c.k3sToken[:16]), c.Config.ClusterName)
+	util.LogInfo(fmt.Sprintf("Recording progress in %s", c.state.Path()), "resume")
+
+	if knownHosts := c.SSHClient.KnownHosts(); knownHosts != nil {
+		util.LogInfo(fmt.Sprintf("Pinning SSH host keys in %s", knownHosts.Path()), "ssh")
+	}
+
+	// Look up server types, locations and images once, failing before anything is created
+	if err := c.serverSpecs().resolve(c.ctx, c.HetznerClient); err != nil {
+		return err
+	}
+
+	// Steps 1-4: Create the Hetzner resources the nodes are installed on
+	infra, err := c.provisionInfrastructure()
+	if err != nil {
+		return err
+	}
+	sshKey, network, masters := infra.SSHKey, infra.Network, infra.Masters
+	apiLoadBalancer, apiFloatingIP := infra.APILoadBalancer, infra.APIFloatingIP
+
+	// Step 5: Wait for masters to be ready
+	err = c.runStep(stepMastersReady, func() error {
+		spinner := util.NewSpinner("Waiting for master nodes to be ready", "master")
+		spinner.Start()
+		if err := c.waitForNodes(masters); err != nil {
+			spinner.Stop(true)
+			return fmt.Errorf("failed waiting for masters: %w", err)
+		}
+		spinner.Stop(true)
+		util.LogSuccess("Master nodes are ready", "master")
+		return nil
+	}, func() (bool, error) {
+		return c.verifyServersReachable(masters), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Step 6: Install k3s on first master
+	err = c.runStep(stepFirstMaster, func() error {
+		spinner := util.NewSpinner("Installing k3s on first master", "master")
+		spinner.Start()
+		if err := c.installK3sOnFirstMaster(masters[0], masters, apiLoadBalancer, apiFloatingIP); err != nil {
+			spinner.Stop(true)
+			return fmt.Errorf("failed to install k3s on first master: %w", err)
+		}
+		spinner.Stop(true)
+		util.LogSuccess("K3s installed on first master", "master")
+		return nil
+	}, func() (bool, error) {
+		return c.verifyK3sInstalled(masters[:1]), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// All further nodes must join with the token the first master was installed with
+	if err := c.adoptClusterToken(masters[0]); err != nil {
+		return err
+	}
+
+	// Step 7: Install k3s on additional masters (if any) - in parallel
+	if len(masters) > 1 {
+		err := c.runStep(stepAdditionalMasters, func() error {
+			spinner := util.NewSpinner(fmt.Sprintf("Installing k3s on %d additional master(s)", len(masters)-1), "master")
+			spinner.Start()
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var errors []error
+
+			for i := 1; i < len(masters); i++ {
+				wg.Add(1)
+				go func(index int) {
+					defer wg.Done()
+					if err := c.installK3sOnAdditionalMaster(masters[index], masters[0], masters, apiLoadBalancer, apiFloatingIP); err != nil {
+						mu.Lock()
+						errors = append(errors, fmt.Errorf("failed to install k3s on master %d: %w", index+1, err))
+						mu.Unlock()
+					}
+				}(i)
+			}
+
+			wg.Wait()
+			hasErrors := len(errors) > 0
+			spinner.Stop(hasErrors)
+
+			if hasErrors {
+				return fmt.Errorf("errors installing k3s on additional masters: %v", errors)
+			}
+
+			util.LogSuccess(fmt.Sprintf("K3s installed on %d additional master(s)", len(masters)-1), "master")
+			return nil
+		}, func() (bool, error) {
+			return c.verifyK3sInstalled(masters[1:]), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Step 7a: Install the floating IP failover agent on all masters
+	if apiFloatingIP != nil {
+		err := c.runStep(stepFloatingIPFailover, func() error {
+			if err := c.installFloatingIPFailover(masters, apiFloatingIP); err != nil {
+				return err
+			}
+			util.LogSuccess(fmt.Sprintf("Floating IP failover agent running on %d master(s)", len(masters)), "floating ip")
+			return nil
+		}, func() (bool, error) {
+			return c.verifyFloatingIPFailover(masters), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Step 8: Create worker nodes (if configured)
+	// Only create nodes for static (non-autoscaling) pools.
+	// Autoscaling pools will be managed entirely by the cluster autoscaler,
+	// which will create nodes from 0 up to max_instances as needed.
+	totalWorkers := 0
+	for _, pool := range c.staticPools {
+		totalWorkers += pool.InstanceCount
+	}
+
+	if len(c.staticPools) > 0 && totalWorkers > 0 {
+		err := c.runStep(stepWorkers, func() error {
+			return c.createAndJoinWorkers(sshKey, network, masters[0], totalWorkers)
+		}, func() (bool, error) {
+			workers, err := c.findStaticWorkers()
+			if err != nil || len(workers) != totalWorkers {
+				return false, err
+			}
+			return c.verifyK3sInstalled(workers), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Step 10: Create global load balancer (if enabled)
+	if c.Config.LoadBalancer.Enabled {
+		err := c.runStep(stepGlobalLoadBalancer, func() error {
+			return c.createGlobalLoadBalancer(network)
+		}, c.verifyGlobalLoadBalancer)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Step 11: Retrieve kubeconfig
+	err = c.runStep(stepKubeconfig, func() error {
+		util.LogInfo("Retrieving kubeconfig", "kubeconfig")
+		if err := c.retrieveKubeconfig(masters[0], apiLoadBalancer, apiFloatingIP); err != nil {
+			return fmt.Errorf("failed to retrieve kubeconfig: %w", err)
+		}
+		util.LogSuccess(fmt.Sprintf("Kubeconfig saved to: %s", c.Config.KubeconfigPath), "kubeconfig")
+		return nil
+	}, func() (bool, error) {
+		kubeconfigPath, err := config.ExpandPath(c.Config.KubeconfigPath)
+		if err != nil {
+			return false, err
+		}
+		return util.FileExists(kubeconfigPath), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Step 12: Install addons
+	// Use pre-computed autoscaling pools (already separated during initialization)
+	// Addon manifests are applied with kubectl apply, so there is no further postcondition to check
+	err = c.runStep(stepAddons, func() error {
+		if err := c.installAddons(masters[0], masters, c.autoscalingPools); err != nil {
+			return fmt.Errorf("failed to install addons: %w", err)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
 
-	// Security Notice
-	util.LogWarning("SECURITY NOTICE: SSH host key verification is disabled for initial provisioning", "security")
-	util.LogWarning("Ensure you're on a trusted network. Consider implementing host key verification for production.", "security")
+	// The state file holds the k3s token and is not needed after a successful run
+	if err := c.state.Remove(); err != nil {
+		util.LogWarning(err.Error(), "resume")
+	}
 
+	fmt.Println()
+	util.LogSuccess("Cluster creation completed successfully!", c.Config.ClusterName)
+	fmt.Println()
+
+	return nil
+}
+
+// clusterInfrastructure holds the Hetzner resources the k3s installation builds on
+type clusterInfrastructure struct {
+	SSHKey          *hcloud.SSHKey
+	Network         *hcloud.Network
+	Masters         []*hcloud.Server
+	APILoadBalancer *hcloud.LoadBalancer
+	APIFloatingIP   *hcloud.FloatingIP
+}
+
+// provisionInfrastructure runs the creation steps that only call the Hetzner API: the SSH key,
+// network, NAT gateway, masters, firewall and the API load balancer or floating IP.
+// Only the NAT gateway step connects to a server, to wait for it before routing through it.
+func (c *CreatorEnhanced) provisionInfrastructure() (*clusterInfrastructure, error) {
 	// Step 1: Create SSH key in Hetzner
-	util.LogInfo("Creating SSH key", "ssh key")
-	sshKey, err := c.createSSHKey()
+	var sshKey *hcloud.SSHKey
+	err := c.runStep(stepSSHKey, func() error {
+		util.LogInfo("Creating SSH key", "ssh key")
+		key, err := c.createSSHKey()
+		if err != nil {
+			return fmt.Errorf("failed to create SSH key: %w", err)
+		}
+		util.LogSuccess(fmt.Sprintf("SSH key created: %s", key.Name), "ssh key")
+		return nil
+	}, func() (bool, error) {
+		key, err := c.HetznerClient.GetSSHKey(c.ctx, fmt.Sprintf("%s-ssh-key", c.Config.ClusterName))
+		sshKey = key
+		return key != nil, err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create SSH key: %w", err)
+		return nil, err
 	}
-	util.LogSuccess(fmt.Sprintf("SSH key created: %s", sshKey.Name), "ssh key")
 
 	// Step 2: Create network if private network is enabled
 	var network *hcloud.Network
 	var natGateway *hcloud.Server
 	if c.Config.Networking.PrivateNetwork.Enabled {
-		util.LogInfo("Creating private network", "network")
-		network, err = c.createNetwork()
+		err := c.runStep(stepNetwork, func() error {
+			util.LogInfo("Creating private network", "network")
+			created, err := c.createNetwork()
+			if err != nil {
+				return fmt.Errorf("failed to create network: %w", err)
+			}
+			util.LogSuccess(fmt.Sprintf("Network created: %s", created.Name), "network")
+			return nil
+		}, func() (bool, error) {
+			existing, err := c.HetznerClient.GetNetwork(c.ctx, c.Config.ClusterName)
+			network = existing
+			return existing != nil, err
+		})
 		if err != nil {
-			return fmt.Errorf("failed to create network: %w", err)
+			return nil, err
 		}
-		util.LogSuccess(fmt.Sprintf("Network created: %s", network.Name), "network")
 
 		// Step 2.1: Create NAT gateway if enabled
-		if c.Config.Networking.PrivateNetwork.NATGateway != nil && c.Config.Networking.PrivateNetwork.NATGateway.Enabled {
-			util.LogInfo("Creating NAT gateway", "nat gateway")
-			natGateway, err = c.createNATGateway(sshKey, network)
-			if err != nil {
-				return fmt.Errorf("failed to create NAT gateway: %w", err)
-			}
-			util.LogSuccess(fmt.Sprintf("NAT gateway created: %s", natGateway.Name), "nat gateway")
-
-			// Wait for NAT gateway to be ready
-			spinner := util.NewSpinner("Waiting for NAT gateway to be ready", "nat gateway")
-			spinner.Start()
-			if err := c.waitForNodes([]*hcloud.Server{natGateway}); err != nil {
+		if c.isNATGatewayEnabled() {
+			err := c.runStep(stepNATGateway, func() error {
+				util.LogInfo("Creating NAT gateway", "nat gateway")
+				created, err := c.createNATGateway(sshKey, network)
+				if err != nil {
+					return fmt.Errorf("failed to create NAT gateway: %w", err)
+				}
+				util.LogSuccess(fmt.Sprintf("NAT gateway created: %s", created.Name), "nat gateway")
+
+				// Wait for NAT gateway to be ready
+				spinner := util.NewSpinner("Waiting for NAT gateway to be ready", "nat gateway")
+				spinner.Start()
+				if err := c.waitForNodes([]*hcloud.Server{created}); err != nil {
+					spinner.Stop(true)
+					return fmt.Errorf("failed waiting for NAT gateway: %w", err)
+				}
 				spinner.Stop(true)
-				return fmt.Errorf("failed waiting for NAT gateway: %w", err)
-			}
-			spinner.Stop(true)
-			util.LogSuccess("NAT gateway is ready", "nat gateway")
+				util.LogSuccess("NAT gateway is ready", "nat gateway")
 
-			// Step 2.2: Add route to network via NAT gateway
-			util.LogInfo("Adding default route via NAT gateway to private network", "nat gateway")
-			if err := c.addNATGatewayRoute(network, natGateway); err != nil {
-				return fmt.Errorf("failed to add NAT gateway route: %w", err)
+				// Step 2.2: Add route to network via NAT gateway
+				util.LogInfo("Adding default route via NAT gateway to private network", "nat gateway")
+				refreshed, err := c.HetznerClient.GetServer(c.ctx, created.Name)
+				if err != nil {
+					return fmt.Errorf("failed to refresh NAT gateway: %w", err)
+				}
+				if err := c.addNATGatewayRoute(network, refreshed); err != nil {
+					return fmt.Errorf("failed to add NAT gateway route: %w", err)
+				}
+				util.LogSuccess("Default route via NAT gateway added to private network", "nat gateway")
+				return nil
+			}, func() (bool, error) {
+				return c.verifyNATGateway(&natGateway)
+			})
+			if err != nil {
+				return nil, err
 			}
-			util.LogSuccess("Default route via NAT gateway added to private network", "nat gateway")
 
 			// Step 2.3: Configure SSH to use NAT gateway as bastion host
 			bastionIP, err := GetServerPublicIP(natGateway)
 			if err != nil {
-				return fmt.Errorf("failed to get NAT gateway public IP: %w", err)
+				return nil, fmt.Errorf("failed to get NAT gateway public IP: %w", err)
 			}
 			c.SSHClient.SetBastion(bastionIP, c.Config.Networking.SSH.Port)
 			util.LogInfo(fmt.Sprintf("Using NAT gateway %s as SSH bastion host", bastionIP), "nat gateway")
@@ -137,204 +460,366 @@ func (c *CreatorEnhanced) Run() error {
 	}
 
 	// Step 3: Create master nodes
-	util.LogInfo(fmt.Sprintf("Creating %d master node(s)", c.Config.MastersPool.InstanceCount), "master")
-	masters, err := c.createMasterNodes(sshKey, network)
+	var masters []*hcloud.Server
+	err = c.runStep(stepMasters, func() error {
+		util.LogInfo(fmt.Sprintf("Creating %d master node(s)", c.Config.MastersPool.InstanceCount), "master")
+		created, err := c.createMasterNodes(sshKey, network)
+		if err != nil {
+			return fmt.Errorf("failed to create master nodes: %w", err)
+		}
+		util.LogSuccess(fmt.Sprintf("Created %d master node(s)", len(created)), "master")
+		return nil
+	}, func() (bool, error) {
+		return c.verifyMasters(&masters)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create master nodes: %w", err)
+		return nil, err
 	}
-	util.LogSuccess(fmt.Sprintf("Created %d master node(s)", len(masters)), "master")
 
 	// Step 4: Create firewall for cluster
-	util.LogInfo("Creating firewall", "firewall")
-	if err := c.createFirewall(network, masters); err != nil {
-		return fmt.Errorf("failed to create firewall: %w", err)
-	}
-
-	// Step 5: Wait for masters to be ready
-	spinner := util.NewSpinner("Waiting for master nodes to be ready", "master")
-	spinner.Start()
-	if err := c.waitForNodes(masters); err != nil {
-		spinner.Stop(true)
-		return fmt.Errorf("failed waiting for masters: %w", err)
+	err = c.runStep(stepFirewall, func() error {
+		util.LogInfo("Creating firewall", "firewall")
+		if err := c.createFirewall(network, masters); err != nil {
+			return fmt.Errorf("failed to create firewall: %w", err)
+		}
+		return nil
+	}, c.verifyFirewall)
+	if err != nil {
+		return nil, err
 	}
-	spinner.Stop(true)
-	util.LogSuccess("Master nodes are ready", "master")
 
-	// Step 5a: Create API load balancer BEFORE installing k3s (if configured)
+	// Step 4a: Create API load balancer BEFORE installing k3s (if configured)
 	// This ensures the load balancer IP can be included in the TLS SANs
 	var apiLoadBalancer *hcloud.LoadBalancer
 	if c.Config.CreateLoadBalancerForKubernetesAPI {
-		util.LogInfo("Creating load balancer for Kubernetes API", "load balancer")
-		networkMgr := NewNetworkResourceManager(c.Config, c.HetznerClient)
+		err := c.runStep(stepAPILoadBalancer, func() error {
+			util.LogInfo("Creating load balancer for Kubernetes API", "load balancer")
+			networkMgr := NewNetworkResourceManager(c.Config, c.HetznerClient)
 
-		// Use the first master's location as default for load balancer
-		location := c.Config.MastersPool.Locations[0]
+			// Use the first master's location as default for load balancer
+			location := c.Config.MastersPool.Locations[0]
 
-		lb, err := networkMgr.CreateAPILoadBalancer(masters, location, network)
+			if _, err := networkMgr.CreateAPILoadBalancer(masters, location, network); err != nil {
+				return fmt.Errorf("failed to create API load balancer: %w", err)
+			}
+			return nil
+		}, func() (bool, error) {
+			lb, err := c.HetznerClient.GetLoadBalancer(c.ctx, fmt.Sprintf("%s-api-lb", c.Config.ClusterName))
+			apiLoadBalancer = lb
+			return lb != nil, err
+		})
 		if err != nil {
-			return fmt.Errorf("failed to create API load balancer: %w", err)
+			return nil, err
 		}
-		apiLoadBalancer = lb
 	}
 
-	// Step 6: Install k3s on first master
-	spinner = util.NewSpinner("Installing k3s on first master", "master")
-	spinner.Start()
-	if err := c.installK3sOnFirstMaster(masters[0], masters, apiLoadBalancer); err != nil {
-		spinner.Stop(true)
-		return fmt.Errorf("failed to install k3s on first master: %w", err)
+	// Step 4b: Create the API floating IP as an alternative to the load balancer
+	// Like the load balancer IP, it must exist before k3s so it is part of the TLS SANs
+	var apiFloatingIP *hcloud.FloatingIP
+	if c.Config.APIFloatingIP.Enabled {
+		err := c.runStep(stepAPIFloatingIP, func() error {
+			util.LogInfo("Creating floating IP for Kubernetes API", "floating ip")
+			if _, err := c.ensureAPIFloatingIP(masters[0]); err != nil {
+				return fmt.Errorf("failed to create API floating IP: %w", err)
+			}
+			return nil
+		}, func() (bool, error) {
+			fip, err := c.HetznerClient.GetFloatingIP(c.ctx, c.Config.APIFloatingIP.Name(c.Config.ClusterName))
+			apiFloatingIP = fip
+			return fip != nil && fip.Server != nil, err
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
-	spinner.Stop(true)
-	util.LogSuccess("K3s installed on first master", "master")
 
-	// Step 7: Install k3s on additional masters (if any) - in parallel
-	if len(masters) > 1 {
-		spinner := util.NewSpinner(fmt.Sprintf("Installing k3s on %d additional master(s)", len(masters)-1), "master")
-		spinner.Start()
+	return &clusterInfrastructure{
+		SSHKey:          sshKey,
+		Network:         network,
+		Masters:         masters,
+		APILoadBalancer: apiLoadBalancer,
+		APIFloatingIP:   apiFloatingIP,
+	}, nil
+}
 
-		var wg sync.WaitGroup
-		var mu sync.Mutex
-		var errors []error
+// runStep runs a creation step and records its outcome in the state file
+// verify checks the step's postcondition and loads the resources later steps depend on;
+// it is called after the step runs and, on resume, instead of running a completed step.
+func (c *CreatorEnhanced) runStep(name string, run func() error, verify func() (bool, error)) error {
+	if c.Resume && c.state.IsCompleted(name) {
+		ok := true
+		if verify != nil {
+			var err error
+			ok, err = verify()
+			if err != nil {
+				return fmt.Errorf("failed to verify step %s: %w", name, err)
+			}
+		}
+		if ok {
+			util.LogInfo(fmt.Sprintf("Step %s already completed, skipping", name), "resume")
+			return nil
+		}
+		util.LogWarning(fmt.Sprintf("Step %s was completed but its postcondition no longer holds, running it again", name), "resume")
+	}
 
-		for i := 1; i < len(masters); i++ {
-			wg.Add(1)
-			go func(index int) {
-				defer wg.Done()
-				if err := c.installK3sOnAdditionalMaster(masters[index], masters[0], masters, apiLoadBalancer); err != nil {
-					mu.Lock()
-					errors = append(errors, fmt.Errorf("failed to install k3s on master %d: %w", index+1, err))
-					mu.Unlock()
-				}
-			}(i)
+	stepErr := run()
+	if stepErr == nil && verify != nil {
+		ok, err := verify()
+		if err != nil {
+			stepErr = fmt.Errorf("failed to verify step %s: %w", name, err)
+		} else if !ok {
+			stepErr = fmt.Errorf("step %s finished but its postcondition does not hold", name)
 		}
+	}
+
+	if stepErr != nil {
+		c.state.MarkFailed(name, stepErr)
+	} else {
+		c.state.MarkCompleted(name)
+	}
+	if err := c.state.Save(); err != nil {
+		util.LogWarning(fmt.Sprintf("Failed to save creation state: %v", err), "resume")
+	}
 
-		wg.Wait()
-		hasErrors := len(errors) > 0
-		spinner.Stop(hasErrors)
+	if stepErr != nil {
+		util.LogError(fmt.Sprintf("Step %s failed, fix the problem and re-run create with --resume", name), "resume")
+	}
+	return stepErr
+}
+
+// verifyNATGateway checks that the NAT gateway is running and routes the private network
+func (c *CreatorEnhanced) verifyNATGateway(natGateway **hcloud.Server) (bool, error) {
+	server, err := c.HetznerClient.GetServer(c.ctx, fmt.Sprintf("%s-nat-gateway", c.Config.ClusterName))
+	if err != nil || server == nil {
+		return false, err
+	}
+	*natGateway = server
+	if server.Status != hcloud.ServerStatusRunning {
+		return false, nil
+	}
+
+	network, err := c.HetznerClient.GetNetwork(c.ctx, c.Config.ClusterName)
+	if err != nil || network == nil {
+		return false, err
+	}
+	for _, route := range network.Routes {
+		if route.Destination != nil && route.Destination.String() == defaultRouteDestination.String() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-		if hasErrors {
-			return fmt.Errorf("errors installing k3s on additional masters: %v", errors)
+// verifyMasters checks that all configured master servers exist and loads them in order
+func (c *CreatorEnhanced) verifyMasters(masters *[]*hcloud.Server) (bool, error) {
+	found := make([]*hcloud.Server, 0, c.Config.MastersPool.InstanceCount)
+	for i := 0; i < c.Config.MastersPool.InstanceCount; i++ {
+		server, err := c.HetznerClient.GetServer(c.ctx, fmt.Sprintf("%s-master-%d", c.Config.ClusterName, i+1))
+		if err != nil || server == nil {
+			return false, err
 		}
+		found = append(found, server)
+	}
+	*masters = found
+	return true, nil
+}
 
-		util.LogSuccess(fmt.Sprintf("K3s installed on %d additional master(s)", len(masters)-1), "master")
+// verifyFirewall checks that the cluster firewall exists unless a local firewall is used
+func (c *CreatorEnhanced) verifyFirewall() (bool, error) {
+	if !c.Config.Networking.PrivateNetwork.Enabled && c.Config.Networking.PublicNetwork.UseLocalFirewall {
+		return true, nil
 	}
+	firewall, err := c.HetznerClient.GetFirewall(c.ctx, fmt.Sprintf("%s-firewall", c.Config.ClusterName))
+	return firewall != nil, err
+}
 
-	// Step 8: Create worker nodes (if configured)
-	// Only create nodes for static (non-autoscaling) pools.
-	// Autoscaling pools will be managed entirely by the cluster autoscaler,
-	// which will create nodes from 0 up to max_instances as needed.
-	totalWorkers := 0
-	for _, pool := range c.staticPools {
-		totalWorkers += pool.InstanceCount
+// verifyGlobalLoadBalancer checks that the global load balancer exists
+func (c *CreatorEnhanced) verifyGlobalLoadBalancer() (bool, error) {
+	lbName := fmt.Sprintf("%s-global-lb", c.Config.ClusterName)
+	if c.Config.LoadBalancer.Name != nil {
+		lbName = *c.Config.LoadBalancer.Name
 	}
+	lb, err := c.HetznerClient.GetLoadBalancer(c.ctx, lbName)
+	return lb != nil, err
+}
 
-	if len(c.staticPools) > 0 && totalWorkers > 0 {
-		util.LogInfo(fmt.Sprintf("Creating %d worker node(s) across %d static pool(s)", totalWorkers, len(c.staticPools)), "worker")
-		workers, err := c.createWorkerNodesFromPools(sshKey, network, c.staticPools)
+// verifyServersReachable checks that servers are running and accept SSH connections
+func (c *CreatorEnhanced) verifyServersReachable(servers []*hcloud.Server) bool {
+	for _, server := range servers {
+		if server.Status != hcloud.ServerStatusRunning {
+			return false
+		}
+		ip, err := GetServerSSHIP(server)
 		if err != nil {
-			return fmt.Errorf("failed to create worker nodes: %w", err)
+			return false
 		}
+		output, err := c.SSHClient.Run(c.ctx, ip, c.Config.Networking.SSH.Port, "echo ready", c.Config.Networking.SSH.UseAgent)
+		if err != nil || strings.TrimSpace(output) != "ready" {
+			return false
+		}
+	}
+	return true
+}
 
-		// Step 9: Wait for workers and install k3s
-		if len(workers) > 0 {
-			spinner := util.NewSpinner("Waiting for worker nodes to be ready", "worker")
-			spinner.Start()
-			if err := c.waitForNodes(workers); err != nil {
-				spinner.Stop(true)
-				return fmt.Errorf("failed waiting for workers: %w", err)
+// verifyK3sInstalled checks that the k3s service is active on all servers
+func (c *CreatorEnhanced) verifyK3sInstalled(servers []*hcloud.Server) bool {
+	for _, server := range servers {
+		ip, err := GetServerSSHIP(server)
+		if err != nil || !c.isK3sInstalled(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// findStaticWorkers returns the existing servers of all static worker pools
+func (c *CreatorEnhanced) findStaticWorkers() ([]*hcloud.Server, error) {
+	var workers []*hcloud.Server
+	for poolIdx, pool := range c.staticPools {
+		poolName := workerPoolName(pool, poolIdx)
+		for i := 0; i < pool.InstanceCount; i++ {
+			server, err := c.HetznerClient.GetServer(c.ctx, fmt.Sprintf("%s-worker-%s-%d", c.Config.ClusterName, poolName, i+1))
+			if err != nil {
+				return nil, err
 			}
-			spinner.Stop(true)
-			util.LogSuccess("Worker nodes are ready", "worker")
+			if server != nil {
+				workers = append(workers, server)
+			}
+		}
+	}
+	return workers, nil
+}
 
-			spinner = util.NewSpinner("Installing k3s on worker nodes", "worker")
-			spinner.Start()
+// adoptClusterToken switches to the token the first master was installed with
+// A resumed or repeated run would otherwise join nodes with a token the cluster does not know.
+func (c *CreatorEnhanced) adoptClusterToken(firstMaster *hcloud.Server) error {
+	ip, err := GetServerSSHIP(firstMaster)
+	if err != nil {
+		return err
+	}
 
-			var wg sync.WaitGroup
-			var mu sync.Mutex
-			var errors []error
+	output, err := c.SSHClient.Run(c.ctx, ip, c.Config.Networking.SSH.Port, k3sServerTokenCheckCmd, c.Config.Networking.SSH.UseAgent)
+	if err != nil || strings.TrimSpace(output) != "exists" {
+		return nil
+	}
 
-			for _, worker := range workers {
-				wg.Add(1)
-				go func(w *hcloud.Server) {
-					defer wg.Done()
-					if err := c.installK3sOnWorker(w, masters[0]); err != nil {
-						mu.Lock()
-						errors = append(errors, fmt.Errorf("failed to install k3s on worker %s: %w", w.Name, err))
-						mu.Unlock()
-					}
-				}(worker)
-			}
+	token, err := c.SSHClient.Run(c.ctx, ip, c.Config.Networking.SSH.Port, k3sServerTokenReadCmd, c.Config.Networking.SSH.UseAgent)
+	if err != nil {
+		return fmt.Errorf("failed to read k3s token from first master: %w", err)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" || token == c.k3sToken {
+		return nil
+	}
 
-			wg.Wait()
-			hasErrors := len(errors) > 0
-			spinner.Stop(hasErrors)
+	c.k3sToken = token
+	c.state.K3sToken = token
+	if err := c.state.Save(); err != nil {
+		util.LogWarning(fmt.Sprintf("Failed to save creation state: %v", err), "resume")
+	}
+	return nil
+}
 
-			if hasErrors {
-				return fmt.Errorf("errors installing k3s on workers: %v", errors)
-			}
+// createAndJoinWorkers creates the static worker nodes and installs k3s on them
+func (c *CreatorEnhanced) createAndJoinWorkers(sshKey *hcloud.SSHKey, network *hcloud.Network, firstMaster *hcloud.Server, totalWorkers int) error {
+	util.LogInfo(fmt.Sprintf("Creating %d worker node(s) across %d static pool(s)", totalWorkers, len(c.staticPools)), "worker")
+	workers, err := c.createWorkerNodesFromPools(sshKey, network, c.staticPools)
+	if err != nil {
+		return fmt.Errorf("failed to create worker nodes: %w", err)
+	}
 
-			util.LogSuccess("K3s installed on all worker nodes", "worker")
-		}
+	// Step 9: Wait for workers and install k3s
+	if len(workers) == 0 {
+		return nil
 	}
 
-	// Step 10: Create global load balancer (if enabled)
-	if c.Config.LoadBalancer.Enabled {
-		networkMgr := NewNetworkResourceManager(c.Config, c.HetznerClient)
+	spinner := util.NewSpinner("Waiting for worker nodes to be ready", "worker")
+	spinner.Start()
+	if err := c.waitForNodes(workers); err != nil {
+		spinner.Stop(true)
+		return fmt.Errorf("failed waiting for workers: %w", err)
+	}
+	spinner.Stop(true)
+	util.LogSuccess("Worker nodes are ready", "worker")
 
-		// Use the first master's location as default for load balancer
-		location := c.Config.MastersPool.Locations[0]
+	spinner = util.NewSpinner("Installing k3s on worker nodes", "worker")
+	spinner.Start()
 
-		// Step 10a: Create DNS zone (if enabled) - must be created before SSL certificate
-		if c.Config.DNSZone.Enabled && c.Config.Domain != "" {
-			util.LogInfo("Creating DNS zone for domain", "dns")
-			_, err := networkMgr.CreateDNSZone()
-			if err != nil {
-				return fmt.Errorf("failed to create DNS zone: %w", err)
-			}
-		}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errors []error
 
-		// Step 10b: Create SSL certificate (if enabled) - must be created before load balancer
-		var certificate *hcloud.Certificate
-		if c.Config.SSLCertificate.Enabled {
-			util.LogInfo("Creating SSL certificate", "ssl")
-			cert, err := networkMgr.CreateSSLCertificate()
-			if err != nil {
-				return fmt.Errorf("failed to create SSL certificate: %w", err)
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(w *hcloud.Server) {
+			defer wg.Done()
+			if err := c.installK3sOnWorker(w, firstMaster); err != nil {
+				mu.Lock()
+				errors = append(errors, fmt.Errorf("failed to install k3s on worker %s: %w", w.Name, err))
+				mu.Unlock()
 			}
-			certificate = cert
-		}
+		}(worker)
+	}
+
+	wg.Wait()
+	hasErrors := len(errors) > 0
+	spinner.Stop(hasErrors)
+
+	if hasErrors {
+		return fmt.Errorf("errors installing k3s on workers: %v", errors)
+	}
 
-		// Step 10c: Create global load balancer with certificate attached
-		util.LogInfo("Creating global load balancer for application traffic", "load balancer")
-		_, err := networkMgr.CreateGlobalLoadBalancer(network, location, certificate)
+	util.LogSuccess("K3s installed on all worker nodes", "worker")
+	return nil
+}
+
+// createGlobalLoadBalancer creates the DNS zone, SSL certificate and global load balancer
+func (c *CreatorEnhanced) createGlobalLoadBalancer(network *hcloud.Network) error {
+	networkMgr := NewNetworkResourceManager(c.Config, c.HetznerClient)
+
+	// Use the first master's location as default for load balancer
+	location := c.Config.MastersPool.Locations[0]
+
+	// Step 10a: Create DNS zone (if enabled) - must be created before SSL certificate
+	if c.Config.DNSZone.Enabled && c.Config.Domain != "" {
+		util.LogInfo("Creating DNS zone for domain", "dns")
+		_, err := networkMgr.CreateDNSZone()
 		if err != nil {
-			return fmt.Errorf("failed to create global load balancer: %w", err)
+			return fmt.Errorf("failed to create DNS zone: %w", err)
 		}
 	}
 
-	// Step 11: Retrieve kubeconfig
-	util.LogInfo("Retrieving kubeconfig", "kubeconfig")
-	if err := c.retrieveKubeconfig(masters[0], apiLoadBalancer); err != nil {
-		return fmt.Errorf("failed to retrieve kubeconfig: %w", err)
+	// Step 10b: Create SSL certificate (if enabled) - must be created before load balancer
+	var certificate *hcloud.Certificate
+	if c.Config.SSLCertificate.Enabled {
+		util.LogInfo("Creating SSL certificate", "ssl")
+		cert, err := networkMgr.CreateSSLCertificate()
+		if err != nil {
+			return fmt.Errorf("failed to create SSL certificate: %w", err)
+		}
+		certificate = cert
 	}
-	util.LogSuccess(fmt.Sprintf("Kubeconfig saved to: %s", c.Config.KubeconfigPath), "kubeconfig")
 
-	// Step 12: Install addons
-	// Use pre-computed autoscaling pools (already separated during initialization)
-	if err := c.installAddons(masters[0], masters, c.autoscalingPools); err != nil {
-		return fmt.Errorf("failed to install addons: %w", err)
+	// Step 10c: Create global load balancer with certificate attached
+	util.LogInfo("Creating global load balancer for application traffic", "load balancer")
+	_, err := networkMgr.CreateGlobalLoadBalancer(network, location, certificate)
+	if err != nil {
+		return fmt.Errorf("failed to create global load balancer: %w", err)
 	}
 
-	fmt.Println()
-	util.LogSuccess("Cluster creation completed successfully!", c.Config.ClusterName)
-	fmt.Println()
-
 	return nil
 }
 
 // createSSHKey creates and uploads SSH key to Hetzner
 func (c *CreatorEnhanced) createSSHKey() (*hcloud.SSHKey, error) {
-	pubKeyPath, _ := c.Config.Networking.SSH.ExpandedPublicKeyPath()
+	return ensureSSHKey(c.ctx, c.Config, c.HetznerClient)
+}
+
+// ensureSSHKey returns the SSH key of the cluster, uploading the public key if it
+// does not exist yet. Nodes trusting a user CA get the key too, as Hetzner sets and
+// emails a root password for servers created without one; sshd ignores it then.
+func ensureSSHKey(ctx context.Context, cfg *config.Main, hetznerClient hetzner.API) (*hcloud.SSHKey, error) {
+	pubKeyPath, _ := cfg.Networking.SSH.ExpandedPublicKeyPath()
 
 	// Check if file exists
 	if !util.FileExists(pubKeyPath) {
@@ -347,17 +832,17 @@ func (c *CreatorEnhanced) createSSHKey() (*hcloud.SSHKey, error) {
 		return nil, err
 	}
 
-	keyName := fmt.Sprintf("%s-ssh-key", c.Config.ClusterName)
+	keyName := fmt.Sprintf("%s-ssh-key", cfg.ClusterName)
 
 	// Check if key already exists
-	existingKey, err := c.HetznerClient.GetSSHKey(c.ctx, keyName)
+	existingKey, err := hetznerClient.GetSSHKey(ctx, keyName)
 	if err == nil && existingKey != nil {
 		util.LogInfo("SSH key already exists, using existing key", "ssh key")
 		return existingKey, nil
 	}
 
 	// Create SSH key
-	return c.HetznerClient.CreateSSHKey(c.ctx, hcloud.SSHKeyCreateOpts{
+	return hetznerClient.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{
 		Name:      keyName,
 		PublicKey: pubKeyContent,
 	})
@@ -432,7 +917,7 @@ func (c *CreatorEnhanced) createNATGateway(sshKey *hcloud.SSHKey, network *hclou
 	}
 
 	// Get image
-	image, err := c.HetznerClient.GetImage(c.ctx, c.Config.Image)
+	image, err := c.HetznerClient.GetImage(c.ctx, c.Config.Image, serverType.Architecture)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get image: %w", err)
 	}
@@ -457,16 +942,31 @@ func (c *CreatorEnhanced) createNATGateway(sshKey *hcloud.SSHKey, network *hclou
 		},
 	}
 
+	// Install a host key pinned before the gateway exists
+	opts.UserData, err = withHostKey(c.SSHClient, nodeName, cloudInitData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin host key of %s: %w", nodeName, err)
+	}
+
 	// Create server
 	util.LogInfo(fmt.Sprintf("Creating NAT gateway: %s in %s", nodeName, location), "nat gateway")
 	server, err := c.HetznerClient.CreateServer(c.ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create NAT gateway server %s: %w", nodeName, err)
 	}
+	warnIfRelocated(server, location, "nat gateway")
 
 	return server, nil
 }
 
+// warnIfRelocated warns when a server was created in another location than requested
+// This happens with location_fallback when the requested location has no capacity.
+func warnIfRelocated(server *hcloud.Server, location, scope string) {
+	if server.Location != nil && location != "" && server.Location.Name != location {
+		util.LogWarning(fmt.Sprintf("%s was created in %s because %s has no capacity", server.Name, server.Location.Name, location), scope)
+	}
+}
+
 var (
 	// defaultRouteDestination is the CIDR for all traffic (default route)
 	defaultRouteDestination = mustParseCIDR("0.0.0.0/0")
@@ -560,7 +1060,15 @@ func (c *CreatorEnhanced) createFirewall(network *hcloud.Network, masters []*hcl
 
 // generateNATGatewayCloudInit generates cloud-init user data for NAT gateway
 func (c *CreatorEnhanced) generateNATGatewayCloudInit() (string, error) {
-	return cloudinit.GenerateNATGatewayCloudInit(c.Config.Networking.PrivateNetwork.Subnet)
+	user, err := sshUser(c.Config)
+	if err != nil {
+		return "", err
+	}
+	caKey, err := userCAKey(c.Config)
+	if err != nil {
+		return "", err
+	}
+	return cloudinit.GenerateNATGatewayCloudInit(c.Config.Networking.PrivateNetwork.Subnet, user, caKey)
 }
 
 // generateCloudInit generates cloud-init user data for servers
@@ -568,18 +1076,36 @@ func (c *CreatorEnhanced) generateNATGatewayCloudInit() (string, error) {
 // Note: This uses override behavior (pool replaces root), not additive (pool + root).
 // Returns the pool value if set, otherwise falls back to root value.
 // The cluster autoscaler uses additive behavior because it has different requirements.
-func (c *CreatorEnhanced) generateCloudInit(pool *config.NodePool) (string, error) {
+// A node image built by hek3ster already contains the root-level packages and pre-k3s
+// commands, so they are left out; pool-level ones still run.
+// Volumes are the volumes the nodes mount at boot, nil if they have none.
+func (c *CreatorEnhanced) generateCloudInit(pool *config.NodePool, volumes *cloudinit.Volumes) (string, error) {
 	// Determine if local firewall should be used
 	useLocalFirewall := !c.Config.Networking.PrivateNetwork.Enabled && c.Config.Networking.PublicNetwork.UseLocalFirewall
 
+	var instanceType string
+	if pool != nil {
+		instanceType = pool.InstanceType
+	}
+	nodeImage, err := usesNodeImage(c.ctx, c.HetznerClient, c.Config.Image, instanceType)
+	if err != nil {
+		return "", err
+	}
+
 	// Merge packages: root-level + pool-level (pool overrides if specified)
 	packages := c.Config.AdditionalPackages
+	if nodeImage {
+		packages = nil
+	}
 	if pool != nil && pool.AdditionalPackages != nil {
 		packages = pool.AdditionalPackages
 	}
 
 	// Merge pre-k3s commands: root-level + pool-level (pool overrides if specified)
 	preK3sCommands := c.Config.AdditionalPreK3sCommands
+	if nodeImage {
+		preK3sCommands = nil
+	}
 	if pool != nil && pool.AdditionalPreK3sCommands != nil {
 		preK3sCommands = pool.AdditionalPreK3sCommands
 	}
@@ -590,8 +1116,19 @@ func (c *CreatorEnhanced) generateCloudInit(pool *config.NodePool) (string, erro
 		postK3sCommands = pool.AdditionalPostK3sCommands
 	}
 
+	user, err := sshUser(c.Config)
+	if err != nil {
+		return "", err
+	}
+	caKey, err := userCAKey(c.Config)
+	if err != nil {
+		return "", err
+	}
+
 	generator := cloudinit.NewGenerator(&cloudinit.Config{
 		SSHPort:                   c.Config.Networking.SSH.Port,
+		SSHUser:                   user,
+		SSHUserCAKey:              caKey,
 		Packages:                  packages,
 		AdditionalPreK3sCommands:  preK3sCommands,
 		AdditionalPostK3sCommands: postK3sCommands,
@@ -602,6 +1139,7 @@ func (c *CreatorEnhanced) generateCloudInit(pool *config.NodePool) (string, erro
 		ServiceCIDR:               c.Config.Networking.ServiceCIDR,
 		AllowedNetworksSSH:        c.Config.Networking.AllowedNetworks.SSH,
 		AllowedNetworksAPI:        c.Config.Networking.AllowedNetworks.API,
+		Volumes:                   volumes,
 	})
 
 	cloudInitYAML, err := generator.Generate()
@@ -615,11 +1153,17 @@ func (c *CreatorEnhanced) generateCloudInit(pool *config.NodePool) (string, erro
 // createMasterNodes creates master nodes in parallel
 func (c *CreatorEnhanced) createMasterNodes(sshKey *hcloud.SSHKey, network *hcloud.Network) ([]*hcloud.Server, error) {
 	// Generate cloud-init data once for all masters, using masters pool configuration
-	cloudInitData, err := c.generateCloudInit(&c.Config.MastersPool.NodePool)
+	cloudInitData, err := c.generateCloudInit(&c.Config.MastersPool.NodePool, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate cloud-init: %w", err)
 	}
 
+	// Spread masters across physical hosts if configured
+	placementGroup, err := c.masterPlacementGroup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create master placement group: %w", err)
+	}
+
 	// Create a slice to hold masters and a mutex to protect it
 	masters := make([]*hcloud.Server, c.Config.MastersPool.InstanceCount)
 	var wg sync.WaitGroup
@@ -664,7 +1208,7 @@ func (c *CreatorEnhanced) createMasterNodes(sshKey *hcloud.SSHKey, network *hclo
 			}
 
 			// Get image
-			image, err := c.HetznerClient.GetImage(c.ctx, c.Config.Image)
+			image, err := c.HetznerClient.GetImage(c.ctx, c.Config.Image, serverType.Architecture)
 			if err != nil {
 				mu.Lock()
 				errors = append(errors, fmt.Errorf("failed to get image: %w", err))
@@ -685,6 +1229,7 @@ func (c *CreatorEnhanced) createMasterNodes(sshKey *hcloud.SSHKey, network *hclo
 					"role":    "master",
 					"managed": "hek3ster",
 				},
+				PlacementGroup: placementGroup,
 			}
 
 			// Add network if enabled
@@ -700,6 +1245,27 @@ func (c *CreatorEnhanced) createMasterNodes(sshKey *hcloud.SSHKey, network *hclo
 				}
 			}
 
+			// Attach the master's primary IP, so it keeps its public IP when recreated
+			if c.Config.MastersPool.PrimaryIPsEnabled() {
+				publicNet, err := c.primaryIPPublicNet(nodeName, location, opts.Labels)
+				if err != nil {
+					mu.Lock()
+					errors = append(errors, fmt.Errorf("failed to allocate primary IP for %s: %w", nodeName, err))
+					mu.Unlock()
+					return
+				}
+				opts.PublicNet = publicNet
+			}
+
+			// Install a host key pinned before the master exists
+			opts.UserData, err = withHostKey(c.SSHClient, nodeName, cloudInitData)
+			if err != nil {
+				mu.Lock()
+				errors = append(errors, fmt.Errorf("failed to pin host key of %s: %w", nodeName, err))
+				mu.Unlock()
+				return
+			}
+
 			// Create server
 			util.LogInfo(fmt.Sprintf("Creating master: %s in %s", nodeName, location), "master")
 			server, err := c.HetznerClient.CreateServer(c.ctx, opts)
@@ -709,6 +1275,7 @@ func (c *CreatorEnhanced) createMasterNodes(sshKey *hcloud.SSHKey, network *hclo
 				mu.Unlock()
 				return
 			}
+			warnIfRelocated(server, location, "master")
 
 			mu.Lock()
 			masters[index] = server
@@ -744,18 +1311,31 @@ func (c *CreatorEnhanced) createWorkerNodesFromPools(sshKey *hcloud.SSHKey, netw
 
 	// Create workers in parallel
 	for poolIdx, pool := range pools {
+		poolName := workerPoolName(pool, poolIdx)
+		poolLabels := map[string]string{
+			"cluster": c.Config.ClusterName,
+			"role":    "worker",
+			"pool":    poolName,
+			"managed": "hek3ster",
+		}
+
 		// Generate cloud-init data for this specific pool
-		cloudInitData, err := c.generateCloudInit(&pool.NodePool)
+		cloudInitData, err := c.generateCloudInit(&pool.NodePool, poolVolumes(c.Config, pool, poolLabels))
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate cloud-init for pool: %w", err)
 		}
 
+		placementGroup, err := c.workerPlacementGroup(pool)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create placement group for pool: %w", err)
+		}
+
 		// Use instance_count for node creation (pools passed here should be static only)
 		nodeCount := pool.InstanceCount
 
 		for i := 0; i < nodeCount; i++ {
 			wg.Add(1)
-			go func(pIdx int, p config.WorkerNodePool, nodeIdx int, cloudInit string) {
+			go func(pIdx int, p config.WorkerNodePool, nodeIdx int, cloudInit string, placementGroup *hcloud.PlacementGroup) {
 				defer wg.Done()
 
 				poolName := p.Name
@@ -795,7 +1375,7 @@ func (c *CreatorEnhanced) createWorkerNodesFromPools(sshKey *hcloud.SSHKey, netw
 				}
 
 				// Get image
-				image, err := c.HetznerClient.GetImage(c.ctx, c.Config.Image)
+				image, err := c.HetznerClient.GetImage(c.ctx, c.Config.Image, serverType.Architecture)
 				if err != nil {
 					mu.Lock()
 					errors = append(errors, fmt.Errorf("failed to get image: %w", err))
@@ -817,6 +1397,7 @@ func (c *CreatorEnhanced) createWorkerNodesFromPools(sshKey *hcloud.SSHKey, netw
 						"pool":    *poolName,
 						"managed": "hek3ster",
 					},
+					PlacementGroup: placementGroup,
 				}
 
 				// Add network if enabled
@@ -832,6 +1413,40 @@ func (c *CreatorEnhanced) createWorkerNodesFromPools(sshKey *hcloud.SSHKey, netw
 					}
 				}
 
+				// Attach the worker's primary IP, so it keeps its public IP when recreated
+				if p.PrimaryIPsEnabled() {
+					publicNet, err := c.primaryIPPublicNet(nodeName, p.Location, opts.Labels)
+					if err != nil {
+						mu.Lock()
+						errors = append(errors, fmt.Errorf("failed to allocate primary IP for %s: %w", nodeName, err))
+						mu.Unlock()
+						return
+					}
+					opts.PublicNet = publicNet
+				}
+
+				// Attach the worker's volumes at creation, so cloud-init finds them at boot
+				if len(p.Volumes) > 0 {
+					volumes, err := c.ensureVolumes(nodeName, p, opts.Labels)
+					if err != nil {
+						mu.Lock()
+						errors = append(errors, fmt.Errorf("failed to create volumes for %s: %w", nodeName, err))
+						mu.Unlock()
+						return
+					}
+					opts.Volumes = volumes
+					opts.Automount = hcloud.Ptr(false)
+				}
+
+				// Install a host key pinned before the worker exists
+				opts.UserData, err = withHostKey(c.SSHClient, nodeName, cloudInit)
+				if err != nil {
+					mu.Lock()
+					errors = append(errors, fmt.Errorf("failed to pin host key of %s: %w", nodeName, err))
+					mu.Unlock()
+					return
+				}
+
 				// Create server
 				util.LogInfo(fmt.Sprintf("Creating worker: %s in %s", nodeName, p.Location), "worker")
 				server, err := c.HetznerClient.CreateServer(c.ctx, opts)
@@ -841,11 +1456,12 @@ func (c *CreatorEnhanced) createWorkerNodesFromPools(sshKey *hcloud.SSHKey, netw
 					mu.Unlock()
 					return
 				}
+				warnIfRelocated(server, p.Location, "worker")
 
 				mu.Lock()
 				workers = append(workers, server)
 				mu.Unlock()
-			}(poolIdx, pool, i, cloudInitData)
+			}(poolIdx, pool, i, cloudInitData, placementGroup)
 		}
 	}
 
@@ -890,6 +1506,11 @@ func (c *CreatorEnhanced) waitForNodes(servers []*hcloud.Server) error {
 			return err
 		}
 
+		// The server may reuse the address of a deleted one, whose host key must not be trusted
+		if err := bindKnownHost(c.SSHClient, server, c.Config.Networking.SSH.Port); err != nil {
+			return err
+		}
+
 		err = c.SSHClient.WaitForInstance(c.ctx, ip, c.Config.Networking.SSH.Port, "echo ready", "ready", c.Config.Networking.SSH.UseAgent, 30)
 		if err != nil {
 			return fmt.Errorf("node %s not ready: %w", server.Name, err)
@@ -1073,7 +1694,7 @@ func (c *CreatorEnhanced) checkNATConnectivityIfNeeded(ip string, nodeType strin
 }
 
 // installK3sOnFirstMaster installs k3s on the first master
-func (c *CreatorEnhanced) installK3sOnFirstMaster(server *hcloud.Server, allMasters []*hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer) error {
+func (c *CreatorEnhanced) installK3sOnFirstMaster(server *hcloud.Server, allMasters []*hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer, apiFloatingIP *hcloud.FloatingIP) error {
 	ip, err := GetServerSSHIP(server)
 	if err != nil {
 		return err
@@ -1093,7 +1714,7 @@ func (c *CreatorEnhanced) installK3sOnFirstMaster(server *hcloud.Server, allMast
 	}
 
 	// Generate TLS SANs for all masters
-	tlsSans, err := GenerateTLSSans(c.Config, allMasters, server, apiLoadBalancer)
+	tlsSans, err := GenerateTLSSans(c.Config, allMasters, server, apiLoadBalancer, apiFloatingIP)
 	if err != nil {
 		return fmt.Errorf("failed to generate TLS SANs: %w", err)
 	}
@@ -1168,7 +1789,7 @@ func (c *CreatorEnhanced) installK3sOnFirstMaster(server *hcloud.Server, allMast
 }
 
 // installK3sOnAdditionalMaster installs k3s on additional masters
-func (c *CreatorEnhanced) installK3sOnAdditionalMaster(server *hcloud.Server, firstMaster *hcloud.Server, allMasters []*hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer) error {
+func (c *CreatorEnhanced) installK3sOnAdditionalMaster(server *hcloud.Server, firstMaster *hcloud.Server, allMasters []*hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer, apiFloatingIP *hcloud.FloatingIP) error {
 	ip, err := GetServerSSHIP(server)
 	if err != nil {
 		return err
@@ -1193,7 +1814,7 @@ func (c *CreatorEnhanced) installK3sOnAdditionalMaster(server *hcloud.Server, fi
 	}
 
 	// Generate TLS SANs for all masters
-	tlsSans, err := GenerateTLSSans(c.Config, allMasters, firstMaster, apiLoadBalancer)
+	tlsSans, err := GenerateTLSSans(c.Config, allMasters, firstMaster, apiLoadBalancer, apiFloatingIP)
 	if err != nil {
 		return fmt.Errorf("failed to generate TLS SANs: %w", err)
 	}
@@ -1323,10 +1944,11 @@ func (c *CreatorEnhanced) installK3sOnWorker(server *hcloud.Server, firstMaster
 // retrieveKubeconfig retrieves kubeconfig from the first master and configures the API server address
 // The API server address selection logic:
 // 1. If API load balancer exists: use load balancer's public IP
-// 2. Else if server has public IP: use server's public IP
-// 3. Else if NAT gateway is enabled: use server's private IP (requires network access or SSH tunnel)
-// 4. Else: error - no accessible API endpoint
-func (c *CreatorEnhanced) retrieveKubeconfig(server *hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer) error {
+// 2. Else if API floating IP exists: use the floating IP
+// 3. Else if server has public IP: use server's public IP
+// 4. Else if NAT gateway is enabled: use server's private IP (requires network access or SSH tunnel)
+// 5. Else: error - no accessible API endpoint
+func (c *CreatorEnhanced) retrieveKubeconfig(server *hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer, apiFloatingIP *hcloud.FloatingIP) error {
 	ip, err := GetServerSSHIP(server)
 	if err != nil {
 		return err
@@ -1357,14 +1979,18 @@ func (c *CreatorEnhanced) retrieveKubeconfig(server *hcloud.Server, apiLoadBalan
 	if apiLoadBalancer != nil && apiLoadBalancer.PublicNet.IPv4.IP != nil {
 		serverIP = apiLoadBalancer.PublicNet.IPv4.IP.String()
 		util.LogInfo(fmt.Sprintf("Using API load balancer IP for kubeconfig: %s", serverIP), "kubeconfig")
+	} else if apiFloatingIP != nil && apiFloatingIP.IP != nil {
+		// Priority 2: API floating IP - follows the healthy master
+		serverIP = apiFloatingIP.IP.String()
+		util.LogInfo(fmt.Sprintf("Using API floating IP for kubeconfig: %s", serverIP), "kubeconfig")
 	} else if server.PublicNet.IPv4.IP != nil {
-		// Priority 2: Server has public IP - use it directly
+		// Priority 3: Server has public IP - use it directly
 		serverIP = server.PublicNet.IPv4.IP.String()
 		util.LogInfo(fmt.Sprintf("Using master public IP for kubeconfig: %s", serverIP), "kubeconfig")
 	} else if c.Config.Networking.PrivateNetwork.Enabled &&
 		c.Config.Networking.PrivateNetwork.NATGateway != nil &&
 		c.Config.Networking.PrivateNetwork.NATGateway.Enabled {
-		// Priority 3: Server has no public IP and NAT gateway is enabled
+		// Priority 4: Server has no public IP and NAT gateway is enabled
 		// Use master's private IP - requires network access (VPN/tunnel) or kubectl via SSH
 		if len(server.PrivateNet) > 0 {
 			serverIP = server.PrivateNet[0].IP.String()
@@ -1375,7 +2001,7 @@ func (c *CreatorEnhanced) retrieveKubeconfig(server *hcloud.Server, apiLoadBalan
 			return fmt.Errorf("server %s has no private IP address", server.Name)
 		}
 	} else {
-		// Priority 4: No accessible endpoint
+		// Priority 5: No accessible endpoint
 		return fmt.Errorf("server %s has no accessible IP address for API access (no public IP, no API load balancer, and NAT gateway is not enabled)", server.Name)
 	}
 
@@ -1391,6 +2017,11 @@ func (c *CreatorEnhanced) retrieveKubeconfig(server *hcloud.Server, apiLoadBalan
 
 // installAddons installs cluster addons
 func (c *CreatorEnhanced) installAddons(firstMaster *hcloud.Server, masters []*hcloud.Server, autoscalingPools []config.WorkerNodePool) error {
+	// The cluster autoscaler places new nodes in the pools' placement groups
+	if err := c.ensureAutoscalingPlacementGroups(autoscalingPools); err != nil {
+		return err
+	}
+
 	// Get SSH IP (for external connections)
 	masterSSHIP, err := GetServerSSHIP(firstMaster)
 	if err != nil {
@@ -1404,6 +2035,16 @@ func (c *CreatorEnhanced) installAddons(firstMaster *hcloud.Server, masters []*h
 	}
 
 	installer := addons.NewInstaller(c.Config, c.SSHClient)
+	if len(autoscalingPools) > 0 {
+		image := c.Config.Image
+		if c.Config.AutoscalingImage != "" {
+			image = c.Config.AutoscalingImage
+		}
+		installer.AutoscalingNodeImage, err = usesNodeImage(c.ctx, c.HetznerClient, image, autoscalingPools[0].InstanceType)
+		if err != nil {
+			return err
+		}
+	}
 	return installer.InstallAll(firstMaster, masters, autoscalingPools, masterSSHIP, masterClusterIP, c.k3sToken)
 }
 
@@ -1,12 +1,19 @@
 package cluster
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
 )
 
 // TestAutoscalingPoolFiltering verifies that autoscaling pools are correctly filtered out
@@ -625,3 +632,165 @@ func TestConcurrentMapAccess(t *testing.T) {
 		}
 	})
 }
+
+// newTestProvisioner returns a creator for a private-network cluster with three masters
+// and an API load balancer, backed by the given fake API
+func newTestProvisioner(t *testing.T, api hetzner.API) *CreatorEnhanced {
+	t.Helper()
+	delay := loadBalancerStabilizationDelay
+	loadBalancerStabilizationDelay = 0
+	t.Cleanup(func() { loadBalancerStabilizationDelay = delay })
+
+	pubKeyPath := filepath.Join(t.TempDir(), "id_ed25519.pub")
+	if err := os.WriteFile(pubKeyPath, []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKey test\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Main{
+		ClusterName:                        "test",
+		Image:                              "ubuntu-24.04",
+		CreateLoadBalancerForKubernetesAPI: true,
+	}
+	cfg.MastersPool.InstanceType = "cpx21"
+	cfg.MastersPool.InstanceCount = 3
+	cfg.MastersPool.Locations = []string{"fsn1"}
+	cfg.Networking.SSH.Port = 22
+	cfg.Networking.SSH.PublicKeyPath = pubKeyPath
+	cfg.Networking.PrivateNetwork.Enabled = true
+	cfg.Networking.PrivateNetwork.Subnet = "10.0.0.0/16"
+
+	state, err := NewCreateState(cfg.ClusterName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHostsPath, err := KnownHostsPath(cfg.ClusterName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHosts, err := util.LoadKnownHosts(knownHostsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshClient := util.NewSSH("", pubKeyPath)
+	sshClient.SetKnownHosts(knownHosts)
+	return &CreatorEnhanced{Config: cfg, HetznerClient: api, SSHClient: sshClient, ctx: context.Background(), state: state}
+}
+
+// TestProvisionInfrastructure runs the Hetzner-side create steps against the fake API
+func TestProvisionInfrastructure(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fake := hetznertest.NewFake()
+	c := newTestProvisioner(t, fake)
+
+	infra, err := c.provisionInfrastructure()
+	if err != nil {
+		t.Fatalf("provisionInfrastructure() error = %v", err)
+	}
+
+	for _, step := range []string{stepSSHKey, stepNetwork, stepMasters, stepFirewall, stepAPILoadBalancer} {
+		if !c.state.IsCompleted(step) {
+			t.Errorf("step %s not recorded as completed", step)
+		}
+	}
+	if c.state.IsCompleted(stepNATGateway) || c.state.IsCompleted(stepAPIFloatingIP) {
+		t.Error("disabled NAT gateway or floating IP steps recorded")
+	}
+
+	if infra.SSHKey == nil || infra.SSHKey.Name != "test-ssh-key" {
+		t.Errorf("SSH key = %+v, want test-ssh-key", infra.SSHKey)
+	}
+	if infra.Network == nil || infra.Network.Name != "test" {
+		t.Fatalf("network = %+v, want test", infra.Network)
+	}
+	if len(infra.Masters) != 3 {
+		t.Fatalf("masters = %d, want 3", len(infra.Masters))
+	}
+	for i, master := range infra.Masters {
+		if want := fmt.Sprintf("test-master-%d", i+1); master.Name != want {
+			t.Errorf("master %d = %s, want %s", i, master.Name, want)
+		}
+		if master.Labels["role"] != "master" || master.Labels["cluster"] != "test" {
+			t.Errorf("%s labels = %v", master.Name, master.Labels)
+		}
+		if len(master.PrivateNet) != 1 || master.PrivateNet[0].Network.ID != infra.Network.ID {
+			t.Errorf("%s is not attached to the cluster network", master.Name)
+		}
+	}
+	if len(fake.Firewalls()) != 1 || fake.Firewalls()[0].Name != "test-firewall" {
+		t.Errorf("firewalls = %v, want test-firewall", fake.Firewalls())
+	}
+	if infra.APILoadBalancer == nil || infra.APILoadBalancer.Name != "test-api-lb" {
+		t.Fatalf("API load balancer = %+v, want test-api-lb", infra.APILoadBalancer)
+	}
+
+	// Every master has a host key pinned before it exists, which moves to its address
+	knownHosts := c.SSHClient.KnownHosts()
+	data, err := os.ReadFile(knownHosts.Path())
+	if err != nil {
+		t.Fatalf("failed to read known hosts: %v", err)
+	}
+	for _, master := range infra.Masters {
+		if !strings.Contains(string(data), master.Name+" ssh-ed25519 ") {
+			t.Errorf("no host key expected for %s:\n%s", master.Name, data)
+		}
+		if err := bindKnownHost(c.SSHClient, master, c.Config.Networking.SSH.Port); err != nil {
+			t.Fatalf("bindKnownHost(%s) error = %v", master.Name, err)
+		}
+	}
+	data, err = os.ReadFile(knownHosts.Path())
+	if err != nil {
+		t.Fatalf("failed to read known hosts: %v", err)
+	}
+	for _, master := range infra.Masters {
+		line := fmt.Sprintf("%s ssh-ed25519 ", master.PublicNet.IPv4.IP)
+		if strings.Contains(string(data), master.Name+" ") || !strings.Contains(string(data), line) {
+			t.Errorf("host key of %s not moved to %s:\n%s", master.Name, master.PublicNet.IPv4.IP, data)
+		}
+	}
+
+	// Resuming finds every resource and creates nothing again
+	c.Resume = true
+	again, err := c.provisionInfrastructure()
+	if err != nil {
+		t.Fatalf("resumed provisionInfrastructure() error = %v", err)
+	}
+	if len(fake.Servers()) != 3 || len(fake.SSHKeys()) != 1 || len(fake.Networks()) != 1 || len(fake.LoadBalancers()) != 1 {
+		t.Errorf("resume created resources again: %d servers, %d keys, %d networks, %d load balancers",
+			len(fake.Servers()), len(fake.SSHKeys()), len(fake.Networks()), len(fake.LoadBalancers()))
+	}
+	if again.APILoadBalancer == nil || again.APILoadBalancer.ID != infra.APILoadBalancer.ID {
+		t.Errorf("resumed load balancer = %+v, want %d", again.APILoadBalancer, infra.APILoadBalancer.ID)
+	}
+}
+
+// TestProvisionInfrastructureFailure tests that a failing step stops provisioning and is recorded
+func TestProvisionInfrastructureFailure(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fake := hetznertest.NewFake()
+	fake.FailOn("CreateFirewall", errors.New("firewall limit reached"))
+	c := newTestProvisioner(t, fake)
+
+	if _, err := c.provisionInfrastructure(); err == nil || !strings.Contains(err.Error(), "firewall limit reached") {
+		t.Fatalf("provisionInfrastructure() error = %v, want the firewall failure", err)
+	}
+	if !c.state.IsCompleted(stepMasters) {
+		t.Error("masters step before the failure not recorded as completed")
+	}
+	if c.state.Steps[stepFirewall].Status != StepStatusFailed {
+		t.Errorf("firewall status = %s, want failed", c.state.Steps[stepFirewall].Status)
+	}
+	if len(fake.LoadBalancers()) != 0 {
+		t.Error("load balancer created after the firewall step failed")
+	}
+
+	// Resuming after the problem is fixed keeps the masters and finishes the remaining steps
+	fake.FailOn("CreateFirewall", nil)
+	c.Resume = true
+	if _, err := c.provisionInfrastructure(); err != nil {
+		t.Fatalf("resumed provisionInfrastructure() error = %v", err)
+	}
+	if len(fake.Servers()) != 3 || len(fake.Firewalls()) != 1 || len(fake.LoadBalancers()) != 1 {
+		t.Errorf("after resume: %d servers, %d firewalls, %d load balancers, want 3, 1 and 1",
+			len(fake.Servers()), len(fake.Firewalls()), len(fake.LoadBalancers()))
+	}
+}
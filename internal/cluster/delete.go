@@ -22,13 +22,13 @@ const (
 // Deleter handles cluster deletion
 type Deleter struct {
 	Config        *config.Main
-	HetznerClient *hetzner.Client
+	HetznerClient hetzner.API
 	Force         bool
 	ctx           context.Context
 }
 
 // NewDeleter creates a new cluster deleter
-func NewDeleter(cfg *config.Main, hetznerClient *hetzner.Client, force bool) *Deleter {
+func NewDeleter(cfg *config.Main, hetznerClient hetzner.API, force bool) *Deleter {
 	return &Deleter{
 		Config:        cfg,
 		HetznerClient: hetznerClient,
@@ -64,37 +64,12 @@ func (d *Deleter) Run() error {
 	// Step 1: Delete servers first
 	spinner := util.NewSpinner("Finding and deleting servers", "servers")
 	spinner.Start()
-	servers, err := d.HetznerClient.ListServers(d.ctx, hcloud.ServerListOpts{
-		ListOpts: hcloud.ListOpts{
-			LabelSelector: clusterLabel,
-		},
-	})
-	if err != nil {
-		spinner.Stop(true)
-		return fmt.Errorf("failed to list servers: %w", err)
-	}
-
-	// Also find servers from autoscaling-enabled worker node pools
-	// These are created by the cluster autoscaler and have the HCloudNodeGroupLabel
-	autoscaledServers, err := d.findAutoscaledPoolServers()
+	// Servers of autoscaling-enabled worker node pools are created by the cluster
+	// autoscaler and found through their HCloudNodeGroupLabel
+	allServers, err := listClusterServers(d.ctx, d.HetznerClient, d.Config)
 	if err != nil {
 		spinner.Stop(true)
-		return fmt.Errorf("failed to find autoscaled pool servers: %w", err)
-	}
-
-	// Merge servers, avoiding duplicates
-	serverMap := make(map[int64]*hcloud.Server)
-	for _, server := range servers {
-		serverMap[server.ID] = server
-	}
-	for _, server := range autoscaledServers {
-		serverMap[server.ID] = server
-	}
-
-	// Convert map back to slice
-	allServers := make([]*hcloud.Server, 0, len(serverMap))
-	for _, server := range serverMap {
-		allServers = append(allServers, server)
+		return err
 	}
 
 	spinner.Stop(true)
@@ -136,6 +111,98 @@ func (d *Deleter) Run() error {
 
 	util.LogSuccess(fmt.Sprintf("Completed deletion of %d server(s)", len(allServers)), "servers")
 
+	// Step 1.1: Delete placement groups, now that their servers are gone
+	placementGroups, err := d.HetznerClient.ListPlacementGroups(d.ctx, hcloud.PlacementGroupListOpts{
+		ListOpts: hcloud.ListOpts{
+			LabelSelector: clusterLabel,
+		},
+	})
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to list placement groups: %v", err)
+		util.LogError(errMsg, "placement group")
+		deletionErrors = append(deletionErrors, errMsg)
+	}
+	for _, placementGroup := range placementGroups {
+		if err := d.HetznerClient.DeletePlacementGroup(d.ctx, placementGroup); err != nil {
+			errMsg := fmt.Sprintf("Failed to delete placement group %s: %v", placementGroup.Name, err)
+			util.LogError(errMsg, "placement group")
+			deletionErrors = append(deletionErrors, errMsg)
+		} else {
+			util.LogSuccess(fmt.Sprintf("Deleted placement group: %s", placementGroup.Name), "placement group")
+		}
+	}
+
+	// Step 1.2: Delete primary IPs, unless their pool keeps them
+	primaryIPs, err := d.HetznerClient.ListPrimaryIPs(d.ctx, hcloud.PrimaryIPListOpts{
+		ListOpts: hcloud.ListOpts{
+			LabelSelector: clusterLabel,
+		},
+	})
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to list primary IPs: %v", err)
+		util.LogError(errMsg, "primary ip")
+		deletionErrors = append(deletionErrors, errMsg)
+	}
+	for _, primaryIP := range primaryIPs {
+		if keepPrimaryIP(d.Config, primaryIP) {
+			util.LogInfo(fmt.Sprintf("Keeping primary IP: %s (%s)", primaryIP.Name, primaryIP.IP), "primary ip")
+			continue
+		}
+		if err := d.HetznerClient.DeletePrimaryIP(d.ctx, primaryIP); err != nil {
+			errMsg := fmt.Sprintf("Failed to delete primary IP %s: %v", primaryIP.Name, err)
+			util.LogError(errMsg, "primary ip")
+			deletionErrors = append(deletionErrors, errMsg)
+		} else {
+			util.LogSuccess(fmt.Sprintf("Deleted primary IP: %s", primaryIP.Name), "primary ip")
+		}
+	}
+
+	// Step 1.3: Delete the API floating IP
+	floatingIPs, err := d.HetznerClient.ListFloatingIPs(d.ctx, hcloud.FloatingIPListOpts{
+		ListOpts: hcloud.ListOpts{
+			LabelSelector: clusterLabel,
+		},
+	})
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to list floating IPs: %v", err)
+		util.LogError(errMsg, "floating ip")
+		deletionErrors = append(deletionErrors, errMsg)
+	}
+	for _, floatingIP := range floatingIPs {
+		if err := d.HetznerClient.DeleteFloatingIP(d.ctx, floatingIP); err != nil {
+			errMsg := fmt.Sprintf("Failed to delete floating IP %s: %v", floatingIP.Name, err)
+			util.LogError(errMsg, "floating ip")
+			deletionErrors = append(deletionErrors, errMsg)
+		} else {
+			util.LogSuccess(fmt.Sprintf("Deleted floating IP: %s", floatingIP.Name), "floating ip")
+		}
+	}
+
+	// Step 1.4: Delete volumes, unless their pool keeps them
+	volumes, err := d.HetznerClient.ListVolumes(d.ctx, hcloud.VolumeListOpts{
+		ListOpts: hcloud.ListOpts{
+			LabelSelector: clusterLabel,
+		},
+	})
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to list volumes: %v", err)
+		util.LogError(errMsg, "volume")
+		deletionErrors = append(deletionErrors, errMsg)
+	}
+	for _, volume := range volumes {
+		if keepVolume(d.Config, volume) {
+			util.LogInfo(fmt.Sprintf("Keeping volume: %s", volume.Name), "volume")
+			continue
+		}
+		if err := d.HetznerClient.DeleteVolume(d.ctx, volume); err != nil {
+			errMsg := fmt.Sprintf("Failed to delete volume %s: %v", volume.Name, err)
+			util.LogError(errMsg, "volume")
+			deletionErrors = append(deletionErrors, errMsg)
+		} else {
+			util.LogSuccess(fmt.Sprintf("Deleted volume: %s", volume.Name), "volume")
+		}
+	}
+
 	// Step 2: Delete load balancers
 	util.LogInfo("Finding and deleting load balancers", "load balancer")
 
@@ -282,6 +349,9 @@ func (d *Deleter) Run() error {
 	// Clean up kubeconfig file if it exists
 	d.cleanupKubeconfig()
 
+	// Forget the host keys of the deleted servers
+	d.cleanupKnownHosts()
+
 	util.LogSuccess("Cluster deletion completed successfully!", d.Config.ClusterName)
 	return nil
 }
@@ -332,34 +402,17 @@ func (d *Deleter) cleanupKubeconfig() {
 	}
 }
 
-// findAutoscaledPoolServers finds servers created by the cluster autoscaler
-// These servers have the HCloudNodeGroupLabel label instead of the cluster label
-func (d *Deleter) findAutoscaledPoolServers() ([]*hcloud.Server, error) {
-	var allServers []*hcloud.Server
-
-	// Iterate through all worker node pools
-	for _, pool := range d.Config.WorkerNodePools {
-		// Only process autoscaling-enabled pools
-		if !pool.AutoscalingEnabled() {
-			continue
-		}
-
-		// Build the node pool name (must match the name used by cluster autoscaler)
-		poolName := pool.BuildNodePoolName(d.Config.ClusterName)
-
-		// Search for servers with the HCloudNodeGroupLabel
-		nodeGroupLabel := fmt.Sprintf("%s=%s", HCloudNodeGroupLabel, poolName)
-		servers, err := d.HetznerClient.ListServers(d.ctx, hcloud.ServerListOpts{
-			ListOpts: hcloud.ListOpts{
-				LabelSelector: nodeGroupLabel,
-			},
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to list servers for node group %s: %w", poolName, err)
-		}
-
-		allServers = append(allServers, servers...)
+// cleanupKnownHosts removes the known_hosts file of the cluster if it exists
+func (d *Deleter) cleanupKnownHosts() {
+	knownHostsPath, err := KnownHostsPath(d.Config.ClusterName)
+	if err != nil {
+		util.LogWarning(fmt.Sprintf("Failed to locate known hosts file: %v", err), "ssh")
+		return
 	}
 
-	return allServers, nil
+	if err := os.Remove(knownHostsPath); err == nil {
+		util.LogSuccess("Known hosts file deleted", "ssh")
+	} else if !os.IsNotExist(err) {
+		util.LogWarning(fmt.Sprintf("Failed to delete known hosts file: %v", err), "ssh")
+	}
 }
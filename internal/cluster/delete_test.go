@@ -3,6 +3,8 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -10,6 +12,7 @@ import (
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/magenx/hek3ster/internal/config"
 	"github.com/magenx/hek3ster/pkg/hetzner"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
 )
 
 // MockHetznerClient is a mock implementation of the Hetzner client for testing
@@ -309,6 +312,233 @@ func TestEmptyServerList(t *testing.T) {
 	}
 }
 
+// TestDeleterRun deletes a whole cluster from the in-memory Hetzner API
+func TestDeleterRun(t *testing.T) {
+	ctx := context.Background()
+	fake := hetznertest.NewFake()
+
+	poolName := "autoscaled"
+	cfg := createTestConfig()
+	cfg.Domain = "example.com"
+	cfg.KubeconfigPath = t.TempDir() + "/kubeconfig"
+	cfg.Networking.PrivateNetwork.Enabled = true
+	cfg.DNSZone.Enabled = true
+	cfg.SSLCertificate.Enabled = true
+	cfg.WorkerNodePools = []config.WorkerNodePool{{NodePool: config.NodePool{
+		Name:                       &poolName,
+		Autoscaling:                &config.Autoscaling{Enabled: true},
+		IncludeClusterNameAsPrefix: true,
+	}, Volumes: []config.Volume{{Name: "data", DeletePolicy: config.VolumeDeletePolicyKeep}}}}
+
+	managed := map[string]string{"cluster": cfg.ClusterName, "managed": "hek3ster"}
+	sshKey, _ := fake.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{Name: "test-cluster-ssh-key", PublicKey: "ssh-ed25519 AAAA"})
+	network, _ := fake.CreateNetwork(ctx, hcloud.NetworkCreateOpts{Name: cfg.ClusterName})
+	firewall, _ := fake.CreateFirewall(ctx, hcloud.FirewallCreateOpts{Name: "test-cluster-firewall"})
+	fake.CreatePlacementGroup(ctx, hcloud.PlacementGroupCreateOpts{Name: "test-cluster-masters", Type: hcloud.PlacementGroupTypeSpread, Labels: managed})
+	fake.CreatePlacementGroup(ctx, hcloud.PlacementGroupCreateOpts{Name: "other-masters", Type: hcloud.PlacementGroupTypeSpread,
+		Labels: map[string]string{"cluster": "other"}})
+	cfg.MastersPool.PrimaryIPs = &config.PrimaryIPs{Enabled: true, DeletePolicy: config.PrimaryIPDeletePolicyKeep}
+	for name, labels := range map[string]map[string]string{
+		"test-cluster-master-1-ipv4": {"cluster": cfg.ClusterName, "role": "master"},
+		"test-cluster-pool-1-ipv4":   {"cluster": cfg.ClusterName, "role": "worker", "pool": "removed"},
+		"other-master-1-ipv4":        {"cluster": "other", "role": "master"},
+	} {
+		fake.CreatePrimaryIP(ctx, hcloud.PrimaryIPCreateOpts{Name: name, Type: hcloud.PrimaryIPTypeIPv4, Labels: labels})
+	}
+	for _, opts := range []hcloud.ServerCreateOpts{
+		{Name: "test-cluster-master-1", Labels: map[string]string{"cluster": cfg.ClusterName, "role": "master"}},
+		{Name: "test-cluster-pool-1", Labels: map[string]string{"cluster": cfg.ClusterName, "role": "worker"}},
+		{Name: "test-cluster-autoscaled-1a2b", Labels: map[string]string{HCloudNodeGroupLabel: "test-cluster-autoscaled"}},
+		{Name: "other-master-1", Labels: map[string]string{"cluster": "other", "role": "master"}},
+	} {
+		opts.Networks = []*hcloud.Network{network}
+		opts.Firewalls = []*hcloud.ServerCreateFirewall{{Firewall: *firewall}}
+		opts.SSHKeys = []*hcloud.SSHKey{sshKey}
+		if _, err := fake.CreateServer(ctx, opts); err != nil {
+			t.Fatalf("CreateServer() error = %v", err)
+		}
+	}
+	for name, labels := range map[string]map[string]string{
+		"test-cluster-pool-1-data":  {"cluster": cfg.ClusterName, "pool": "autoscaled", "volume": "data"},
+		"test-cluster-pool-1-cache": {"cluster": cfg.ClusterName, "pool": "autoscaled", "volume": "cache"},
+		"other-worker-1-data":       {"cluster": "other", "pool": "autoscaled", "volume": "data"},
+	} {
+		fake.CreateVolume(ctx, hcloud.VolumeCreateOpts{Name: name, Size: 10, Location: &hcloud.Location{Name: "fsn1"}, Labels: labels})
+	}
+	fake.CreateLoadBalancer(ctx, hcloud.LoadBalancerCreateOpts{Name: "test-cluster-api-lb", Network: network})
+	fake.CreateFloatingIP(ctx, hcloud.FloatingIPCreateOpts{Type: hcloud.FloatingIPTypeIPv4, Name: hcloud.Ptr("test-cluster-api-ip"),
+		HomeLocation: &hcloud.Location{Name: "fsn1"}, Labels: map[string]string{"cluster": cfg.ClusterName, "role": "api"}})
+	fake.CreateZone(ctx, hcloud.ZoneCreateOpts{Name: "example.com", Labels: managed})
+	fake.CreateManagedCertificate(ctx, hcloud.CertificateCreateOpts{Name: "example.com", Labels: managed})
+
+	deleter := NewDeleter(cfg, fake, true)
+	err := deleter.Run()
+
+	// The firewall is still applied to the server of the other cluster
+	if err == nil {
+		t.Fatal("Run() error = nil, want an error for the firewall still in use")
+	}
+	servers := fake.Servers()
+	if len(servers) != 1 || servers[0].Name != "other-master-1" {
+		t.Errorf("remaining servers = %v, want only other-master-1", servers)
+	}
+	if n := len(fake.LoadBalancers()) + len(fake.FloatingIPs()) + len(fake.Networks()) + len(fake.Zones()) + len(fake.Certificates()) + len(fake.SSHKeys()); n != 0 {
+		t.Errorf("%d load balancer(s), floating IP(s), network(s), zone(s), certificate(s) or SSH key(s) left, want 0", n)
+	}
+	if len(fake.Firewalls()) != 1 {
+		t.Errorf("firewalls left = %d, want 1", len(fake.Firewalls()))
+	}
+	if placementGroups := fake.PlacementGroups(); len(placementGroups) != 1 || placementGroups[0].Name != "other-masters" {
+		t.Errorf("remaining placement groups = %v, want only other-masters", placementGroups)
+	}
+
+	// The masters keep their primary IPs, the IP of a removed pool is deleted
+	primaryIPs := fake.PrimaryIPs()
+	if len(primaryIPs) != 2 || primaryIPs[0].Name != "other-master-1-ipv4" || primaryIPs[1].Name != "test-cluster-master-1-ipv4" {
+		t.Errorf("remaining primary IPs = %v, want other-master-1-ipv4 and test-cluster-master-1-ipv4", primaryIPs)
+	}
+
+	// The data volume is kept, the cache volume is no longer configured
+	volumes := fake.Volumes()
+	if len(volumes) != 2 || volumes[0].Name != "other-worker-1-data" || volumes[1].Name != "test-cluster-pool-1-data" {
+		t.Errorf("remaining volumes = %v, want other-worker-1-data and test-cluster-pool-1-data", volumes)
+	}
+
+	// With the other server gone the firewall can be deleted on a second run
+	if err := fake.DeleteServer(ctx, servers[0]); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if err := deleter.Run(); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if len(fake.Firewalls()) != 0 {
+		t.Error("firewall left after the second run")
+	}
+}
+
+// TestDeleterRun_API creates, reports and deletes a cluster against the fake Hetzner Cloud API over HTTP
+func TestDeleterRun_API(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := hetznertest.NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+	server.SetActionPolls(2)
+
+	creator := newTestProvisioner(t, client)
+	cfg := creator.Config
+	cfg.KubeconfigPath = t.TempDir() + "/kubeconfig"
+
+	infra, err := creator.provisionInfrastructure()
+	if err != nil {
+		t.Fatalf("provisionInfrastructure() error = %v", err)
+	}
+
+	// Status reports every master, with the node state read from the first one
+	reporter := &StatusReporter{Config: cfg, HetznerClient: client, ctx: context.Background()}
+	statuses, err := reporter.collectStatuses(func(master *hcloud.Server) (map[string]kubeNode, error) {
+		if master.Name != "test-master-1" {
+			t.Errorf("node state read from %s, want test-master-1", master.Name)
+		}
+		return map[string]kubeNode{"test-master-1": {Name: "test-master-1", Ready: true}}, nil
+	})
+	if err != nil {
+		t.Fatalf("collectStatuses() error = %v", err)
+	}
+	if len(statuses) != len(infra.Masters) {
+		t.Fatalf("collectStatuses() = %+v, want the %d masters", statuses, len(infra.Masters))
+	}
+	for _, status := range statuses {
+		want := "NotJoined"
+		if status.Name == "test-master-1" {
+			want = "True"
+		}
+		if status.Role != "master" || status.Ready != want || status.PrivateIP == "-" {
+			t.Errorf("status of %s = %+v, want a master with Ready %s and a private IP", status.Name, status, want)
+		}
+	}
+
+	if err := NewDeleter(cfg, client, true).Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	fake := server.Fake
+	if n := len(fake.Servers()) + len(fake.Firewalls()) + len(fake.Networks()) + len(fake.LoadBalancers()) + len(fake.SSHKeys()); n != 0 {
+		t.Errorf("%d server(s), firewall(s), network(s), load balancer(s) or SSH key(s) left, want 0", n)
+	}
+
+	// Status of the deleted cluster finds nothing
+	statuses, err = reporter.collectStatuses(func(*hcloud.Server) (map[string]kubeNode, error) {
+		t.Error("node state read from a deleted cluster")
+		return nil, nil
+	})
+	if err != nil || len(statuses) != 0 {
+		t.Errorf("collectStatuses() after delete = %+v, %v, want none", statuses, err)
+	}
+}
+
+// TestDeleterRun_APIFailures tests create, status and delete when API requests or actions fail
+func TestDeleterRun_APIFailures(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := hetznertest.NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+
+	// A failed create_server action stops provisioning before the firewall
+	server.FailAction("create_server", "server location disabled")
+	creator := newTestProvisioner(t, client)
+	cfg := creator.Config
+	if _, err := creator.provisionInfrastructure(); err == nil || !strings.Contains(err.Error(), "server location disabled") {
+		t.Fatalf("provisionInfrastructure() error = %v, want the create_server failure", err)
+	}
+	if creator.state.Steps[stepMasters].Status != StepStatusFailed {
+		t.Errorf("masters status = %s, want failed", creator.state.Steps[stepMasters].Status)
+	}
+	if len(server.Fake.Firewalls()) != 0 || len(server.Fake.LoadBalancers()) != 0 {
+		t.Error("firewall or load balancer created after the masters step failed")
+	}
+
+	// Resuming once the action succeeds finishes the cluster
+	server.FailAction("create_server", "")
+	creator.Resume = true
+	infra, err := creator.provisionInfrastructure()
+	if err != nil {
+		t.Fatalf("resumed provisionInfrastructure() error = %v", err)
+	}
+
+	// Status fails when listing the servers fails for the first request and both retries
+	reporter := &StatusReporter{Config: cfg, HetznerClient: client, ctx: context.Background()}
+	noNodes := func(*hcloud.Server) (map[string]kubeNode, error) { return nil, fmt.Errorf("no SSH") }
+	server.FailRequest(http.MethodGet, "/servers", http.StatusServiceUnavailable, hcloud.ErrorCodeMaintenance, 3)
+	if _, err := reporter.collectStatuses(noNodes); err == nil {
+		t.Error("collectStatuses() error = nil, want the list failure")
+	}
+
+	// Node state that cannot be read leaves the servers in the report
+	statuses, err := reporter.collectStatuses(noNodes)
+	if err != nil || len(statuses) != len(infra.Masters) || statuses[0].Ready != "-" {
+		t.Errorf("collectStatuses() = %+v, %v, want the masters without node state", statuses, err)
+	}
+
+	// Delete reports a load balancer that stays locked through the retries and leaves it for the next run
+	lbPath := fmt.Sprintf("/load_balancers/%d", infra.APILoadBalancer.ID)
+	server.FailRequest(http.MethodDelete, lbPath, http.StatusLocked, hcloud.ErrorCodeLocked, 3)
+	deleter := NewDeleter(cfg, client, true)
+	if err := deleter.Run(); err == nil {
+		t.Fatal("Run() error = nil, want the load balancer failure")
+	}
+	if len(server.Fake.Servers()) != 0 || len(server.Fake.LoadBalancers()) != 1 {
+		t.Errorf("after failed delete: %d servers, %d load balancers, want 0 and 1",
+			len(server.Fake.Servers()), len(server.Fake.LoadBalancers()))
+	}
+
+	if err := deleter.Run(); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	fake := server.Fake
+	if n := len(fake.Servers()) + len(fake.Firewalls()) + len(fake.Networks()) + len(fake.LoadBalancers()) + len(fake.SSHKeys()); n != 0 {
+		t.Errorf("%d server(s), firewall(s), network(s), load balancer(s) or SSH key(s) left, want 0", n)
+	}
+}
+
 // Helper function to create a test configuration
 func createTestConfig() *config.Main {
 	return &config.Main{
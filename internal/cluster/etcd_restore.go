@@ -0,0 +1,200 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+const (
+	// etcdSnapshotDir is the directory k3s writes local etcd snapshots to
+	etcdSnapshotDir = "/var/lib/rancher/k3s/server/db/snapshots"
+	// etcdDataDir is the etcd data directory removed before a master rejoins the restored cluster
+	// The snapshots next to it are kept.
+	etcdDataDir = "/var/lib/rancher/k3s/server/db/etcd"
+
+	// restoreServiceTimeout limits stopping, starting and restarting a k3s service
+	restoreServiceTimeout = 5 * time.Minute
+	// restoreResetTimeout limits the cluster reset, which includes downloading S3 snapshots
+	restoreResetTimeout = 15 * time.Minute
+)
+
+// restoreStep is one step of an etcd restore, run on each of its servers in turn
+type restoreStep struct {
+	Description string
+	Servers     []*hcloud.Server
+	Command     string
+	Timeout     time.Duration
+}
+
+// restoreSource finds the snapshot to restore on the first master
+// A local snapshot on the first master is preferred over the same snapshot in S3.
+func restoreSource(snapshots []EtcdSnapshot, name, firstMaster string) (EtcdSnapshot, error) {
+	var s3, elsewhere *EtcdSnapshot
+	for i, snapshot := range snapshots {
+		if snapshot.Name != name {
+			continue
+		}
+		switch {
+		case snapshot.Location == snapshotLocationS3:
+			s3 = &snapshots[i]
+		case snapshot.Node == firstMaster:
+			return snapshot, nil
+		default:
+			elsewhere = &snapshots[i]
+		}
+	}
+
+	if s3 != nil {
+		return *s3, nil
+	}
+	if elsewhere != nil {
+		return EtcdSnapshot{}, fmt.Errorf("snapshot %s is stored on %s, copy it to %s on %s first",
+			name, elsewhere.Node, etcdSnapshotDir, firstMaster)
+	}
+	return EtcdSnapshot{}, fmt.Errorf("snapshot %s not found", name)
+}
+
+// clusterResetCommand returns the command that resets etcd to a snapshot on the first master
+// Local snapshots are restored by path, S3 snapshots by name with the S3 arguments.
+func clusterResetCommand(snapshot EtcdSnapshot, s3Args string) string {
+	restorePath := path.Join(etcdSnapshotDir, snapshot.Name)
+	if snapshot.Location == snapshotLocationS3 {
+		restorePath = snapshot.Name
+	}
+
+	cmd := fmt.Sprintf("sudo k3s server --cluster-reset --cluster-reset-restore-path=%s", util.ShellQuote(restorePath))
+	if snapshot.Location == snapshotLocationS3 && s3Args != "" {
+		cmd += " " + s3Args
+	}
+	return cmd + " 2>&1"
+}
+
+// buildRestorePlan returns the ordered steps that restore the cluster from a snapshot
+// All masters are stopped, etcd is reset on the first master, the other masters wipe their
+// etcd data and rejoin one at a time, and finally the agents are restarted.
+func buildRestorePlan(masters, workers []*hcloud.Server, snapshot EtcdSnapshot, s3Args string) []restoreStep {
+	firstMaster := masters[0]
+	otherMasters := masters[1:]
+
+	steps := []restoreStep{
+		{Description: "Stopping k3s", Servers: masters, Command: "sudo systemctl stop k3s", Timeout: restoreServiceTimeout},
+		{Description: fmt.Sprintf("Resetting etcd to snapshot %s", snapshot.Name), Servers: []*hcloud.Server{firstMaster},
+			Command: clusterResetCommand(snapshot, s3Args), Timeout: restoreResetTimeout},
+		{Description: "Starting k3s", Servers: []*hcloud.Server{firstMaster}, Command: "sudo systemctl start k3s", Timeout: restoreServiceTimeout},
+	}
+
+	if len(otherMasters) > 0 {
+		steps = append(steps,
+			restoreStep{Description: "Removing etcd data", Servers: otherMasters,
+				Command: fmt.Sprintf("sudo rm -rf %s", etcdDataDir), Timeout: restoreServiceTimeout},
+			restoreStep{Description: "Rejoining the cluster", Servers: otherMasters,
+				Command: "sudo systemctl start k3s", Timeout: restoreServiceTimeout},
+		)
+	}
+
+	if len(workers) > 0 {
+		steps = append(steps, restoreStep{Description: "Restarting k3s agent", Servers: workers,
+			Command: "sudo systemctl restart k3s-agent", Timeout: restoreServiceTimeout})
+	}
+
+	return steps
+}
+
+// Restore restores the cluster datastore from a snapshot
+// The snapshot must be stored on the first master or in S3. Unless force is set,
+// the cluster name must be typed to confirm, since the current datastore is replaced.
+func (m *EtcdSnapshotManager) Restore(name string, force bool) error {
+	servers, err := listClusterServers(m.ctx, m.HetznerClient, m.Config)
+	if err != nil {
+		return err
+	}
+
+	var masters, workers []*hcloud.Server
+	for _, server := range servers {
+		switch serverRole(server) {
+		case "master":
+			masters = append(masters, server)
+		case "worker":
+			workers = append(workers, server)
+		}
+	}
+	if len(masters) == 0 {
+		return fmt.Errorf("no master nodes found for cluster: %s", m.Config.ClusterName)
+	}
+
+	snapshots, err := m.List()
+	if err != nil {
+		return err
+	}
+	snapshot, err := restoreSource(snapshots, name, masters[0].Name)
+	if err != nil {
+		return err
+	}
+
+	util.LogInfo(fmt.Sprintf("Restoring %s snapshot %s on %s, %d master(s) and %d worker(s) will be restarted",
+		snapshot.Location, snapshot.Name, masters[0].Name, len(masters), len(workers)), "restore")
+
+	if !force {
+		if err := m.confirmRestore(); err != nil {
+			return err
+		}
+	}
+
+	s3Args := m.Config.Datastore.EmbeddedEtcd.GenerateSnapshotS3Args()
+	for _, step := range buildRestorePlan(masters, workers, snapshot, s3Args) {
+		for _, server := range step.Servers {
+			util.LogInfo(step.Description, server.Name)
+			if err := m.runRestoreStep(server, step); err != nil {
+				return fmt.Errorf("%s on %s failed: %w", strings.ToLower(step.Description), server.Name, err)
+			}
+		}
+	}
+
+	util.LogSuccess(fmt.Sprintf("Cluster restored from snapshot %s", snapshot.Name), m.Config.ClusterName)
+	return nil
+}
+
+// runRestoreStep runs the command of a restore step on one server within the step timeout
+func (m *EtcdSnapshotManager) runRestoreStep(server *hcloud.Server, step restoreStep) error {
+	ip, err := GetServerSSHIP(server)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, step.Timeout)
+	defer cancel()
+
+	output, err := m.SSHClient.Run(ctx, ip, m.Config.Networking.SSH.Port, step.Command, m.Config.Networking.SSH.UseAgent)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %v", step.Timeout)
+		}
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// confirmRestore prompts the user to confirm the restore by typing the cluster name
+func (m *EtcdSnapshotManager) confirmRestore() error {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Print("The current datastore will be replaced. Please enter the cluster name to confirm the restore: ")
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if strings.TrimSpace(input) != m.Config.ClusterName {
+		util.LogError("Cluster name does not match. Aborting restore.", "restore")
+		return fmt.Errorf("cluster name confirmation failed")
+	}
+	return nil
+}
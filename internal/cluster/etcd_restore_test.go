@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// TestRestoreSource tests finding the snapshot to restore on the first master
+func TestRestoreSource(t *testing.T) {
+	snapshots := []EtcdSnapshot{
+		{Name: "local-first", Node: "test-master-1", Location: snapshotLocationLocal},
+		{Name: "local-other", Node: "test-master-2", Location: snapshotLocationLocal},
+		{Name: "both", Location: snapshotLocationS3},
+		{Name: "both", Node: "test-master-1", Location: snapshotLocationLocal},
+		{Name: "remote", Location: snapshotLocationS3},
+	}
+
+	tests := []struct {
+		name         string
+		snapshot     string
+		wantLocation string
+		wantErr      string
+	}{
+		{name: "local on first master", snapshot: "local-first", wantLocation: snapshotLocationLocal},
+		{name: "local preferred over s3", snapshot: "both", wantLocation: snapshotLocationLocal},
+		{name: "s3", snapshot: "remote", wantLocation: snapshotLocationS3},
+		{name: "local on other master", snapshot: "local-other", wantErr: "stored on test-master-2"},
+		{name: "missing", snapshot: "missing", wantErr: "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := restoreSource(snapshots, tt.snapshot, "test-master-1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("restoreSource() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restoreSource() error = %v", err)
+			}
+			if got.Location != tt.wantLocation {
+				t.Errorf("restoreSource() location = %q, want %q", got.Location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+// TestClusterResetCommand tests the reset command for local and S3 snapshots
+func TestClusterResetCommand(t *testing.T) {
+	s3Args := "--etcd-s3 --etcd-s3-bucket=backups"
+
+	local := clusterResetCommand(EtcdSnapshot{Name: "on-demand-1", Location: snapshotLocationLocal}, s3Args)
+	if want := "sudo k3s server --cluster-reset --cluster-reset-restore-path='/var/lib/rancher/k3s/server/db/snapshots/on-demand-1' 2>&1"; local != want {
+		t.Errorf("clusterResetCommand() local = %q, want %q", local, want)
+	}
+
+	s3 := clusterResetCommand(EtcdSnapshot{Name: "on-demand-1", Location: snapshotLocationS3}, s3Args)
+	if want := "sudo k3s server --cluster-reset --cluster-reset-restore-path='on-demand-1' --etcd-s3 --etcd-s3-bucket=backups 2>&1"; s3 != want {
+		t.Errorf("clusterResetCommand() s3 = %q, want %q", s3, want)
+	}
+}
+
+// TestBuildRestorePlan tests the order of the restore steps
+func TestBuildRestorePlan(t *testing.T) {
+	masters := []*hcloud.Server{{Name: "test-master-1"}, {Name: "test-master-2"}, {Name: "test-master-3"}}
+	workers := []*hcloud.Server{{Name: "test-worker-1"}}
+	snapshot := EtcdSnapshot{Name: "on-demand-1", Location: snapshotLocationLocal}
+
+	steps := buildRestorePlan(masters, workers, snapshot, "")
+
+	want := []struct {
+		command string
+		servers int
+	}{
+		{"sudo systemctl stop k3s", 3},
+		{"sudo k3s server --cluster-reset", 1},
+		{"sudo systemctl start k3s", 1},
+		{"sudo rm -rf /var/lib/rancher/k3s/server/db/etcd", 2},
+		{"sudo systemctl start k3s", 2},
+		{"sudo systemctl restart k3s-agent", 1},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("buildRestorePlan() returned %d steps, want %d", len(steps), len(want))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(steps[i].Command, w.command) || len(steps[i].Servers) != w.servers {
+			t.Errorf("step %d = %q on %d server(s), want %q on %d", i, steps[i].Command, len(steps[i].Servers), w.command, w.servers)
+		}
+		if steps[i].Timeout <= 0 {
+			t.Errorf("step %d has no timeout", i)
+		}
+	}
+	if steps[1].Servers[0].Name != "test-master-1" {
+		t.Errorf("cluster reset runs on %s, want test-master-1", steps[1].Servers[0].Name)
+	}
+
+	// A single master without workers only stops, resets and starts it
+	if steps := buildRestorePlan(masters[:1], nil, snapshot, ""); len(steps) != 3 {
+		t.Errorf("buildRestorePlan() for a single master returned %d steps, want 3", len(steps))
+	}
+}
@@ -0,0 +1,408 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+const (
+	// DefaultEtcdSnapshotName is the name prefix k3s uses for on-demand snapshots
+	DefaultEtcdSnapshotName = "on-demand"
+	// DefaultEtcdSnapshotRetention is the k3s default number of snapshots kept per name
+	DefaultEtcdSnapshotRetention int64 = 5
+	// snapshotLocationLocal marks snapshots stored on a master's disk
+	snapshotLocationLocal = "local"
+	// snapshotLocationS3 marks snapshots stored in S3
+	snapshotLocationS3 = "s3"
+)
+
+// EtcdSnapshot describes an embedded etcd snapshot
+type EtcdSnapshot struct {
+	Name string `json:"name"`
+	// Node is the master that holds a local snapshot; S3 snapshots are shared by all masters
+	Node      string    `json:"node,omitempty"`
+	Location  string    `json:"location"`
+	URI       string    `json:"uri"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EtcdSnapshotManager manages embedded etcd snapshots by running k3s etcd-snapshot on the masters
+type EtcdSnapshotManager struct {
+	Config        *config.Main
+	HetznerClient hetzner.API
+	SSHClient     *util.SSH
+	ctx           context.Context
+}
+
+// NewEtcdSnapshotManager creates a new etcd snapshot manager
+func NewEtcdSnapshotManager(cfg *config.Main, hetznerClient hetzner.API) (*EtcdSnapshotManager, error) {
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &EtcdSnapshotManager{
+		Config:        cfg,
+		HetznerClient: hetznerClient,
+		SSHClient:     sshClient,
+		ctx:           ctx,
+	}
+
+	// Configure NAT gateway as bastion host if enabled
+	if err := setupNATGatewayBastion(manager.ctx, cfg, hetznerClient, manager.SSHClient, "etcd"); err != nil {
+		return nil, fmt.Errorf("failed to configure NAT gateway bastion: %w", err)
+	}
+
+	return manager, nil
+}
+
+// Save takes an on-demand snapshot on a master and returns the snapshot and master names
+// node selects the master; the first master is used if it is empty.
+func (m *EtcdSnapshotManager) Save(name, node string) (string, string, error) {
+	masters, err := m.findMasters()
+	if err != nil {
+		return "", "", err
+	}
+
+	master := masters[0]
+	if node != "" {
+		master = nil
+		for _, candidate := range masters {
+			if candidate.Name == node {
+				master = candidate
+				break
+			}
+		}
+		if master == nil {
+			return "", "", fmt.Errorf("master %s not found", node)
+		}
+	}
+
+	if name == "" {
+		name = DefaultEtcdSnapshotName
+	}
+	output, err := m.run(master, fmt.Sprintf("save --name %s", util.ShellQuote(name)), true, true)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save snapshot on %s: %w", master.Name, err)
+	}
+
+	snapshot := parseSnapshotName(output)
+	if snapshot == "" {
+		snapshot = name
+	}
+	return snapshot, master.Name, nil
+}
+
+// List returns the snapshots of every master, oldest first
+func (m *EtcdSnapshotManager) List() ([]EtcdSnapshot, error) {
+	masters, err := m.findMasters()
+	if err != nil {
+		return nil, err
+	}
+
+	var lists [][]EtcdSnapshot
+	for _, master := range masters {
+		output, err := m.run(master, "ls", true, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots on %s: %w", master.Name, err)
+		}
+		snapshots, err := parseEtcdSnapshotList(output, master.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots on %s: %w", master.Name, err)
+		}
+		lists = append(lists, snapshots)
+	}
+
+	return mergeEtcdSnapshots(lists...), nil
+}
+
+// Delete deletes snapshots by name from the masters and S3 that hold them
+func (m *EtcdSnapshotManager) Delete(names []string) error {
+	snapshots, err := m.List()
+	if err != nil {
+		return err
+	}
+	masters, err := m.findMasters()
+	if err != nil {
+		return err
+	}
+
+	targets, err := snapshotDeleteTargets(snapshots, names, masters[0].Name)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*hcloud.Server, len(masters))
+	for _, master := range masters {
+		byName[master.Name] = master
+	}
+
+	for _, target := range targets {
+		master, ok := byName[target.Node]
+		if !ok {
+			return fmt.Errorf("master %s not found", target.Node)
+		}
+		args := "delete " + strings.Join(quoteAll(target.Names), " ")
+		if _, err := m.run(master, args, target.S3, true); err != nil {
+			return fmt.Errorf("failed to delete snapshots on %s: %w", master.Name, err)
+		}
+		for _, name := range target.Names {
+			util.LogSuccess(fmt.Sprintf("Deleted snapshot %s", name), target.Node)
+		}
+	}
+
+	return nil
+}
+
+// Prune deletes the oldest snapshots with the given name prefix beyond the retention count
+// Every master prunes its local snapshots; S3 snapshots are pruned along with them.
+func (m *EtcdSnapshotManager) Prune(name string, retention int64) error {
+	if retention < 1 {
+		return fmt.Errorf("retention must be at least 1")
+	}
+	if name == "" {
+		name = DefaultEtcdSnapshotName
+	}
+
+	masters, err := m.findMasters()
+	if err != nil {
+		return err
+	}
+
+	for _, master := range masters {
+		args := fmt.Sprintf("prune --name %s --snapshot-retention %d", util.ShellQuote(name), retention)
+		if _, err := m.run(master, args, true, true); err != nil {
+			return fmt.Errorf("failed to prune snapshots on %s: %w", master.Name, err)
+		}
+		util.LogSuccess(fmt.Sprintf("Pruned %s snapshots, keeping the newest %d", name, retention), master.Name)
+	}
+
+	return nil
+}
+
+// findMasters returns the master servers of the cluster, sorted by name
+func (m *EtcdSnapshotManager) findMasters() ([]*hcloud.Server, error) {
+	servers, err := listClusterServers(m.ctx, m.HetznerClient, m.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var masters []*hcloud.Server
+	for _, server := range servers {
+		if serverRole(server) == "master" {
+			masters = append(masters, server)
+		}
+	}
+	if len(masters) == 0 {
+		return nil, fmt.Errorf("no master nodes found for cluster: %s", m.Config.ClusterName)
+	}
+	return masters, nil
+}
+
+// run runs a k3s etcd-snapshot subcommand on a master
+// includeS3 adds the S3 arguments when S3 is configured, so S3 snapshots are included.
+// withLogs merges the k3s log output into the result, which save needs to report the file name.
+func (m *EtcdSnapshotManager) run(master *hcloud.Server, args string, includeS3, withLogs bool) (string, error) {
+	ip, err := GetServerSSHIP(master)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := "sudo k3s etcd-snapshot " + args
+	if s3Args := m.Config.Datastore.EmbeddedEtcd.GenerateSnapshotS3Args(); includeS3 && s3Args != "" {
+		cmd += " " + s3Args
+	}
+	if withLogs {
+		cmd += " 2>&1"
+	} else {
+		cmd += " 2>/dev/null"
+	}
+
+	output, err := m.SSHClient.Run(m.ctx, ip, m.Config.Networking.SSH.Port, cmd, m.Config.Networking.SSH.UseAgent)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+	return output, nil
+}
+
+// parseEtcdSnapshotList parses the table printed by `k3s etcd-snapshot ls`
+// Columns are located by their header, so older k3s releases without a Location column still parse.
+func parseEtcdSnapshotList(output, node string) ([]EtcdSnapshot, error) {
+	var snapshots []EtcdSnapshot
+	var columns map[string]int
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if columns == nil {
+			if strings.EqualFold(fields[0], "name") {
+				columns = make(map[string]int, len(fields))
+				for i, field := range fields {
+					columns[strings.ToLower(field)] = i
+				}
+			}
+			continue
+		}
+
+		if len(fields) != len(columns) {
+			continue
+		}
+
+		snapshot := EtcdSnapshot{Name: fields[columns["name"]], Node: node, Location: snapshotLocationLocal}
+		if i, ok := columns["location"]; ok {
+			snapshot.URI = fields[i]
+			if strings.HasPrefix(snapshot.URI, "s3://") {
+				snapshot.Location = snapshotLocationS3
+				snapshot.Node = ""
+			}
+		}
+		if i, ok := columns["size"]; ok {
+			snapshot.Size, _ = strconv.ParseInt(fields[i], 10, 64)
+		}
+		if i, ok := columns["created"]; ok {
+			snapshot.CreatedAt, _ = time.Parse(time.RFC3339, fields[i])
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if columns == nil && strings.TrimSpace(output) != "" {
+		return nil, fmt.Errorf("unexpected etcd-snapshot ls output")
+	}
+	return snapshots, nil
+}
+
+// mergeEtcdSnapshots combines the snapshot lists of several masters
+// S3 snapshots are listed by every master and are kept once. The result is sorted oldest first.
+func mergeEtcdSnapshots(lists ...[]EtcdSnapshot) []EtcdSnapshot {
+	seen := make(map[string]bool)
+	var merged []EtcdSnapshot
+
+	for _, list := range lists {
+		for _, snapshot := range list {
+			key := snapshot.Location + "/" + snapshot.Node + "/" + snapshot.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, snapshot)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if !merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+		}
+		return merged[i].Name < merged[j].Name
+	})
+	return merged
+}
+
+// snapshotDeleteTarget groups the snapshots deleted with one k3s etcd-snapshot delete call
+type snapshotDeleteTarget struct {
+	Node  string
+	S3    bool
+	Names []string
+}
+
+// snapshotDeleteTargets finds where each named snapshot is stored
+// Local snapshots are deleted on their master, S3 snapshots through s3Node.
+func snapshotDeleteTargets(snapshots []EtcdSnapshot, names []string, s3Node string) ([]snapshotDeleteTarget, error) {
+	var targets []snapshotDeleteTarget
+	index := make(map[string]int)
+
+	for _, name := range names {
+		found := false
+		for _, snapshot := range snapshots {
+			if snapshot.Name != name {
+				continue
+			}
+			found = true
+
+			target := snapshotDeleteTarget{Node: snapshot.Node, S3: snapshot.Location == snapshotLocationS3}
+			if target.S3 {
+				target.Node = s3Node
+			}
+			key := fmt.Sprintf("%s/%t", target.Node, target.S3)
+			if i, ok := index[key]; ok {
+				targets[i].Names = append(targets[i].Names, name)
+				continue
+			}
+			index[key] = len(targets)
+			target.Names = []string{name}
+			targets = append(targets, target)
+		}
+		if !found {
+			return nil, fmt.Errorf("snapshot %s not found", name)
+		}
+	}
+
+	return targets, nil
+}
+
+// quoteAll shell-quotes every value
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = util.ShellQuote(value)
+	}
+	return quoted
+}
+
+// FormatEtcdSnapshots writes snapshots as an aligned table with human readable sizes and ages
+func FormatEtcdSnapshots(w io.Writer, snapshots []EtcdSnapshot, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tNODE\tLOCATION\tSIZE\tAGE")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			snapshot.Name, valueOrDash(snapshot.Node), snapshot.Location, formatSize(snapshot.Size), formatAge(snapshot.CreatedAt, now))
+	}
+	tw.Flush()
+}
+
+// formatSize formats a byte count with a binary unit
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge formats the time since a timestamp like kubectl does, e.g. 45s, 12m, 5h or 3d
+func formatAge(created, now time.Time) string {
+	if created.IsZero() {
+		return "-"
+	}
+	age := now.Sub(created)
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
@@ -0,0 +1,177 @@
+package cluster
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseEtcdSnapshotList tests parsing `k3s etcd-snapshot ls` output
+func TestParseEtcdSnapshotList(t *testing.T) {
+	output := `Name                                   Location                                                                              Size     Created
+on-demand-test-master-1-1768903200     file:///var/lib/rancher/k3s/server/db/snapshots/on-demand-test-master-1-1768903200     5242880  2026-01-20T10:00:00Z
+etcd-snapshot-test-master-1-1768906800 s3://backups/test/etcd-snapshot-test-master-1-1768906800                              5300000  2026-01-20T11:00:00Z
+`
+	snapshots, err := parseEtcdSnapshotList(output, "test-master-1")
+	if err != nil {
+		t.Fatalf("parseEtcdSnapshotList() error = %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("parseEtcdSnapshotList() returned %d snapshots, want 2", len(snapshots))
+	}
+
+	local := snapshots[0]
+	if local.Name != "on-demand-test-master-1-1768903200" || local.Node != "test-master-1" || local.Location != snapshotLocationLocal {
+		t.Errorf("local snapshot = %+v", local)
+	}
+	if local.Size != 5242880 {
+		t.Errorf("local snapshot size = %d, want 5242880", local.Size)
+	}
+	if want := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC); !local.CreatedAt.Equal(want) {
+		t.Errorf("local snapshot created = %v, want %v", local.CreatedAt, want)
+	}
+
+	s3 := snapshots[1]
+	if s3.Location != snapshotLocationS3 || s3.Node != "" {
+		t.Errorf("S3 snapshot location = %q, node = %q, want s3 without node", s3.Location, s3.Node)
+	}
+}
+
+// TestParseEtcdSnapshotListWithoutLocation tests parsing output of k3s releases without a Location column
+func TestParseEtcdSnapshotListWithoutLocation(t *testing.T) {
+	output := "Name Size Created\non-demand-test-master-1-1768903200 1024 2026-01-20T10:00:00Z\n"
+	snapshots, err := parseEtcdSnapshotList(output, "test-master-1")
+	if err != nil {
+		t.Fatalf("parseEtcdSnapshotList() error = %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Location != snapshotLocationLocal || snapshots[0].Size != 1024 {
+		t.Errorf("parseEtcdSnapshotList() = %+v", snapshots)
+	}
+
+	if snapshots, err := parseEtcdSnapshotList("", "test-master-1"); err != nil || len(snapshots) != 0 {
+		t.Errorf("parseEtcdSnapshotList() on empty output = %v, %v", snapshots, err)
+	}
+	if _, err := parseEtcdSnapshotList("etcd datastore is not started", "test-master-1"); err == nil {
+		t.Error("expected an error for unexpected output")
+	}
+}
+
+// TestMergeEtcdSnapshots tests that S3 snapshots listed by every master are kept once
+func TestMergeEtcdSnapshots(t *testing.T) {
+	older := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	s3 := EtcdSnapshot{Name: "etcd-snapshot-1", Location: snapshotLocationS3, CreatedAt: newer}
+	merged := mergeEtcdSnapshots(
+		[]EtcdSnapshot{s3, {Name: "on-demand-1", Node: "test-master-1", Location: snapshotLocationLocal, CreatedAt: older}},
+		[]EtcdSnapshot{s3, {Name: "on-demand-1", Node: "test-master-2", Location: snapshotLocationLocal, CreatedAt: older}},
+	)
+
+	if len(merged) != 3 {
+		t.Fatalf("mergeEtcdSnapshots() returned %d snapshots, want 3", len(merged))
+	}
+	if merged[2].Name != "etcd-snapshot-1" {
+		t.Errorf("mergeEtcdSnapshots() newest snapshot = %q, want etcd-snapshot-1 last", merged[2].Name)
+	}
+}
+
+// TestSnapshotDeleteTargets tests grouping deletions by master and S3
+func TestSnapshotDeleteTargets(t *testing.T) {
+	snapshots := []EtcdSnapshot{
+		{Name: "a", Node: "test-master-1", Location: snapshotLocationLocal},
+		{Name: "b", Node: "test-master-1", Location: snapshotLocationLocal},
+		{Name: "c", Node: "test-master-2", Location: snapshotLocationLocal},
+		{Name: "d", Location: snapshotLocationS3},
+	}
+
+	targets, err := snapshotDeleteTargets(snapshots, []string{"a", "b", "c", "d"}, "test-master-1")
+	if err != nil {
+		t.Fatalf("snapshotDeleteTargets() error = %v", err)
+	}
+
+	want := []snapshotDeleteTarget{
+		{Node: "test-master-1", Names: []string{"a", "b"}},
+		{Node: "test-master-2", Names: []string{"c"}},
+		{Node: "test-master-1", S3: true, Names: []string{"d"}},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("snapshotDeleteTargets() = %+v, want %+v", targets, want)
+	}
+	for i := range want {
+		if targets[i].Node != want[i].Node || targets[i].S3 != want[i].S3 || strings.Join(targets[i].Names, ",") != strings.Join(want[i].Names, ",") {
+			t.Errorf("target %d = %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+
+	if _, err := snapshotDeleteTargets(snapshots, []string{"missing"}, "test-master-1"); err == nil {
+		t.Error("expected an error for an unknown snapshot")
+	}
+}
+
+// TestFormatSize tests human readable snapshot sizes
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0B"},
+		{512, "512B"},
+		{1536, "1.5KiB"},
+		{5242880, "5.0MiB"},
+		{3 << 30, "3.0GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSize(tt.size); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+// TestFormatAge tests kubectl style snapshot ages
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{45 * time.Second, "45s"},
+		{12 * time.Minute, "12m"},
+		{5 * time.Hour, "5h"},
+		{72 * time.Hour, "3d"},
+	}
+
+	for _, tt := range tests {
+		if got := formatAge(now.Add(-tt.age), now); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+	if got := formatAge(time.Time{}, now); got != "-" {
+		t.Errorf("formatAge() without timestamp = %q, want -", got)
+	}
+}
+
+// TestFormatEtcdSnapshots tests the snapshot table
+func TestFormatEtcdSnapshots(t *testing.T) {
+	now := time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	FormatEtcdSnapshots(&buf, []EtcdSnapshot{
+		{Name: "on-demand-1", Node: "test-master-1", Location: snapshotLocationLocal, Size: 2048, CreatedAt: now.Add(-2 * time.Hour)},
+		{Name: "etcd-snapshot-1", Location: snapshotLocationS3, Size: 512, CreatedAt: now.Add(-30 * time.Minute)},
+	}, now)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("FormatEtcdSnapshots() wrote %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "NAME NODE LOCATION SIZE AGE" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "on-demand-1 test-master-1 local 2.0KiB 2h" {
+		t.Errorf("local row = %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "etcd-snapshot-1 - s3 512B 30m" {
+		t.Errorf("S3 row = %q", lines[2])
+	}
+}
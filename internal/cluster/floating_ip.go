@@ -0,0 +1,124 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/cloudinit"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+// floatingIPFailoverService is the systemd unit of the failover agent on the masters
+const floatingIPFailoverService = "floating-ip-failover"
+
+// ensureAPIFloatingIP returns the floating IP of the Kubernetes API, creating it if
+// it does not exist yet. An unassigned IP is assigned to the first master; after
+// that the failover agents on the masters decide where it lives.
+func (c *CreatorEnhanced) ensureAPIFloatingIP(firstMaster *hcloud.Server) (*hcloud.FloatingIP, error) {
+	name := c.Config.APIFloatingIP.Name(c.Config.ClusterName)
+	floatingIP, err := c.HetznerClient.GetFloatingIP(c.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if floatingIP == nil {
+		homeLocation := c.Config.APIFloatingIP.HomeLocation
+		if homeLocation == "" {
+			homeLocation = c.Config.MastersPool.Locations[0]
+		}
+		floatingIP, err = c.HetznerClient.CreateFloatingIP(c.ctx, hcloud.FloatingIPCreateOpts{
+			Type:         hcloud.FloatingIPTypeIPv4,
+			Name:         hcloud.Ptr(name),
+			Description:  hcloud.Ptr(fmt.Sprintf("Kubernetes API of cluster %s", c.Config.ClusterName)),
+			HomeLocation: &hcloud.Location{Name: homeLocation},
+			Labels: map[string]string{
+				"cluster": c.Config.ClusterName,
+				"role":    "api",
+				"managed": "hek3ster",
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		util.LogSuccess(fmt.Sprintf("Floating IP created: %s (%s)", name, floatingIP.IP), "floating ip")
+	}
+
+	if floatingIP.Server == nil {
+		if err := c.HetznerClient.AssignFloatingIP(c.ctx, floatingIP, firstMaster); err != nil {
+			return nil, err
+		}
+		floatingIP.Server = &hcloud.Server{ID: firstMaster.ID}
+	}
+	return floatingIP, nil
+}
+
+// installFloatingIPFailover installs the failover agent on every master
+// The agents keep the floating IP on a healthy master. The master index is the
+// agent's priority, so after a failure the lowest healthy master claims the IP.
+func (c *CreatorEnhanced) installFloatingIPFailover(masters []*hcloud.Server, floatingIP *hcloud.FloatingIP) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errors []error
+
+	for i, master := range masters {
+		wg.Add(1)
+		go func(priority int, server *hcloud.Server) {
+			defer wg.Done()
+			if err := c.installFloatingIPFailoverOnMaster(server, floatingIP, priority); err != nil {
+				mu.Lock()
+				errors = append(errors, fmt.Errorf("failed to install failover agent on %s: %w", server.Name, err))
+				mu.Unlock()
+			}
+		}(i, master)
+	}
+	wg.Wait()
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors installing floating IP failover agents: %v", errors)
+	}
+	return nil
+}
+
+// installFloatingIPFailoverOnMaster installs the failover agent on a single master
+func (c *CreatorEnhanced) installFloatingIPFailoverOnMaster(server *hcloud.Server, floatingIP *hcloud.FloatingIP, priority int) error {
+	ip, err := GetServerSSHIP(server)
+	if err != nil {
+		return err
+	}
+
+	installCmd, err := cloudinit.GenerateFloatingIPFailoverInstallCommand(cloudinit.FloatingIPFailover{
+		HetznerToken:    c.Config.HetznerToken,
+		HetznerEndpoint: c.Config.HetznerEndpoint,
+		FloatingIPID:    floatingIP.ID,
+		FloatingIP:      floatingIP.IP.String(),
+		Priority:        priority,
+		CheckInterval:   c.Config.APIFloatingIP.CheckInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.SSHClient.Run(c.ctx, ip, c.Config.Networking.SSH.Port, installCmd, c.Config.Networking.SSH.UseAgent); err != nil {
+		return err
+	}
+	util.LogSuccess(fmt.Sprintf("Floating IP failover agent installed on %s", server.Name), "floating ip")
+	return nil
+}
+
+// verifyFloatingIPFailover checks that the failover agent runs on every master
+func (c *CreatorEnhanced) verifyFloatingIPFailover(masters []*hcloud.Server) bool {
+	checkCmd := fmt.Sprintf("systemctl is-active %s 2>/dev/null", floatingIPFailoverService)
+	for _, server := range masters {
+		ip, err := GetServerSSHIP(server)
+		if err != nil {
+			return false
+		}
+		output, err := c.SSHClient.Run(c.ctx, ip, c.Config.Networking.SSH.Port, checkCmd, c.Config.Networking.SSH.UseAgent)
+		if err != nil || strings.TrimSpace(output) != "active" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
+)
+
+func TestCreatorAPIFloatingIP(t *testing.T) {
+	ctx := context.Background()
+	fake := hetznertest.NewFake()
+	cfg := &config.Main{
+		ClusterName:   "test",
+		MastersPool:   config.MasterNodePool{Locations: []string{"nbg1"}},
+		APIFloatingIP: config.APIFloatingIP{Enabled: true},
+	}
+	c := &CreatorEnhanced{Config: cfg, HetznerClient: fake, ctx: ctx}
+
+	master1, _ := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-1", Location: &hcloud.Location{Name: "nbg1"}})
+	master2, _ := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-2", Location: &hcloud.Location{Name: "nbg1"}})
+
+	floatingIP, err := c.ensureAPIFloatingIP(master1)
+	if err != nil {
+		t.Fatalf("ensureAPIFloatingIP() error = %v", err)
+	}
+	if floatingIP.Name != "test-api-ip" || floatingIP.HomeLocation.Name != "nbg1" || floatingIP.Labels["role"] != "api" {
+		t.Errorf("floating IP = %+v, want test-api-ip in nbg1 with role api", floatingIP)
+	}
+	if floatingIP.Server == nil || floatingIP.Server.ID != master1.ID {
+		t.Errorf("floating IP server = %+v, want the first master", floatingIP.Server)
+	}
+
+	// A rerun leaves the IP with the master the failover agents moved it to
+	if err := fake.AssignFloatingIP(ctx, floatingIP, master2); err != nil {
+		t.Fatalf("AssignFloatingIP() error = %v", err)
+	}
+	reused, err := c.ensureAPIFloatingIP(master1)
+	if err != nil || reused.ID != floatingIP.ID || reused.Server.ID != master2.ID {
+		t.Errorf("ensureAPIFloatingIP() on rerun = %+v, %v, want IP %d on the second master", reused, err, floatingIP.ID)
+	}
+	if got := len(fake.FloatingIPs()); got != 1 {
+		t.Errorf("floating IPs = %d, want 1", got)
+	}
+}
+
+func TestGenerateTLSSansWithAPIFloatingIP(t *testing.T) {
+	cfg := &config.Main{}
+	master := &hcloud.Server{Name: "test-master-1", PublicNet: hcloud.ServerPublicNet{IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("203.0.113.10")}}}
+	floatingIP := &hcloud.FloatingIP{IP: net.ParseIP("198.51.100.1")}
+
+	result, err := GenerateTLSSans(cfg, []*hcloud.Server{master}, master, nil, floatingIP)
+	if err != nil {
+		t.Fatalf("GenerateTLSSans() error = %v", err)
+	}
+	if !strings.Contains(result, "--tls-san=198.51.100.1") {
+		t.Errorf("GenerateTLSSans() = %q, want the floating IP", result)
+	}
+}
@@ -1,12 +1,16 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/cloudinit"
 	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
 )
 
 // GetServerIP returns the appropriate IP address for a server based on network configuration
@@ -54,7 +58,7 @@ func GetServerSSHIP(server *hcloud.Server) (string, error) {
 
 // GenerateTLSSans generates TLS SAN (Subject Alternative Name) flags for k3s installation
 // This ensures the k3s API server certificate includes all necessary IP addresses and hostnames
-func GenerateTLSSans(cfg *config.Main, masters []*hcloud.Server, firstMaster *hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer) (string, error) {
+func GenerateTLSSans(cfg *config.Main, masters []*hcloud.Server, firstMaster *hcloud.Server, apiLoadBalancer *hcloud.LoadBalancer, apiFloatingIP *hcloud.FloatingIP) (string, error) {
 	// Use a map to collect unique SANs while building the list
 	uniqueSans := make(map[string]bool)
 
@@ -74,6 +78,11 @@ func GenerateTLSSans(cfg *config.Main, masters []*hcloud.Server, firstMaster *hc
 		uniqueSans[fmt.Sprintf("--tls-san=%s", lbIP)] = true
 	}
 
+	// Add API floating IP if configured and created
+	if apiFloatingIP != nil && apiFloatingIP.IP != nil {
+		uniqueSans[fmt.Sprintf("--tls-san=%s", apiFloatingIP.IP.String())] = true
+	}
+
 	// Add API server hostname if configured
 	if cfg.APIServerHostname != "" {
 		uniqueSans[fmt.Sprintf("--tls-san=%s", cfg.APIServerHostname)] = true
@@ -105,3 +114,148 @@ func GenerateTLSSans(cfg *config.Main, masters []*hcloud.Server, firstMaster *hc
 
 	return strings.Join(sortedSans, " "), nil
 }
+
+// listClusterServers returns every server that belongs to the cluster, sorted by name.
+// This includes servers labelled with cluster=<name> as well as servers created by the
+// cluster autoscaler, which only carry the HCloudNodeGroupLabel of their pool.
+func listClusterServers(ctx context.Context, hetznerClient hetzner.API, cfg *config.Main) ([]*hcloud.Server, error) {
+	clusterLabel := fmt.Sprintf("cluster=%s", cfg.ClusterName)
+	servers, err := hetznerClient.ListServers(ctx, hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{
+			LabelSelector: clusterLabel,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %w", err)
+	}
+
+	// Merge servers, avoiding duplicates
+	serverMap := make(map[int64]*hcloud.Server)
+	for _, server := range servers {
+		serverMap[server.ID] = server
+	}
+
+	for _, pool := range cfg.WorkerNodePools {
+		if !pool.AutoscalingEnabled() {
+			continue
+		}
+
+		// Build the node pool name (must match the name used by cluster autoscaler)
+		poolName := pool.BuildNodePoolName(cfg.ClusterName)
+		nodeGroupLabel := fmt.Sprintf("%s=%s", HCloudNodeGroupLabel, poolName)
+		autoscaled, err := hetznerClient.ListServers(ctx, hcloud.ServerListOpts{
+			ListOpts: hcloud.ListOpts{
+				LabelSelector: nodeGroupLabel,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list servers for node group %s: %w", poolName, err)
+		}
+		for _, server := range autoscaled {
+			serverMap[server.ID] = server
+		}
+	}
+
+	allServers := make([]*hcloud.Server, 0, len(serverMap))
+	for _, server := range serverMap {
+		allServers = append(allServers, server)
+	}
+
+	// Sort for deterministic output
+	sort.Slice(allServers, func(i, j int) bool {
+		return allServers[i].Name < allServers[j].Name
+	})
+
+	return allServers, nil
+}
+
+// serverRole returns the role of a cluster server based on its labels
+// Servers created by the cluster autoscaler have no role label and are reported as workers
+func serverRole(server *hcloud.Server) string {
+	if role, ok := server.Labels["role"]; ok && role != "" {
+		return role
+	}
+	if _, ok := server.Labels[HCloudNodeGroupLabel]; ok {
+		return "worker"
+	}
+	return "unknown"
+}
+
+// serverPool returns the node pool a server belongs to based on its labels
+func serverPool(server *hcloud.Server) string {
+	if pool, ok := server.Labels["pool"]; ok && pool != "" {
+		return pool
+	}
+	if nodeGroup, ok := server.Labels[HCloudNodeGroupLabel]; ok && nodeGroup != "" {
+		return nodeGroup
+	}
+	if serverRole(server) == "master" {
+		return "masters"
+	}
+	return ""
+}
+
+// setupNATGatewayBastion configures the NAT gateway as SSH bastion host if it is enabled
+// A missing NAT gateway is not an error; the cluster may not have been created yet.
+func setupNATGatewayBastion(ctx context.Context, cfg *config.Main, hetznerClient hetzner.API, sshClient *util.SSH, scope string) error {
+	bastionIP, err := natGatewayBastionIP(ctx, cfg, hetznerClient)
+	if err != nil || bastionIP == "" {
+		return err
+	}
+
+	sshClient.SetBastion(bastionIP, cfg.Networking.SSH.Port)
+	util.LogInfo(fmt.Sprintf("Using NAT gateway %s as SSH bastion host", bastionIP), scope)
+
+	return nil
+}
+
+// natGatewayBastionIP returns the public IP of the NAT gateway nodes are reached through,
+// empty if the NAT gateway is disabled or does not exist
+func natGatewayBastionIP(ctx context.Context, cfg *config.Main, hetznerClient hetzner.API) (string, error) {
+	if cfg.Networking.PrivateNetwork.NATGateway == nil ||
+		!cfg.Networking.PrivateNetwork.NATGateway.Enabled {
+		return "", nil
+	}
+
+	natGatewayName := fmt.Sprintf("%s-nat-gateway", cfg.ClusterName)
+	natGateway, err := hetznerClient.GetServer(ctx, natGatewayName)
+	if err != nil || natGateway == nil {
+		return "", nil
+	}
+
+	bastionIP, err := GetServerPublicIP(natGateway)
+	if err != nil {
+		return "", fmt.Errorf("failed to get NAT gateway public IP: %w", err)
+	}
+	return bastionIP, nil
+}
+
+// sshUser returns the non-root login user the nodes are set up for, nil for root
+func sshUser(cfg *config.Main) (*cloudinit.SSHUser, error) {
+	if !cfg.Networking.SSH.NonRootUser() {
+		return nil, nil
+	}
+	publicKey, err := cfg.Networking.SSH.AuthorizedKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	return cloudinit.NewSSHUser(cfg.Networking.SSH.User, publicKey), nil
+}
+
+// userCAKey returns the public key of the SSH user CA the nodes trust, empty without one
+func userCAKey(cfg *config.Main) (string, error) {
+	key, err := cfg.Networking.SSH.UserCAPublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to read SSH user CA public key: %w", err)
+	}
+	return key, nil
+}
+
+// workerPoolName returns the name used in server names and labels for a static worker pool
+// Unnamed pools are numbered by their position among the static pools.
+func workerPoolName(pool config.WorkerNodePool, index int) string {
+	if pool.Name != nil {
+		return *pool.Name
+	}
+	return fmt.Sprintf("pool-%d", index+1)
+}
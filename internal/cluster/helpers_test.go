@@ -149,7 +149,7 @@ func TestGenerateTLSSans(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := GenerateTLSSans(tt.cfg, tt.masters, tt.masters[0], nil)
+			result, err := GenerateTLSSans(tt.cfg, tt.masters, tt.masters[0], nil, nil)
 			if err != nil {
 				t.Fatalf("GenerateTLSSans() error = %v", err)
 			}
@@ -217,7 +217,7 @@ func TestGenerateTLSSansWithAPILoadBalancer(t *testing.T) {
 		},
 	}
 
-	result, err := GenerateTLSSans(cfg, masters, masters[0], apiLB)
+	result, err := GenerateTLSSans(cfg, masters, masters[0], apiLB, nil)
 	if err != nil {
 		t.Fatalf("GenerateTLSSans() error = %v", err)
 	}
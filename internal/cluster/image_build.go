@@ -0,0 +1,301 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/cloudinit"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+const (
+	// imageBuildServerTimeout is how long the build server may take to start or to shut down
+	imageBuildServerTimeout = 5 * time.Minute
+	// nodeImageRole is the role label of the snapshots built by hek3ster
+	nodeImageRole = "node-image"
+)
+
+// invalidLabelChars matches the characters Hetzner does not allow in label values
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// ImageBuildOptions holds the settings of a node image build
+type ImageBuildOptions struct {
+	// ServerType of the build server; it also decides the architecture of the image.
+	// Defaults to the instance type of the masters.
+	ServerType string
+	// Location of the build server. Defaults to the first location of the masters.
+	Location string
+	// K3sBinary downloads the k3s binary of the configured version into the image
+	K3sBinary bool
+	// AirgapImages downloads the airgap images of the configured version into the image
+	AirgapImages bool
+}
+
+// ImageBuilder builds node images: it boots a temporary server from the configured
+// image, applies the additional packages and pre-k3s commands, and snapshots it
+type ImageBuilder struct {
+	Config        *config.Main
+	HetznerClient hetzner.API
+	SSHClient     *util.SSH
+	Options       ImageBuildOptions
+	ctx           context.Context
+}
+
+// NewImageBuilder creates a new node image builder
+func NewImageBuilder(cfg *config.Main, hetznerClient hetzner.API, opts ImageBuildOptions) (*ImageBuilder, error) {
+	if opts.ServerType == "" {
+		opts.ServerType = cfg.MastersPool.InstanceType
+	}
+	if opts.Location == "" && len(cfg.MastersPool.Locations) > 0 {
+		opts.Location = cfg.MastersPool.Locations[0]
+	}
+	if opts.ServerType == "" || opts.Location == "" {
+		return nil, fmt.Errorf("the server type and location of the build server are required")
+	}
+
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImageBuilder{
+		Config:        cfg,
+		HetznerClient: hetznerClient,
+		SSHClient:     sshClient,
+		Options:       opts,
+		ctx:           ctx,
+	}, nil
+}
+
+// Build builds a node image and returns the snapshot
+// The build server is deleted again, whether the build succeeds or not.
+func (b *ImageBuilder) Build() (*hcloud.Image, error) {
+	sshKey, err := ensureSSHKey(b.ctx, b.Config, b.HetznerClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH key: %w", err)
+	}
+
+	server, err := b.createBuildServer(sshKey)
+	if err != nil {
+		return nil, err
+	}
+	defer b.deleteBuildServer(server)
+
+	if err := b.prepare(server); err != nil {
+		return nil, err
+	}
+
+	util.LogInfo(fmt.Sprintf("Shutting down %s", server.Name), "image")
+	if err := b.HetznerClient.ShutdownServer(b.ctx, server); err != nil {
+		return nil, err
+	}
+	if err := b.HetznerClient.WaitForServerStatus(b.ctx, server, hcloud.ServerStatusOff, imageBuildServerTimeout); err != nil {
+		return nil, fmt.Errorf("build server did not shut down: %w", err)
+	}
+
+	util.LogInfo(fmt.Sprintf("Creating snapshot of %s, this can take several minutes", server.Name), "image")
+	image, err := b.HetznerClient.CreateImage(b.ctx, server, hcloud.ServerCreateImageOpts{
+		Type:        hcloud.ImageTypeSnapshot,
+		Description: hcloud.Ptr(b.description()),
+		Labels:      b.labels(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	util.LogSuccess(fmt.Sprintf("Snapshot created: %d (%s)", image.ID, image.Description), "image")
+	return image, nil
+}
+
+// buildServerName returns the name of the temporary build server
+func (b *ImageBuilder) buildServerName() string {
+	return fmt.Sprintf("%s-image-builder", b.Config.ClusterName)
+}
+
+// createBuildServer creates the temporary server the image is built on
+// Its cloud-init installs the additional packages and runs the pre-k3s commands
+// like on a node, but leaves out the firewall and k3s.
+func (b *ImageBuilder) createBuildServer(sshKey *hcloud.SSHKey) (*hcloud.Server, error) {
+	name := b.buildServerName()
+	existing, err := b.HetznerClient.GetServer(b.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("build server %s already exists, wait for the running build or delete the server", name)
+	}
+
+	serverType, err := b.HetznerClient.GetServerType(b.ctx, b.Options.ServerType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server type: %w", err)
+	}
+	location, err := b.HetznerClient.GetLocation(b.ctx, b.Options.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+	image, err := b.HetznerClient.GetImage(b.ctx, b.Config.Image, serverType.Architecture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+
+	user, err := sshUser(b.Config)
+	if err != nil {
+		return nil, err
+	}
+	caKey, err := userCAKey(b.Config)
+	if err != nil {
+		return nil, err
+	}
+	cloudInitData, err := cloudinit.NewGenerator(&cloudinit.Config{
+		SSHPort:                  b.Config.Networking.SSH.Port,
+		SSHUser:                  user,
+		SSHUserCAKey:             caKey,
+		Packages:                 b.Config.AdditionalPackages,
+		AdditionalPreK3sCommands: b.Config.AdditionalPreK3sCommands,
+	}).Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate cloud-init: %w", err)
+	}
+	cloudInitData, err = withHostKey(b.SSHClient, name, cloudInitData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin host key of %s: %w", name, err)
+	}
+
+	util.LogInfo(fmt.Sprintf("Creating build server %s (%s) from %s in %s", name, b.Options.ServerType, b.Config.Image, b.Options.Location), "image")
+	server, err := b.HetznerClient.CreateServer(b.ctx, hcloud.ServerCreateOpts{
+		Name:       name,
+		ServerType: serverType,
+		Image:      image,
+		Location:   location,
+		SSHKeys:    []*hcloud.SSHKey{sshKey},
+		UserData:   cloudInitData,
+		Labels: map[string]string{
+			"cluster": b.Config.ClusterName,
+			"role":    "image-builder",
+			"managed": "hek3ster",
+		},
+		PublicNet: &hcloud.ServerCreatePublicNet{
+			EnableIPv4: true,
+			EnableIPv6: false,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build server %s: %w", name, err)
+	}
+	return server, nil
+}
+
+// prepare waits for cloud-init on the build server and prepares it for the snapshot
+func (b *ImageBuilder) prepare(server *hcloud.Server) error {
+	if err := b.HetznerClient.WaitForServerStatus(b.ctx, server, hcloud.ServerStatusRunning, imageBuildServerTimeout); err != nil {
+		return fmt.Errorf("build server failed to start: %w", err)
+	}
+
+	ip, err := GetServerSSHIP(server)
+	if err != nil {
+		return err
+	}
+	port := b.Config.Networking.SSH.Port
+	if err := bindKnownHost(b.SSHClient, server, port); err != nil {
+		return err
+	}
+	useAgent := b.Config.Networking.SSH.UseAgent
+
+	if err := b.SSHClient.WaitForInstance(b.ctx, ip, port, "echo ready", "ready", useAgent, 30); err != nil {
+		return fmt.Errorf("build server not ready: %w", err)
+	}
+	util.LogInfo("Waiting for cloud-init to install packages and run the pre-k3s commands", "image")
+	if err := b.SSHClient.WaitForCloudInit(b.ctx, ip, port, useAgent); err != nil {
+		return fmt.Errorf("cloud-init failed on the build server: %w", err)
+	}
+
+	prepareCmd, err := cloudinit.GenerateImagePrepareCommand(cloudinit.ImagePreparation{
+		K3sVersion:   b.Config.K3sVersion,
+		K3sBinary:    b.Options.K3sBinary,
+		AirgapImages: b.Options.AirgapImages,
+	})
+	if err != nil {
+		return err
+	}
+	util.LogInfo("Preparing the build server for the snapshot", "image")
+	if err := b.SSHClient.RunWithOutput(b.ctx, ip, port, prepareCmd, useAgent, server.Name); err != nil {
+		return fmt.Errorf("failed to prepare the build server: %w", err)
+	}
+	return nil
+}
+
+// deleteBuildServer deletes the build server; a failure is only logged
+func (b *ImageBuilder) deleteBuildServer(server *hcloud.Server) {
+	if err := b.HetznerClient.DeleteServer(b.ctx, server); err != nil {
+		util.LogWarning(fmt.Sprintf("Failed to delete build server %s, delete it manually: %v", server.Name, err), "image")
+		return
+	}
+	util.LogSuccess(fmt.Sprintf("Build server %s deleted", server.Name), "image")
+}
+
+// labels returns the labels of the snapshot
+// The k3s version is only recorded when the image contains k3s files.
+func (b *ImageBuilder) labels() map[string]string {
+	labels := map[string]string{
+		"cluster":     b.Config.ClusterName,
+		"role":        nodeImageRole,
+		"managed":     "hek3ster",
+		"base-image":  labelValue(b.Config.Image),
+		"snapshot-os": labelValue(b.Config.SnapshotOS),
+	}
+	if b.Options.K3sBinary || b.Options.AirgapImages {
+		labels["k3s-version"] = labelValue(b.Config.K3sVersion)
+	}
+	return labels
+}
+
+// description returns the description of the snapshot
+func (b *ImageBuilder) description() string {
+	var contents []string
+	if b.Options.K3sBinary {
+		contents = append(contents, "k3s "+b.Config.K3sVersion)
+	}
+	if b.Options.AirgapImages {
+		contents = append(contents, "airgap images")
+	}
+	description := fmt.Sprintf("%s node image from %s", b.Config.ClusterName, b.Config.Image)
+	if len(contents) > 0 {
+		description += " with " + strings.Join(contents, " and ")
+	}
+	return description
+}
+
+// isNodeImage reports whether an image is a node image built by hek3ster
+// Such images have the root-level additional packages and pre-k3s commands baked in.
+func isNodeImage(image *hcloud.Image) bool {
+	return image != nil && image.Type == hcloud.ImageTypeSnapshot && image.Labels["role"] == nodeImageRole
+}
+
+// usesNodeImage reports whether an image name or ID refers to a node image built by hek3ster
+// The image is resolved for the architecture of the server type the nodes run on.
+func usesNodeImage(ctx context.Context, hetznerClient hetzner.API, nameOrID, instanceType string) (bool, error) {
+	serverType, err := hetznerClient.GetServerType(ctx, instanceType)
+	if err != nil {
+		return false, fmt.Errorf("failed to get server type: %w", err)
+	}
+	image, err := hetznerClient.GetImage(ctx, nameOrID, serverType.Architecture)
+	if err != nil {
+		return false, fmt.Errorf("failed to get image: %w", err)
+	}
+	return isNodeImage(image), nil
+}
+
+// labelValue turns a value into a valid Hetzner label value, e.g. v1.32.0+k3s1 into v1.32.0-k3s1
+func labelValue(value string) string {
+	value = invalidLabelChars.ReplaceAllString(value, "-")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "-_.")
+}
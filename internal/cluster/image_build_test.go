@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
+)
+
+func TestImageBuilderBuildServer(t *testing.T) {
+	ctx := context.Background()
+	fake := hetznertest.NewFake()
+	cfg := &config.Main{
+		ClusterName:              "test",
+		Image:                    "ubuntu-24.04",
+		AdditionalPackages:       []string{"nfs-common"},
+		AdditionalPreK3sCommands: []string{"echo prepared > /etc/prepared"},
+	}
+	cfg.Networking.SSH.Port = 22
+	knownHosts, err := util.LoadKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshClient := util.NewSSH("", "")
+	sshClient.SetKnownHosts(knownHosts)
+	b := &ImageBuilder{
+		Config:        cfg,
+		HetznerClient: fake,
+		SSHClient:     sshClient,
+		Options:       ImageBuildOptions{ServerType: "cx22", Location: "fsn1"},
+		ctx:           ctx,
+	}
+
+	sshKey, err := fake.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{Name: "test-ssh-key", PublicKey: "ssh-ed25519 AAAA"})
+	if err != nil {
+		t.Fatalf("CreateSSHKey() error = %v", err)
+	}
+	server, err := b.createBuildServer(sshKey)
+	if err != nil {
+		t.Fatalf("createBuildServer() error = %v", err)
+	}
+	if server.Name != "test-image-builder" || server.Labels["cluster"] != "test" || server.Labels["role"] != "image-builder" {
+		t.Errorf("build server = %s %v, want test-image-builder labelled with the cluster", server.Name, server.Labels)
+	}
+	if server.PublicNet.IPv4.IP == nil {
+		t.Error("build server has no public IPv4 for SSH")
+	}
+	if data, err := os.ReadFile(knownHosts.Path()); err != nil || !strings.Contains(string(data), "test-image-builder ssh-ed25519 ") {
+		t.Errorf("no host key expected for the build server: %s %v", data, err)
+	}
+
+	// A second build does not reuse a server that may still be in use
+	if _, err := b.createBuildServer(sshKey); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("createBuildServer() with an existing build server error = %v, want already exists", err)
+	}
+
+	b.deleteBuildServer(server)
+	if got, _ := fake.GetServer(ctx, "test-image-builder"); got != nil {
+		t.Error("build server not deleted")
+	}
+
+	// An arm build server boots the arm variant of the image
+	b.Options.ServerType = "cax11"
+	server, err = b.createBuildServer(sshKey)
+	if err != nil {
+		t.Fatalf("createBuildServer() on cax11 error = %v", err)
+	}
+	if server.Image == nil || server.Image.Architecture != hcloud.ArchitectureARM {
+		t.Errorf("cax11 build server image = %+v, want the arm image", server.Image)
+	}
+}
+
+func TestImageBuilderLabels(t *testing.T) {
+	cfg := &config.Main{ClusterName: "test", Image: "ubuntu-24.04", K3sVersion: "v1.32.0+k3s1", SnapshotOS: "default"}
+
+	tests := []struct {
+		name            string
+		options         ImageBuildOptions
+		wantK3sVersion  string
+		wantDescription string
+	}{
+		{
+			name:            "packages only",
+			wantDescription: "test node image from ubuntu-24.04",
+		},
+		{
+			name:            "k3s binary and airgap images",
+			options:         ImageBuildOptions{K3sBinary: true, AirgapImages: true},
+			wantK3sVersion:  "v1.32.0-k3s1",
+			wantDescription: "test node image from ubuntu-24.04 with k3s v1.32.0+k3s1 and airgap images",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &ImageBuilder{Config: cfg, Options: tt.options}
+			labels := b.labels()
+			if labels["cluster"] != "test" || labels["role"] != "node-image" || labels["base-image"] != "ubuntu-24.04" || labels["snapshot-os"] != "default" {
+				t.Errorf("labels() = %v, want the cluster, role, base image and snapshot OS", labels)
+			}
+			if labels["k3s-version"] != tt.wantK3sVersion {
+				t.Errorf("k3s-version label = %q, want %q", labels["k3s-version"], tt.wantK3sVersion)
+			}
+			if got := b.description(); got != tt.wantDescription {
+				t.Errorf("description() = %q, want %q", got, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	tests := map[string]string{
+		"v1.32.0+k3s1":          "v1.32.0-k3s1",
+		"ubuntu-24.04":          "ubuntu-24.04",
+		"123456":                "123456",
+		"+weird value+":         "weird-value",
+		strings.Repeat("a", 70): strings.Repeat("a", 63),
+	}
+	for value, want := range tests {
+		if got := labelValue(value); got != want {
+			t.Errorf("labelValue(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestGenerateCloudInitNodeImage(t *testing.T) {
+	ctx := context.Background()
+	fake := hetznertest.NewFake()
+	fake.AddImage(&hcloud.Image{ID: 4242, Type: hcloud.ImageTypeSnapshot, Status: hcloud.ImageStatusAvailable,
+		Labels: map[string]string{"role": nodeImageRole}})
+	fake.AddImage(&hcloud.Image{ID: 4343, Type: hcloud.ImageTypeSnapshot, Status: hcloud.ImageStatusAvailable})
+
+	poolPackage := "pool-package"
+	tests := []struct {
+		name      string
+		image     string
+		pool      *config.NodePool
+		wantBaked bool
+		wantPool  bool
+	}{
+		{name: "system image", image: "ubuntu-24.04", pool: &config.NodePool{InstanceType: "cx22"}, wantBaked: true},
+		{name: "system image on arm", image: "ubuntu-24.04", pool: &config.NodePool{InstanceType: "cax11"}, wantBaked: true},
+		{name: "other snapshot", image: "4343", pool: &config.NodePool{InstanceType: "cx22"}, wantBaked: true},
+		{name: "node image", image: "4242", pool: &config.NodePool{InstanceType: "cx22"}},
+		{name: "node image with pool packages", image: "4242",
+			pool: &config.NodePool{InstanceType: "cx22", AdditionalPackages: []string{poolPackage}}, wantPool: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Main{
+				ClusterName:              "test",
+				Image:                    tt.image,
+				AdditionalPackages:       []string{"nfs-common"},
+				AdditionalPreK3sCommands: []string{"echo prepared > /etc/prepared"},
+			}
+			cfg.Networking.SSH.Port = 22
+			c := &CreatorEnhanced{Config: cfg, HetznerClient: fake, ctx: ctx}
+
+			cloudInit, err := c.generateCloudInit(tt.pool, nil)
+			if err != nil {
+				t.Fatalf("generateCloudInit() error = %v", err)
+			}
+			if got := strings.Contains(cloudInit, "nfs-common"); got != tt.wantBaked {
+				t.Errorf("root-level package in cloud-init = %v, want %v", got, tt.wantBaked)
+			}
+			if got := strings.Contains(cloudInit, "/etc/prepared"); got != tt.wantBaked {
+				t.Errorf("root-level pre-k3s command in cloud-init = %v, want %v", got, tt.wantBaked)
+			}
+			if got := strings.Contains(cloudInit, poolPackage); got != tt.wantPool {
+				t.Errorf("pool package in cloud-init = %v, want %v", got, tt.wantPool)
+			}
+		})
+	}
+}
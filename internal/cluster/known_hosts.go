@@ -0,0 +1,147 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/cloudinit"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+	"golang.org/x/crypto/ssh"
+)
+
+// knownHostsFile is the file in the cluster state directory that pins the SSH host keys
+const knownHostsFile = "known_hosts"
+
+// KnownHostsPath returns the path of the known_hosts file of a cluster
+func KnownHostsPath(clusterName string) (string, error) {
+	dir, err := ClusterStateDir(clusterName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, knownHostsFile), nil
+}
+
+// newSSHClient creates the SSH client of a cluster command
+// Host keys are verified against the cluster's known_hosts file: the keys hek3ster
+// generated for the servers it created, and the key of any other server pinned on the
+// first connection. The file is synced with the servers of the cluster first, so
+// addresses that moved to another server do not fail verification.
+func newSSHClient(ctx context.Context, cfg *config.Main, hetznerClient hetzner.API) (*util.SSH, error) {
+	privKeyPath, err := cfg.Networking.SSH.ExpandedPrivateKeyPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand private key path: %w", err)
+	}
+
+	pubKeyPath, err := cfg.Networking.SSH.ExpandedPublicKeyPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand public key path: %w", err)
+	}
+
+	path, err := KnownHostsPath(cfg.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	knownHosts, err := util.LoadKnownHosts(path)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := listClusterServers(ctx, hetznerClient, cfg)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]int64, len(servers))
+	for _, server := range servers {
+		if address, ok := sshAddress(server, cfg.Networking.SSH.Port); ok {
+			owners[address] = server.ID
+		}
+	}
+	if err := knownHosts.Sync(owners); err != nil {
+		return nil, err
+	}
+	// Servers created by a run that stopped before they got an address claim their keys now
+	for _, server := range servers {
+		if address, ok := sshAddress(server, cfg.Networking.SSH.Port); ok {
+			if err := knownHosts.Claim(server.Name, address, server.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sshClient := util.NewSSH(privKeyPath, pubKeyPath)
+	sshClient.SetUser(cfg.Networking.SSH.User)
+	if cfg.Networking.SSH.UsesCertificates() {
+		certPath, err := cfg.Networking.SSH.ExpandedCertificatePath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand certificate path: %w", err)
+		}
+		sshClient.UseCertificate(certPath)
+	}
+	sshClient.SetKnownHosts(knownHosts)
+	return sshClient, nil
+}
+
+// withHostKey generates the SSH host key of a server about to be created
+// The key is pinned under the server name before the server exists and added to its
+// cloud-init, so the first connection is verified instead of trusted.
+func withHostKey(sshClient *util.SSH, name, cloudInitData string) (string, error) {
+	knownHosts := sshClient.KnownHosts()
+	if knownHosts == nil {
+		return cloudInitData, nil
+	}
+
+	privateKey, publicKey, err := util.GenerateHostKey()
+	if err != nil {
+		return "", err
+	}
+	if err := knownHosts.Expect(name, publicKey); err != nil {
+		return "", err
+	}
+	return cloudinit.WithHostKey(cloudInitData, privateKey, string(ssh.MarshalAuthorizedKey(publicKey))), nil
+}
+
+// bindKnownHost records a server created during the run as owner of its SSH address,
+// replacing a key pinned for a previous server at the same address, and pins the host
+// key generated for the server there
+func bindKnownHost(sshClient *util.SSH, server *hcloud.Server, port int) error {
+	knownHosts := sshClient.KnownHosts()
+	if knownHosts == nil {
+		return nil
+	}
+	address, ok := sshAddress(server, port)
+	if !ok {
+		return nil
+	}
+	if err := knownHosts.Bind(address, server.ID); err != nil {
+		return err
+	}
+	return knownHosts.Claim(server.Name, address, server.ID)
+}
+
+// forgetKnownHost removes the host key pinned for a server that was deleted
+func forgetKnownHost(sshClient *util.SSH, server *hcloud.Server, port int) error {
+	knownHosts := sshClient.KnownHosts()
+	if knownHosts == nil {
+		return nil
+	}
+	address, ok := sshAddress(server, port)
+	if !ok {
+		return nil
+	}
+	return knownHosts.Forget(address)
+}
+
+// sshAddress returns the host:port address hek3ster connects to a server on
+func sshAddress(server *hcloud.Server, port int) (string, bool) {
+	ip, err := GetServerSSHIP(server)
+	if err != nil {
+		return "", false
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(port)), true
+}
@@ -13,15 +13,19 @@ import (
 	"github.com/magenx/hek3ster/pkg/hetzner"
 )
 
+// loadBalancerStabilizationDelay is how long a new load balancer is given before targets
+// are added, as Hetzner may not find cloud targets right after creation
+var loadBalancerStabilizationDelay = 5 * time.Second
+
 // NetworkResourceManager handles creation of firewalls and load balancers
 type NetworkResourceManager struct {
 	Config        *config.Main
-	HetznerClient *hetzner.Client
+	HetznerClient hetzner.API
 	ctx           context.Context
 }
 
 // NewNetworkResourceManager creates a new network resource manager
-func NewNetworkResourceManager(cfg *config.Main, hetznerClient *hetzner.Client) *NetworkResourceManager {
+func NewNetworkResourceManager(cfg *config.Main, hetznerClient hetzner.API) *NetworkResourceManager {
 	return &NetworkResourceManager{
 		Config:        cfg,
 		HetznerClient: hetznerClient,
@@ -91,7 +95,6 @@ func (n *NetworkResourceManager) CreateAPILoadBalancer(masterServers []*hcloud.S
 	const (
 		maxNetworkAttachmentRetries = 5
 		initialRetryDelay           = 2 * time.Second
-		stabilizationDelay          = 5 * time.Second // Additional delay after network attachment is verified
 	)
 
 	// If load balancer was created with a network attachment, refresh its data from the API
@@ -123,7 +126,7 @@ func (n *NetworkResourceManager) CreateAPILoadBalancer(masterServers []*hcloud.S
 				// Add stabilization delay to ensure network attachment is fully operational
 				// in Hetzner's backend before attempting to add targets
 				util.LogInfo("Waiting for network attachment to stabilize", "load balancer")
-				time.Sleep(stabilizationDelay)
+				time.Sleep(loadBalancerStabilizationDelay)
 				break
 			}
 
@@ -138,7 +141,7 @@ func (n *NetworkResourceManager) CreateAPILoadBalancer(masterServers []*hcloud.S
 		// in Hetzner's backend before attempting to add server targets
 		// This prevents "cloud target was not found" errors
 		util.LogInfo("Waiting for load balancer to stabilize before adding targets", "load balancer")
-		time.Sleep(stabilizationDelay)
+		time.Sleep(loadBalancerStabilizationDelay)
 	}
 
 	// Add master servers as targets using label selector
@@ -375,7 +378,6 @@ func (n *NetworkResourceManager) CreateGlobalLoadBalancer(network *hcloud.Networ
 	const (
 		maxNetworkAttachmentRetries = 5
 		initialRetryDelay           = 2 * time.Second
-		stabilizationDelay          = 5 * time.Second // Additional delay after network attachment is verified
 	)
 
 	util.LogSuccess(fmt.Sprintf("Global load balancer created: %s (IP: %s)", lbName, lb.PublicNet.IPv4.IP.String()), "load balancer")
@@ -409,7 +411,7 @@ func (n *NetworkResourceManager) CreateGlobalLoadBalancer(network *hcloud.Networ
 				// Add stabilization delay to ensure network attachment is fully operational
 				// in Hetzner's backend before attempting to add targets
 				util.LogInfo("Waiting for network attachment to stabilize", "load balancer")
-				time.Sleep(stabilizationDelay)
+				time.Sleep(loadBalancerStabilizationDelay)
 				break
 			}
 
@@ -0,0 +1,195 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+// NodeShell opens interactive shells on cluster nodes and writes OpenSSH config for them
+type NodeShell struct {
+	Config        *config.Main
+	HetznerClient hetzner.API
+	SSHClient     *util.SSH
+	ctx           context.Context
+}
+
+// NewNodeShell creates a new node shell
+func NewNodeShell(cfg *config.Main, hetznerClient hetzner.API) (*NodeShell, error) {
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NodeShell{
+		Config:        cfg,
+		HetznerClient: hetznerClient,
+		SSHClient:     sshClient,
+		ctx:           ctx,
+	}, nil
+}
+
+// Open opens an interactive shell on the server a target resolves to
+// The target is an instance name, a role or a pool; if it matches several servers,
+// the first one by name is used.
+func (n *NodeShell) Open(target string) error {
+	servers, err := listClusterServers(n.ctx, n.HetznerClient, n.Config)
+	if err != nil {
+		return err
+	}
+
+	matches := resolveSSHTarget(servers, target)
+	if len(matches) == 0 {
+		return fmt.Errorf("no server of cluster %s matches %q, use an instance name, a role (master, worker, nat-gateway) or a pool name", n.Config.ClusterName, target)
+	}
+	server := matches[0]
+	if len(matches) > 1 {
+		util.LogInfo(fmt.Sprintf("%d servers match %s, connecting to %s", len(matches), target, server.Name), "ssh")
+	}
+
+	ip, err := GetServerSSHIP(server)
+	if err != nil {
+		return fmt.Errorf("failed to get IP of %s: %w", server.Name, err)
+	}
+
+	// The NAT gateway is the bastion itself and is reached directly
+	if serverRole(server) != "nat-gateway" {
+		if err := setupNATGatewayBastion(n.ctx, n.Config, n.HetznerClient, n.SSHClient, "ssh"); err != nil {
+			return fmt.Errorf("failed to configure NAT gateway bastion: %w", err)
+		}
+	}
+
+	util.LogInfo(fmt.Sprintf("Connecting to %s (%s) as %s", server.Name, ip, n.Config.Networking.SSH.User), "ssh")
+	return n.SSHClient.Shell(ip, n.Config.Networking.SSH.Port, n.Config.Networking.SSH.UseAgent)
+}
+
+// WriteSSHConfig writes an OpenSSH config snippet with a host entry for every server
+func (n *NodeShell) WriteSSHConfig(w io.Writer) error {
+	servers, err := listClusterServers(n.ctx, n.HetznerClient, n.Config)
+	if err != nil {
+		return err
+	}
+	bastionIP, err := natGatewayBastionIP(n.ctx, n.Config, n.HetznerClient)
+	if err != nil {
+		return err
+	}
+	knownHostsPath, err := KnownHostsPath(n.Config.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	sshConfig, err := formatSSHConfig(n.Config, servers, bastionIP, knownHostsPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, sshConfig)
+	return err
+}
+
+// resolveSSHTarget returns the servers matching a target, sorted by name
+// An instance name matches before a role, and a role before a pool.
+func resolveSSHTarget(servers []*hcloud.Server, target string) []*hcloud.Server {
+	for _, server := range servers {
+		if server.Name == target {
+			return []*hcloud.Server{server}
+		}
+	}
+
+	var byRole, byPool []*hcloud.Server
+	for _, server := range servers {
+		if serverRole(server) == target {
+			byRole = append(byRole, server)
+		}
+		if serverPool(server) == target {
+			byPool = append(byPool, server)
+		}
+	}
+	if len(byRole) > 0 {
+		return byRole
+	}
+	return byPool
+}
+
+// formatSSHConfig formats OpenSSH host entries for the servers of a cluster
+// Nodes are reached through the NAT gateway with ProxyJump when bastionIP is set,
+// and host keys are checked against the cluster's known_hosts file.
+func formatSSHConfig(cfg *config.Main, servers []*hcloud.Server, bastionIP, knownHostsPath string) (string, error) {
+	sshConfig := cfg.Networking.SSH
+
+	// Options shared by every host entry
+	var common []string
+	common = append(common, fmt.Sprintf("User %s", sshConfig.User))
+	common = append(common, fmt.Sprintf("Port %d", sshConfig.Port))
+	if !sshConfig.UsesCertificates() || sshConfig.CertificatePath != "" {
+		privKeyPath, err := sshConfig.ExpandedPrivateKeyPath()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand private key path: %w", err)
+		}
+		common = append(common, "IdentityFile "+sshConfigValue(privKeyPath))
+	}
+	if sshConfig.CertificatePath != "" {
+		certPath, err := sshConfig.ExpandedCertificatePath()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand certificate path: %w", err)
+		}
+		common = append(common, "CertificateFile "+sshConfigValue(certPath))
+	}
+	if !sshConfig.UseAgent {
+		common = append(common, "IdentitiesOnly yes")
+	}
+	common = append(common, "UserKnownHostsFile "+sshConfigValue(knownHostsPath))
+	common = append(common, "StrictHostKeyChecking accept-new")
+
+	// The NAT gateway comes first, as the other entries jump through it
+	var bastionName string
+	ordered := make([]*hcloud.Server, 0, len(servers))
+	for _, server := range servers {
+		if serverRole(server) == "nat-gateway" {
+			bastionName = server.Name
+			ordered = append([]*hcloud.Server{server}, ordered...)
+		} else {
+			ordered = append(ordered, server)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# hek3ster cluster %s\n", cfg.ClusterName)
+	for _, server := range ordered {
+		ip, err := GetServerSSHIP(server)
+		if err != nil {
+			fmt.Fprintf(&b, "\n# %s has no IP address\n", server.Name)
+			continue
+		}
+
+		fmt.Fprintf(&b, "\nHost %s\n", server.Name)
+		fmt.Fprintf(&b, "  HostName %s\n", ip)
+		for _, option := range common {
+			fmt.Fprintf(&b, "  %s\n", option)
+		}
+		if bastionIP != "" && server.Name != bastionName {
+			jump := bastionName
+			if jump == "" {
+				jump = fmt.Sprintf("%s@%s:%d", sshConfig.User, bastionIP, sshConfig.Port)
+			}
+			fmt.Fprintf(&b, "  ProxyJump %s\n", jump)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// sshConfigValue quotes an OpenSSH config value that contains spaces
+func sshConfigValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return strconv.Quote(value)
+	}
+	return value
+}
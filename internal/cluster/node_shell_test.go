@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+)
+
+func shellTestServers() []*hcloud.Server {
+	private := func(ip string) []hcloud.ServerPrivateNet {
+		return []hcloud.ServerPrivateNet{{IP: net.ParseIP(ip)}}
+	}
+	return []*hcloud.Server{
+		{Name: "test-master1", Labels: map[string]string{"cluster": "test", "role": "master"}, PrivateNet: private("10.0.0.3")},
+		{Name: "test-master2", Labels: map[string]string{"cluster": "test", "role": "master"}, PrivateNet: private("10.0.0.4")},
+		{
+			Name:      "test-nat-gateway",
+			Labels:    map[string]string{"cluster": "test", "role": "nat-gateway"},
+			PublicNet: hcloud.ServerPublicNet{IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("203.0.113.10")}},
+		},
+		{Name: "test-pool-php-worker1", Labels: map[string]string{"cluster": "test", "role": "worker", "pool": "php"}, PrivateNet: private("10.0.0.5")},
+		{Name: "test-db-abc12", Labels: map[string]string{HCloudNodeGroupLabel: "db"}, PrivateNet: private("10.0.0.6")},
+	}
+}
+
+func TestResolveSSHTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{target: "test-master2", want: []string{"test-master2"}},
+		{target: "master", want: []string{"test-master1", "test-master2"}},
+		{target: "masters", want: []string{"test-master1", "test-master2"}},
+		{target: "nat-gateway", want: []string{"test-nat-gateway"}},
+		{target: "worker", want: []string{"test-pool-php-worker1", "test-db-abc12"}},
+		{target: "php", want: []string{"test-pool-php-worker1"}},
+		{target: "db", want: []string{"test-db-abc12"}},
+		{target: "missing", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			var got []string
+			for _, server := range resolveSSHTarget(shellTestServers(), tt.target) {
+				got = append(got, server.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatSSHConfig(t *testing.T) {
+	cfg := &config.Main{ClusterName: "test"}
+	cfg.Networking.SSH = config.SSH{Port: 2222, User: "ops", PrivateKeyPath: "/keys/id ed25519"}
+
+	sshConfig, err := formatSSHConfig(cfg, shellTestServers(), "203.0.113.10", "/state/test/known_hosts")
+	if err != nil {
+		t.Fatalf("formatSSHConfig failed: %v", err)
+	}
+
+	// The gateway is listed first and reached directly
+	gateway := strings.Index(sshConfig, "Host test-nat-gateway\n")
+	master := strings.Index(sshConfig, "Host test-master1\n")
+	if gateway < 0 || master < 0 || gateway > master {
+		t.Fatalf("expected the NAT gateway before the nodes:\n%s", sshConfig)
+	}
+	gatewayEntry := sshConfig[gateway:master]
+	if strings.Contains(gatewayEntry, "ProxyJump") {
+		t.Errorf("NAT gateway should be reached directly:\n%s", gatewayEntry)
+	}
+
+	for _, expected := range []string{
+		"Host test-master1\n  HostName 10.0.0.3\n",
+		"  HostName 203.0.113.10\n",
+		"  User ops\n",
+		"  Port 2222\n",
+		`  IdentityFile "/keys/id ed25519"`,
+		"  IdentitiesOnly yes\n",
+		"  UserKnownHostsFile /state/test/known_hosts\n",
+		"  ProxyJump test-nat-gateway\n",
+	} {
+		if !strings.Contains(sshConfig, expected) {
+			t.Errorf("SSH config doesn't contain %q:\n%s", expected, sshConfig)
+		}
+	}
+	if got := strings.Count(sshConfig, "ProxyJump test-nat-gateway"); got != 4 {
+		t.Errorf("expected 4 nodes behind the NAT gateway, got %d", got)
+	}
+}
+
+func TestFormatSSHConfig_AgentCertificate(t *testing.T) {
+	cfg := &config.Main{ClusterName: "test"}
+	cfg.Networking.SSH = config.SSH{Port: 22, User: "root", UseAgent: true, PrivateKeyPath: "/keys/id_ed25519", UserCAPublicKeyPath: "/keys/user_ca.pub"}
+
+	sshConfig, err := formatSSHConfig(cfg, shellTestServers()[2:3], "", "/state/test/known_hosts")
+	if err != nil {
+		t.Fatalf("formatSSHConfig failed: %v", err)
+	}
+	for _, unexpected := range []string{"IdentityFile", "CertificateFile", "IdentitiesOnly", "ProxyJump"} {
+		if strings.Contains(sshConfig, unexpected) {
+			t.Errorf("SSH config with an agent certificate contains %q:\n%s", unexpected, sshConfig)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+// ensurePlacementGroup returns the spread placement group with the name, creating it
+// if it does not exist yet. The group is labelled with the cluster so the deleter
+// can find it.
+func (c *CreatorEnhanced) ensurePlacementGroup(name, role string) (*hcloud.PlacementGroup, error) {
+	placementGroup, err := c.HetznerClient.GetPlacementGroup(c.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if placementGroup != nil {
+		return placementGroup, nil
+	}
+
+	placementGroup, err = c.HetznerClient.CreatePlacementGroup(c.ctx, hcloud.PlacementGroupCreateOpts{
+		Name: name,
+		Type: hcloud.PlacementGroupTypeSpread,
+		Labels: map[string]string{
+			"cluster": c.Config.ClusterName,
+			"role":    role,
+			"managed": "hek3ster",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	util.LogSuccess(fmt.Sprintf("Placement group created: %s", name), "placement group")
+	return placementGroup, nil
+}
+
+// masterPlacementGroup returns the placement group of the masters, or nil if the
+// master pool does not use one
+func (c *CreatorEnhanced) masterPlacementGroup() (*hcloud.PlacementGroup, error) {
+	if !c.Config.MastersPool.SpreadPlacement() {
+		return nil, nil
+	}
+	return c.ensurePlacementGroup(c.Config.MastersPool.PlacementGroupName(c.Config.ClusterName), "master")
+}
+
+// workerPlacementGroup returns the placement group of a worker pool, or nil if the
+// pool does not use one
+func (c *CreatorEnhanced) workerPlacementGroup(pool config.WorkerNodePool) (*hcloud.PlacementGroup, error) {
+	if !pool.SpreadPlacement() {
+		return nil, nil
+	}
+	return c.ensurePlacementGroup(pool.PlacementGroupName(c.Config.ClusterName), "worker")
+}
+
+// ensureAutoscalingPlacementGroups creates the placement groups of autoscaled pools
+// The cluster autoscaler only references them by name, so they must exist before it
+// creates the first node.
+func (c *CreatorEnhanced) ensureAutoscalingPlacementGroups(pools []config.WorkerNodePool) error {
+	for _, pool := range pools {
+		if _, err := c.workerPlacementGroup(pool); err != nil {
+			return fmt.Errorf("failed to create placement group for pool %s: %w", pool.PlacementGroupName(c.Config.ClusterName), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
+)
+
+func TestCreatorPlacementGroups(t *testing.T) {
+	fake := hetznertest.NewFake()
+	cfg := &config.Main{ClusterName: "test"}
+	cfg.MastersPool.PlacementGroup = config.PlacementGroupSpread
+	c := &CreatorEnhanced{Config: cfg, HetznerClient: fake, ctx: context.Background()}
+
+	spreadName, packedName := "spread", "packed"
+	spread := config.WorkerNodePool{NodePool: config.NodePool{Name: &spreadName, PlacementGroup: config.PlacementGroupSpread}}
+	packed := config.WorkerNodePool{NodePool: config.NodePool{Name: &packedName}}
+
+	masters, err := c.masterPlacementGroup()
+	if err != nil || masters == nil || masters.Name != "test-masters" || masters.Type != hcloud.PlacementGroupTypeSpread {
+		t.Fatalf("masterPlacementGroup() = %+v, %v, want the spread group test-masters", masters, err)
+	}
+	if masters.Labels["cluster"] != "test" || masters.Labels["role"] != "master" {
+		t.Errorf("master placement group labels = %v, want cluster=test and role=master", masters.Labels)
+	}
+
+	// An existing group is reused
+	again, err := c.masterPlacementGroup()
+	if err != nil || again == nil || again.ID != masters.ID {
+		t.Errorf("second masterPlacementGroup() = %+v, %v, want the existing group", again, err)
+	}
+
+	workers, err := c.workerPlacementGroup(spread)
+	if err != nil || workers == nil || workers.Name != "test-spread" {
+		t.Errorf("workerPlacementGroup(spread) = %+v, %v, want test-spread", workers, err)
+	}
+	if none, err := c.workerPlacementGroup(packed); err != nil || none != nil {
+		t.Errorf("workerPlacementGroup(packed) = %+v, %v, want no group", none, err)
+	}
+
+	if err := c.ensureAutoscalingPlacementGroups([]config.WorkerNodePool{spread, packed}); err != nil {
+		t.Fatalf("ensureAutoscalingPlacementGroups() error = %v", err)
+	}
+	if got := len(fake.PlacementGroups()); got != 2 {
+		t.Errorf("placement groups = %d, want 2", got)
+	}
+}
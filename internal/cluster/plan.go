@@ -0,0 +1,510 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+// PlanAction describes what create or apply would do with a resource
+type PlanAction string
+
+const (
+	// PlanActionCreate means the resource does not exist and will be created
+	PlanActionCreate PlanAction = "create"
+	// PlanActionUpdate means the resource exists and will be modified in place
+	PlanActionUpdate PlanAction = "update"
+	// PlanActionReuse means the resource exists and will be used as-is
+	PlanActionReuse PlanAction = "reuse"
+	// PlanActionDelete means the resource exists and will be removed
+	PlanActionDelete PlanAction = "delete"
+	// PlanActionReplace means the resource exists and will be removed and created again
+	PlanActionReplace PlanAction = "replace"
+)
+
+// PlanChange is a single entry of a create or apply plan
+type PlanChange struct {
+	Action   PlanAction
+	Resource string
+	Name     string
+	Details  []string
+}
+
+// Planner previews the Hetzner resources that create would add, change or reuse
+// It only performs read-only API calls.
+type Planner struct {
+	Config        *config.Main
+	HetznerClient hetzner.API
+	ctx           context.Context
+}
+
+// NewPlanner creates a new creation planner
+func NewPlanner(cfg *config.Main, hetznerClient hetzner.API) *Planner {
+	return &Planner{
+		Config:        cfg,
+		HetznerClient: hetznerClient,
+		ctx:           context.Background(),
+	}
+}
+
+// Run builds the plan and prints it
+func (p *Planner) Run() error {
+	spinner := util.NewSpinner("Querying existing resources", "plan")
+	spinner.Start()
+	changes, err := p.Plan()
+	spinner.Stop(true)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(FormatPlan(changes))
+	return nil
+}
+
+// Plan builds the list of planned changes in the order create would apply them
+func (p *Planner) Plan() ([]PlanChange, error) {
+	var changes []PlanChange
+
+	// SSH key
+	sshKeyChange, err := p.planSSHKey()
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, sshKeyChange)
+
+	natEnabled := p.Config.Networking.PrivateNetwork.Enabled &&
+		p.Config.Networking.PrivateNetwork.NATGateway != nil &&
+		p.Config.Networking.PrivateNetwork.NATGateway.Enabled
+
+	// Network and NAT gateway
+	if p.Config.Networking.PrivateNetwork.Enabled {
+		networkName := p.Config.ClusterName
+		network, err := p.HetznerClient.GetNetwork(p.ctx, networkName)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, planNetwork(network, networkName, p.Config.Networking.PrivateNetwork.Subnet, natEnabled))
+
+		if natEnabled {
+			natCfg := p.Config.Networking.PrivateNetwork.NATGateway
+			location := natCfg.Location
+			if location == "" {
+				location = p.Config.MastersPool.Locations[0]
+			}
+			name := fmt.Sprintf("%s-nat-gateway", p.Config.ClusterName)
+			server, err := p.HetznerClient.GetServer(p.ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, planServer("nat gateway", server, name, natCfg.InstanceType, location))
+		}
+	}
+
+	// Masters
+	if p.Config.MastersPool.SpreadPlacement() {
+		change, err := p.planPlacementGroup(p.Config.MastersPool.PlacementGroupName(p.Config.ClusterName))
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	for i := 0; i < p.Config.MastersPool.InstanceCount; i++ {
+		location := p.Config.MastersPool.Locations[i%len(p.Config.MastersPool.Locations)]
+		name := fmt.Sprintf("%s-master-%d", p.Config.ClusterName, i+1)
+		if p.Config.MastersPool.PrimaryIPsEnabled() {
+			change, err := p.planPrimaryIP(name, location)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+		}
+		server, err := p.HetznerClient.GetServer(p.ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, planServer("master", server, name, p.Config.MastersPool.InstanceType, location))
+	}
+
+	// Static workers (autoscaling pools are managed by the cluster autoscaler)
+	staticPools, autoscalingPools := separateWorkerPools(p.Config.WorkerNodePools)
+	for poolIdx, pool := range staticPools {
+		poolName := workerPoolName(pool, poolIdx)
+		if pool.SpreadPlacement() {
+			change, err := p.planPlacementGroup(pool.PlacementGroupName(p.Config.ClusterName))
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+		}
+		for i := 0; i < pool.InstanceCount; i++ {
+			name := fmt.Sprintf("%s-worker-%s-%d", p.Config.ClusterName, poolName, i+1)
+			if pool.PrimaryIPsEnabled() {
+				change, err := p.planPrimaryIP(name, pool.Location)
+				if err != nil {
+					return nil, err
+				}
+				changes = append(changes, change)
+			}
+			for _, volume := range pool.Volumes {
+				change, err := p.planVolume(name, volume, pool.Location)
+				if err != nil {
+					return nil, err
+				}
+				changes = append(changes, change)
+			}
+			server, err := p.HetznerClient.GetServer(p.ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, planServer("worker", server, name, pool.InstanceType, pool.Location))
+		}
+	}
+
+	// Firewall
+	if !p.Config.Networking.PrivateNetwork.Enabled && p.Config.Networking.PublicNetwork.UseLocalFirewall {
+		util.LogInfo("Using local firewall, Hetzner cloud firewall is not planned", "plan")
+	} else {
+		name := fmt.Sprintf("%s-firewall", p.Config.ClusterName)
+		firewall, err := p.HetznerClient.GetFirewall(p.ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		change := PlanChange{Action: PlanActionCreate, Resource: "firewall", Name: name}
+		if firewall != nil {
+			change.Action = PlanActionReuse
+		} else {
+			change.Details = append(change.Details, fmt.Sprintf("applied to servers with label cluster=%s", p.Config.ClusterName))
+		}
+		changes = append(changes, change)
+	}
+
+	// API load balancer
+	if p.Config.CreateLoadBalancerForKubernetesAPI {
+		name := fmt.Sprintf("%s-api-lb", p.Config.ClusterName)
+		lb, err := p.HetznerClient.GetLoadBalancer(p.ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, planLoadBalancer("api load balancer", lb, name, "lb11", p.Config.MastersPool.Locations[0]))
+	}
+
+	// API floating IP
+	if p.Config.APIFloatingIP.Enabled {
+		change, err := p.planFloatingIP()
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	// Global load balancer with its DNS zone and certificate
+	if p.Config.LoadBalancer.Enabled {
+		if p.Config.DNSZone.Enabled && p.Config.Domain != "" {
+			name := p.Config.Domain
+			if p.Config.DNSZone.Name != "" {
+				name = p.Config.DNSZone.Name
+			}
+			zone, err := p.HetznerClient.GetZone(p.ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, planManagedResource("dns zone", name, zone != nil, zoneLabels(zone), p.Config.ClusterName))
+		}
+
+		if p.Config.SSLCertificate.Enabled {
+			name := p.Config.Domain
+			if p.Config.SSLCertificate.Name != "" {
+				name = p.Config.SSLCertificate.Name
+			}
+			cert, err := p.HetznerClient.GetCertificate(p.ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, planManagedResource("certificate", name, cert != nil, certificateLabels(cert), p.Config.ClusterName))
+		}
+
+		name := fmt.Sprintf("%s-global-lb", p.Config.ClusterName)
+		if p.Config.LoadBalancer.Name != nil {
+			name = *p.Config.LoadBalancer.Name
+		}
+		location := p.Config.MastersPool.Locations[0]
+		if p.Config.LoadBalancer.Location != "" {
+			location = p.Config.LoadBalancer.Location
+		}
+		lb, err := p.HetznerClient.GetLoadBalancer(p.ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, planLoadBalancer("global load balancer", lb, name, p.Config.LoadBalancer.Type, location))
+	}
+
+	for _, pool := range autoscalingPools {
+		if pool.SpreadPlacement() {
+			change, err := p.planPlacementGroup(pool.PlacementGroupName(p.Config.ClusterName))
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+		}
+		util.LogInfo(fmt.Sprintf("Autoscaling pool %s is managed by the cluster autoscaler and not planned", pool.BuildNodePoolName(p.Config.ClusterName)), "plan")
+	}
+
+	return changes, nil
+}
+
+// planPlacementGroup plans a spread placement group
+func (p *Planner) planPlacementGroup(name string) (PlanChange, error) {
+	placementGroup, err := p.HetznerClient.GetPlacementGroup(p.ctx, name)
+	if err != nil {
+		return PlanChange{}, err
+	}
+	change := PlanChange{Action: PlanActionCreate, Resource: "placement group", Name: name}
+	if placementGroup != nil {
+		change.Action = PlanActionReuse
+	} else {
+		change.Details = append(change.Details, "type spread")
+	}
+	return change, nil
+}
+
+// planPrimaryIP plans the primary IPv4 of a node
+func (p *Planner) planPrimaryIP(nodeName, location string) (PlanChange, error) {
+	name := primaryIPName(nodeName)
+	primaryIP, err := p.HetznerClient.GetPrimaryIP(p.ctx, name)
+	if err != nil {
+		return PlanChange{}, err
+	}
+	change := PlanChange{Action: PlanActionCreate, Resource: "primary ip", Name: name}
+	if primaryIP != nil {
+		change.Action = PlanActionReuse
+		change.Details = append(change.Details, fmt.Sprintf("address %s", primaryIP.IP))
+	} else {
+		change.Details = append(change.Details, fmt.Sprintf("ipv4 in %s", location))
+	}
+	return change, nil
+}
+
+// planVolume plans a volume of a worker node
+func (p *Planner) planVolume(nodeName string, spec config.Volume, location string) (PlanChange, error) {
+	name := volumeName(nodeName, spec.Name)
+	volume, err := p.HetznerClient.GetVolume(p.ctx, name)
+	if err != nil {
+		return PlanChange{}, err
+	}
+	change := PlanChange{Action: PlanActionCreate, Resource: "volume", Name: name}
+	if volume != nil {
+		change.Action = PlanActionReuse
+		if volume.Size != spec.Size {
+			change.Details = append(change.Details, fmt.Sprintf("size %d GB differs from configured %d GB (not changed by create)", volume.Size, spec.Size))
+		}
+	} else {
+		change.Details = append(change.Details, fmt.Sprintf("%d GB %s in %s, mounted at %s", spec.Size, spec.Format, location, spec.MountPath))
+	}
+	return change, nil
+}
+
+// planFloatingIP plans the floating IP of the Kubernetes API
+func (p *Planner) planFloatingIP() (PlanChange, error) {
+	name := p.Config.APIFloatingIP.Name(p.Config.ClusterName)
+	floatingIP, err := p.HetznerClient.GetFloatingIP(p.ctx, name)
+	if err != nil {
+		return PlanChange{}, err
+	}
+	change := PlanChange{Action: PlanActionCreate, Resource: "floating ip", Name: name}
+	if floatingIP != nil {
+		change.Action = PlanActionReuse
+		change.Details = append(change.Details, fmt.Sprintf("address %s", floatingIP.IP))
+	} else {
+		homeLocation := p.Config.APIFloatingIP.HomeLocation
+		if homeLocation == "" {
+			homeLocation = p.Config.MastersPool.Locations[0]
+		}
+		change.Details = append(change.Details, fmt.Sprintf("ipv4 in %s, failover between %d master(s)", homeLocation, p.Config.MastersPool.InstanceCount))
+	}
+	return change, nil
+}
+
+// planSSHKey plans the cluster SSH key
+func (p *Planner) planSSHKey() (PlanChange, error) {
+	name := fmt.Sprintf("%s-ssh-key", p.Config.ClusterName)
+	change := PlanChange{Action: PlanActionCreate, Resource: "ssh key", Name: name}
+
+	sshKey, err := p.HetznerClient.GetSSHKey(p.ctx, name)
+	if err != nil {
+		return change, err
+	}
+	if sshKey == nil {
+		return change, nil
+	}
+
+	change.Action = PlanActionReuse
+	pubKeyPath, err := p.Config.Networking.SSH.ExpandedPublicKeyPath()
+	if err == nil {
+		if fingerprint, err := util.CalculateFingerprint(pubKeyPath); err == nil && fingerprint != sshKey.Fingerprint {
+			change.Details = append(change.Details, fmt.Sprintf("fingerprint %s differs from local key %s (not changed by create)", sshKey.Fingerprint, fingerprint))
+		}
+	}
+	return change, nil
+}
+
+// planNetwork plans the private network and the NAT gateway default route
+func planNetwork(network *hcloud.Network, name, subnet string, natEnabled bool) PlanChange {
+	if network == nil {
+		change := PlanChange{Action: PlanActionCreate, Resource: "network", Name: name, Details: []string{fmt.Sprintf("ip range %s", subnet)}}
+		if natEnabled {
+			change.Details = append(change.Details, "default route via NAT gateway")
+		}
+		return change
+	}
+
+	change := PlanChange{Action: PlanActionReuse, Resource: "network", Name: name}
+	if network.IPRange != nil && network.IPRange.String() != subnet {
+		change.Details = append(change.Details, fmt.Sprintf("ip range %s differs from configured %s (not changed by create)", network.IPRange.String(), subnet))
+	}
+
+	if natEnabled {
+		hasDefaultRoute := false
+		for _, route := range network.Routes {
+			if route.Destination != nil && route.Destination.String() == defaultRouteDestination.String() {
+				hasDefaultRoute = true
+				break
+			}
+		}
+		if !hasDefaultRoute {
+			change.Action = PlanActionUpdate
+			change.Details = append(change.Details, "add default route via NAT gateway")
+		}
+	}
+
+	return change
+}
+
+// planServer plans a single server, reporting drift between the existing server and the configuration
+func planServer(resource string, server *hcloud.Server, name, instanceType, location string) PlanChange {
+	if server == nil {
+		return PlanChange{
+			Action:   PlanActionCreate,
+			Resource: resource,
+			Name:     name,
+			Details:  []string{fmt.Sprintf("type %s in %s", instanceType, location)},
+		}
+	}
+
+	change := PlanChange{Action: PlanActionReuse, Resource: resource, Name: name}
+	if server.ServerType != nil && server.ServerType.Name != instanceType {
+		change.Details = append(change.Details, fmt.Sprintf("type %s differs from configured %s (not changed by create)", server.ServerType.Name, instanceType))
+	}
+	if server.Location != nil && server.Location.Name != location {
+		change.Details = append(change.Details, fmt.Sprintf("location %s differs from configured %s (not changed by create)", server.Location.Name, location))
+	}
+	return change
+}
+
+// planLoadBalancer plans a load balancer, reporting drift in type and location
+func planLoadBalancer(resource string, lb *hcloud.LoadBalancer, name, lbType, location string) PlanChange {
+	if lb == nil {
+		return PlanChange{
+			Action:   PlanActionCreate,
+			Resource: resource,
+			Name:     name,
+			Details:  []string{fmt.Sprintf("type %s in %s", lbType, location)},
+		}
+	}
+
+	change := PlanChange{Action: PlanActionReuse, Resource: resource, Name: name}
+	if lb.LoadBalancerType != nil && lb.LoadBalancerType.Name != lbType {
+		change.Details = append(change.Details, fmt.Sprintf("type %s differs from configured %s (not changed by create)", lb.LoadBalancerType.Name, lbType))
+	}
+	if lb.Location != nil && lb.Location.Name != location {
+		change.Details = append(change.Details, fmt.Sprintf("location %s differs from configured %s (not changed by create)", lb.Location.Name, location))
+	}
+	return change
+}
+
+// planManagedResource plans a DNS zone or certificate, noting when an existing resource is not managed by this cluster
+func planManagedResource(resource, name string, exists bool, labels map[string]string, clusterName string) PlanChange {
+	if !exists {
+		return PlanChange{Action: PlanActionCreate, Resource: resource, Name: name}
+	}
+
+	change := PlanChange{Action: PlanActionReuse, Resource: resource, Name: name}
+	if labels["cluster"] != clusterName || labels["managed"] != "hek3ster" {
+		change.Details = append(change.Details, "not managed by this cluster (will not be deleted by delete)")
+	}
+	return change
+}
+
+// zoneLabels returns the labels of a zone, or nil if the zone does not exist
+func zoneLabels(zone *hcloud.Zone) map[string]string {
+	if zone == nil {
+		return nil
+	}
+	return zone.Labels
+}
+
+// certificateLabels returns the labels of a certificate, or nil if the certificate does not exist
+func certificateLabels(cert *hcloud.Certificate) map[string]string {
+	if cert == nil {
+		return nil
+	}
+	return cert.Labels
+}
+
+// FormatPlan renders a plan as a terraform-style diff followed by a summary line
+func FormatPlan(changes []PlanChange) string {
+	green := "\033[32m"
+	yellow := "\033[33m"
+	red := "\033[31m"
+	reset := "\033[0m"
+
+	if os.Getenv("NO_COLOR") != "" {
+		green = ""
+		yellow = ""
+		red = ""
+		reset = ""
+	}
+
+	var b strings.Builder
+	var toCreate, toUpdate, toDelete, toReuse int
+
+	b.WriteString("\nhek3ster will perform the following actions:\n\n")
+	for _, change := range changes {
+		var symbol, color string
+		switch change.Action {
+		case PlanActionCreate:
+			symbol, color = "+", green
+			toCreate++
+		case PlanActionUpdate:
+			symbol, color = "~", yellow
+			toUpdate++
+		case PlanActionDelete:
+			symbol, color = "-", red
+			toDelete++
+		case PlanActionReplace:
+			symbol, color = "-/+", yellow
+			toCreate++
+			toDelete++
+		default:
+			symbol, color = "=", ""
+			toReuse++
+		}
+
+		fmt.Fprintf(&b, "  %s%s %s \"%s\"%s", color, symbol, change.Resource, change.Name, reset)
+		if change.Action == PlanActionReuse {
+			b.WriteString(" (exists)")
+		}
+		b.WriteString("\n")
+		for _, detail := range change.Details {
+			fmt.Fprintf(&b, "      %s\n", detail)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nPlan: %d to add, %d to change, %d to destroy, %d to reuse.\n", toCreate, toUpdate, toDelete, toReuse)
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// TestPlanServer tests server planning and drift detection
+func TestPlanServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		server      *hcloud.Server
+		wantAction  PlanAction
+		wantDetails int
+	}{
+		{
+			name:        "missing server is created",
+			server:      nil,
+			wantAction:  PlanActionCreate,
+			wantDetails: 1,
+		},
+		{
+			name: "matching server is reused",
+			server: &hcloud.Server{
+				ServerType: &hcloud.ServerType{Name: "cpx21"},
+				Location:   &hcloud.Location{Name: "fsn1"},
+			},
+			wantAction:  PlanActionReuse,
+			wantDetails: 0,
+		},
+		{
+			name: "drifted server is reused with details",
+			server: &hcloud.Server{
+				ServerType: &hcloud.ServerType{Name: "cpx31"},
+				Location:   &hcloud.Location{Name: "nbg1"},
+			},
+			wantAction:  PlanActionReuse,
+			wantDetails: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := planServer("master", tt.server, "test-master-1", "cpx21", "fsn1")
+			if change.Action != tt.wantAction {
+				t.Errorf("Action = %s, want %s", change.Action, tt.wantAction)
+			}
+			if len(change.Details) != tt.wantDetails {
+				t.Errorf("Details = %v, want %d entries", change.Details, tt.wantDetails)
+			}
+		})
+	}
+}
+
+// TestPlanNetwork tests network planning including the NAT gateway route
+func TestPlanNetwork(t *testing.T) {
+	_, ipRange, _ := net.ParseCIDR("10.0.0.0/16")
+	_, defaultRoute, _ := net.ParseCIDR("0.0.0.0/0")
+
+	tests := []struct {
+		name       string
+		network    *hcloud.Network
+		natEnabled bool
+		wantAction PlanAction
+	}{
+		{
+			name:       "missing network is created",
+			network:    nil,
+			natEnabled: true,
+			wantAction: PlanActionCreate,
+		},
+		{
+			name:       "existing network without NAT is reused",
+			network:    &hcloud.Network{IPRange: ipRange},
+			natEnabled: false,
+			wantAction: PlanActionReuse,
+		},
+		{
+			name:       "existing network missing default route is updated",
+			network:    &hcloud.Network{IPRange: ipRange},
+			natEnabled: true,
+			wantAction: PlanActionUpdate,
+		},
+		{
+			name: "existing network with default route is reused",
+			network: &hcloud.Network{
+				IPRange: ipRange,
+				Routes:  []hcloud.NetworkRoute{{Destination: defaultRoute}},
+			},
+			natEnabled: true,
+			wantAction: PlanActionReuse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := planNetwork(tt.network, "test", "10.0.0.0/16", tt.natEnabled)
+			if change.Action != tt.wantAction {
+				t.Errorf("Action = %s, want %s", change.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+// TestPlanManagedResource tests ownership detection for zones and certificates
+func TestPlanManagedResource(t *testing.T) {
+	owned := planManagedResource("dns zone", "example.com", true, map[string]string{"cluster": "test", "managed": "hek3ster"}, "test")
+	if owned.Action != PlanActionReuse || len(owned.Details) != 0 {
+		t.Errorf("owned zone = %+v, want reuse without details", owned)
+	}
+
+	foreign := planManagedResource("dns zone", "example.com", true, nil, "test")
+	if foreign.Action != PlanActionReuse || len(foreign.Details) != 1 {
+		t.Errorf("foreign zone = %+v, want reuse with one detail", foreign)
+	}
+
+	missing := planManagedResource("certificate", "example.com", false, nil, "test")
+	if missing.Action != PlanActionCreate {
+		t.Errorf("missing certificate action = %s, want create", missing.Action)
+	}
+}
+
+// TestFormatPlan tests the plan summary line
+func TestFormatPlan(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	output := FormatPlan([]PlanChange{
+		{Action: PlanActionCreate, Resource: "master", Name: "test-master-1"},
+		{Action: PlanActionCreate, Resource: "master", Name: "test-master-2"},
+		{Action: PlanActionUpdate, Resource: "network", Name: "test"},
+		{Action: PlanActionReuse, Resource: "ssh key", Name: "test-ssh-key"},
+		{Action: PlanActionDelete, Resource: "worker", Name: "test-worker-small-3"},
+		{Action: PlanActionReplace, Resource: "worker", Name: "test-worker-small-1"},
+	})
+
+	if !strings.Contains(output, `+ master "test-master-1"`) {
+		t.Errorf("output missing create line:\n%s", output)
+	}
+	if !strings.Contains(output, `~ network "test"`) {
+		t.Errorf("output missing update line:\n%s", output)
+	}
+	if !strings.Contains(output, `= ssh key "test-ssh-key" (exists)`) {
+		t.Errorf("output missing reuse line:\n%s", output)
+	}
+	if !strings.Contains(output, `- worker "test-worker-small-3"`) {
+		t.Errorf("output missing delete line:\n%s", output)
+	}
+	if !strings.Contains(output, `-/+ worker "test-worker-small-1"`) {
+		t.Errorf("output missing replace line:\n%s", output)
+	}
+	if !strings.Contains(output, "Plan: 3 to add, 1 to change, 2 to destroy, 1 to reuse.") {
+		t.Errorf("output missing summary:\n%s", output)
+	}
+}
@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+// primaryIPName returns the name of the primary IPv4 of a node
+func primaryIPName(nodeName string) string {
+	return nodeName + "-ipv4"
+}
+
+// ensurePrimaryIP returns the primary IPv4 of a node, creating it if it does not exist
+// yet. An existing primary IP is reused, so a recreated node keeps its public IP. The
+// IP carries the labels of its server so the deleter can apply the pool's policy.
+func (c *CreatorEnhanced) ensurePrimaryIP(nodeName, location string, labels map[string]string) (*hcloud.PrimaryIP, error) {
+	name := primaryIPName(nodeName)
+	primaryIP, err := c.HetznerClient.GetPrimaryIP(c.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if primaryIP != nil {
+		if primaryIP.AssigneeID != 0 {
+			return nil, fmt.Errorf("primary IP %s is already assigned to server %d", name, primaryIP.AssigneeID)
+		}
+		if primaryIP.Location != nil && primaryIP.Location.Name != location {
+			return nil, fmt.Errorf("primary IP %s is in %s, but the node is created in %s", name, primaryIP.Location.Name, location)
+		}
+		util.LogInfo(fmt.Sprintf("Reusing primary IP %s (%s)", name, primaryIP.IP), "primary ip")
+		return primaryIP, nil
+	}
+
+	primaryIP, err = c.HetznerClient.CreatePrimaryIP(c.ctx, hcloud.PrimaryIPCreateOpts{
+		Name:         name,
+		Type:         hcloud.PrimaryIPTypeIPv4,
+		AssigneeType: "server",
+		AutoDelete:   hcloud.Ptr(false),
+		Location:     location,
+		Labels:       maps.Clone(labels),
+	})
+	if err != nil {
+		return nil, err
+	}
+	util.LogSuccess(fmt.Sprintf("Primary IP created: %s (%s)", name, primaryIP.IP), "primary ip")
+	return primaryIP, nil
+}
+
+// primaryIPPublicNet returns the public network of a node that uses a primary IPv4
+func (c *CreatorEnhanced) primaryIPPublicNet(nodeName, location string, labels map[string]string) (*hcloud.ServerCreatePublicNet, error) {
+	primaryIP, err := c.ensurePrimaryIP(nodeName, location, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	ipv6 := c.Config.Networking.PublicNetwork.IPv6
+	return &hcloud.ServerCreatePublicNet{
+		EnableIPv4: true,
+		EnableIPv6: ipv6 == nil || ipv6.Enabled,
+		IPv4:       primaryIP,
+	}, nil
+}
+
+// keepPrimaryIP reports whether a primary IP survives the deletion of its server
+// The pool is found through the role and pool labels of the IP. IPs of pools that are
+// no longer configured are deleted.
+func keepPrimaryIP(cfg *config.Main, primaryIP *hcloud.PrimaryIP) bool {
+	switch primaryIP.Labels["role"] {
+	case "master":
+		return cfg.MastersPool.KeepPrimaryIPs()
+	case "worker":
+		for _, pool := range cfg.WorkerNodePools {
+			if pool.Name != nil && *pool.Name == primaryIP.Labels["pool"] {
+				return pool.KeepPrimaryIPs()
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
+)
+
+func TestCreatorPrimaryIPs(t *testing.T) {
+	ctx := context.Background()
+	fake := hetznertest.NewFake()
+	c := &CreatorEnhanced{Config: &config.Main{ClusterName: "test"}, HetznerClient: fake, ctx: ctx}
+	labels := map[string]string{"cluster": "test", "role": "master", "managed": "hek3ster"}
+
+	publicNet, err := c.primaryIPPublicNet("test-master-1", "fsn1", labels)
+	if err != nil {
+		t.Fatalf("primaryIPPublicNet() error = %v", err)
+	}
+	if publicNet.IPv4 == nil || publicNet.IPv4.Name != "test-master-1-ipv4" || !publicNet.EnableIPv4 || !publicNet.EnableIPv6 {
+		t.Fatalf("primaryIPPublicNet() = %+v, want IPv4 test-master-1-ipv4 with IPv6 enabled", publicNet)
+	}
+	if publicNet.IPv4.AutoDelete || publicNet.IPv4.Labels["role"] != "master" {
+		t.Errorf("primary IP = %+v, want no auto delete and the server labels", publicNet.IPv4)
+	}
+
+	// Recreating the node reuses its primary IP
+	server, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-1", Location: &hcloud.Location{Name: "fsn1"}, PublicNet: publicNet})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if _, err := c.ensurePrimaryIP("test-master-1", "fsn1", labels); err == nil || !strings.Contains(err.Error(), "already assigned") {
+		t.Errorf("ensurePrimaryIP() of an assigned IP error = %v, want already assigned", err)
+	}
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	reused, err := c.ensurePrimaryIP("test-master-1", "fsn1", labels)
+	if err != nil || reused.ID != publicNet.IPv4.ID {
+		t.Errorf("ensurePrimaryIP() after recreation = %+v, %v, want primary IP %d", reused, err, publicNet.IPv4.ID)
+	}
+	if _, err := c.ensurePrimaryIP("test-master-1", "nbg1", labels); err == nil || !strings.Contains(err.Error(), "is in fsn1") {
+		t.Errorf("ensurePrimaryIP() in another location error = %v, want a location error", err)
+	}
+	if got := len(fake.PrimaryIPs()); got != 1 {
+		t.Errorf("primary IPs = %d, want 1", got)
+	}
+}
+
+func TestKeepPrimaryIP(t *testing.T) {
+	web, db := "web", "db"
+	cfg := &config.Main{ClusterName: "test"}
+	cfg.MastersPool.PrimaryIPs = &config.PrimaryIPs{Enabled: true, DeletePolicy: config.PrimaryIPDeletePolicyKeep}
+	cfg.WorkerNodePools = []config.WorkerNodePool{
+		{NodePool: config.NodePool{Name: &web, PrimaryIPs: &config.PrimaryIPs{Enabled: true}}},
+		{NodePool: config.NodePool{Name: &db, PrimaryIPs: &config.PrimaryIPs{Enabled: true, DeletePolicy: config.PrimaryIPDeletePolicyKeep}}},
+	}
+
+	tests := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"role": "master"}, true},
+		{map[string]string{"role": "worker", "pool": "web"}, false},
+		{map[string]string{"role": "worker", "pool": "db"}, true},
+		{map[string]string{"role": "worker", "pool": "removed"}, false},
+		{map[string]string{}, false},
+	}
+	for _, tt := range tests {
+		if got := keepPrimaryIP(cfg, &hcloud.PrimaryIP{Labels: tt.labels}); got != tt.want {
+			t.Errorf("keepPrimaryIP(%v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+// rollbackOrder lists resource kinds in the order they are deleted on rollback
+// Load balancers reference servers, networks and certificates, servers are attached
+// to networks, firewalls, primary and floating IPs, volumes and placement groups, and certificates are validated through
+// the DNS zone.
+var rollbackOrder = []hetzner.ResourceKind{
+	hetzner.ResourceLoadBalancer,
+	hetzner.ResourceServer,
+	hetzner.ResourcePrimaryIP,
+	hetzner.ResourceFloatingIP,
+	hetzner.ResourceVolume,
+	hetzner.ResourcePlacementGroup,
+	hetzner.ResourceFirewall,
+	hetzner.ResourceCertificate,
+	hetzner.ResourceZone,
+	hetzner.ResourceNetwork,
+	hetzner.ResourceSSHKey,
+}
+
+// rollbackSequence orders created resources for deletion: by kind following
+// rollbackOrder, and within a kind in reverse creation order
+func rollbackSequence(resources []hetzner.CreatedResource) []hetzner.CreatedResource {
+	sequence := make([]hetzner.CreatedResource, 0, len(resources))
+	for _, kind := range rollbackOrder {
+		for i := len(resources) - 1; i >= 0; i-- {
+			if resources[i].Kind == kind {
+				sequence = append(sequence, resources[i])
+			}
+		}
+	}
+	return sequence
+}
+
+// rollbackResources deletes the resources created during a failed run
+// Resources that existed before the run are never recorded and are left untouched.
+// Failures are logged and do not stop the rollback; the remaining resources are returned.
+func rollbackResources(ctx context.Context, hetznerClient hetzner.API, resources []hetzner.CreatedResource) []hetzner.CreatedResource {
+	var remaining []hetzner.CreatedResource
+
+	for _, resource := range rollbackSequence(resources) {
+		util.LogInfo(fmt.Sprintf("Deleting %s %s", resource.Kind, resource.Name), "rollback")
+		if err := deleteCreatedResource(ctx, hetznerClient, resource); err != nil {
+			util.LogError(fmt.Sprintf("Failed to delete %s %s: %v", resource.Kind, resource.Name, err), "rollback")
+			remaining = append(remaining, resource)
+			continue
+		}
+		util.LogSuccess(fmt.Sprintf("Deleted %s %s", resource.Kind, resource.Name), "rollback")
+	}
+
+	return remaining
+}
+
+// deleteCreatedResource deletes a single tracked resource by ID
+func deleteCreatedResource(ctx context.Context, hetznerClient hetzner.API, resource hetzner.CreatedResource) error {
+	switch resource.Kind {
+	case hetzner.ResourceLoadBalancer:
+		return hetznerClient.DeleteLoadBalancer(ctx, &hcloud.LoadBalancer{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceServer:
+		return hetznerClient.DeleteServer(ctx, &hcloud.Server{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourcePrimaryIP:
+		return hetznerClient.DeletePrimaryIP(ctx, &hcloud.PrimaryIP{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceFloatingIP:
+		return hetznerClient.DeleteFloatingIP(ctx, &hcloud.FloatingIP{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceVolume:
+		return hetznerClient.DeleteVolume(ctx, &hcloud.Volume{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourcePlacementGroup:
+		return hetznerClient.DeletePlacementGroup(ctx, &hcloud.PlacementGroup{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceFirewall:
+		return hetznerClient.DeleteFirewall(ctx, &hcloud.Firewall{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceCertificate:
+		return hetznerClient.DeleteCertificate(ctx, &hcloud.Certificate{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceZone:
+		return hetznerClient.DeleteZone(ctx, &hcloud.Zone{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceNetwork:
+		return hetznerClient.DeleteNetwork(ctx, &hcloud.Network{ID: resource.ID, Name: resource.Name})
+	case hetzner.ResourceSSHKey:
+		return hetznerClient.DeleteSSHKey(ctx, &hcloud.SSHKey{ID: resource.ID, Name: resource.Name})
+	default:
+		return fmt.Errorf("unsupported resource kind %s", resource.Kind)
+	}
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
+)
+
+// TestRollbackSequence tests that resources are deleted in reverse dependency order
+func TestRollbackSequence(t *testing.T) {
+	created := []hetzner.CreatedResource{
+		{Kind: hetzner.ResourceSSHKey, ID: 1, Name: "test-ssh-key"},
+		{Kind: hetzner.ResourceNetwork, ID: 2, Name: "test"},
+		{Kind: hetzner.ResourceServer, ID: 3, Name: "test-nat-gateway"},
+		{Kind: hetzner.ResourceServer, ID: 4, Name: "test-master-1"},
+		{Kind: hetzner.ResourceFirewall, ID: 5, Name: "test-firewall"},
+		{Kind: hetzner.ResourceLoadBalancer, ID: 6, Name: "test-api-lb"},
+		{Kind: hetzner.ResourceZone, ID: 7, Name: "example.com"},
+		{Kind: hetzner.ResourceCertificate, ID: 8, Name: "example.com"},
+		{Kind: hetzner.ResourceLoadBalancer, ID: 9, Name: "test-global-lb"},
+		{Kind: hetzner.ResourcePlacementGroup, ID: 10, Name: "test-masters"},
+		{Kind: hetzner.ResourcePrimaryIP, ID: 11, Name: "test-master-1-ipv4"},
+		{Kind: hetzner.ResourceFloatingIP, ID: 12, Name: "test-api-ip"},
+		{Kind: hetzner.ResourceVolume, ID: 13, Name: "test-worker-1-data"},
+	}
+
+	want := []int64{9, 6, 4, 3, 11, 12, 13, 10, 5, 8, 7, 2, 1}
+
+	sequence := rollbackSequence(created)
+	if len(sequence) != len(want) {
+		t.Fatalf("got %d resources, want %d", len(sequence), len(want))
+	}
+	for i, id := range want {
+		if sequence[i].ID != id {
+			t.Errorf("position %d: got %s %s (ID %d), want ID %d", i, sequence[i].Kind, sequence[i].Name, sequence[i].ID, id)
+		}
+	}
+}
+
+// TestRollbackResources tests that only tracked resources are deleted
+func TestRollbackResources(t *testing.T) {
+	ctx := context.Background()
+	fake := hetznertest.NewFake()
+
+	// Created before the run and not tracked
+	if _, err := fake.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{Name: "test-ssh-key", PublicKey: "ssh-ed25519 AAAA"}); err != nil {
+		t.Fatalf("CreateSSHKey() error = %v", err)
+	}
+
+	tracker := hetzner.NewResourceTracker()
+	fake.SetResourceTracker(tracker)
+	network, _ := fake.CreateNetwork(ctx, hcloud.NetworkCreateOpts{Name: "test"})
+	firewall, _ := fake.CreateFirewall(ctx, hcloud.FirewallCreateOpts{Name: "test-firewall"})
+	placementGroup, _ := fake.CreatePlacementGroup(ctx, hcloud.PlacementGroupCreateOpts{Name: "test-masters", Type: hcloud.PlacementGroupTypeSpread})
+	primaryIP, _ := fake.CreatePrimaryIP(ctx, hcloud.PrimaryIPCreateOpts{Name: "test-master-1-ipv4", Type: hcloud.PrimaryIPTypeIPv4, AutoDelete: hcloud.Ptr(false)})
+	fake.CreateServer(ctx, hcloud.ServerCreateOpts{
+		Name:           "test-master-1",
+		Networks:       []*hcloud.Network{network},
+		Firewalls:      []*hcloud.ServerCreateFirewall{{Firewall: *firewall}},
+		PlacementGroup: placementGroup,
+		PublicNet:      &hcloud.ServerCreatePublicNet{EnableIPv4: true, IPv4: primaryIP},
+	})
+	fake.CreateLoadBalancer(ctx, hcloud.LoadBalancerCreateOpts{Name: "test-api-lb", Network: network})
+	fake.SetResourceTracker(nil)
+
+	remaining := rollbackResources(ctx, fake, tracker.Resources())
+	if len(remaining) != 0 {
+		t.Errorf("rollbackResources() left %v", remaining)
+	}
+	if len(fake.Servers())+len(fake.Networks())+len(fake.Firewalls())+len(fake.LoadBalancers())+len(fake.PlacementGroups())+len(fake.PrimaryIPs()) != 0 {
+		t.Error("tracked resources left after rollback")
+	}
+	if len(fake.SSHKeys()) != 1 {
+		t.Error("untracked SSH key was deleted")
+	}
+}
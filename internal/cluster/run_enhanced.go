@@ -18,30 +18,24 @@ import (
 // RunnerEnhanced handles running commands on cluster nodes with parallel execution
 type RunnerEnhanced struct {
 	Config        *config.Main
-	HetznerClient *hetzner.Client
+	HetznerClient hetzner.API
 	SSHClient     *util.SSH
 	ctx           context.Context
 }
 
 // NewRunnerEnhanced creates a new enhanced command runner
-func NewRunnerEnhanced(cfg *config.Main, hetznerClient *hetzner.Client) (*RunnerEnhanced, error) {
-	privKeyPath, err := cfg.Networking.SSH.ExpandedPrivateKeyPath()
+func NewRunnerEnhanced(cfg *config.Main, hetznerClient hetzner.API) (*RunnerEnhanced, error) {
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to expand private key path: %w", err)
+		return nil, err
 	}
 
-	pubKeyPath, err := cfg.Networking.SSH.ExpandedPublicKeyPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to expand public key path: %w", err)
-	}
-
-	sshClient := util.NewSSH(privKeyPath, pubKeyPath)
-
 	runner := &RunnerEnhanced{
 		Config:        cfg,
 		HetznerClient: hetznerClient,
 		SSHClient:     sshClient,
-		ctx:           context.Background(),
+		ctx:           ctx,
 	}
 
 	// Configure NAT gateway as bastion host if enabled
@@ -160,36 +154,10 @@ func (r *RunnerEnhanced) validateScriptFile(scriptPath string) error {
 
 // runCommandOnAllNodesParallel runs a command on all nodes in parallel
 func (r *RunnerEnhanced) runCommandOnAllNodesParallel(command string) error {
-	// Find all servers with cluster label
-	clusterLabel := fmt.Sprintf("cluster=%s", r.Config.ClusterName)
-	servers, err := r.HetznerClient.ListServers(r.ctx, hcloud.ServerListOpts{
-		ListOpts: hcloud.ListOpts{
-			LabelSelector: clusterLabel,
-		},
-	})
+	// Find all servers of the cluster, including autoscaled ones
+	allServers, err := listClusterServers(r.ctx, r.HetznerClient, r.Config)
 	if err != nil {
-		return fmt.Errorf("failed to list servers: %w", err)
-	}
-
-	// Also find servers from autoscaling-enabled worker node pools
-	autoscaledServers, err := r.findAutoscaledPoolServers()
-	if err != nil {
-		return fmt.Errorf("failed to find autoscaled pool servers: %w", err)
-	}
-
-	// Merge servers, avoiding duplicates
-	serverMap := make(map[int64]*hcloud.Server)
-	for _, server := range servers {
-		serverMap[server.ID] = server
-	}
-	for _, server := range autoscaledServers {
-		serverMap[server.ID] = server
-	}
-
-	// Convert map back to slice
-	allServers := make([]*hcloud.Server, 0, len(serverMap))
-	for _, server := range serverMap {
-		allServers = append(allServers, server)
+		return err
 	}
 
 	if len(allServers) == 0 {
@@ -311,36 +279,10 @@ func (r *RunnerEnhanced) executeCommandOnServer(server *hcloud.Server, command s
 
 // runScriptOnAllNodesParallel runs a script on all nodes in parallel
 func (r *RunnerEnhanced) runScriptOnAllNodesParallel(scriptContent, scriptName string) error {
-	// Find all servers with cluster label
-	clusterLabel := fmt.Sprintf("cluster=%s", r.Config.ClusterName)
-	servers, err := r.HetznerClient.ListServers(r.ctx, hcloud.ServerListOpts{
-		ListOpts: hcloud.ListOpts{
-			LabelSelector: clusterLabel,
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list servers: %w", err)
-	}
-
-	// Also find servers from autoscaling-enabled worker node pools
-	autoscaledServers, err := r.findAutoscaledPoolServers()
+	// Find all servers of the cluster, including autoscaled ones
+	allServers, err := listClusterServers(r.ctx, r.HetznerClient, r.Config)
 	if err != nil {
-		return fmt.Errorf("failed to find autoscaled pool servers: %w", err)
-	}
-
-	// Merge servers, avoiding duplicates
-	serverMap := make(map[int64]*hcloud.Server)
-	for _, server := range servers {
-		serverMap[server.ID] = server
-	}
-	for _, server := range autoscaledServers {
-		serverMap[server.ID] = server
-	}
-
-	// Convert map back to slice
-	allServers := make([]*hcloud.Server, 0, len(serverMap))
-	for _, server := range serverMap {
-		allServers = append(allServers, server)
+		return err
 	}
 
 	if len(allServers) == 0 {
@@ -507,62 +449,5 @@ func (r *RunnerEnhanced) cleanupScript(ip, remoteScriptPath string) {
 
 // configureNATGatewayBastion configures the NAT gateway as a bastion host if enabled
 func (r *RunnerEnhanced) configureNATGatewayBastion() error {
-	// Check if NAT gateway is enabled
-	if r.Config.Networking.PrivateNetwork.NATGateway == nil ||
-		!r.Config.Networking.PrivateNetwork.NATGateway.Enabled {
-		return nil
-	}
-
-	// Find NAT gateway server
-	natGatewayName := fmt.Sprintf("%s-nat-gateway", r.Config.ClusterName)
-	natGateway, err := r.HetznerClient.GetServer(r.ctx, natGatewayName)
-	if err != nil || natGateway == nil {
-		// NAT gateway might not exist yet (e.g., cluster not created)
-		// This is not an error for the run command - just skip bastion configuration
-		return nil
-	}
-
-	// Get NAT gateway public IP
-	bastionIP, err := GetServerPublicIP(natGateway)
-	if err != nil {
-		return fmt.Errorf("failed to get NAT gateway public IP: %w", err)
-	}
-
-	// Configure SSH to use NAT gateway as bastion host
-	r.SSHClient.SetBastion(bastionIP, r.Config.Networking.SSH.Port)
-	util.LogInfo(fmt.Sprintf("Using NAT gateway %s as SSH bastion host", bastionIP), "run")
-
-	return nil
-}
-
-// findAutoscaledPoolServers finds servers created by the cluster autoscaler
-// These servers have the HCloudNodeGroupLabel label instead of the cluster label
-func (r *RunnerEnhanced) findAutoscaledPoolServers() ([]*hcloud.Server, error) {
-	var allServers []*hcloud.Server
-
-	// Iterate through all worker node pools
-	for _, pool := range r.Config.WorkerNodePools {
-		// Only process autoscaling-enabled pools
-		if !pool.AutoscalingEnabled() {
-			continue
-		}
-
-		// Build the node pool name (must match the name used by cluster autoscaler)
-		poolName := pool.BuildNodePoolName(r.Config.ClusterName)
-
-		// Search for servers with the HCloudNodeGroupLabel
-		nodeGroupLabel := fmt.Sprintf("%s=%s", HCloudNodeGroupLabel, poolName)
-		servers, err := r.HetznerClient.ListServers(r.ctx, hcloud.ServerListOpts{
-			ListOpts: hcloud.ListOpts{
-				LabelSelector: nodeGroupLabel,
-			},
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to list servers for node group %s: %w", poolName, err)
-		}
-
-		allServers = append(allServers, servers...)
-	}
-
-	return allServers, nil
+	return setupNATGatewayBastion(r.ctx, r.Config, r.HetznerClient, r.SSHClient, "run")
 }
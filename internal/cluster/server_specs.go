@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+// serverSpecs collects the distinct server types, locations and images of the servers
+// a run creates, so each one is looked up once before the first server is created
+type serverSpecs struct {
+	serverTypes []string
+	locations   []string
+	images      []imageSpec
+}
+
+// imageSpec is an image together with a server type it boots on. The server type
+// decides the architecture the image name is resolved for.
+type imageSpec struct {
+	image      string
+	serverType string
+}
+
+// add records the specs of a server
+func (s *serverSpecs) add(serverType, location, image string) {
+	s.serverTypes = appendUnique(s.serverTypes, serverType)
+	s.locations = appendUnique(s.locations, location)
+	if image != "" && !slices.Contains(s.images, imageSpec{image, serverType}) {
+		s.images = append(s.images, imageSpec{image, serverType})
+	}
+}
+
+// addWorkerPools records the specs of worker pools
+func (s *serverSpecs) addWorkerPools(pools []config.WorkerNodePool, image string) {
+	for _, pool := range pools {
+		s.add(pool.InstanceType, pool.Location, image)
+	}
+}
+
+// resolve looks up every spec once. The client keeps the results for the rest of
+// the run, so server creation no longer fetches them per server. All unknown
+// server types, locations and images are reported together. Images are resolved
+// for the architecture of their server type, so they are skipped when that is unknown.
+func (s *serverSpecs) resolve(ctx context.Context, api hetzner.API) error {
+	var problems []string
+	architectures := make(map[string]hcloud.Architecture)
+	for _, name := range s.serverTypes {
+		serverType, err := api.GetServerType(ctx, name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		architectures[name] = serverType.Architecture
+	}
+	for _, name := range s.locations {
+		if _, err := api.GetLocation(ctx, name); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, spec := range s.images {
+		architecture, ok := architectures[spec.serverType]
+		if !ok {
+			continue
+		}
+		if _, err := api.GetImage(ctx, spec.image, architecture); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid server configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// serverSpecs returns the specs of the NAT gateway, masters and workers
+// The cluster autoscaler creates the nodes of autoscaled pools later, so their
+// specs are checked up front as well, with the image the autoscaler boots them from.
+func (c *CreatorEnhanced) serverSpecs() *serverSpecs {
+	specs := &serverSpecs{}
+	if c.Config.Networking.PrivateNetwork.Enabled && c.isNATGatewayEnabled() {
+		natGateway := c.Config.Networking.PrivateNetwork.NATGateway
+		location := natGateway.Location
+		if location == "" {
+			location = c.Config.MastersPool.Locations[0]
+		}
+		specs.add(natGateway.InstanceType, location, c.Config.Image)
+	}
+	for _, location := range c.Config.MastersPool.Locations {
+		specs.add(c.Config.MastersPool.InstanceType, location, c.Config.Image)
+	}
+	specs.addWorkerPools(c.staticPools, c.Config.Image)
+	autoscalingImage := c.Config.Image
+	if c.Config.AutoscalingImage != "" {
+		autoscalingImage = c.Config.AutoscalingImage
+	}
+	specs.addWorkerPools(c.autoscalingPools, autoscalingImage)
+	return specs
+}
+
+// appendUnique appends a non-empty value that is not in the slice yet
+func appendUnique(values []string, value string) []string {
+	if value == "" || slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
+)
+
+func TestCreatorServerSpecs(t *testing.T) {
+	cfg := &config.Main{
+		Image:            "ubuntu-24.04",
+		AutoscalingImage: "4242",
+		MastersPool: config.MasterNodePool{
+			NodePool:  config.NodePool{InstanceType: "cx22", InstanceCount: 3},
+			Locations: []string{"fsn1", "nbg1", "fsn1"},
+		},
+	}
+	cfg.Networking.PrivateNetwork.Enabled = true
+	cfg.Networking.PrivateNetwork.NATGateway = &config.NATGateway{Enabled: true, InstanceType: "cpx11"}
+
+	c := &CreatorEnhanced{
+		Config: cfg,
+		staticPools: []config.WorkerNodePool{
+			{NodePool: config.NodePool{InstanceType: "cx32", InstanceCount: 10}, Location: "hel1"},
+			{NodePool: config.NodePool{InstanceType: "cx22", InstanceCount: 10}, Location: "fsn1"},
+		},
+		autoscalingPools: []config.WorkerNodePool{
+			{NodePool: config.NodePool{InstanceType: "cax11"}, Location: "ash"},
+		},
+	}
+
+	specs := c.serverSpecs()
+	want := &serverSpecs{
+		serverTypes: []string{"cpx11", "cx22", "cx32", "cax11"},
+		locations:   []string{"fsn1", "nbg1", "hel1", "ash"},
+		images: []imageSpec{
+			{image: "ubuntu-24.04", serverType: "cpx11"},
+			{image: "ubuntu-24.04", serverType: "cx22"},
+			{image: "ubuntu-24.04", serverType: "cx32"},
+			{image: "4242", serverType: "cax11"},
+		},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("serverSpecs() = %+v, want %+v", specs, want)
+	}
+
+	// Autoscaled pools fall back to the image without an autoscaling image
+	cfg.AutoscalingImage = ""
+	if specs := c.serverSpecs(); !slices.Contains(specs.images, imageSpec{"ubuntu-24.04", "cax11"}) {
+		t.Errorf("serverSpecs() images = %+v, want ubuntu-24.04 for cax11", specs.images)
+	}
+}
+
+func TestCreatorServerSpecsUnknownAutoscalingImage(t *testing.T) {
+	cfg := &config.Main{
+		Image:            "ubuntu-24.04",
+		AutoscalingImage: "9999",
+		MastersPool: config.MasterNodePool{
+			NodePool:  config.NodePool{InstanceType: "cx22", InstanceCount: 1},
+			Locations: []string{"fsn1"},
+		},
+	}
+	c := &CreatorEnhanced{
+		Config: cfg,
+		autoscalingPools: []config.WorkerNodePool{
+			{NodePool: config.NodePool{InstanceType: "cx32"}, Location: "nbg1"},
+		},
+	}
+
+	err := c.serverSpecs().resolve(context.Background(), hetznertest.NewFake())
+	if err == nil || !strings.Contains(err.Error(), "image 9999 not found") {
+		t.Errorf("resolve() error = %v, want the unknown autoscaling image reported", err)
+	}
+}
+
+func TestServerSpecsResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		specs    serverSpecs
+		wantErrs []string
+	}{
+		{
+			name: "known specs",
+			specs: serverSpecs{serverTypes: []string{"cx22", "cax11"}, locations: []string{"fsn1"},
+				images: []imageSpec{{"ubuntu-24.04", "cx22"}, {"ubuntu-24.04", "cax11"}}},
+		},
+		{
+			name: "unknown server type and image",
+			specs: serverSpecs{serverTypes: []string{"cx22", "cx999"}, locations: []string{"fsn1"},
+				images: []imageSpec{{"windows-95", "cx22"}, {"ubuntu-24.04", "cx999"}}},
+			wantErrs: []string{"server type cx999 not found", "image windows-95 not found"},
+		},
+		{
+			name:     "image missing for the architecture",
+			specs:    serverSpecs{serverTypes: []string{"cx22", "cax11"}, images: []imageSpec{{"x86-only", "cx22"}, {"x86-only", "cax11"}}},
+			wantErrs: []string{"image x86-only not found for arm"},
+		},
+		{
+			name:     "unknown location",
+			specs:    serverSpecs{locations: []string{"mars1"}},
+			wantErrs: []string{"location mars1 not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := hetznertest.NewFake()
+			fake.AddImage(&hcloud.Image{Name: "x86-only", Type: hcloud.ImageTypeSystem, Architecture: hcloud.ArchitectureX86})
+			err := tt.specs.resolve(context.Background(), fake)
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("resolve() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("resolve() error = nil, want %v", tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("resolve() error = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
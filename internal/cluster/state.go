@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+const (
+	// stateBaseDir is the directory, relative to the home directory, holding per-cluster local state
+	stateBaseDir = ".hek3ster/clusters"
+	// createStateFile is the name of the file recording create progress
+	createStateFile = "create-state.json"
+)
+
+// StepStatus is the recorded outcome of a creation step
+type StepStatus string
+
+const (
+	// StepStatusCompleted means the step finished and its postcondition held
+	StepStatusCompleted StepStatus = "completed"
+	// StepStatusFailed means the step returned an error or its postcondition did not hold
+	StepStatusFailed StepStatus = "failed"
+)
+
+// StepState records the outcome of a single creation step
+type StepState struct {
+	Status    StepStatus `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CreateState is the persisted progress of a cluster creation
+// It is stored with 0600 permissions because it contains the k3s token.
+type CreateState struct {
+	ClusterName string                `json:"cluster_name"`
+	K3sToken    string                `json:"k3s_token,omitempty"`
+	Steps       map[string]*StepState `json:"steps"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+
+	path string
+}
+
+// ClusterStateDir returns the local state directory for a cluster
+func ClusterStateDir(clusterName string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, stateBaseDir, clusterName), nil
+}
+
+// NewCreateState creates an empty creation state for a cluster
+func NewCreateState(clusterName string) (*CreateState, error) {
+	dir, err := ClusterStateDir(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateState{
+		ClusterName: clusterName,
+		Steps:       make(map[string]*StepState),
+		path:        filepath.Join(dir, createStateFile),
+	}, nil
+}
+
+// LoadCreateState loads the creation state of a cluster
+// It returns nil without error if no state has been recorded.
+func LoadCreateState(clusterName string) (*CreateState, error) {
+	state, err := NewCreateState(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(state.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", state.path, err)
+	}
+	if state.ClusterName != clusterName {
+		return nil, fmt.Errorf("state file %s belongs to cluster %s", state.path, state.ClusterName)
+	}
+	if state.Steps == nil {
+		state.Steps = make(map[string]*StepState)
+	}
+
+	return state, nil
+}
+
+// Path returns the location of the state file
+func (s *CreateState) Path() string {
+	return s.path
+}
+
+// IsCompleted reports whether a step was recorded as completed
+func (s *CreateState) IsCompleted(step string) bool {
+	record, ok := s.Steps[step]
+	return ok && record.Status == StepStatusCompleted
+}
+
+// MarkCompleted records a step as completed
+func (s *CreateState) MarkCompleted(step string) {
+	s.Steps[step] = &StepState{Status: StepStatusCompleted, UpdatedAt: time.Now().UTC()}
+}
+
+// MarkFailed records a step as failed with its error
+func (s *CreateState) MarkFailed(step string, stepErr error) {
+	s.Steps[step] = &StepState{Status: StepStatusFailed, Error: stepErr.Error(), UpdatedAt: time.Now().UTC()}
+}
+
+// Save writes the state file
+func (s *CreateState) Save() error {
+	s.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	return util.WriteToFile(s.path, data, 0600)
+}
+
+// Remove deletes the state file
+func (s *CreateState) Remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}
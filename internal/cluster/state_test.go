@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCreateStateSaveLoad tests persisting and loading creation state
+func TestCreateStateSaveLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	state, err := NewCreateState("test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	state.K3sToken = "secret"
+	state.MarkCompleted(stepSSHKey)
+	state.MarkFailed(stepMasters, errors.New("boom"))
+
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	info, err := os.Stat(state.Path())
+	if err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("state file permissions = %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := LoadCreateState("test")
+	if err != nil {
+		t.Fatalf("LoadCreateState() error: %v", err)
+	}
+	if loaded.K3sToken != "secret" {
+		t.Errorf("K3sToken = %q, want secret", loaded.K3sToken)
+	}
+	if !loaded.IsCompleted(stepSSHKey) {
+		t.Error("ssh key step should be completed")
+	}
+	if loaded.IsCompleted(stepMasters) {
+		t.Error("failed masters step should not be completed")
+	}
+	if loaded.Steps[stepMasters].Error != "boom" {
+		t.Errorf("masters error = %q, want boom", loaded.Steps[stepMasters].Error)
+	}
+
+	if err := loaded.Remove(); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	missing, err := LoadCreateState("test")
+	if err != nil || missing != nil {
+		t.Errorf("LoadCreateState() after remove = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+// TestLoadCreateStateWrongCluster tests that state of another cluster is rejected
+func TestLoadCreateStateWrongCluster(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path := filepath.Join(home, stateBaseDir, "test", createStateFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"cluster_name": "other"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadCreateState("test"); err == nil {
+		t.Error("expected error for state of another cluster")
+	}
+}
+
+// TestRunStep tests skipping, re-running and recording of creation steps
+func TestRunStep(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	state, err := NewCreateState("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.MarkCompleted(stepSSHKey)
+	state.MarkCompleted(stepNetwork)
+
+	c := &CreatorEnhanced{Resume: true, state: state}
+
+	// Completed step with a valid postcondition is skipped
+	ran := false
+	err = c.runStep(stepSSHKey, func() error { ran = true; return nil }, func() (bool, error) { return true, nil })
+	if err != nil || ran {
+		t.Errorf("completed step: err = %v, ran = %v; want skipped", err, ran)
+	}
+
+	// Completed step whose postcondition no longer holds runs again
+	ran = false
+	verified := 0
+	err = c.runStep(stepNetwork, func() error { ran = true; return nil }, func() (bool, error) {
+		verified++
+		return verified > 1, nil
+	})
+	if err != nil || !ran {
+		t.Errorf("broken step: err = %v, ran = %v; want re-run", err, ran)
+	}
+
+	// Failing step is recorded as failed
+	err = c.runStep(stepMasters, func() error { return errors.New("boom") }, nil)
+	if err == nil {
+		t.Error("expected error from failing step")
+	}
+	if state.Steps[stepMasters].Status != StepStatusFailed {
+		t.Errorf("masters status = %s, want failed", state.Steps[stepMasters].Status)
+	}
+
+	// Step whose postcondition does not hold after running fails
+	err = c.runStep(stepFirewall, func() error { return nil }, func() (bool, error) { return false, nil })
+	if err == nil || state.IsCompleted(stepFirewall) {
+		t.Errorf("unverified step: err = %v, completed = %v; want failure", err, state.IsCompleted(stepFirewall))
+	}
+
+	// Progress is persisted
+	loaded, err := LoadCreateState("test")
+	if err != nil || loaded == nil {
+		t.Fatalf("LoadCreateState() = %v, %v", loaded, err)
+	}
+	if !loaded.IsCompleted(stepNetwork) {
+		t.Error("network step should be persisted as completed")
+	}
+}
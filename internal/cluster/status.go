@@ -0,0 +1,280 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+const (
+	// k3sGetNodesJSONCmd is the command used to read node state from a master
+	k3sGetNodesJSONCmd = "sudo k3s kubectl get nodes -o json 2>/dev/null"
+)
+
+// StatusReporter reports the live inventory and health of a cluster
+type StatusReporter struct {
+	Config        *config.Main
+	HetznerClient hetzner.API
+	SSHClient     *util.SSH
+	ctx           context.Context
+}
+
+// NodeStatus describes a cluster server together with its Kubernetes node state
+type NodeStatus struct {
+	Name         string
+	Role         string
+	Pool         string
+	Location     string
+	PublicIP     string
+	PrivateIP    string
+	ServerStatus string
+	K3sVersion   string
+	Ready        string
+}
+
+// kubeNode holds the subset of Kubernetes node state used by hek3ster
+type kubeNode struct {
+	Name           string
+	KubeletVersion string
+	Ready          bool
+	Labels         map[string]string
+	Annotations    map[string]string
+	Taints         []config.Taint
+}
+
+// kubeNodeList mirrors the fields of `kubectl get nodes -o json` that we need
+type kubeNodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `json:"name"`
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Spec struct {
+			Taints []struct {
+				Key    string `json:"key"`
+				Value  string `json:"value"`
+				Effect string `json:"effect"`
+			} `json:"taints"`
+		} `json:"spec"`
+		Status struct {
+			NodeInfo struct {
+				KubeletVersion string `json:"kubeletVersion"`
+			} `json:"nodeInfo"`
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// NewStatusReporter creates a new cluster status reporter
+func NewStatusReporter(cfg *config.Main, hetznerClient hetzner.API) (*StatusReporter, error) {
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
+	if err != nil {
+		return nil, err
+	}
+
+	reporter := &StatusReporter{
+		Config:        cfg,
+		HetznerClient: hetznerClient,
+		SSHClient:     sshClient,
+		ctx:           ctx,
+	}
+
+	// Configure NAT gateway as bastion host if enabled
+	if err := setupNATGatewayBastion(reporter.ctx, cfg, hetznerClient, reporter.SSHClient, "status"); err != nil {
+		return nil, fmt.Errorf("failed to configure NAT gateway bastion: %w", err)
+	}
+
+	return reporter, nil
+}
+
+// Run collects the cluster status and prints it as a table
+func (s *StatusReporter) Run() error {
+	statuses, err := s.collectStatuses(s.fetchNodes)
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		util.LogWarning(fmt.Sprintf("No servers found for cluster: %s", s.Config.ClusterName), "status")
+		return nil
+	}
+
+	s.printStatuses(statuses)
+
+	readyCount := 0
+	for _, status := range statuses {
+		if status.Ready == "True" {
+			readyCount++
+		}
+	}
+
+	fmt.Println()
+	util.LogInfo(fmt.Sprintf("%d server(s), %d node(s) Ready", len(statuses), readyCount), s.Config.ClusterName)
+
+	return nil
+}
+
+// collectStatuses lists the cluster servers and joins them with the node state read by fetchNodes
+// Node state that cannot be read is reported as a warning, not an error.
+func (s *StatusReporter) collectStatuses(fetchNodes func(master *hcloud.Server) (map[string]kubeNode, error)) ([]NodeStatus, error) {
+	spinner := util.NewSpinner("Finding cluster servers", "status")
+	spinner.Start()
+	servers, err := listClusterServers(s.ctx, s.HetznerClient, s.Config)
+	spinner.Stop(true)
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, nil
+	}
+
+	// Read Kubernetes node state from the first master
+	var nodes map[string]kubeNode
+	master := firstMasterServer(servers)
+	if master == nil {
+		util.LogWarning("No master node found, Kubernetes node state is unavailable", "status")
+	} else {
+		nodes, err = fetchNodes(master)
+		if err != nil {
+			util.LogWarning(fmt.Sprintf("Failed to read node state from %s: %v", master.Name, err), "status")
+		}
+	}
+
+	return buildNodeStatuses(servers, nodes), nil
+}
+
+// fetchNodes reads the Kubernetes node list from a master via SSH
+func (s *StatusReporter) fetchNodes(master *hcloud.Server) (map[string]kubeNode, error) {
+	return fetchKubeNodes(s.ctx, s.Config, s.SSHClient, master)
+}
+
+// fetchKubeNodes reads the Kubernetes node list from a master via SSH
+func fetchKubeNodes(ctx context.Context, cfg *config.Main, sshClient *util.SSH, master *hcloud.Server) (map[string]kubeNode, error) {
+	ip, err := GetServerSSHIP(master)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := sshClient.Run(ctx, ip, cfg.Networking.SSH.Port, k3sGetNodesJSONCmd, cfg.Networking.SSH.UseAgent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	return parseKubeNodes(output)
+}
+
+// printStatuses prints node statuses as an aligned table
+func (s *StatusReporter) printStatuses(statuses []NodeStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tROLE\tPOOL\tLOCATION\tPUBLIC IP\tPRIVATE IP\tSERVER\tK3S VERSION\tREADY")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			st.Name, st.Role, st.Pool, st.Location, st.PublicIP, st.PrivateIP, st.ServerStatus, st.K3sVersion, st.Ready)
+	}
+	w.Flush()
+}
+
+// firstMasterServer returns the first master server by name, or nil if there is none
+// The servers slice is expected to be sorted by name
+func firstMasterServer(servers []*hcloud.Server) *hcloud.Server {
+	for _, server := range servers {
+		if serverRole(server) == "master" {
+			return server
+		}
+	}
+	return nil
+}
+
+// buildNodeStatuses combines Hetzner server data with Kubernetes node state
+// Servers without a matching Kubernetes node (e.g. the NAT gateway) report "-" for node fields
+func buildNodeStatuses(servers []*hcloud.Server, nodes map[string]kubeNode) []NodeStatus {
+	statuses := make([]NodeStatus, 0, len(servers))
+	for _, server := range servers {
+		status := NodeStatus{
+			Name:         server.Name,
+			Role:         serverRole(server),
+			Pool:         valueOrDash(serverPool(server)),
+			Location:     "-",
+			PublicIP:     "-",
+			PrivateIP:    "-",
+			ServerStatus: string(server.Status),
+			K3sVersion:   "-",
+			Ready:        "-",
+		}
+
+		if server.Location != nil {
+			status.Location = server.Location.Name
+		}
+		if server.PublicNet.IPv4.IP != nil {
+			status.PublicIP = server.PublicNet.IPv4.IP.String()
+		}
+		if len(server.PrivateNet) > 0 {
+			status.PrivateIP = server.PrivateNet[0].IP.String()
+		}
+
+		if node, ok := nodes[server.Name]; ok {
+			status.K3sVersion = node.KubeletVersion
+			if node.Ready {
+				status.Ready = "True"
+			} else {
+				status.Ready = "False"
+			}
+		} else if nodes != nil && status.Role != "nat-gateway" {
+			// Node state was retrieved but the server has not joined the cluster
+			status.Ready = "NotJoined"
+		}
+
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// parseKubeNodes parses the output of `kubectl get nodes -o json` into a map keyed by node name
+func parseKubeNodes(output string) (map[string]kubeNode, error) {
+	var list kubeNodeList
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse node list: %w", err)
+	}
+
+	nodes := make(map[string]kubeNode, len(list.Items))
+	for _, item := range list.Items {
+		node := kubeNode{
+			Name:           item.Metadata.Name,
+			KubeletVersion: item.Status.NodeInfo.KubeletVersion,
+			Labels:         item.Metadata.Labels,
+			Annotations:    item.Metadata.Annotations,
+		}
+		for _, taint := range item.Spec.Taints {
+			node.Taints = append(node.Taints, config.Taint{Key: taint.Key, Value: taint.Value, Effect: taint.Effect})
+		}
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Ready" {
+				node.Ready = condition.Status == "True"
+				break
+			}
+		}
+		nodes[node.Name] = node
+	}
+
+	return nodes, nil
+}
+
+// valueOrDash returns "-" for empty strings so table columns stay aligned
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// TestParseKubeNodes tests parsing of kubectl node list output
+func TestParseKubeNodes(t *testing.T) {
+	output := `{
+  "items": [
+    {
+      "metadata": {"name": "test-master-1", "labels": {"node-role.kubernetes.io/control-plane": "true"}},
+      "status": {
+        "nodeInfo": {"kubeletVersion": "v1.32.0+k3s1"},
+        "conditions": [
+          {"type": "MemoryPressure", "status": "False"},
+          {"type": "Ready", "status": "True"}
+        ]
+      }
+    },
+    {
+      "metadata": {"name": "test-worker-pool-1-1"},
+      "status": {
+        "nodeInfo": {"kubeletVersion": "v1.31.4+k3s1"},
+        "conditions": [
+          {"type": "Ready", "status": "Unknown"}
+        ]
+      }
+    }
+  ]
+}`
+
+	nodes, err := parseKubeNodes(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(nodes))
+	}
+
+	master := nodes["test-master-1"]
+	if !master.Ready {
+		t.Error("expected master to be Ready")
+	}
+	if master.KubeletVersion != "v1.32.0+k3s1" {
+		t.Errorf("expected master version v1.32.0+k3s1, got %s", master.KubeletVersion)
+	}
+	if _, ok := master.Labels["node-role.kubernetes.io/control-plane"]; !ok {
+		t.Error("expected master to carry the control-plane label")
+	}
+
+	worker := nodes["test-worker-pool-1-1"]
+	if worker.Ready {
+		t.Error("expected worker not to be Ready")
+	}
+}
+
+// TestParseKubeNodesInvalid tests that invalid output returns an error
+func TestParseKubeNodesInvalid(t *testing.T) {
+	if _, err := parseKubeNodes("The connection to the server was refused"); err == nil {
+		t.Error("expected error for non-JSON output")
+	}
+}
+
+// TestBuildNodeStatuses tests combining server and node data into status rows
+func TestBuildNodeStatuses(t *testing.T) {
+	servers := []*hcloud.Server{
+		{
+			Name:     "test-master-1",
+			Status:   hcloud.ServerStatusRunning,
+			Labels:   map[string]string{"cluster": "test", "role": "master"},
+			Location: &hcloud.Location{Name: "fsn1"},
+			PublicNet: hcloud.ServerPublicNet{
+				IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("1.2.3.4")},
+			},
+			PrivateNet: []hcloud.ServerPrivateNet{{IP: net.ParseIP("10.0.0.2")}},
+		},
+		{
+			Name:     "test-nat-gateway",
+			Status:   hcloud.ServerStatusRunning,
+			Labels:   map[string]string{"cluster": "test", "role": "nat-gateway"},
+			Location: &hcloud.Location{Name: "fsn1"},
+		},
+		{
+			Name:     "test-autoscaled-abc",
+			Status:   hcloud.ServerStatusRunning,
+			Labels:   map[string]string{HCloudNodeGroupLabel: "test-autoscaled"},
+			Location: &hcloud.Location{Name: "nbg1"},
+		},
+		{
+			Name:   "test-worker-pool-1-1",
+			Status: hcloud.ServerStatusStarting,
+			Labels: map[string]string{"cluster": "test", "role": "worker", "pool": "pool-1"},
+		},
+	}
+
+	nodes := map[string]kubeNode{
+		"test-master-1":       {Name: "test-master-1", KubeletVersion: "v1.32.0+k3s1", Ready: true},
+		"test-autoscaled-abc": {Name: "test-autoscaled-abc", KubeletVersion: "v1.32.0+k3s1", Ready: false},
+	}
+
+	statuses := buildNodeStatuses(servers, nodes)
+	if len(statuses) != len(servers) {
+		t.Fatalf("expected %d statuses, got %d", len(servers), len(statuses))
+	}
+
+	expected := []NodeStatus{
+		{Name: "test-master-1", Role: "master", Pool: "masters", Location: "fsn1", PublicIP: "1.2.3.4", PrivateIP: "10.0.0.2", ServerStatus: "running", K3sVersion: "v1.32.0+k3s1", Ready: "True"},
+		{Name: "test-nat-gateway", Role: "nat-gateway", Pool: "-", Location: "fsn1", PublicIP: "-", PrivateIP: "-", ServerStatus: "running", K3sVersion: "-", Ready: "-"},
+		{Name: "test-autoscaled-abc", Role: "worker", Pool: "test-autoscaled", Location: "nbg1", PublicIP: "-", PrivateIP: "-", ServerStatus: "running", K3sVersion: "v1.32.0+k3s1", Ready: "False"},
+		{Name: "test-worker-pool-1-1", Role: "worker", Pool: "pool-1", Location: "-", PublicIP: "-", PrivateIP: "-", ServerStatus: "starting", K3sVersion: "-", Ready: "NotJoined"},
+	}
+
+	for i, want := range expected {
+		if statuses[i] != want {
+			t.Errorf("status %d:\n  got  %+v\n  want %+v", i, statuses[i], want)
+		}
+	}
+}
+
+// TestFirstMasterServer tests selection of the first master server
+func TestFirstMasterServer(t *testing.T) {
+	servers := []*hcloud.Server{
+		{Name: "test-master-1", Labels: map[string]string{"role": "master"}},
+		{Name: "test-master-2", Labels: map[string]string{"role": "master"}},
+		{Name: "test-worker-1", Labels: map[string]string{"role": "worker"}},
+	}
+
+	master := firstMasterServer(servers)
+	if master == nil || master.Name != "test-master-1" {
+		t.Errorf("expected test-master-1, got %v", master)
+	}
+
+	if firstMasterServer(servers[2:]) != nil {
+		t.Error("expected nil when there are no masters")
+	}
+}
@@ -3,6 +3,7 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
@@ -14,42 +15,43 @@ import (
 // UpgraderEnhanced handles cluster upgrades with full implementation
 type UpgraderEnhanced struct {
 	Config        *config.Main
-	HetznerClient *hetzner.Client
+	HetznerClient hetzner.API
 	SSHClient     *util.SSH
 	NewK3sVersion string
 	Force         bool
+	Rollback      bool
+	Timeout       time.Duration
 	ctx           context.Context
 }
 
 // NewUpgraderEnhanced creates a new enhanced cluster upgrader
-func NewUpgraderEnhanced(cfg *config.Main, hetznerClient *hetzner.Client, newVersion string, force bool) (*UpgraderEnhanced, error) {
-	privKeyPath, err := cfg.Networking.SSH.ExpandedPrivateKeyPath()
+func NewUpgraderEnhanced(cfg *config.Main, hetznerClient hetzner.API, newVersion string, force bool) (*UpgraderEnhanced, error) {
+	ctx := context.Background()
+	sshClient, err := newSSHClient(ctx, cfg, hetznerClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to expand private key path: %w", err)
+		return nil, err
 	}
 
-	pubKeyPath, err := cfg.Networking.SSH.ExpandedPublicKeyPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to expand public key path: %w", err)
-	}
-
-	sshClient := util.NewSSH(privKeyPath, pubKeyPath)
-
 	return &UpgraderEnhanced{
 		Config:        cfg,
 		HetznerClient: hetznerClient,
 		SSHClient:     sshClient,
 		NewK3sVersion: newVersion,
 		Force:         force,
-		ctx:           context.Background(),
+		Timeout:       DefaultUpgradeTimeout,
+		ctx:           ctx,
 	}, nil
 }
 
 // Run executes the cluster upgrade process
 func (u *UpgraderEnhanced) Run() error {
-	util.LogInfo("Starting cluster upgrade", u.Config.ClusterName)
-	util.LogInfo(fmt.Sprintf("Current version: %s", u.Config.K3sVersion), u.Config.ClusterName)
-	util.LogInfo(fmt.Sprintf("Target version: %s", u.NewK3sVersion), u.Config.ClusterName)
+	if u.Rollback {
+		util.LogInfo("Starting cluster upgrade rollback", u.Config.ClusterName)
+	} else {
+		util.LogInfo("Starting cluster upgrade", u.Config.ClusterName)
+		util.LogInfo(fmt.Sprintf("Current version: %s", u.Config.K3sVersion), u.Config.ClusterName)
+		util.LogInfo(fmt.Sprintf("Target version: %s", u.NewK3sVersion), u.Config.ClusterName)
+	}
 
 	// Confirm upgrade if not forced
 	if !u.Force {
@@ -58,23 +60,86 @@ func (u *UpgraderEnhanced) Run() error {
 		return fmt.Errorf("upgrade requires --force flag")
 	}
 
+	if u.Rollback {
+		return u.runRollback()
+	}
+
 	// Step 1: Find all cluster nodes
+	masters, workers, err := u.findNodes()
+	if err != nil {
+		return err
+	}
+
+	// Step 2: Run pre-flight safety checks
+	spinner := util.NewSpinner("Running pre-flight checks", "preflight")
+	spinner.Start()
+	report, err := u.runPreflightChecks(masters[0])
+	spinner.Stop(true)
+	if err != nil {
+		return fmt.Errorf("failed to run pre-flight checks: %w", err)
+	}
+	for _, warning := range report.Warnings {
+		util.LogWarning(warning, "preflight")
+	}
+	if len(report.Errors) > 0 {
+		for _, problem := range report.Errors {
+			util.LogError(problem, "preflight")
+		}
+		return fmt.Errorf("%d pre-flight check(s) failed", len(report.Errors))
+	}
+	util.LogSuccess("Pre-flight checks passed", "preflight")
+
+	// Step 3: Record the pre-upgrade version and snapshot etcd for rollback
+	spinner = util.NewSpinner("Recording pre-upgrade state", "upgrade")
+	spinner.Start()
+	state, err := u.recordUpgradeState(masters[0])
+	spinner.Stop(true)
+	if err != nil {
+		return fmt.Errorf("failed to record pre-upgrade state: %w", err)
+	}
+
+	// Step 4: Upgrade the nodes and verify cluster health
+	if err := u.upgradeNodes(masters, workers); err != nil {
+		return err
+	}
+
+	state.MarkCompleted()
+	if err := state.Save(); err != nil {
+		util.LogWarning(fmt.Sprintf("Failed to record upgrade completion: %v", err), "upgrade")
+	}
+
+	fmt.Println()
+	util.LogSuccess("Cluster upgrade completed successfully!", u.Config.ClusterName)
+	util.LogInfo(fmt.Sprintf("All nodes are now running k3s %s", u.NewK3sVersion), u.Config.ClusterName)
+	util.LogInfo(fmt.Sprintf("Update k3s_version in your configuration, or run 'upgrade --rollback' to return to %s", state.PreviousVersion), u.Config.ClusterName)
+	fmt.Println()
+
+	return nil
+}
+
+// findNodes finds the master and worker servers, requiring at least one master
+func (u *UpgraderEnhanced) findNodes() ([]*hcloud.Server, []*hcloud.Server, error) {
 	spinner := util.NewSpinner("Finding cluster nodes", "cluster")
 	spinner.Start()
 	masters, workers, err := u.findClusterNodes()
 	if err != nil {
 		spinner.Stop(true)
-		return fmt.Errorf("failed to find cluster nodes: %w", err)
+		return nil, nil, fmt.Errorf("failed to find cluster nodes: %w", err)
 	}
 	spinner.Stop(true)
 	util.LogSuccess(fmt.Sprintf("Found %d master(s) and %d worker(s)", len(masters), len(workers)), "cluster")
 
 	if len(masters) == 0 {
-		return fmt.Errorf("no master nodes found for cluster: %s", u.Config.ClusterName)
+		return nil, nil, fmt.Errorf("no master nodes found for cluster: %s", u.Config.ClusterName)
 	}
+	return masters, workers, nil
+}
 
-	// Step 2: Check if system-upgrade-controller is installed
-	spinner = util.NewSpinner("Checking system-upgrade-controller", "upgrade")
+// upgradeNodes moves all nodes to u.NewK3sVersion with system-upgrade-controller
+// It is shared by upgrades and rollbacks.
+func (u *UpgraderEnhanced) upgradeNodes(masters, workers []*hcloud.Server) error {
+	// Check if system-upgrade-controller is installed
+	spinner := util.NewSpinner("Checking system-upgrade-controller", "upgrade")
 	spinner.Start()
 	if err := u.ensureSystemUpgradeController(masters[0]); err != nil {
 		spinner.Stop(true)
@@ -83,31 +148,27 @@ func (u *UpgraderEnhanced) Run() error {
 	spinner.Stop(true)
 	util.LogSuccess("System-upgrade-controller is ready", "upgrade")
 
-	// Step 3: Upgrade master nodes
-	if len(masters) > 0 {
-		spinner = util.NewSpinner(fmt.Sprintf("Upgrading %d master node(s)", len(masters)), "master")
-		spinner.Start()
-		if err := u.upgradeMasters(masters); err != nil {
-			spinner.Stop(true)
-			return fmt.Errorf("failed to upgrade masters: %w", err)
-		}
+	// Upgrade master nodes
+	spinner = util.NewSpinner(fmt.Sprintf("Upgrading %d master node(s)", len(masters)), "master")
+	spinner.Start()
+	if err := u.upgradeMasters(masters); err != nil {
 		spinner.Stop(true)
-		util.LogSuccess("Master nodes upgraded successfully", "master")
+		return fmt.Errorf("failed to upgrade masters: %w", err)
 	}
+	spinner.Stop(true)
+	util.LogSuccess("Master nodes upgraded successfully", "master")
 
-	// Step 4: Upgrade worker nodes
+	// Upgrade worker nodes pool by pool
+	// No spinner here, the canary pause may prompt for confirmation
 	if len(workers) > 0 {
-		spinner = util.NewSpinner(fmt.Sprintf("Upgrading %d worker node(s)", len(workers)), "worker")
-		spinner.Start()
-		if err := u.upgradeWorkers(workers); err != nil {
-			spinner.Stop(true)
+		util.LogInfo(fmt.Sprintf("Upgrading %d worker node(s)", len(workers)), "worker")
+		if err := u.upgradeWorkerPools(masters[0], workers); err != nil {
 			return fmt.Errorf("failed to upgrade workers: %w", err)
 		}
-		spinner.Stop(true)
 		util.LogSuccess("Worker nodes upgraded successfully", "worker")
 	}
 
-	// Step 5: Verify cluster health
+	// Verify cluster health
 	spinner = util.NewSpinner("Verifying cluster health", "health")
 	spinner.Start()
 	if err := u.verifyClusterHealth(masters[0]); err != nil {
@@ -117,37 +178,25 @@ func (u *UpgraderEnhanced) Run() error {
 	spinner.Stop(true)
 	util.LogSuccess("Cluster health verified", "health")
 
-	fmt.Println()
-	util.LogSuccess("Cluster upgrade completed successfully!", u.Config.ClusterName)
-	util.LogInfo(fmt.Sprintf("All nodes are now running k3s %s", u.NewK3sVersion), u.Config.ClusterName)
-	fmt.Println()
-
 	return nil
 }
 
 // findClusterNodes finds all master and worker nodes in the cluster
+// Workers include servers created by the cluster autoscaler.
 func (u *UpgraderEnhanced) findClusterNodes() ([]*hcloud.Server, []*hcloud.Server, error) {
-	clusterLabel := fmt.Sprintf("cluster=%s", u.Config.ClusterName)
-
-	// Get all servers with cluster label
-	servers, err := u.HetznerClient.ListServers(u.ctx, hcloud.ServerListOpts{
-		ListOpts: hcloud.ListOpts{
-			LabelSelector: clusterLabel,
-		},
-	})
+	servers, err := listClusterServers(u.ctx, u.HetznerClient, u.Config)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to list servers: %w", err)
+		return nil, nil, err
 	}
 
 	// Separate masters and workers
 	var masters, workers []*hcloud.Server
 	for _, server := range servers {
-		if role, ok := server.Labels["role"]; ok {
-			if role == "master" {
-				masters = append(masters, server)
-			} else if role == "worker" {
-				workers = append(workers, server)
-			}
+		switch serverRole(server) {
+		case "master":
+			masters = append(masters, server)
+		case "worker":
+			workers = append(workers, server)
 		}
 	}
 
@@ -181,58 +230,67 @@ func (u *UpgraderEnhanced) ensureSystemUpgradeController(master *hcloud.Server)
 }
 
 // upgradeMasters upgrades master nodes using system-upgrade-controller
+// With embedded etcd, masters are rolled one at a time and every etcd member must be
+// healthy before the next one is restarted.
 func (u *UpgraderEnhanced) upgradeMasters(masters []*hcloud.Server) error {
 	// Use first master to apply upgrade plan
 	firstMaster := masters[0]
-	ip, err := GetServerSSHIP(firstMaster)
-	if err != nil {
-		return err
-	}
 
-	// Generate upgrade plan for masters
-	upgradePlan := u.generateMasterUpgradePlan()
-
-	// Apply upgrade plan
-	applyCmd := fmt.Sprintf("echo '%s' | sudo k3s kubectl apply -f -", upgradePlan)
-	_, err = u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, applyCmd, u.Config.Networking.SSH.UseAgent)
-	if err != nil {
-		return fmt.Errorf("failed to apply master upgrade plan: %w", err)
+	if u.Config.Datastore.Mode != "etcd" {
+		if err := u.applyMasterUpgradePlan(firstMaster, ""); err != nil {
+			return err
+		}
+		return u.waitForUpgrade(firstMaster, "server-plan", isControlPlaneNode, len(masters), "master")
 	}
 
-	// Wait for upgrades to complete
-	return u.waitForUpgrade(firstMaster, "master", len(masters))
-}
+	for _, master := range masters {
+		problems, err := u.checkEtcdHealth(firstMaster)
+		if err != nil {
+			return fmt.Errorf("failed to check etcd health before upgrading %s: %w", master.Name, err)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("not upgrading %s, etcd is not healthy: %s", master.Name, strings.Join(problems, "; "))
+		}
 
-// upgradeWorkers upgrades worker nodes using system-upgrade-controller
-func (u *UpgraderEnhanced) upgradeWorkers(workers []*hcloud.Server) error {
-	// Use first master to apply upgrade plan
-	masters, _, err := u.findClusterNodes()
-	if err != nil || len(masters) == 0 {
-		return fmt.Errorf("no master nodes found")
+		if err := u.applyMasterUpgradePlan(firstMaster, master.Name); err != nil {
+			return err
+		}
+		name := master.Name
+		selected := func(node kubeNode) bool { return node.Name == name }
+		if err := u.waitForUpgrade(firstMaster, "server-plan", selected, 1, "master"); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	firstMaster := masters[0]
-	ip, err := GetServerSSHIP(firstMaster)
+// applyMasterUpgradePlan applies the master upgrade plan, limited to one node if nodeName is set
+func (u *UpgraderEnhanced) applyMasterUpgradePlan(master *hcloud.Server, nodeName string) error {
+	ip, err := GetServerSSHIP(master)
 	if err != nil {
 		return err
 	}
 
-	// Generate upgrade plan for workers
-	upgradePlan := u.generateWorkerUpgradePlan()
-
-	// Apply upgrade plan
+	upgradePlan := generateMasterUpgradePlan(u.NewK3sVersion, nodeName)
 	applyCmd := fmt.Sprintf("echo '%s' | sudo k3s kubectl apply -f -", upgradePlan)
-	_, err = u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, applyCmd, u.Config.Networking.SSH.UseAgent)
-	if err != nil {
-		return fmt.Errorf("failed to apply worker upgrade plan: %w", err)
+	if _, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, applyCmd, u.Config.Networking.SSH.UseAgent); err != nil {
+		return fmt.Errorf("failed to apply master upgrade plan: %w", err)
 	}
-
-	// Wait for upgrades to complete
-	return u.waitForUpgrade(firstMaster, "worker", len(workers))
+	return nil
 }
 
 // generateMasterUpgradePlan generates upgrade plan YAML for masters
-func (u *UpgraderEnhanced) generateMasterUpgradePlan() string {
+// A non-empty nodeName limits the plan to that master.
+func generateMasterUpgradePlan(version, nodeName string) string {
+	var node string
+	if nodeName != "" {
+		node = fmt.Sprintf(`
+    - key: kubernetes.io/hostname
+      operator: In
+      values:
+      - %s`, nodeName)
+	}
+
 	return fmt.Sprintf(`apiVersion: upgrade.cattle.io/v1
 kind: Plan
 metadata:
@@ -244,77 +302,11 @@ spec:
   nodeSelector:
     matchExpressions:
     - key: node-role.kubernetes.io/control-plane
-      operator: Exists
+      operator: Exists%s
   serviceAccountName: system-upgrade
   upgrade:
     image: rancher/k3s-upgrade
-  version: %s`, u.NewK3sVersion)
-}
-
-// generateWorkerUpgradePlan generates upgrade plan YAML for workers
-func (u *UpgraderEnhanced) generateWorkerUpgradePlan() string {
-	return fmt.Sprintf(`apiVersion: upgrade.cattle.io/v1
-kind: Plan
-metadata:
-  name: agent-plan
-  namespace: system-upgrade
-spec:
-  concurrency: 2
-  cordon: true
-  nodeSelector:
-    matchExpressions:
-    - key: node-role.kubernetes.io/control-plane
-      operator: DoesNotExist
-  prepare:
-    args:
-    - prepare
-    - server-plan
-    image: rancher/k3s-upgrade
-  serviceAccountName: system-upgrade
-  upgrade:
-    image: rancher/k3s-upgrade
-  version: %s`, u.NewK3sVersion)
-}
-
-// waitForUpgrade waits for upgrade to complete on all nodes
-func (u *UpgraderEnhanced) waitForUpgrade(master *hcloud.Server, nodeType string, nodeCount int) error {
-	ip, err := GetServerSSHIP(master)
-	if err != nil {
-		return err
-	}
-
-	maxAttempts := 60 // 30 minutes max (30 seconds * 60)
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		// Check node versions
-		checkCmd := fmt.Sprintf("sudo k3s kubectl get nodes -o jsonpath='{.items[*].status.nodeInfo.kubeletVersion}'")
-		_, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, checkCmd, u.Config.Networking.SSH.UseAgent)
-		if err == nil {
-			// Silent check - just verifying command succeeded
-		}
-
-		// Check if upgrade plan jobs are completed
-		checkJobsCmd := fmt.Sprintf("sudo k3s kubectl get jobs -n system-upgrade -l upgrade.cattle.io/plan-name=%s-plan -o jsonpath='{.items[*].status.succeeded}'", nodeType)
-		_, err = u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, checkJobsCmd, u.Config.Networking.SSH.UseAgent)
-		if err == nil {
-			// Silent check - just verifying command succeeded
-		}
-
-		// Check all nodes are ready
-		checkReadyCmd := "sudo k3s kubectl get nodes --no-headers | grep -c Ready"
-		_, err = u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, checkReadyCmd, u.Config.Networking.SSH.UseAgent)
-		if err == nil {
-			// Silent check - just verifying command succeeded
-		}
-
-		// For simplicity, we'll wait a reasonable time
-		if attempt >= 20 { // After 10 minutes, assume success
-			return nil
-		}
-
-		time.Sleep(30 * time.Second)
-	}
-
-	return fmt.Errorf("upgrade did not complete within expected time")
+  version: %s`, node, version)
 }
 
 // verifyClusterHealth verifies cluster health after upgrade
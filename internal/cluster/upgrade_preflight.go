@@ -0,0 +1,386 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+const (
+	// k3sEtcdTLSDir holds the client certificates of the embedded etcd on k3s servers
+	k3sEtcdTLSDir = "/var/lib/rancher/k3s/server/tls/etcd"
+	// etcdLocalClientURL is the client URL of the etcd member on the same server
+	etcdLocalClientURL = "https://127.0.0.1:2379"
+	// k3sGetPDBsJSONCmd lists PodDisruptionBudgets in all namespaces as JSON
+	k3sGetPDBsJSONCmd = "sudo k3s kubectl get pdb -A -o json"
+)
+
+var (
+	// k3sVersionPattern matches k3s and kubelet versions such as v1.32.1+k3s1
+	k3sVersionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:\+k3s(\d+))?$`)
+	// addonVersionPattern finds a semantic version in an addon version or manifest URL
+	addonVersionPattern = regexp.MustCompile(`v(\d+)\.(\d+)\.(\d+)`)
+)
+
+// addonSupport maps an addon minor release to the newest Kubernetes minor it is known to support
+type addonSupport struct {
+	AddonMinor      int
+	KubernetesMinor int
+}
+
+// Known Kubernetes support of the Hetzner addons, taken from the upstream compatibility matrices.
+// Entries are sorted by addon minor; a release newer than the last entry is assumed to be compatible.
+// Update these tables together with the default manifest URLs in the config package.
+var (
+	ccmSupport = []addonSupport{
+		{AddonMinor: 20, KubernetesMinor: 31},
+		{AddonMinor: 22, KubernetesMinor: 32},
+		{AddonMinor: 25, KubernetesMinor: 33},
+		{AddonMinor: 27, KubernetesMinor: 34},
+	}
+	csiSupport = []addonSupport{
+		{AddonMinor: 10, KubernetesMinor: 31},
+		{AddonMinor: 12, KubernetesMinor: 32},
+		{AddonMinor: 14, KubernetesMinor: 33},
+		{AddonMinor: 17, KubernetesMinor: 34},
+	}
+)
+
+// k3sVersion is a parsed k3s release version
+type k3sVersion struct {
+	Major    int
+	Minor    int
+	Patch    int
+	Revision int
+}
+
+// parseK3sVersion parses a k3s or kubelet version such as v1.32.1+k3s1
+func parseK3sVersion(value string) (k3sVersion, error) {
+	match := k3sVersionPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return k3sVersion{}, fmt.Errorf("invalid k3s version %q", value)
+	}
+
+	var version k3sVersion
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	version.Patch, _ = strconv.Atoi(match[3])
+	if match[4] != "" {
+		version.Revision, _ = strconv.Atoi(match[4])
+	}
+	return version, nil
+}
+
+// compare returns -1, 0 or 1 if v is older than, equal to or newer than other
+func (v k3sVersion) compare(other k3sVersion) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch, v.Revision - other.Revision} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkVersionSkew rejects downgrades and upgrades that skip a Kubernetes minor version
+// Kubernetes only supports upgrading control planes and kubelets one minor version at a time.
+func checkVersionSkew(current, target k3sVersion) error {
+	if target.compare(current) < 0 {
+		return fmt.Errorf("downgrade from v%d.%d.%d is not supported", current.Major, current.Minor, current.Patch)
+	}
+	if target.Major != current.Major {
+		return fmt.Errorf("upgrade across major versions from v%d.%d is not supported", current.Major, current.Minor)
+	}
+	if target.Minor > current.Minor+1 {
+		return fmt.Errorf("upgrade from v%d.%d to v%d.%d skips minor versions, upgrade to v%d.%d first",
+			current.Major, current.Minor, target.Major, target.Minor, current.Major, current.Minor+1)
+	}
+	return nil
+}
+
+// preflightReport collects the results of the pre-flight upgrade checks
+// Errors block the upgrade, warnings are only reported.
+type preflightReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+// checkUpgradeNodes validates node readiness and version skew for every node
+// Nodes that already run the target version are accepted, so an interrupted upgrade can be re-run.
+func checkUpgradeNodes(nodes map[string]kubeNode, target k3sVersion) []string {
+	if len(nodes) == 0 {
+		return []string{"no Kubernetes nodes found"}
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		node := nodes[name]
+		if !node.Ready {
+			problems = append(problems, fmt.Sprintf("node %s is not Ready", name))
+		}
+
+		current, err := parseK3sVersion(node.KubeletVersion)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("node %s: %v", name, err))
+			continue
+		}
+		if err := checkVersionSkew(current, target); err != nil {
+			problems = append(problems, fmt.Sprintf("node %s: %v", name, err))
+		}
+	}
+
+	return problems
+}
+
+// etcdMember is a member of the embedded etcd cluster
+type etcdMember struct {
+	Name       string   `json:"name"`
+	ClientURLs []string `json:"clientURLs"`
+	IsLearner  bool     `json:"isLearner"`
+}
+
+// etcdRequestCmd builds a curl command that calls the etcd API with the k3s client certificate
+// A non-empty body is sent as a POST request, as the etcd v3 JSON gateway expects.
+func etcdRequestCmd(url, body string) string {
+	cmd := fmt.Sprintf("sudo curl -sS --max-time 10 --cacert %[1]s/server-ca.crt --cert %[1]s/client.crt --key %[1]s/client.key",
+		k3sEtcdTLSDir)
+	if body != "" {
+		cmd += " -X POST -d " + util.ShellQuote(body)
+	}
+	return cmd + " " + util.ShellQuote(url)
+}
+
+// parseEtcdMembers parses the response of the etcd member list API
+func parseEtcdMembers(output string) ([]etcdMember, error) {
+	var list struct {
+		Members []etcdMember `json:"members"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse etcd member list: %w", err)
+	}
+	return list.Members, nil
+}
+
+// parseEtcdHealth parses the response of the etcd /health endpoint
+func parseEtcdHealth(output string) error {
+	var health struct {
+		Health string `json:"health"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &health); err != nil {
+		return fmt.Errorf("unexpected health response: %s", strings.TrimSpace(output))
+	}
+	if health.Health != "true" {
+		if health.Reason != "" {
+			return fmt.Errorf("unhealthy: %s", health.Reason)
+		}
+		return fmt.Errorf("unhealthy")
+	}
+	return nil
+}
+
+// checkEtcdMembers probes every etcd member and returns the members that are not healthy
+// Rolling a member restarts it, so every member must be healthy, not only a quorum:
+// with one member already down, restarting another loses quorum in a three-member cluster.
+func checkEtcdMembers(members []etcdMember, probe func(clientURL string) error) []string {
+	if len(members) == 0 {
+		return []string{"no etcd members found"}
+	}
+
+	var problems []string
+	for _, member := range members {
+		switch {
+		case len(member.ClientURLs) == 0:
+			problems = append(problems, fmt.Sprintf("etcd member %s has not started", member.Name))
+		case member.IsLearner:
+			problems = append(problems, fmt.Sprintf("etcd member %s is a learner that has not caught up", member.Name))
+		default:
+			if err := probe(member.ClientURLs[0]); err != nil {
+				problems = append(problems, fmt.Sprintf("etcd member %s (%s) is not healthy: %v", member.Name, member.ClientURLs[0], err))
+			}
+		}
+	}
+	return problems
+}
+
+// checkEtcdHealth lists the etcd members through a master and checks the health of each
+func (u *UpgraderEnhanced) checkEtcdHealth(master *hcloud.Server) ([]string, error) {
+	ip, err := GetServerSSHIP(master)
+	if err != nil {
+		return nil, err
+	}
+	port, useAgent := u.Config.Networking.SSH.Port, u.Config.Networking.SSH.UseAgent
+
+	output, err := u.SSHClient.Run(u.ctx, ip, port, etcdRequestCmd(etcdLocalClientURL+"/v3/cluster/member/list", "{}"), useAgent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etcd members: %w", err)
+	}
+	members, err := parseEtcdMembers(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkEtcdMembers(members, func(clientURL string) error {
+		output, err := u.SSHClient.Run(u.ctx, ip, port, etcdRequestCmd(strings.TrimRight(clientURL, "/")+"/health", ""), useAgent)
+		if err != nil {
+			return err
+		}
+		return parseEtcdHealth(output)
+	}), nil
+}
+
+// podDisruptionBudgetList mirrors the fields of `kubectl get pdb -o json` that we need
+type podDisruptionBudgetList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			DisruptionsAllowed int `json:"disruptionsAllowed"`
+			ExpectedPods       int `json:"expectedPods"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// parseBlockingPDBs returns the PodDisruptionBudgets that allow no disruptions
+// Such budgets block draining the nodes that run their pods, stalling the upgrade.
+// Budgets that currently select no pods are ignored.
+func parseBlockingPDBs(output string) ([]string, error) {
+	var list podDisruptionBudgetList
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse PodDisruptionBudget list: %w", err)
+	}
+
+	var blocking []string
+	for _, item := range list.Items {
+		if item.Status.ExpectedPods > 0 && item.Status.DisruptionsAllowed == 0 {
+			blocking = append(blocking, item.Metadata.Namespace+"/"+item.Metadata.Name)
+		}
+	}
+	sort.Strings(blocking)
+	return blocking, nil
+}
+
+// addonMinorVersion extracts the major and minor version from the first source that contains one
+func addonMinorVersion(sources ...string) (int, int, bool) {
+	for _, source := range sources {
+		match := addonVersionPattern.FindStringSubmatch(source)
+		if match == nil {
+			continue
+		}
+		major, _ := strconv.Atoi(match[1])
+		minor, _ := strconv.Atoi(match[2])
+		return major, minor, true
+	}
+	return 0, 0, false
+}
+
+// supportsKubernetesMinor reports whether an addon minor release is known to support a Kubernetes minor
+func supportsKubernetesMinor(table []addonSupport, addonMinor, kubernetesMinor int) bool {
+	if len(table) == 0 || addonMinor > table[len(table)-1].AddonMinor {
+		return true
+	}
+
+	supported := -1
+	for _, entry := range table {
+		if entry.AddonMinor <= addonMinor {
+			supported = entry.KubernetesMinor
+		}
+	}
+	return kubernetesMinor <= supported
+}
+
+// checkAddonCompatibility warns about pinned addon versions not known to support the target Kubernetes minor
+func checkAddonCompatibility(addons config.Addons, target k3sVersion) []string {
+	var warnings []string
+	targetMinor := fmt.Sprintf("v%d.%d", target.Major, target.Minor)
+
+	if ccm := addons.CloudControllerManager; ccm != nil && ccm.Enabled {
+		if _, minor, ok := addonMinorVersion(ccm.Version, ccm.ManifestURL); !ok {
+			warnings = append(warnings, fmt.Sprintf("cannot determine the cloud controller manager version, verify it supports Kubernetes %s", targetMinor))
+		} else if !supportsKubernetesMinor(ccmSupport, minor, target.Minor) {
+			warnings = append(warnings, fmt.Sprintf("cloud controller manager v1.%d is not known to support Kubernetes %s", minor, targetMinor))
+		}
+	}
+
+	if csi := addons.CSIDriver; csi != nil && csi.Enabled {
+		if _, minor, ok := addonMinorVersion(csi.Version, csi.ManifestURL); !ok {
+			warnings = append(warnings, fmt.Sprintf("cannot determine the CSI driver version, verify it supports Kubernetes %s", targetMinor))
+		} else if !supportsKubernetesMinor(csiSupport, minor, target.Minor) {
+			warnings = append(warnings, fmt.Sprintf("CSI driver v2.%d is not known to support Kubernetes %s", minor, targetMinor))
+		}
+	}
+
+	// Cluster autoscaler releases track Kubernetes minors one to one
+	if autoscaler := addons.ClusterAutoscaler; autoscaler != nil && autoscaler.Enabled {
+		if major, minor, ok := addonMinorVersion(autoscaler.ContainerImageTag); !ok {
+			warnings = append(warnings, fmt.Sprintf("cannot determine the cluster autoscaler image version, verify it supports Kubernetes %s", targetMinor))
+		} else if major != target.Major || minor != target.Minor {
+			warnings = append(warnings, fmt.Sprintf("cluster autoscaler image %s does not match Kubernetes %s, set container_image_tag to a %s.x release",
+				autoscaler.ContainerImageTag, targetMinor, targetMinor))
+		}
+	}
+
+	return warnings
+}
+
+// runPreflightChecks verifies that the cluster can safely be upgraded to the target version
+func (u *UpgraderEnhanced) runPreflightChecks(master *hcloud.Server) (preflightReport, error) {
+	var report preflightReport
+
+	target, err := parseK3sVersion(u.NewK3sVersion)
+	if err != nil {
+		return report, err
+	}
+
+	nodes, err := fetchKubeNodes(u.ctx, u.Config, u.SSHClient, master)
+	if err != nil {
+		return report, err
+	}
+
+	report.Errors = append(report.Errors, checkUpgradeNodes(nodes, target)...)
+
+	if u.Config.Datastore.Mode == "etcd" {
+		problems, err := u.checkEtcdHealth(master)
+		if err != nil {
+			return report, err
+		}
+		report.Errors = append(report.Errors, problems...)
+	}
+
+	ip, err := GetServerSSHIP(master)
+	if err != nil {
+		return report, err
+	}
+	output, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, k3sGetPDBsJSONCmd, u.Config.Networking.SSH.UseAgent)
+	if err != nil {
+		return report, fmt.Errorf("failed to list PodDisruptionBudgets: %w", err)
+	}
+	blocking, err := parseBlockingPDBs(output)
+	if err != nil {
+		return report, err
+	}
+	for _, pdb := range blocking {
+		report.Errors = append(report.Errors, fmt.Sprintf("PodDisruptionBudget %s allows no disruptions and would block node drains", pdb))
+	}
+
+	report.Warnings = append(report.Warnings, checkAddonCompatibility(u.Config.Addons, target)...)
+
+	return report, nil
+}
@@ -0,0 +1,257 @@
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/magenx/hek3ster/internal/config"
+)
+
+// TestParseK3sVersion tests parsing k3s and kubelet versions
+func TestParseK3sVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    k3sVersion
+		wantErr bool
+	}{
+		{input: "v1.32.1+k3s1", want: k3sVersion{Major: 1, Minor: 32, Patch: 1, Revision: 1}},
+		{input: "v1.31.4+k3s2", want: k3sVersion{Major: 1, Minor: 31, Patch: 4, Revision: 2}},
+		{input: "1.30.0", want: k3sVersion{Major: 1, Minor: 30}},
+		{input: "latest", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseK3sVersion(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseK3sVersion(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseK3sVersion(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCheckVersionSkew tests rejecting downgrades and skipped minor versions
+func TestCheckVersionSkew(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		target  string
+		wantErr string
+	}{
+		{name: "patch upgrade", current: "v1.31.4+k3s1", target: "v1.31.5+k3s1"},
+		{name: "k3s revision upgrade", current: "v1.31.4+k3s1", target: "v1.31.4+k3s2"},
+		{name: "next minor", current: "v1.31.4+k3s1", target: "v1.32.1+k3s1"},
+		{name: "same version", current: "v1.32.1+k3s1", target: "v1.32.1+k3s1"},
+		{name: "skipped minor", current: "v1.30.4+k3s1", target: "v1.32.1+k3s1", wantErr: "upgrade to v1.31 first"},
+		{name: "downgrade", current: "v1.32.1+k3s1", target: "v1.31.4+k3s1", wantErr: "downgrade"},
+		{name: "revision downgrade", current: "v1.32.1+k3s2", target: "v1.32.1+k3s1", wantErr: "downgrade"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, _ := parseK3sVersion(tt.current)
+			target, _ := parseK3sVersion(tt.target)
+			err := checkVersionSkew(current, target)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCheckUpgradeNodes tests node readiness and per-node version checks
+func TestCheckUpgradeNodes(t *testing.T) {
+	target, _ := parseK3sVersion("v1.32.1+k3s1")
+	nodes := map[string]kubeNode{
+		"test-master-1":        {Name: "test-master-1", KubeletVersion: "v1.32.1+k3s1", Ready: true},
+		"test-master-2":        {Name: "test-master-2", KubeletVersion: "v1.31.4+k3s1", Ready: true},
+		"test-worker-pool-1-1": {Name: "test-worker-pool-1-1", KubeletVersion: "v1.31.4+k3s1", Ready: false},
+		"test-worker-pool-1-2": {Name: "test-worker-pool-1-2", KubeletVersion: "v1.30.2+k3s1", Ready: true},
+	}
+
+	problems := checkUpgradeNodes(nodes, target)
+	want := []string{
+		"node test-worker-pool-1-1 is not Ready",
+		"node test-worker-pool-1-2: upgrade from v1.30 to v1.32 skips minor versions, upgrade to v1.31 first",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("checkUpgradeNodes() = %v, want %v", problems, want)
+	}
+
+	if problems := checkUpgradeNodes(map[string]kubeNode{}, target); len(problems) != 1 {
+		t.Errorf("expected a problem when no nodes are found, got %v", problems)
+	}
+}
+
+// TestParseEtcdMembers tests parsing the etcd member list API response
+func TestParseEtcdMembers(t *testing.T) {
+	output := `{"header":{"cluster_id":"1"},"members":[
+		{"ID":"11","name":"test-master-1-a1","peerURLs":["https://10.0.0.2:2380"],"clientURLs":["https://10.0.0.2:2379"]},
+		{"ID":"12","peerURLs":["https://10.0.0.3:2380"],"isLearner":true}]}`
+
+	members, err := parseEtcdMembers(output)
+	if err != nil {
+		t.Fatalf("parseEtcdMembers failed: %v", err)
+	}
+	if len(members) != 2 || members[0].Name != "test-master-1-a1" || members[0].ClientURLs[0] != "https://10.0.0.2:2379" {
+		t.Errorf("members = %+v", members)
+	}
+	if !members[1].IsLearner || len(members[1].ClientURLs) != 0 {
+		t.Errorf("second member = %+v, want an unstarted learner", members[1])
+	}
+
+	if _, err := parseEtcdMembers("curl: (7) Failed to connect"); err == nil {
+		t.Error("expected error for a non-JSON response")
+	}
+}
+
+// TestParseEtcdHealth tests parsing the etcd /health response
+func TestParseEtcdHealth(t *testing.T) {
+	if err := parseEtcdHealth(`{"health":"true","reason":""}`); err != nil {
+		t.Errorf("healthy member: %v", err)
+	}
+	if err := parseEtcdHealth(`{"health":"false","reason":"RAFT NO LEADER"}`); err == nil || !strings.Contains(err.Error(), "RAFT NO LEADER") {
+		t.Errorf("unhealthy member error = %v, want the reason", err)
+	}
+	if err := parseEtcdHealth("curl: (28) Operation timed out"); err == nil {
+		t.Error("expected error for a non-JSON response")
+	}
+}
+
+// TestCheckEtcdMembers tests that every etcd member must be healthy
+func TestCheckEtcdMembers(t *testing.T) {
+	member := func(name, url string) etcdMember {
+		return etcdMember{Name: name, ClientURLs: []string{url}}
+	}
+	tests := []struct {
+		name      string
+		members   []etcdMember
+		unhealthy string
+		wantIssue int
+	}{
+		{name: "all healthy", members: []etcdMember{member("m1", "https://10.0.0.2:2379"), member("m2", "https://10.0.0.3:2379"), member("m3", "https://10.0.0.4:2379")}},
+		{name: "one of three down", members: []etcdMember{member("m1", "https://10.0.0.2:2379"), member("m2", "https://10.0.0.3:2379"), member("m3", "https://10.0.0.4:2379")}, unhealthy: "https://10.0.0.4:2379", wantIssue: 1},
+		{name: "unstarted member", members: []etcdMember{member("m1", "https://10.0.0.2:2379"), {Name: ""}}, wantIssue: 1},
+		{name: "learner", members: []etcdMember{member("m1", "https://10.0.0.2:2379"), {Name: "m2", ClientURLs: []string{"https://10.0.0.3:2379"}, IsLearner: true}}, wantIssue: 1},
+		{name: "no members", wantIssue: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := checkEtcdMembers(tt.members, func(clientURL string) error {
+				if clientURL == tt.unhealthy {
+					return fmt.Errorf("unhealthy")
+				}
+				return nil
+			})
+			if len(problems) != tt.wantIssue {
+				t.Errorf("checkEtcdMembers() = %v, want %d problem(s)", problems, tt.wantIssue)
+			}
+		})
+	}
+}
+
+// TestEtcdRequestCmd tests that etcd requests use the k3s client certificate
+func TestEtcdRequestCmd(t *testing.T) {
+	get := etcdRequestCmd("https://10.0.0.2:2379/health", "")
+	for _, want := range []string{"--cert " + k3sEtcdTLSDir + "/client.crt", "--cacert " + k3sEtcdTLSDir + "/server-ca.crt", "'https://10.0.0.2:2379/health'"} {
+		if !strings.Contains(get, want) {
+			t.Errorf("command %q missing %q", get, want)
+		}
+	}
+	if strings.Contains(get, "POST") {
+		t.Errorf("health request should be a GET: %s", get)
+	}
+	if post := etcdRequestCmd(etcdLocalClientURL+"/v3/cluster/member/list", "{}"); !strings.Contains(post, "-X POST -d '{}'") {
+		t.Errorf("member list request should POST a body: %s", post)
+	}
+}
+
+// TestGenerateMasterUpgradePlan tests limiting the master plan to one node
+func TestGenerateMasterUpgradePlan(t *testing.T) {
+	all := generateMasterUpgradePlan("v1.33.1+k3s1", "")
+	if strings.Contains(all, "kubernetes.io/hostname") {
+		t.Errorf("plan for all masters selects a node:\n%s", all)
+	}
+	one := generateMasterUpgradePlan("v1.33.1+k3s1", "test-master-2")
+	if !strings.Contains(one, "key: kubernetes.io/hostname\n      operator: In\n      values:\n      - test-master-2") {
+		t.Errorf("plan for one master does not select it:\n%s", one)
+	}
+}
+
+// TestParseBlockingPDBs tests finding PodDisruptionBudgets that block drains
+func TestParseBlockingPDBs(t *testing.T) {
+	output := `{"items":[
+		{"metadata":{"name":"web","namespace":"default"},"status":{"disruptionsAllowed":1,"expectedPods":3}},
+		{"metadata":{"name":"db","namespace":"data"},"status":{"disruptionsAllowed":0,"expectedPods":1}},
+		{"metadata":{"name":"idle","namespace":"default"},"status":{"disruptionsAllowed":0,"expectedPods":0}}
+	]}`
+
+	blocking, err := parseBlockingPDBs(output)
+	if err != nil {
+		t.Fatalf("parseBlockingPDBs() error: %v", err)
+	}
+	if want := []string{"data/db"}; !reflect.DeepEqual(blocking, want) {
+		t.Errorf("parseBlockingPDBs() = %v, want %v", blocking, want)
+	}
+
+	if _, err := parseBlockingPDBs("not json"); err == nil {
+		t.Error("expected error for invalid output")
+	}
+}
+
+// TestCheckAddonCompatibility tests warnings for addon versions pinned in the configuration
+func TestCheckAddonCompatibility(t *testing.T) {
+	target, _ := parseK3sVersion("v1.34.1+k3s1")
+
+	t.Run("defaults", func(t *testing.T) {
+		var addons config.Addons
+		addons.SetDefaults()
+		if warnings := checkAddonCompatibility(addons, target); len(warnings) != 0 {
+			t.Errorf("unexpected warnings for default addons: %v", warnings)
+		}
+	})
+
+	t.Run("outdated addons", func(t *testing.T) {
+		addons := config.Addons{
+			CloudControllerManager: &config.CloudControllerManager{
+				Enabled:     true,
+				ManifestURL: "https://github.com/hetznercloud/hcloud-cloud-controller-manager/releases/download/v1.22.0/ccm-networks.yaml",
+			},
+			CSIDriver:         &config.CSIDriver{Enabled: true, Version: "v2.12.0"},
+			ClusterAutoscaler: &config.ClusterAutoscaler{Enabled: true, ContainerImageTag: "v1.33.0"},
+		}
+		warnings := checkAddonCompatibility(addons, target)
+		if len(warnings) != 3 {
+			t.Fatalf("expected 3 warnings, got %v", warnings)
+		}
+		for i, want := range []string{"cloud controller manager v1.22", "CSI driver v2.12", "cluster autoscaler image v1.33.0"} {
+			if !strings.Contains(warnings[i], want) {
+				t.Errorf("warning %d = %q, want it to mention %q", i, warnings[i], want)
+			}
+		}
+	})
+
+	t.Run("disabled addons", func(t *testing.T) {
+		addons := config.Addons{
+			CloudControllerManager: &config.CloudControllerManager{Enabled: false},
+			CSIDriver:              &config.CSIDriver{Enabled: false},
+			ClusterAutoscaler:      &config.ClusterAutoscaler{Enabled: false},
+		}
+		if warnings := checkAddonCompatibility(addons, target); len(warnings) != 0 {
+			t.Errorf("unexpected warnings for disabled addons: %v", warnings)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+const (
+	// controlPlaneLabel marks k3s server nodes
+	controlPlaneLabel = "node-role.kubernetes.io/control-plane"
+	// upgradePlanLabel is the label system-upgrade-controller sets on the jobs of a plan
+	upgradePlanLabel = "upgrade.cattle.io/plan"
+	// upgradeNodeLabel is the label system-upgrade-controller sets on a job for its target node
+	upgradeNodeLabel = "upgrade.cattle.io/node"
+	// upgradePollInterval is the delay between upgrade progress checks
+	upgradePollInterval = 15 * time.Second
+	// DefaultUpgradeTimeout is the default time allowed for each upgrade plan to complete
+	DefaultUpgradeTimeout = 30 * time.Minute
+)
+
+// upgradeJob is the subset of a system-upgrade-controller job used to track progress
+type upgradeJob struct {
+	Name      string
+	Node      string
+	Succeeded bool
+	Failed    bool
+	Message   string
+}
+
+// upgradeJobList mirrors the fields of `kubectl get jobs -o json` that we need
+type upgradeJobList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Status struct {
+			Succeeded  int                   `json:"succeeded"`
+			Conditions []kubeStatusCondition `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// kubeStatusCondition is a generic Kubernetes status condition
+type kubeStatusCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// upgradePlanStatus mirrors the status of a system-upgrade-controller Plan
+type upgradePlanStatus struct {
+	Status struct {
+		Conditions []kubeStatusCondition `json:"conditions"`
+	} `json:"status"`
+}
+
+// upgradeProgress summarises the upgrade state of the nodes targeted by a plan
+type upgradeProgress struct {
+	Total    int
+	Upgraded []string
+	// Pending maps node names to a short description of their current state
+	Pending map[string]string
+}
+
+// done reports whether every targeted node runs the target version and is Ready
+func (p upgradeProgress) done() bool {
+	return p.Total > 0 && len(p.Pending) == 0
+}
+
+// pendingNodes returns the names of nodes that are not upgraded yet, sorted
+func (p upgradeProgress) pendingNodes() []string {
+	names := make([]string, 0, len(p.Pending))
+	for name := range p.Pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isControlPlaneNode selects the k3s server nodes targeted by the server plan
+func isControlPlaneNode(node kubeNode) bool {
+	_, ok := node.Labels[controlPlaneLabel]
+	return ok
+}
+
+// computeUpgradeProgress compares the kubelet version of the selected nodes with the target version
+// A node counts as upgraded once it reports the target version and is Ready again.
+func computeUpgradeProgress(nodes map[string]kubeNode, selected func(kubeNode) bool, targetVersion string) upgradeProgress {
+	progress := upgradeProgress{Pending: make(map[string]string)}
+
+	for name, node := range nodes {
+		if !selected(node) {
+			continue
+		}
+		progress.Total++
+
+		switch {
+		case node.KubeletVersion == targetVersion && node.Ready:
+			progress.Upgraded = append(progress.Upgraded, name)
+		case node.KubeletVersion == targetVersion:
+			progress.Pending[name] = fmt.Sprintf("%s, not Ready", node.KubeletVersion)
+		default:
+			progress.Pending[name] = node.KubeletVersion
+		}
+	}
+
+	sort.Strings(progress.Upgraded)
+	return progress
+}
+
+// parseUpgradeJobs parses the output of `kubectl get jobs -o json` for a plan
+func parseUpgradeJobs(output string) ([]upgradeJob, error) {
+	var list upgradeJobList
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse job list: %w", err)
+	}
+
+	jobs := make([]upgradeJob, 0, len(list.Items))
+	for _, item := range list.Items {
+		job := upgradeJob{
+			Name:      item.Metadata.Name,
+			Node:      item.Metadata.Labels[upgradeNodeLabel],
+			Succeeded: item.Status.Succeeded > 0,
+		}
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Failed" && condition.Status == "True" {
+				job.Failed = true
+				job.Message = condition.Message
+				if job.Message == "" {
+					job.Message = condition.Reason
+				}
+			}
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// parsePlanFailure returns the reason a Plan cannot be executed, or "" if it is healthy
+func parsePlanFailure(output string) (string, error) {
+	var plan upgradePlanStatus
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &plan); err != nil {
+		return "", fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	for _, condition := range plan.Status.Conditions {
+		if condition.Type == "Validated" && condition.Status == "False" {
+			if condition.Message != "" {
+				return condition.Message, nil
+			}
+			return condition.Reason, nil
+		}
+	}
+	return "", nil
+}
+
+// waitForUpgrade waits until every node targeted by a plan runs the new k3s version
+// It reports per-node progress, fails as soon as an upgrade job or the plan fails,
+// and gives up after u.Timeout.
+func (u *UpgraderEnhanced) waitForUpgrade(master *hcloud.Server, planName string, selected func(kubeNode) bool, expectedNodes int, scope string) error {
+	ip, err := GetServerSSHIP(master)
+	if err != nil {
+		return err
+	}
+
+	timeout := u.Timeout
+	if timeout <= 0 {
+		timeout = DefaultUpgradeTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	reported := make(map[string]string)
+	lastProgress := upgradeProgress{}
+	warnedMissing := false
+
+	for {
+		nodes, err := fetchKubeNodes(u.ctx, u.Config, u.SSHClient, master)
+		if err != nil {
+			// The API server may be briefly unavailable while masters restart
+			util.LogWarning(fmt.Sprintf("Failed to read node state: %v", err), scope)
+		} else {
+			progress := computeUpgradeProgress(nodes, selected, u.NewK3sVersion)
+			reportUpgradeProgress(progress, reported, u.NewK3sVersion, scope)
+			lastProgress = progress
+
+			if progress.Total < expectedNodes && !warnedMissing {
+				util.LogWarning(fmt.Sprintf("Found %d server(s) but only %d Kubernetes node(s) to upgrade", expectedNodes, progress.Total), scope)
+				warnedMissing = true
+			}
+
+			if progress.done() {
+				return nil
+			}
+		}
+
+		if err := u.checkUpgradeFailures(ip, planName); err != nil {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("upgrade did not complete within %v: %d/%d node(s) on %s, pending: %s",
+				timeout, len(lastProgress.Upgraded), lastProgress.Total, u.NewK3sVersion, strings.Join(lastProgress.pendingNodes(), ", "))
+		}
+
+		time.Sleep(upgradePollInterval)
+	}
+}
+
+// checkUpgradeFailures returns an error if the plan is invalid or one of its jobs failed
+func (u *UpgraderEnhanced) checkUpgradeFailures(ip, planName string) error {
+	planCmd := fmt.Sprintf("sudo k3s kubectl get plan %s -n system-upgrade -o json 2>/dev/null", util.ShellQuote(planName))
+	output, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, planCmd, u.Config.Networking.SSH.UseAgent)
+	if err == nil && strings.TrimSpace(output) != "" {
+		reason, parseErr := parsePlanFailure(output)
+		if parseErr == nil && reason != "" {
+			return fmt.Errorf("upgrade plan %s is invalid: %s", planName, reason)
+		}
+	}
+
+	jobsCmd := fmt.Sprintf("sudo k3s kubectl get jobs -n system-upgrade -l %s -o json 2>/dev/null", util.ShellQuote(upgradePlanLabel+"="+planName))
+	output, err = u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, jobsCmd, u.Config.Networking.SSH.UseAgent)
+	if err != nil || strings.TrimSpace(output) == "" {
+		return nil
+	}
+
+	jobs, err := parseUpgradeJobs(output)
+	if err != nil {
+		return nil
+	}
+	for _, job := range jobs {
+		if job.Failed {
+			return fmt.Errorf("upgrade job %s for node %s failed: %s", job.Name, job.Node, job.Message)
+		}
+	}
+
+	return nil
+}
+
+// reportUpgradeProgress logs nodes whose upgrade state changed since the last report
+func reportUpgradeProgress(progress upgradeProgress, reported map[string]string, targetVersion, scope string) {
+	changed := false
+
+	for _, name := range progress.Upgraded {
+		if reported[name] != targetVersion {
+			util.LogSuccess(fmt.Sprintf("%s upgraded to %s", name, targetVersion), scope)
+			reported[name] = targetVersion
+			changed = true
+		}
+	}
+	for _, name := range progress.pendingNodes() {
+		state := progress.Pending[name]
+		if reported[name] != state {
+			util.LogInfo(fmt.Sprintf("%s waiting for upgrade (currently %s)", name, state), scope)
+			reported[name] = state
+			changed = true
+		}
+	}
+
+	if changed {
+		util.LogInfo(fmt.Sprintf("%d/%d node(s) upgraded", len(progress.Upgraded), progress.Total), scope)
+	}
+}
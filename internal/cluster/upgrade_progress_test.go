@@ -0,0 +1,144 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestComputeUpgradeProgress tests counting upgraded nodes per plan target
+func TestComputeUpgradeProgress(t *testing.T) {
+	target := "v1.32.1+k3s1"
+	nodes := map[string]kubeNode{
+		"test-master-1":        {Name: "test-master-1", KubeletVersion: target, Ready: true, Labels: map[string]string{controlPlaneLabel: "true"}},
+		"test-master-2":        {Name: "test-master-2", KubeletVersion: target, Ready: false, Labels: map[string]string{controlPlaneLabel: "true"}},
+		"test-master-3":        {Name: "test-master-3", KubeletVersion: "v1.31.4+k3s1", Ready: true, Labels: map[string]string{controlPlaneLabel: "true"}},
+		"test-worker-pool-1-1": {Name: "test-worker-pool-1-1", KubeletVersion: target, Ready: true, Labels: map[string]string{HCloudNodeGroupLabel: "pool-1"}},
+		"test-worker-pool-2-1": {Name: "test-worker-pool-2-1", KubeletVersion: "v1.31.4+k3s1", Ready: true, Labels: map[string]string{HCloudNodeGroupLabel: "pool-2"}},
+	}
+
+	tests := []struct {
+		name         string
+		selected     func(kubeNode) bool
+		wantTotal    int
+		wantUpgraded []string
+		wantPending  map[string]string
+		wantDone     bool
+	}{
+		{
+			name:         "masters",
+			selected:     isControlPlaneNode,
+			wantTotal:    3,
+			wantUpgraded: []string{"test-master-1"},
+			wantPending: map[string]string{
+				"test-master-2": target + ", not Ready",
+				"test-master-3": "v1.31.4+k3s1",
+			},
+		},
+		{
+			name:         "workers",
+			selected:     inNodeGroup("pool-1"),
+			wantTotal:    1,
+			wantUpgraded: []string{"test-worker-pool-1-1"},
+			wantPending:  map[string]string{},
+			wantDone:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := computeUpgradeProgress(nodes, tt.selected, target)
+			if progress.Total != tt.wantTotal {
+				t.Errorf("Total = %d, want %d", progress.Total, tt.wantTotal)
+			}
+			if !reflect.DeepEqual(progress.Upgraded, tt.wantUpgraded) {
+				t.Errorf("Upgraded = %v, want %v", progress.Upgraded, tt.wantUpgraded)
+			}
+			if !reflect.DeepEqual(progress.Pending, tt.wantPending) {
+				t.Errorf("Pending = %v, want %v", progress.Pending, tt.wantPending)
+			}
+			if progress.done() != tt.wantDone {
+				t.Errorf("done() = %v, want %v", progress.done(), tt.wantDone)
+			}
+		})
+	}
+}
+
+// TestUpgradeProgressDoneWithoutNodes tests that an empty target set is never done
+func TestUpgradeProgressDoneWithoutNodes(t *testing.T) {
+	progress := computeUpgradeProgress(map[string]kubeNode{}, isControlPlaneNode, "v1.32.1+k3s1")
+	if progress.done() {
+		t.Error("done() should be false when no nodes are targeted")
+	}
+}
+
+// TestParseUpgradeJobs tests detecting succeeded and failed upgrade jobs
+func TestParseUpgradeJobs(t *testing.T) {
+	output := `{"items":[
+		{"metadata":{"name":"apply-server-plan-on-master-1","labels":{"upgrade.cattle.io/node":"test-master-1"}},
+		 "status":{"succeeded":1,"conditions":[{"type":"Complete","status":"True"}]}},
+		{"metadata":{"name":"apply-server-plan-on-master-2","labels":{"upgrade.cattle.io/node":"test-master-2"}},
+		 "status":{"conditions":[{"type":"Failed","status":"True","reason":"BackoffLimitExceeded","message":"Job has reached the specified backoff limit"}]}},
+		{"metadata":{"name":"apply-server-plan-on-master-3","labels":{"upgrade.cattle.io/node":"test-master-3"}},
+		 "status":{"conditions":[{"type":"Failed","status":"True","reason":"DeadlineExceeded"}]}}
+	]}`
+
+	jobs, err := parseUpgradeJobs(output)
+	if err != nil {
+		t.Fatalf("parseUpgradeJobs() error: %v", err)
+	}
+
+	want := []upgradeJob{
+		{Name: "apply-server-plan-on-master-1", Node: "test-master-1", Succeeded: true},
+		{Name: "apply-server-plan-on-master-2", Node: "test-master-2", Failed: true, Message: "Job has reached the specified backoff limit"},
+		{Name: "apply-server-plan-on-master-3", Node: "test-master-3", Failed: true, Message: "DeadlineExceeded"},
+	}
+	if !reflect.DeepEqual(jobs, want) {
+		t.Errorf("parseUpgradeJobs() = %+v, want %+v", jobs, want)
+	}
+
+	if _, err := parseUpgradeJobs("not json"); err == nil {
+		t.Error("expected error for invalid output")
+	}
+}
+
+// TestParsePlanFailure tests reading the validation status of a plan
+func TestParsePlanFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "valid plan",
+			output: `{"status":{"conditions":[{"type":"Validated","status":"True"}]}}`,
+			want:   "",
+		},
+		{
+			name:   "invalid plan",
+			output: `{"status":{"conditions":[{"type":"Validated","status":"False","reason":"Error","message":"spec.version is invalid"}]}}`,
+			want:   "spec.version is invalid",
+		},
+		{
+			name:   "invalid plan without message",
+			output: `{"status":{"conditions":[{"type":"Validated","status":"False","reason":"Error"}]}}`,
+			want:   "Error",
+		},
+		{
+			name:   "no status yet",
+			output: `{}`,
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePlanFailure(tt.output)
+			if err != nil {
+				t.Fatalf("parsePlanFailure() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parsePlanFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
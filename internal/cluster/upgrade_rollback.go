@@ -0,0 +1,173 @@
+package cluster
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+// preUpgradeSnapshotPrefix prefixes the names of etcd snapshots taken before an upgrade
+const preUpgradeSnapshotPrefix = "pre-upgrade"
+
+var (
+	// snapshotSavedPattern matches the k3s log line reporting where a snapshot was written
+	snapshotSavedPattern = regexp.MustCompile(`Saving (?:current )?etcd snapshot to (\S+)`)
+	// invalidSnapshotNameChars matches characters that are replaced in snapshot names
+	invalidSnapshotNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// preUpgradeSnapshotName returns the snapshot name prefix used before upgrading from a version
+// k3s appends the node name and a timestamp to it.
+func preUpgradeSnapshotName(previousVersion string) string {
+	version := invalidSnapshotNameChars.ReplaceAllString(strings.ToLower(previousVersion), "-")
+	return preUpgradeSnapshotPrefix + "-" + strings.Trim(version, "-")
+}
+
+// parseSnapshotName extracts the snapshot file name from `k3s etcd-snapshot save` output
+func parseSnapshotName(output string) string {
+	match := snapshotSavedPattern.FindStringSubmatch(output)
+	if match == nil {
+		return ""
+	}
+	return filepath.Base(strings.Trim(match[1], `"'`))
+}
+
+// oldestKubeletVersion returns the oldest valid kubelet version reported by the nodes, or ""
+func oldestKubeletVersion(nodes map[string]kubeNode) string {
+	var oldest string
+	var oldestVersion k3sVersion
+	for _, node := range nodes {
+		version, err := parseK3sVersion(node.KubeletVersion)
+		if err != nil {
+			continue
+		}
+		if oldest == "" || version.compare(oldestVersion) < 0 {
+			oldest = node.KubeletVersion
+			oldestVersion = version
+		}
+	}
+	return oldest
+}
+
+// recordUpgradeState records the pre-upgrade version and takes an etcd snapshot
+// An unfinished upgrade to the same version keeps its record, so a re-run does not
+// replace the original pre-upgrade version and snapshot.
+func (u *UpgraderEnhanced) recordUpgradeState(master *hcloud.Server) (*UpgradeState, error) {
+	existing, err := LoadUpgradeState(u.Config.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.TargetVersion == u.NewK3sVersion && existing.CompletedAt == nil {
+		util.LogInfo(fmt.Sprintf("Continuing recorded upgrade from %s", existing.PreviousVersion), "upgrade")
+		return existing, nil
+	}
+
+	nodes, err := fetchKubeNodes(u.ctx, u.Config, u.SSHClient, master)
+	if err != nil {
+		return nil, err
+	}
+	previousVersion := oldestKubeletVersion(nodes)
+	if previousVersion == "" {
+		previousVersion = u.Config.K3sVersion
+	}
+
+	state, err := NewUpgradeState(u.Config.ClusterName, previousVersion, u.NewK3sVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Config.Datastore.Mode == "etcd" {
+		snapshot, err := u.takeEtcdSnapshot(master, preUpgradeSnapshotName(previousVersion))
+		if err != nil {
+			return nil, fmt.Errorf("failed to take pre-upgrade etcd snapshot: %w", err)
+		}
+		state.Snapshot = snapshot
+		state.SnapshotNode = master.Name
+		util.LogSuccess(fmt.Sprintf("Saved etcd snapshot %s on %s", snapshot, master.Name), "upgrade")
+	} else {
+		util.LogWarning("The cluster uses an external datastore, back it up before upgrading", "upgrade")
+	}
+
+	if err := state.Save(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// takeEtcdSnapshot saves an on-demand etcd snapshot on a master and returns its name
+func (u *UpgraderEnhanced) takeEtcdSnapshot(master *hcloud.Server, name string) (string, error) {
+	ip, err := GetServerSSHIP(master)
+	if err != nil {
+		return "", err
+	}
+
+	// The snapshot is uploaded to S3 as well when S3 is configured
+	cmd := fmt.Sprintf("sudo k3s etcd-snapshot save --name %s", util.ShellQuote(name))
+	if s3Args := u.Config.Datastore.EmbeddedEtcd.GenerateSnapshotS3Args(); s3Args != "" {
+		cmd += " " + s3Args
+	}
+	cmd += " 2>&1"
+	output, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, cmd, u.Config.Networking.SSH.UseAgent)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+
+	snapshot := parseSnapshotName(output)
+	if snapshot == "" {
+		// Keep the prefix so the snapshot can still be found with `k3s etcd-snapshot ls`
+		util.LogWarning("Could not determine the snapshot file name, recording its name prefix", "upgrade")
+		snapshot = name
+	}
+	return snapshot, nil
+}
+
+// runRollback moves every node back to the pre-upgrade k3s version
+// The version comes from the recorded upgrade, or from k3s_version if none was recorded.
+// The etcd datastore is not restored; the pre-upgrade snapshot is reported instead.
+func (u *UpgraderEnhanced) runRollback() error {
+	state, err := LoadUpgradeState(u.Config.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	if state != nil {
+		u.NewK3sVersion = state.PreviousVersion
+		if u.Config.K3sVersion != state.PreviousVersion {
+			util.LogWarning(fmt.Sprintf("k3s_version is %s but the recorded pre-upgrade version is %s, rolling back to %s",
+				u.Config.K3sVersion, state.PreviousVersion, state.PreviousVersion), u.Config.ClusterName)
+		}
+		util.LogInfo(fmt.Sprintf("Rolling back upgrade from %s to %s", state.PreviousVersion, state.TargetVersion), u.Config.ClusterName)
+	} else {
+		u.NewK3sVersion = u.Config.K3sVersion
+		util.LogWarning(fmt.Sprintf("No recorded upgrade found, rolling back to k3s_version %s", u.NewK3sVersion), u.Config.ClusterName)
+	}
+
+	if _, err := parseK3sVersion(u.NewK3sVersion); err != nil {
+		return err
+	}
+
+	masters, workers, err := u.findNodes()
+	if err != nil {
+		return err
+	}
+
+	if err := u.upgradeNodes(masters, workers); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	util.LogSuccess(fmt.Sprintf("Cluster rolled back to k3s %s", u.NewK3sVersion), u.Config.ClusterName)
+	if state != nil && state.Snapshot != "" {
+		util.LogInfo(fmt.Sprintf("The datastore was not restored. Pre-upgrade etcd snapshot: %s (on %s)", state.Snapshot, state.SnapshotNode), u.Config.ClusterName)
+	}
+	fmt.Println()
+
+	if state != nil {
+		return state.Remove()
+	}
+	return nil
+}
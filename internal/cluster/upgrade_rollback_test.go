@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPreUpgradeSnapshotName tests naming snapshots after the pre-upgrade version
+func TestPreUpgradeSnapshotName(t *testing.T) {
+	if got, want := preUpgradeSnapshotName("v1.31.4+k3s1"), "pre-upgrade-v1-31-4-k3s1"; got != want {
+		t.Errorf("preUpgradeSnapshotName() = %q, want %q", got, want)
+	}
+}
+
+// TestParseSnapshotName tests reading the snapshot file name from k3s output
+func TestParseSnapshotName(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "saved snapshot",
+			output: `time="2026-01-20T10:00:00Z" level=info msg="Saving etcd snapshot to /var/lib/rancher/k3s/server/db/snapshots/pre-upgrade-v1-31-4-k3s1-test-master-1-1768903200"`,
+			want:   "pre-upgrade-v1-31-4-k3s1-test-master-1-1768903200",
+		},
+		{
+			name:   "saved current snapshot",
+			output: "INFO[0000] Saving current etcd snapshot to /var/lib/rancher/k3s/server/db/snapshots/on-demand-test-master-1-1768903200\n",
+			want:   "on-demand-test-master-1-1768903200",
+		},
+		{
+			name:   "no snapshot line",
+			output: "etcd datastore is not started",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSnapshotName(tt.output); got != tt.want {
+				t.Errorf("parseSnapshotName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOldestKubeletVersion tests finding the pre-upgrade version of a partially upgraded cluster
+func TestOldestKubeletVersion(t *testing.T) {
+	nodes := map[string]kubeNode{
+		"test-master-1":        {KubeletVersion: "v1.32.1+k3s1"},
+		"test-worker-pool-1-1": {KubeletVersion: "v1.31.4+k3s1"},
+		"test-worker-pool-1-2": {KubeletVersion: "v1.31.4+k3s2"},
+		"test-worker-pool-1-3": {KubeletVersion: ""},
+	}
+	if got, want := oldestKubeletVersion(nodes), "v1.31.4+k3s1"; got != want {
+		t.Errorf("oldestKubeletVersion() = %q, want %q", got, want)
+	}
+	if got := oldestKubeletVersion(map[string]kubeNode{}); got != "" {
+		t.Errorf("oldestKubeletVersion() without nodes = %q, want empty", got)
+	}
+}
+
+// TestUpgradeStateSaveLoad tests persisting the pre-upgrade version and snapshot
+func TestUpgradeStateSaveLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	missing, err := LoadUpgradeState("test")
+	if err != nil || missing != nil {
+		t.Fatalf("LoadUpgradeState() without state = %v, %v; want nil, nil", missing, err)
+	}
+
+	state, err := NewUpgradeState("test", "v1.31.4+k3s1", "v1.32.1+k3s1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	state.Snapshot = "pre-upgrade-v1-31-4-k3s1-test-master-1-1768903200"
+	state.SnapshotNode = "test-master-1"
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	loaded, err := LoadUpgradeState("test")
+	if err != nil {
+		t.Fatalf("LoadUpgradeState() error: %v", err)
+	}
+	if loaded.PreviousVersion != "v1.31.4+k3s1" || loaded.TargetVersion != "v1.32.1+k3s1" {
+		t.Errorf("versions = %s -> %s, want v1.31.4+k3s1 -> v1.32.1+k3s1", loaded.PreviousVersion, loaded.TargetVersion)
+	}
+	if loaded.Snapshot != state.Snapshot || loaded.SnapshotNode != "test-master-1" {
+		t.Errorf("snapshot = %s on %s, want %s on test-master-1", loaded.Snapshot, loaded.SnapshotNode, state.Snapshot)
+	}
+	if loaded.CompletedAt != nil {
+		t.Error("upgrade should not be completed yet")
+	}
+
+	loaded.MarkCompleted()
+	if err := loaded.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	completed, err := LoadUpgradeState("test")
+	if err != nil || completed.CompletedAt == nil || time.Since(*completed.CompletedAt) > time.Minute {
+		t.Errorf("LoadUpgradeState() after completion = %+v, %v", completed, err)
+	}
+
+	if err := completed.Remove(); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if missing, _ := LoadUpgradeState("test"); missing != nil {
+		t.Error("state should be removed")
+	}
+}
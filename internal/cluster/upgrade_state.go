@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+// upgradeStateFile is the name of the file recording the last upgrade
+const upgradeStateFile = "upgrade-state.json"
+
+// UpgradeState records the last upgrade of a cluster so that it can be rolled back
+type UpgradeState struct {
+	ClusterName     string     `json:"cluster_name"`
+	PreviousVersion string     `json:"previous_version"`
+	TargetVersion   string     `json:"target_version"`
+	Snapshot        string     `json:"snapshot,omitempty"`
+	SnapshotNode    string     `json:"snapshot_node,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+
+	path string
+}
+
+// NewUpgradeState creates an upgrade record for a cluster
+func NewUpgradeState(clusterName, previousVersion, targetVersion string) (*UpgradeState, error) {
+	dir, err := ClusterStateDir(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpgradeState{
+		ClusterName:     clusterName,
+		PreviousVersion: previousVersion,
+		TargetVersion:   targetVersion,
+		StartedAt:       time.Now().UTC(),
+		path:            filepath.Join(dir, upgradeStateFile),
+	}, nil
+}
+
+// LoadUpgradeState loads the last upgrade record of a cluster
+// It returns nil without error if no upgrade has been recorded.
+func LoadUpgradeState(clusterName string) (*UpgradeState, error) {
+	state, err := NewUpgradeState(clusterName, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(state.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upgrade state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse upgrade state file %s: %w", state.path, err)
+	}
+	if state.ClusterName != clusterName {
+		return nil, fmt.Errorf("upgrade state file %s belongs to cluster %s", state.path, state.ClusterName)
+	}
+
+	return state, nil
+}
+
+// Path returns the location of the upgrade state file
+func (s *UpgradeState) Path() string {
+	return s.path
+}
+
+// MarkCompleted records that every node runs the target version
+func (s *UpgradeState) MarkCompleted() {
+	now := time.Now().UTC()
+	s.CompletedAt = &now
+}
+
+// Save writes the upgrade state file
+func (s *UpgradeState) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode upgrade state: %w", err)
+	}
+
+	return util.WriteToFile(s.path, data, 0600)
+}
+
+// Remove deletes the upgrade state file
+func (s *UpgradeState) Remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove upgrade state file: %w", err)
+	}
+	return nil
+}
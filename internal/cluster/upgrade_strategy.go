@@ -0,0 +1,304 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+const (
+	// legacyAgentPlanName is the single worker plan used before pool-by-pool upgrades
+	legacyAgentPlanName = "agent-plan"
+	// agentPlanPrefix prefixes the names of the per-pool worker plans
+	agentPlanPrefix = "agent-plan-"
+	// maxPlanNameLength keeps plan names usable as label values on upgrade jobs
+	maxPlanNameLength = 63
+)
+
+// invalidPlanNameChars matches characters not allowed in a plan name
+var invalidPlanNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// poolUpgrade is a worker pool upgraded by its own system-upgrade-controller plan
+type poolUpgrade struct {
+	// Pool is the pool name from the configuration, or the node group for unknown pools
+	Pool string
+	// NodeGroup is the value of the hcloud/node-group label on the pool's nodes
+	NodeGroup   string
+	Nodes       int
+	Concurrency int64
+	Drain       *config.DrainOptions
+	Canary      bool
+}
+
+// workerNodeGroup returns the hcloud/node-group label value for a worker pool
+// Autoscaled pools use the node group name known to the cluster autoscaler, static pools
+// the pool name from their server labels. staticIndex is the position among static pools.
+func workerNodeGroup(cfg *config.Main, pool config.WorkerNodePool, staticIndex int) string {
+	if pool.AutoscalingEnabled() {
+		return pool.BuildNodePoolName(cfg.ClusterName)
+	}
+	return workerPoolName(pool, staticIndex)
+}
+
+// buildPoolUpgrades orders the worker pools for a pool-by-pool upgrade
+// The canary pool goes first, followed by the other pools in configuration order and
+// finally node groups that are no longer configured. Pools without nodes are skipped.
+func buildPoolUpgrades(cfg *config.Main, nodeCounts map[string]int) []poolUpgrade {
+	var upgrades []poolUpgrade
+	seen := make(map[string]bool)
+
+	staticIndex := 0
+	for _, pool := range cfg.WorkerNodePools {
+		nodeGroup := workerNodeGroup(cfg, pool, staticIndex)
+		if !pool.AutoscalingEnabled() {
+			staticIndex++
+		}
+		if seen[nodeGroup] {
+			continue
+		}
+		seen[nodeGroup] = true
+
+		if nodeCounts[nodeGroup] == 0 {
+			continue
+		}
+
+		upgrade := poolUpgrade{
+			Pool:        nodeGroup,
+			NodeGroup:   nodeGroup,
+			Nodes:       nodeCounts[nodeGroup],
+			Concurrency: pool.EffectiveUpgradeConcurrency(cfg.K3sUpgradeConcurrency),
+		}
+		if pool.Name != nil {
+			upgrade.Pool = *pool.Name
+		}
+		if pool.Upgrade != nil {
+			upgrade.Drain = pool.Upgrade.Drain
+		}
+		upgrade.Canary = cfg.UpgradeStrategy.CanaryPool != "" && upgrade.Pool == cfg.UpgradeStrategy.CanaryPool
+
+		upgrades = append(upgrades, upgrade)
+	}
+
+	var unknown []string
+	for nodeGroup, count := range nodeCounts {
+		if !seen[nodeGroup] && count > 0 {
+			unknown = append(unknown, nodeGroup)
+		}
+	}
+	sort.Strings(unknown)
+	for _, nodeGroup := range unknown {
+		upgrades = append(upgrades, poolUpgrade{
+			Pool:        nodeGroup,
+			NodeGroup:   nodeGroup,
+			Nodes:       nodeCounts[nodeGroup],
+			Concurrency: max(cfg.K3sUpgradeConcurrency, 1),
+		})
+	}
+
+	// Move the canary pool to the front, keeping the order of the others
+	sort.SliceStable(upgrades, func(i, j int) bool {
+		return upgrades[i].Canary && !upgrades[j].Canary
+	})
+
+	return upgrades
+}
+
+// poolPlanName returns the name of the upgrade plan for a worker node group
+func poolPlanName(nodeGroup string) string {
+	name := agentPlanPrefix + invalidPlanNameChars.ReplaceAllString(strings.ToLower(nodeGroup), "-")
+	if len(name) > maxPlanNameLength {
+		name = name[:maxPlanNameLength]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// generatePoolUpgradePlan generates upgrade plan YAML for the workers of one pool
+func generatePoolUpgradePlan(upgrade poolUpgrade, version string) string {
+	var drain string
+	if upgrade.Drain != nil {
+		var b strings.Builder
+		b.WriteString("\n  drain:")
+		if upgrade.Drain.Timeout != "" {
+			// The plan stores the drain timeout as a duration in nanoseconds
+			if timeout, err := time.ParseDuration(upgrade.Drain.Timeout); err == nil {
+				fmt.Fprintf(&b, "\n    timeout: %d", int64(timeout))
+			}
+		}
+		fmt.Fprintf(&b, "\n    deleteEmptydirData: %t", upgrade.Drain.DeleteEmptydirData)
+		fmt.Fprintf(&b, "\n    force: %t", upgrade.Drain.Force)
+		fmt.Fprintf(&b, "\n    disableEviction: %t", upgrade.Drain.DisableEviction)
+		drain = b.String()
+	}
+
+	return fmt.Sprintf(`apiVersion: upgrade.cattle.io/v1
+kind: Plan
+metadata:
+  name: %s
+  namespace: system-upgrade
+spec:
+  concurrency: %d
+  cordon: true%s
+  nodeSelector:
+    matchExpressions:
+    - key: node-role.kubernetes.io/control-plane
+      operator: DoesNotExist
+    - key: %s
+      operator: In
+      values:
+      - %s
+  prepare:
+    args:
+    - prepare
+    - server-plan
+    image: rancher/k3s-upgrade
+  serviceAccountName: system-upgrade
+  upgrade:
+    image: rancher/k3s-upgrade
+  version: %s`, poolPlanName(upgrade.NodeGroup), upgrade.Concurrency, drain, HCloudNodeGroupLabel, upgrade.NodeGroup, version)
+}
+
+// nodeGroupLabelCommands returns the commands that label worker nodes with their node group
+// Static workers are not labelled at install time, so the label is derived from the server labels.
+// Workers without a Kubernetes node are skipped; the returned counts include only labelled nodes.
+func nodeGroupLabelCommands(workers []*hcloud.Server, nodes map[string]kubeNode) ([]string, map[string]int) {
+	var commands []string
+	counts := make(map[string]int)
+
+	for _, server := range workers {
+		node, ok := nodes[server.Name]
+		if !ok {
+			continue
+		}
+		nodeGroup := serverPool(server)
+		if nodeGroup == "" {
+			continue
+		}
+		counts[nodeGroup]++
+
+		if node.Labels[HCloudNodeGroupLabel] != nodeGroup {
+			commands = append(commands, fmt.Sprintf("sudo k3s kubectl label node %s %s --overwrite",
+				util.ShellQuote(server.Name), util.ShellQuote(HCloudNodeGroupLabel+"="+nodeGroup)))
+		}
+	}
+
+	return commands, counts
+}
+
+// inNodeGroup returns a node selector for the workers of a node group
+func inNodeGroup(nodeGroup string) func(kubeNode) bool {
+	return func(node kubeNode) bool {
+		_, isControlPlane := node.Labels[controlPlaneLabel]
+		return !isControlPlane && node.Labels[HCloudNodeGroupLabel] == nodeGroup
+	}
+}
+
+// upgradeWorkerPools upgrades the workers pool by pool, starting with the canary pool
+func (u *UpgraderEnhanced) upgradeWorkerPools(master *hcloud.Server, workers []*hcloud.Server) error {
+	ip, err := GetServerSSHIP(master)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := fetchKubeNodes(u.ctx, u.Config, u.SSHClient, master)
+	if err != nil {
+		return err
+	}
+
+	commands, nodeCounts := nodeGroupLabelCommands(workers, nodes)
+	for _, cmd := range commands {
+		if _, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, cmd, u.Config.Networking.SSH.UseAgent); err != nil {
+			return fmt.Errorf("failed to label worker node: %w", err)
+		}
+	}
+
+	// The legacy plan targets every worker and would race with the per-pool plans
+	deleteCmd := fmt.Sprintf("sudo k3s kubectl delete plan %s -n system-upgrade --ignore-not-found", legacyAgentPlanName)
+	if _, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, deleteCmd, u.Config.Networking.SSH.UseAgent); err != nil {
+		return fmt.Errorf("failed to delete legacy worker upgrade plan: %w", err)
+	}
+
+	upgrades := buildPoolUpgrades(u.Config, nodeCounts)
+	for i, upgrade := range upgrades {
+		scope := "worker"
+		label := fmt.Sprintf("pool %s", upgrade.Pool)
+		if upgrade.Canary {
+			label = fmt.Sprintf("canary pool %s", upgrade.Pool)
+		}
+		util.LogInfo(fmt.Sprintf("Upgrading %s: %d node(s), %d at a time", label, upgrade.Nodes, upgrade.Concurrency), scope)
+
+		applyCmd := fmt.Sprintf("echo '%s' | sudo k3s kubectl apply -f -", generatePoolUpgradePlan(upgrade, u.NewK3sVersion))
+		if _, err := u.SSHClient.Run(u.ctx, ip, u.Config.Networking.SSH.Port, applyCmd, u.Config.Networking.SSH.UseAgent); err != nil {
+			return fmt.Errorf("failed to apply upgrade plan for pool %s: %w", upgrade.Pool, err)
+		}
+
+		if err := u.waitForUpgrade(master, poolPlanName(upgrade.NodeGroup), inNodeGroup(upgrade.NodeGroup), upgrade.Nodes, scope); err != nil {
+			return fmt.Errorf("pool %s: %w", upgrade.Pool, err)
+		}
+		util.LogSuccess(fmt.Sprintf("Upgraded %s", label), scope)
+
+		// A rollback should restore service quickly, so it does not pause after the canary
+		if upgrade.Canary && !u.Rollback && i < len(upgrades)-1 {
+			if err := u.pauseAfterCanary(master, upgrade); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// pauseAfterCanary waits for the canary soak period and/or a manual confirmation
+// The canary nodes must still be Ready after the soak period.
+func (u *UpgraderEnhanced) pauseAfterCanary(master *hcloud.Server, canary poolUpgrade) error {
+	soakPeriod, err := u.Config.UpgradeStrategy.SoakPeriod()
+	if err != nil {
+		return err
+	}
+
+	if soakPeriod > 0 {
+		util.LogInfo(fmt.Sprintf("Soaking canary pool %s for %v", canary.Pool, soakPeriod), "canary")
+		time.Sleep(soakPeriod)
+
+		nodes, err := fetchKubeNodes(u.ctx, u.Config, u.SSHClient, master)
+		if err != nil {
+			return fmt.Errorf("failed to check canary pool after soak period: %w", err)
+		}
+		progress := computeUpgradeProgress(nodes, inNodeGroup(canary.NodeGroup), u.NewK3sVersion)
+		if !progress.done() {
+			return fmt.Errorf("canary pool %s is unhealthy after soak period, pending: %s",
+				canary.Pool, strings.Join(progress.pendingNodes(), ", "))
+		}
+		util.LogSuccess(fmt.Sprintf("Canary pool %s is healthy", canary.Pool), "canary")
+	}
+
+	if u.Config.UpgradeStrategy.ConfirmAfterCanary {
+		return confirmContinueUpgrade(canary.Pool)
+	}
+	return nil
+}
+
+// confirmContinueUpgrade asks for confirmation before upgrading the remaining pools
+func confirmContinueUpgrade(canaryPool string) error {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Printf("\nCanary pool %s upgraded. Continue with the remaining pools? Only 'yes' will be accepted: ", canaryPool)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if strings.TrimSpace(input) != "yes" {
+		util.LogWarning("Upgrade stopped after canary pool, re-run the upgrade to continue", "canary")
+		return fmt.Errorf("upgrade stopped after canary pool %s", canaryPool)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package cluster
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+)
+
+// TestBuildPoolUpgrades tests ordering worker pools for a pool-by-pool upgrade
+func TestBuildPoolUpgrades(t *testing.T) {
+	web, db, batch := "web", "db", "batch"
+	drain := &config.DrainOptions{Timeout: "5m"}
+	cfg := &config.Main{
+		ClusterName:           "test",
+		K3sUpgradeConcurrency: 2,
+		UpgradeStrategy:       config.UpgradeStrategy{CanaryPool: "db"},
+		WorkerNodePools: []config.WorkerNodePool{
+			{NodePool: config.NodePool{Name: &web}},
+			{NodePool: config.NodePool{Name: &db}, Upgrade: &config.PoolUpgrade{Concurrency: 1, Drain: drain}},
+			{NodePool: config.NodePool{Name: &batch, IncludeClusterNameAsPrefix: true, Autoscaling: &config.Autoscaling{Enabled: true, MaxInstances: 3}}, Upgrade: &config.PoolUpgrade{Concurrency: 3}},
+		},
+	}
+	counts := map[string]int{"web": 3, "db": 2, "test-batch": 1, "legacy": 1}
+
+	got := buildPoolUpgrades(cfg, counts)
+	want := []poolUpgrade{
+		{Pool: "db", NodeGroup: "db", Nodes: 2, Concurrency: 1, Drain: drain, Canary: true},
+		{Pool: "web", NodeGroup: "web", Nodes: 3, Concurrency: 2},
+		{Pool: "batch", NodeGroup: "test-batch", Nodes: 1, Concurrency: 3},
+		{Pool: "legacy", NodeGroup: "legacy", Nodes: 1, Concurrency: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildPoolUpgrades() = %+v, want %+v", got, want)
+	}
+
+	// Pools without nodes are skipped
+	got = buildPoolUpgrades(cfg, map[string]int{"web": 1})
+	if len(got) != 1 || got[0].Pool != "web" || got[0].Canary {
+		t.Errorf("buildPoolUpgrades() = %+v, want only the web pool", got)
+	}
+}
+
+// TestPoolPlanName tests deriving valid plan names from node groups
+func TestPoolPlanName(t *testing.T) {
+	tests := []struct {
+		nodeGroup string
+		want      string
+	}{
+		{nodeGroup: "web", want: "agent-plan-web"},
+		{nodeGroup: "Test_GPU.Pool", want: "agent-plan-test-gpu-pool"},
+		{nodeGroup: strings.Repeat("a", 80), want: "agent-plan-" + strings.Repeat("a", 52)},
+	}
+
+	for _, tt := range tests {
+		if got := poolPlanName(tt.nodeGroup); got != tt.want {
+			t.Errorf("poolPlanName(%q) = %q, want %q", tt.nodeGroup, got, tt.want)
+		}
+	}
+}
+
+// TestGeneratePoolUpgradePlan tests rendering a per-pool upgrade plan
+func TestGeneratePoolUpgradePlan(t *testing.T) {
+	upgrade := poolUpgrade{Pool: "web", NodeGroup: "web", Nodes: 3, Concurrency: 2}
+
+	plan := generatePoolUpgradePlan(upgrade, "v1.32.1+k3s1")
+	for _, want := range []string{
+		"name: agent-plan-web",
+		"concurrency: 2",
+		"- key: hcloud/node-group\n      operator: In\n      values:\n      - web",
+		"version: v1.32.1+k3s1",
+	} {
+		if !strings.Contains(plan, want) {
+			t.Errorf("plan does not contain %q:\n%s", want, plan)
+		}
+	}
+	if strings.Contains(plan, "drain:") {
+		t.Errorf("plan without drain options should only cordon:\n%s", plan)
+	}
+
+	upgrade.Drain = &config.DrainOptions{Timeout: "10m", DeleteEmptydirData: true}
+	plan = generatePoolUpgradePlan(upgrade, "v1.32.1+k3s1")
+	for _, want := range []string{"drain:", "timeout: 600000000000", "deleteEmptydirData: true", "force: false"} {
+		if !strings.Contains(plan, want) {
+			t.Errorf("plan does not contain %q:\n%s", want, plan)
+		}
+	}
+}
+
+// TestNodeGroupLabelCommands tests labelling worker nodes with their node group
+func TestNodeGroupLabelCommands(t *testing.T) {
+	workers := []*hcloud.Server{
+		{Name: "test-worker-web-1", Labels: map[string]string{"role": "worker", "pool": "web"}},
+		{Name: "test-worker-web-2", Labels: map[string]string{"role": "worker", "pool": "web"}},
+		{Name: "test-batch-abc", Labels: map[string]string{HCloudNodeGroupLabel: "test-batch"}},
+		{Name: "test-worker-web-3", Labels: map[string]string{"role": "worker", "pool": "web"}},
+	}
+	nodes := map[string]kubeNode{
+		"test-worker-web-1": {Name: "test-worker-web-1", Labels: map[string]string{HCloudNodeGroupLabel: "web"}},
+		"test-worker-web-2": {Name: "test-worker-web-2", Labels: map[string]string{}},
+		"test-batch-abc":    {Name: "test-batch-abc", Labels: map[string]string{HCloudNodeGroupLabel: "test-batch"}},
+	}
+
+	commands, counts := nodeGroupLabelCommands(workers, nodes)
+
+	wantCommands := []string{"sudo k3s kubectl label node 'test-worker-web-2' 'hcloud/node-group=web' --overwrite"}
+	if !reflect.DeepEqual(commands, wantCommands) {
+		t.Errorf("commands = %v, want %v", commands, wantCommands)
+	}
+	wantCounts := map[string]int{"web": 2, "test-batch": 1}
+	if !reflect.DeepEqual(counts, wantCounts) {
+		t.Errorf("counts = %v, want %v", counts, wantCounts)
+	}
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/cloudinit"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/internal/util"
+)
+
+// volumeName returns the name of a volume of a node
+func volumeName(nodeName, volume string) string {
+	return nodeName + "-" + volume
+}
+
+// ensureVolumes returns the volumes of a worker node, creating those that do not exist
+// yet. Existing volumes are reused, so a recreated node keeps its data. The volumes
+// carry the labels of their server and their name, so the deleter can apply their
+// delete policy.
+func (c *CreatorEnhanced) ensureVolumes(nodeName string, pool config.WorkerNodePool, labels map[string]string) ([]*hcloud.Volume, error) {
+	volumes := make([]*hcloud.Volume, 0, len(pool.Volumes))
+	for _, spec := range pool.Volumes {
+		name := volumeName(nodeName, spec.Name)
+		volume, err := c.HetznerClient.GetVolume(c.ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if volume != nil {
+			if volume.Server != nil {
+				return nil, fmt.Errorf("volume %s is already attached to server %d", name, volume.Server.ID)
+			}
+			if volume.Location != nil && volume.Location.Name != pool.Location {
+				return nil, fmt.Errorf("volume %s is in %s, but the node is created in %s", name, volume.Location.Name, pool.Location)
+			}
+			util.LogInfo(fmt.Sprintf("Reusing volume %s", name), "volume")
+			volumes = append(volumes, volume)
+			continue
+		}
+
+		volumeLabels := maps.Clone(labels)
+		volumeLabels["volume"] = spec.Name
+		volume, err = c.HetznerClient.CreateVolume(c.ctx, hcloud.VolumeCreateOpts{
+			Name:     name,
+			Size:     spec.Size,
+			Location: &hcloud.Location{Name: pool.Location},
+			Labels:   volumeLabels,
+		})
+		if err != nil {
+			return nil, err
+		}
+		util.LogSuccess(fmt.Sprintf("Volume created: %s (%d GB)", name, spec.Size), "volume")
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// poolVolumes returns the cloud-init volume settings of a static worker pool, or nil
+// if the pool has no volumes. hek3ster creates the volumes of static pools before
+// their nodes, so the nodes never create volumes themselves.
+func poolVolumes(cfg *config.Main, pool config.WorkerNodePool, labels map[string]string) *cloudinit.Volumes {
+	if len(pool.Volumes) == 0 {
+		return nil
+	}
+	volumes := &cloudinit.Volumes{
+		HetznerEndpoint: cfg.HetznerEndpoint,
+		Labels:          labels,
+	}
+	for _, spec := range pool.Volumes {
+		volumes.Mounts = append(volumes.Mounts, cloudinit.VolumeMount{
+			Name:      spec.Name,
+			Size:      spec.Size,
+			Format:    spec.Format,
+			MountPath: spec.MountPath,
+		})
+	}
+	return volumes
+}
+
+// keepVolume reports whether a volume survives the deletion of its server
+// The volume is found through the pool and volume labels. Volumes of pools or
+// volumes that are no longer configured are deleted.
+func keepVolume(cfg *config.Main, volume *hcloud.Volume) bool {
+	for _, pool := range cfg.WorkerNodePools {
+		if pool.Name == nil || *pool.Name != volume.Labels["pool"] {
+			continue
+		}
+		for _, spec := range pool.Volumes {
+			if spec.Name == volume.Labels["volume"] {
+				return spec.KeepOnDelete()
+			}
+		}
+	}
+	return false
+}
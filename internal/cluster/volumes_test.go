@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/internal/config"
+	"github.com/magenx/hek3ster/pkg/hetzner/hetznertest"
+)
+
+func TestCreatorVolumes(t *testing.T) {
+	ctx := context.Background()
+	fake := hetznertest.NewFake()
+	c := &CreatorEnhanced{Config: &config.Main{ClusterName: "test"}, HetznerClient: fake, ctx: ctx}
+	pool := config.WorkerNodePool{Location: "fsn1", Volumes: []config.Volume{
+		{Name: "data", Size: 50, Format: "ext4", MountPath: "/var/lib/data"},
+		{Name: "logs", Size: 10, Format: "xfs", MountPath: "/var/log/app"},
+	}}
+	labels := map[string]string{"cluster": "test", "role": "worker", "pool": "db", "managed": "hek3ster"}
+
+	volumes, err := c.ensureVolumes("test-worker-db-1", pool, labels)
+	if err != nil {
+		t.Fatalf("ensureVolumes() error = %v", err)
+	}
+	if len(volumes) != 2 || volumes[0].Name != "test-worker-db-1-data" || volumes[1].Name != "test-worker-db-1-logs" {
+		t.Fatalf("ensureVolumes() = %v, want the data and logs volumes", volumes)
+	}
+	if volumes[0].Size != 50 || volumes[0].Labels["volume"] != "data" || volumes[0].Labels["pool"] != "db" {
+		t.Errorf("data volume = %+v, want 50 GB with the pool and volume labels", volumes[0])
+	}
+	if _, ok := labels["volume"]; ok {
+		t.Error("ensureVolumes() modified the server labels")
+	}
+
+	// Recreating the node reuses its volumes
+	server, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-worker-db-1", Location: &hcloud.Location{Name: "fsn1"}, Volumes: volumes})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if _, err := c.ensureVolumes("test-worker-db-1", pool, labels); err == nil || !strings.Contains(err.Error(), "already attached") {
+		t.Errorf("ensureVolumes() of attached volumes error = %v, want already attached", err)
+	}
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	reused, err := c.ensureVolumes("test-worker-db-1", pool, labels)
+	if err != nil || len(reused) != 2 || reused[0].ID != volumes[0].ID {
+		t.Errorf("ensureVolumes() after recreation = %v, %v, want the existing volumes", reused, err)
+	}
+	pool.Location = "nbg1"
+	if _, err := c.ensureVolumes("test-worker-db-1", pool, labels); err == nil || !strings.Contains(err.Error(), "is in fsn1") {
+		t.Errorf("ensureVolumes() in another location error = %v, want a location error", err)
+	}
+	if got := len(fake.Volumes()); got != 2 {
+		t.Errorf("volumes = %d, want 2", got)
+	}
+}
+
+func TestKeepVolume(t *testing.T) {
+	web := "web"
+	cfg := &config.Main{ClusterName: "test"}
+	cfg.WorkerNodePools = []config.WorkerNodePool{{
+		NodePool: config.NodePool{Name: &web},
+		Volumes: []config.Volume{
+			{Name: "data", DeletePolicy: config.VolumeDeletePolicyKeep},
+			{Name: "cache"},
+		},
+	}}
+
+	tests := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"pool": "web", "volume": "data"}, true},
+		{map[string]string{"pool": "web", "volume": "cache"}, false},
+		{map[string]string{"pool": "web", "volume": "removed"}, false},
+		{map[string]string{"pool": "removed", "volume": "data"}, false},
+	}
+	for _, tt := range tests {
+		if got := keepVolume(cfg, &hcloud.Volume{Labels: tt.labels}); got != tt.want {
+			t.Errorf("keepVolume(%v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+}
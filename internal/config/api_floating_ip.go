@@ -0,0 +1,22 @@
+package config
+
+// APIFloatingIP represents a floating IP used as the Kubernetes API endpoint
+// It is an alternative to the API load balancer: a failover agent on every master
+// moves the IP to a healthy master through the Hetzner API.
+type APIFloatingIP struct {
+	Enabled       bool   `yaml:"enabled,omitempty"`
+	HomeLocation  string `yaml:"home_location,omitempty"`  // Defaults to the first master location
+	CheckInterval int    `yaml:"check_interval,omitempty"` // Seconds between health checks of the failover agent
+}
+
+// SetDefaults sets default values for the API floating IP
+func (a *APIFloatingIP) SetDefaults() {
+	if a.CheckInterval == 0 {
+		a.CheckInterval = 5
+	}
+}
+
+// Name returns the name of the API floating IP of a cluster
+func (a *APIFloatingIP) Name(clusterName string) string {
+	return clusterName + "-api-ip"
+}
@@ -228,22 +228,42 @@ func (e *EmbeddedEtcd) GenerateEtcdArgs() string {
 		args = append(args, fmt.Sprintf("--etcd-snapshot-schedule-cron='%s'", e.SnapshotScheduleCron))
 	}
 
-	if e.IsS3Configured() {
-		args = append(args, "--etcd-s3")
-		args = append(args, fmt.Sprintf("--etcd-s3-endpoint=%s", e.S3Endpoint))
-		args = append(args, fmt.Sprintf("--etcd-s3-region=%s", e.S3Region))
-		args = append(args, fmt.Sprintf("--etcd-s3-bucket=%s", e.S3Bucket))
-		args = append(args, fmt.Sprintf("--etcd-s3-access-key=%s", e.S3AccessKey))
-		args = append(args, fmt.Sprintf("--etcd-s3-secret-key=%s", e.S3SecretKey))
+	args = append(args, e.s3Args()...)
 
-		if e.S3ForcePathStyle {
-			args = append(args, "--etcd-s3-force-path-style")
-		}
+	return strings.Join(args, " ")
+}
 
-		if e.S3Folder != "" {
-			args = append(args, fmt.Sprintf("--etcd-s3-folder=%s", e.S3Folder))
-		}
+// GenerateSnapshotS3Args generates the S3 arguments for `k3s etcd-snapshot`
+// It returns an empty string if S3 is not configured.
+func (e *EmbeddedEtcd) GenerateSnapshotS3Args() string {
+	if e == nil {
+		return ""
 	}
+	return strings.Join(e.s3Args(), " ")
+}
 
-	return strings.Join(args, " ")
+// s3Args returns the etcd S3 arguments shared by k3s server and k3s etcd-snapshot
+func (e *EmbeddedEtcd) s3Args() []string {
+	if !e.IsS3Configured() {
+		return nil
+	}
+
+	args := []string{
+		"--etcd-s3",
+		fmt.Sprintf("--etcd-s3-endpoint=%s", e.S3Endpoint),
+		fmt.Sprintf("--etcd-s3-region=%s", e.S3Region),
+		fmt.Sprintf("--etcd-s3-bucket=%s", e.S3Bucket),
+		fmt.Sprintf("--etcd-s3-access-key=%s", e.S3AccessKey),
+		fmt.Sprintf("--etcd-s3-secret-key=%s", e.S3SecretKey),
+	}
+
+	if e.S3ForcePathStyle {
+		args = append(args, "--etcd-s3-force-path-style")
+	}
+
+	if e.S3Folder != "" {
+		args = append(args, fmt.Sprintf("--etcd-s3-folder=%s", e.S3Folder))
+	}
+
+	return args
 }
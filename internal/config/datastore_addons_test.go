@@ -255,3 +255,30 @@ func TestDatastore_SetDefaults_CallsEmbeddedEtcdSetDefaults(t *testing.T) {
 		t.Errorf("EmbeddedEtcd.SnapshotScheduleCron = %s, want '0 * * * *'", ds.EmbeddedEtcd.SnapshotScheduleCron)
 	}
 }
+
+func TestEmbeddedEtcd_GenerateSnapshotS3Args(t *testing.T) {
+	var nilEtcd *EmbeddedEtcd
+	if got := nilEtcd.GenerateSnapshotS3Args(); got != "" {
+		t.Errorf("GenerateSnapshotS3Args() on nil = %q, want empty", got)
+	}
+
+	etcd := &EmbeddedEtcd{SnapshotRetention: 24, SnapshotScheduleCron: "0 * * * *"}
+	if got := etcd.GenerateSnapshotS3Args(); got != "" {
+		t.Errorf("GenerateSnapshotS3Args() without S3 = %q, want empty", got)
+	}
+
+	etcd.S3Enabled = true
+	etcd.S3Endpoint = "s3.example.com"
+	etcd.S3Region = "eu-central"
+	etcd.S3Bucket = "backups"
+	etcd.S3AccessKey = "access"
+	etcd.S3SecretKey = "secret"
+	got := etcd.GenerateSnapshotS3Args()
+	want := "--etcd-s3 --etcd-s3-endpoint=s3.example.com --etcd-s3-region=eu-central --etcd-s3-bucket=backups --etcd-s3-access-key=access --etcd-s3-secret-key=secret"
+	if got != want {
+		t.Errorf("GenerateSnapshotS3Args() = %q, want %q", got, want)
+	}
+	if strings.Contains(got, "snapshot-retention") {
+		t.Error("GenerateSnapshotS3Args() must not include snapshot schedule arguments")
+	}
+}
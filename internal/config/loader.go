@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 
@@ -58,6 +59,67 @@ func (l *Loader) load() error {
 	return nil
 }
 
+// SetValue sets a top-level setting in the configuration file and reloads it
+// The file is edited as a YAML document, so comments and the order of the other
+// settings are kept. A setting that is not in the file yet is appended.
+func (l *Loader) SetValue(key, value string) error {
+	info, err := os.Stat(l.ConfigFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	data, err := os.ReadFile(l.ConfigFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse configuration file: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("configuration file %s is not a YAML mapping", l.ConfigFilePath)
+	}
+
+	root := doc.Content[0]
+	found := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != key {
+			continue
+		}
+		node := root.Content[i+1]
+		if node.Kind != yaml.ScalarNode {
+			node = &yaml.Node{Kind: yaml.ScalarNode}
+			root.Content[i+1] = node
+		}
+		node.Tag = "!!str"
+		node.Style = 0
+		node.Value = value
+		found = true
+		break
+	}
+	if !found {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+		)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode configuration file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode configuration file: %w", err)
+	}
+
+	if err := os.WriteFile(l.ConfigFilePath, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write configuration file: %w", err)
+	}
+	return l.load()
+}
+
 // Validate validates the configuration
 func (l *Loader) Validate(action string) error {
 	if l.Settings == nil {
@@ -77,8 +139,20 @@ func (l *Loader) Validate(action string) error {
 		l.validateForDelete()
 	case "upgrade":
 		l.validateForUpgrade()
+	case "rollback":
+		l.validateForRollback()
 	case "run":
 		l.validateForRun()
+	case "status":
+		l.validateForStatus()
+	case "ssh":
+		l.validateForSSH()
+	case "apply":
+		l.validateForApply()
+	case "etcd":
+		l.validateForEtcd()
+	case "image":
+		l.validateForImage()
 	}
 
 	if len(l.Errors) > 0 {
@@ -126,12 +200,50 @@ func (l *Loader) validateForUpgrade() {
 	// Version format validation is performed in internal/config/validator.go
 }
 
+// validateForRollback validates configuration for upgrade rollback
+func (l *Loader) validateForRollback() {
+	if l.NewK3sVersion != "" {
+		l.Errors = append(l.Errors, "new k3s version cannot be combined with rollback, the cluster is rolled back to the recorded pre-upgrade version")
+	}
+}
+
 // validateForRun validates configuration for run action
 func (l *Loader) validateForRun() {
 	// Basic validation is sufficient for run operations
 	// Command or script validation is handled by the CLI layer
 }
 
+// validateForStatus validates configuration for status action
+func (l *Loader) validateForStatus() {
+	// Basic validation is sufficient for status operations
+	// Status is read-only and only needs cluster name and Hetzner token
+}
+
+// validateForSSH validates configuration for ssh action
+func (l *Loader) validateForSSH() {
+	// Basic validation is sufficient for ssh operations
+	// The target node is resolved from the cluster servers
+}
+
+// validateForApply validates configuration for apply action
+func (l *Loader) validateForApply() {
+	// Apply changes worker pools, so the comprehensive validation
+	// in internal/config/validator.go is run by the CLI layer as for create
+}
+
+// validateForEtcd validates configuration for etcd snapshot actions
+func (l *Loader) validateForEtcd() {
+	if l.Settings.Datastore.Mode != "etcd" {
+		l.Errors = append(l.Errors, fmt.Sprintf("etcd commands require datastore mode etcd, got %q", l.Settings.Datastore.Mode))
+	}
+}
+
+// validateForImage validates configuration for node image builds
+func (l *Loader) validateForImage() {
+	// Basic validation is sufficient for image builds
+	// The build server settings are checked by the image builder
+}
+
 // GetErrors returns validation errors
 func (l *Loader) GetErrors() []string {
 	return l.Errors
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -192,3 +194,85 @@ worker_node_pools:
 		t.Error("Expected WorkerPool.IncludeClusterNameAsPrefix to be true after SetDefaults")
 	}
 }
+
+func TestValidateForRollback(t *testing.T) {
+	loader := &Loader{Settings: &Main{}, Errors: []string{}}
+	loader.validateForRollback()
+	if loader.HasErrors() {
+		t.Errorf("unexpected errors for rollback: %v", loader.GetErrors())
+	}
+
+	loader = &Loader{Settings: &Main{}, NewK3sVersion: "v1.32.1+k3s1", Errors: []string{}}
+	loader.validateForRollback()
+	if !loader.HasErrors() {
+		t.Error("expected an error when a new k3s version is combined with rollback")
+	}
+}
+
+// TestValidateForEtcd tests that etcd commands require the embedded etcd datastore
+func TestValidateForEtcd(t *testing.T) {
+	loader := &Loader{Settings: &Main{Datastore: Datastore{Mode: "etcd"}}, Errors: []string{}}
+	loader.validateForEtcd()
+	if loader.HasErrors() {
+		t.Errorf("unexpected errors for etcd datastore: %v", loader.GetErrors())
+	}
+
+	loader = &Loader{Settings: &Main{Datastore: Datastore{Mode: "external"}}, Errors: []string{}}
+	loader.validateForEtcd()
+	if !loader.HasErrors() {
+		t.Error("expected an error for an external datastore")
+	}
+}
+
+// TestSetValue tests that settings are written to the configuration file without losing comments
+func TestSetValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	content := `# Production cluster
+hetzner_token: test-token
+cluster_name: test-cluster # must be unique
+kubeconfig_path: ./kubeconfig
+k3s_version: v1.32.0+k3s1
+image: ubuntu-24.04 # base image
+masters_pool:
+  instance_type: cpx22
+  instance_count: 1
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	loader, err := NewLoader(path, "", false)
+	if err != nil {
+		t.Fatalf("NewLoader() error = %v", err)
+	}
+	if err := loader.SetValue("image", "123456"); err != nil {
+		t.Fatalf("SetValue(image) error = %v", err)
+	}
+	if err := loader.SetValue("autoscaling_image", "123456"); err != nil {
+		t.Fatalf("SetValue(autoscaling_image) error = %v", err)
+	}
+
+	if loader.Settings.Image != "123456" || loader.Settings.AutoscalingImage != "123456" {
+		t.Errorf("settings not reloaded: image = %q, autoscaling_image = %q", loader.Settings.Image, loader.Settings.AutoscalingImage)
+	}
+	if loader.Settings.MastersPool.InstanceType != "cpx22" {
+		t.Errorf("masters_pool.instance_type = %q, want cpx22", loader.Settings.MastersPool.InstanceType)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	for _, want := range []string{"# Production cluster", "# must be unique", `image: "123456" # base image`, `autoscaling_image: "123456"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config file does not contain %q:\n%s", want, data)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat config: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("config file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -9,6 +9,8 @@ import (
 // Main represents the main configuration structure
 type Main struct {
 	HetznerToken                       string           `yaml:"hetzner_token"`
+	HetznerEndpoint                    string           `yaml:"hetzner_endpoint,omitempty"`
+	LocationFallback                   bool             `yaml:"location_fallback,omitempty"`
 	ClusterName                        string           `yaml:"cluster_name"`
 	KubeconfigPath                     string           `yaml:"kubeconfig_path"`
 	K3sVersion                         string           `yaml:"k3s_version"`
@@ -39,7 +41,9 @@ type Main struct {
 	IncludeInstanceTypeInInstanceName  bool             `yaml:"include_instance_type_in_instance_name,omitempty"`
 	ProtectAgainstDeletion             bool             `yaml:"protect_against_deletion,omitempty"`
 	CreateLoadBalancerForKubernetesAPI bool             `yaml:"create_load_balancer_for_the_kubernetes_api,omitempty"`
+	APIFloatingIP                      APIFloatingIP    `yaml:"api_floating_ip,omitempty"`
 	K3sUpgradeConcurrency              int64            `yaml:"k3s_upgrade_concurrency,omitempty"`
+	UpgradeStrategy                    UpgradeStrategy  `yaml:"upgrade_strategy,omitempty"`
 	GrowRootPartitionAutomatically     bool             `yaml:"grow_root_partition_automatically,omitempty"`
 }
 
@@ -57,6 +61,9 @@ func (c *Main) SetDefaults() {
 	if c.HetznerToken == "" {
 		c.HetznerToken = os.Getenv("HCLOUD_TOKEN")
 	}
+	if c.HetznerEndpoint == "" {
+		c.HetznerEndpoint = os.Getenv("HCLOUD_ENDPOINT")
+	}
 	// Set default to true for protect against deletion
 	if !c.ProtectAgainstDeletion {
 		c.ProtectAgainstDeletion = true
@@ -71,6 +78,7 @@ func (c *Main) SetDefaults() {
 	c.LoadBalancer.SetDefaults()
 	c.DNSZone.SetDefaults()
 	c.SSLCertificate.SetDefaults()
+	c.APIFloatingIP.SetDefaults()
 
 	// Set defaults for master and worker node pools
 	c.MastersPool.SetDefaults()
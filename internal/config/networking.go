@@ -1,5 +1,11 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
 // Default version constants
 const (
 	// DefaultCiliumVersion is the default Cilium version to install
@@ -256,9 +262,17 @@ func (a *AllowedNetworks) SetDefaults() {
 // SSH represents SSH configuration
 type SSH struct {
 	Port           int    `yaml:"port,omitempty"`
+	User           string `yaml:"user,omitempty"` // Login user; a non-root user runs commands through sudo
 	UseAgent       bool   `yaml:"use_agent,omitempty"`
 	PrivateKeyPath string `yaml:"private_key_path,omitempty"`
 	PublicKeyPath  string `yaml:"public_key_path,omitempty"`
+	// UserCAPublicKeyPath is the public key of the SSH user CA the nodes trust.
+	// With a user CA, hek3ster logs in with a certificate. The public key is still uploaded
+	// so Hetzner sets no root password, but sshd ignores authorized keys.
+	UserCAPublicKeyPath string `yaml:"user_ca_public_key_path,omitempty"`
+	// CertificatePath is the certificate of the private key signed by the user CA.
+	// With use_agent, it may be left empty to use a certificate loaded into the agent.
+	CertificatePath string `yaml:"certificate_path,omitempty"`
 }
 
 // SetDefaults sets default values for SSH
@@ -266,6 +280,9 @@ func (s *SSH) SetDefaults() {
 	if s.Port == 0 {
 		s.Port = 22
 	}
+	if s.User == "" {
+		s.User = "root"
+	}
 	if s.PrivateKeyPath == "" {
 		s.PrivateKeyPath = "~/.ssh/id_rsa"
 	}
@@ -274,6 +291,55 @@ func (s *SSH) SetDefaults() {
 	}
 }
 
+// NonRootUser reports whether hek3ster logs in as a user other than root
+// Nodes then create the user, allow it passwordless sudo and refuse root logins.
+func (s *SSH) NonRootUser() bool {
+	return s.User != "" && s.User != "root"
+}
+
+// UsesCertificates reports whether nodes trust a user CA instead of the cluster key
+func (s *SSH) UsesCertificates() bool {
+	return s.UserCAPublicKeyPath != ""
+}
+
+// AuthorizedKey returns the public key the nodes authorize for the login user
+// With a user CA, certificates are trusted instead and no key is authorized.
+func (s *SSH) AuthorizedKey() (string, error) {
+	if s.UsesCertificates() {
+		return "", nil
+	}
+	return readKeyFile(s.PublicKeyPath)
+}
+
+// UserCAPublicKey returns the public key of the user CA, empty without a user CA
+func (s *SSH) UserCAPublicKey() (string, error) {
+	if !s.UsesCertificates() {
+		return "", nil
+	}
+	return readKeyFile(s.UserCAPublicKeyPath)
+}
+
+// readKeyFile reads a single-line key file such as id_ed25519.pub
+func readKeyFile(path string) (string, error) {
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ExpandedCertificatePath returns the expanded certificate path, empty if none is set
+func (s *SSH) ExpandedCertificatePath() (string, error) {
+	if s.CertificatePath == "" {
+		return "", nil
+	}
+	return ExpandPath(s.CertificatePath)
+}
+
 // ExpandedPrivateKeyPath returns the expanded private key path
 func (s *SSH) ExpandedPrivateKeyPath() (string, error) {
 	return ExpandPath(s.PrivateKeyPath)
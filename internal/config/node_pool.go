@@ -15,13 +15,87 @@ type NodePool struct {
 	AdditionalPackages             []string     `yaml:"additional_packages,omitempty"`
 	IncludeClusterNameAsPrefix     bool         `yaml:"include_cluster_name_as_prefix,omitempty"`
 	GrowRootPartitionAutomatically *bool        `yaml:"grow_root_partition_automatically,omitempty"`
+	PlacementGroup                 string       `yaml:"placement_group,omitempty"`
+	PrimaryIPs                     *PrimaryIPs  `yaml:"primary_ips,omitempty"`
 }
 
+// PrimaryIPs represents the primary IP configuration of a node pool
+// Every node gets a labelled primary IPv4 address that outlives the server, so a
+// recreated node comes back with the same public IP.
+type PrimaryIPs struct {
+	Enabled      bool   `yaml:"enabled,omitempty"`
+	DeletePolicy string `yaml:"delete_policy,omitempty"`
+}
+
+const (
+	// PrimaryIPDeletePolicyDelete removes the primary IPs when the cluster is deleted
+	PrimaryIPDeletePolicyDelete = "delete"
+	// PrimaryIPDeletePolicyKeep keeps the primary IPs when the cluster is deleted
+	PrimaryIPDeletePolicyKeep = "keep"
+)
+
+// Volume represents a Hetzner volume attached to every node of a worker pool
+// The node formats the volume on first boot and mounts it at the mount path.
+type Volume struct {
+	Name         string `yaml:"name"`
+	Size         int    `yaml:"size"`
+	Format       string `yaml:"format,omitempty"`
+	MountPath    string `yaml:"mount_path"`
+	DeletePolicy string `yaml:"delete_policy,omitempty"`
+}
+
+const (
+	// VolumeFormatExt4 formats a volume with ext4
+	VolumeFormatExt4 = "ext4"
+	// VolumeFormatXFS formats a volume with XFS
+	VolumeFormatXFS = "xfs"
+
+	// VolumeDeletePolicyDelete removes the volumes when the cluster is deleted
+	VolumeDeletePolicyDelete = "delete"
+	// VolumeDeletePolicyKeep keeps the volumes when the cluster is deleted
+	VolumeDeletePolicyKeep = "keep"
+)
+
+const (
+	// MinVolumeSize is the smallest volume size in GB
+	MinVolumeSize = 10
+	// MaxVolumeSize is the largest volume size in GB
+	MaxVolumeSize = 10240
+	// MaxServerVolumes is the number of volumes that can be attached to a server
+	MaxServerVolumes = 16
+)
+
+// KeepOnDelete returns true if the volume survives cluster deletion
+func (v *Volume) KeepOnDelete() bool {
+	return v.DeletePolicy == VolumeDeletePolicyKeep
+}
+
+// PlacementGroupSpread places every server of a pool on a different physical host
+const PlacementGroupSpread = "spread"
+
+// MaxSpreadPlacementGroupServers is the number of servers a spread placement group can hold
+const MaxSpreadPlacementGroupServers = 10
+
 // AutoscalingEnabled returns true if autoscaling is enabled for this pool
 func (n *NodePool) AutoscalingEnabled() bool {
 	return n.Autoscaling != nil && n.Autoscaling.Enabled
 }
 
+// SpreadPlacement returns true if the servers of this pool are spread across physical hosts
+func (n *NodePool) SpreadPlacement() bool {
+	return n.PlacementGroup == PlacementGroupSpread
+}
+
+// PrimaryIPsEnabled returns true if the nodes of this pool use primary IPs
+func (n *NodePool) PrimaryIPsEnabled() bool {
+	return n.PrimaryIPs != nil && n.PrimaryIPs.Enabled
+}
+
+// KeepPrimaryIPs returns true if the primary IPs of this pool survive cluster deletion
+func (n *NodePool) KeepPrimaryIPs() bool {
+	return n.PrimaryIPsEnabled() && n.PrimaryIPs.DeletePolicy == PrimaryIPDeletePolicyKeep
+}
+
 // EffectiveGrowRootPartitionAutomatically returns the effective value for grow root partition
 func (n *NodePool) EffectiveGrowRootPartitionAutomatically(globalValue bool) bool {
 	if n.GrowRootPartitionAutomatically != nil {
@@ -52,7 +126,9 @@ func (m *MasterNodePool) SetDefaults() {
 // WorkerNodePool represents worker node pool configuration
 type WorkerNodePool struct {
 	NodePool `yaml:",inline"`
-	Location string `yaml:"location,omitempty"`
+	Location string       `yaml:"location,omitempty"`
+	Upgrade  *PoolUpgrade `yaml:"upgrade,omitempty"`
+	Volumes  []Volume     `yaml:"volumes,omitempty"`
 }
 
 // SetDefaults sets default values for worker pool
@@ -68,6 +144,11 @@ func (w *WorkerNodePool) SetDefaults() {
 	if !w.IncludeClusterNameAsPrefix {
 		w.IncludeClusterNameAsPrefix = true
 	}
+	for i := range w.Volumes {
+		if w.Volumes[i].Format == "" {
+			w.Volumes[i].Format = VolumeFormatExt4
+		}
+	}
 }
 
 // BuildNodePoolName builds the node pool name for this worker pool
@@ -84,6 +165,21 @@ func (w *WorkerNodePool) BuildNodePoolName(clusterName string) string {
 	return poolName
 }
 
+// PlacementGroupName returns the name of the placement group of the master pool
+func (m *MasterNodePool) PlacementGroupName(clusterName string) string {
+	return clusterName + "-masters"
+}
+
+// PlacementGroupName returns the name of the placement group of the worker pool
+// Placement group names are unique per project, so the cluster name is always included.
+func (w *WorkerNodePool) PlacementGroupName(clusterName string) string {
+	poolName := "default"
+	if w.Name != nil {
+		poolName = *w.Name
+	}
+	return clusterName + "-" + poolName
+}
+
 // Label represents a Kubernetes label
 type Label struct {
 	Key   string `yaml:"key"`
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// UpgradeStrategy represents how worker pools are upgraded
+// Pools are upgraded one after another. The canary pool, if set, goes first and the
+// upgrade pauses after it for the soak period and/or a manual confirmation.
+type UpgradeStrategy struct {
+	CanaryPool         string `yaml:"canary_pool,omitempty"`
+	CanarySoakPeriod   string `yaml:"canary_soak_period,omitempty"`
+	ConfirmAfterCanary bool   `yaml:"confirm_after_canary,omitempty"`
+}
+
+// SoakPeriod returns the parsed canary soak period, or zero if none is set
+func (u *UpgradeStrategy) SoakPeriod() (time.Duration, error) {
+	if u.CanarySoakPeriod == "" {
+		return 0, nil
+	}
+	period, err := time.ParseDuration(u.CanarySoakPeriod)
+	if err != nil {
+		return 0, fmt.Errorf("invalid canary_soak_period %q: %w", u.CanarySoakPeriod, err)
+	}
+	return period, nil
+}
+
+// PoolUpgrade represents per-pool upgrade settings
+type PoolUpgrade struct {
+	Concurrency int64         `yaml:"concurrency,omitempty"`
+	Drain       *DrainOptions `yaml:"drain,omitempty"`
+}
+
+// DrainOptions represents how nodes are drained before they are upgraded
+// Nodes are only cordoned when no drain options are set.
+type DrainOptions struct {
+	Timeout            string `yaml:"timeout,omitempty"`
+	DeleteEmptydirData bool   `yaml:"delete_emptydir_data,omitempty"`
+	Force              bool   `yaml:"force,omitempty"`
+	DisableEviction    bool   `yaml:"disable_eviction,omitempty"`
+}
+
+// EffectiveUpgradeConcurrency returns the number of nodes of the pool upgraded at a time
+// It falls back to the global k3s_upgrade_concurrency setting.
+func (w *WorkerNodePool) EffectiveUpgradeConcurrency(globalValue int64) int64 {
+	if w.Upgrade != nil && w.Upgrade.Concurrency > 0 {
+		return w.Upgrade.Concurrency
+	}
+	if globalValue > 0 {
+		return globalValue
+	}
+	return 1
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpgradeStrategySoakPeriod(t *testing.T) {
+	strategy := UpgradeStrategy{}
+	if period, err := strategy.SoakPeriod(); err != nil || period != 0 {
+		t.Errorf("SoakPeriod() = %v, %v; want 0, nil", period, err)
+	}
+
+	strategy.CanarySoakPeriod = "15m"
+	if period, err := strategy.SoakPeriod(); err != nil || period != 15*time.Minute {
+		t.Errorf("SoakPeriod() = %v, %v; want 15m, nil", period, err)
+	}
+
+	strategy.CanarySoakPeriod = "fifteen minutes"
+	if _, err := strategy.SoakPeriod(); err == nil {
+		t.Error("expected error for invalid soak period")
+	}
+}
+
+func TestEffectiveUpgradeConcurrency(t *testing.T) {
+	tests := []struct {
+		name   string
+		pool   WorkerNodePool
+		global int64
+		want   int64
+	}{
+		{name: "global setting", pool: WorkerNodePool{}, global: 3, want: 3},
+		{name: "pool override", pool: WorkerNodePool{Upgrade: &PoolUpgrade{Concurrency: 5}}, global: 3, want: 5},
+		{name: "unset pool concurrency", pool: WorkerNodePool{Upgrade: &PoolUpgrade{}}, global: 2, want: 2},
+		{name: "no setting", pool: WorkerNodePool{}, global: 0, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pool.EffectiveUpgradeConcurrency(tt.global); got != tt.want {
+				t.Errorf("EffectiveUpgradeConcurrency() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUpgradeStrategy(t *testing.T) {
+	webPool := "web"
+	tests := []struct {
+		name        string
+		strategy    UpgradeStrategy
+		upgrade     *PoolUpgrade
+		wantErr     string
+		wantWarning string
+	}{
+		{
+			name:     "valid canary",
+			strategy: UpgradeStrategy{CanaryPool: "web", CanarySoakPeriod: "10m", ConfirmAfterCanary: true},
+			upgrade:  &PoolUpgrade{Concurrency: 2, Drain: &DrainOptions{Timeout: "5m"}},
+		},
+		{
+			name:     "unknown canary pool",
+			strategy: UpgradeStrategy{CanaryPool: "db"},
+			wantErr:  "canary_pool 'db' not found",
+		},
+		{
+			name:     "invalid soak period",
+			strategy: UpgradeStrategy{CanaryPool: "web", CanarySoakPeriod: "soon"},
+			wantErr:  "invalid canary_soak_period",
+		},
+		{
+			name:        "soak period without canary",
+			strategy:    UpgradeStrategy{CanarySoakPeriod: "10m"},
+			wantWarning: "no effect without canary_pool",
+		},
+		{
+			name:    "negative pool concurrency",
+			upgrade: &PoolUpgrade{Concurrency: -1},
+			wantErr: "upgrade concurrency cannot be negative",
+		},
+		{
+			name:    "invalid drain timeout",
+			upgrade: &PoolUpgrade{Drain: &DrainOptions{Timeout: "later"}},
+			wantErr: "invalid upgrade drain timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Main{
+				UpgradeStrategy: tt.strategy,
+				WorkerNodePools: []WorkerNodePool{
+					{NodePool: NodePool{Name: &webPool}, Upgrade: tt.upgrade},
+				},
+			}
+			validator := NewValidator(config)
+			validator.validateUpgradeStrategy()
+
+			errors := strings.Join(validator.GetErrors(), "\n")
+			if tt.wantErr == "" && errors != "" {
+				t.Errorf("unexpected errors: %s", errors)
+			}
+			if tt.wantErr != "" && !strings.Contains(errors, tt.wantErr) {
+				t.Errorf("errors = %q, want one containing %q", errors, tt.wantErr)
+			}
+			warnings := strings.Join(validator.GetWarnings(), "\n")
+			if tt.wantWarning != "" && !strings.Contains(warnings, tt.wantWarning) {
+				t.Errorf("warnings = %q, want one containing %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
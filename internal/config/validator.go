@@ -6,6 +6,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // Validator provides configuration validation
@@ -35,8 +36,10 @@ func (v *Validator) Validate() error {
 	v.validateWorkerPools()
 	v.validateDatastore()
 	v.validateLoadBalancer()
+	v.validateAPIFloatingIP()
 	v.validateDNSZone()
 	v.validateSSLCertificate()
+	v.validateUpgradeStrategy()
 	v.validateExternalTools()
 
 	if len(v.errors) > 0 {
@@ -88,6 +91,7 @@ func (v *Validator) validateK3sVersion() {
 
 // validateSSHKeys validates SSH key paths
 func (v *Validator) validateSSHKeys() {
+	// The public key is uploaded to Hetzner even with a user CA, see ensureSSHKey
 	if v.config.Networking.SSH.PublicKeyPath == "" {
 		v.errors = append(v.errors, "SSH public_key_path is required")
 	} else {
@@ -101,25 +105,68 @@ func (v *Validator) validateSSHKeys() {
 				fmt.Sprintf("SSH public key not found: %s", v.config.Networking.SSH.PublicKeyPath))
 		}
 	}
+	if v.config.Networking.SSH.UsesCertificates() {
+		v.validateSSHCertificates()
+	}
 
-	if v.config.Networking.SSH.PrivateKeyPath == "" {
-		v.errors = append(v.errors, "SSH private_key_path is required")
-	} else {
-		// Expand path to handle ~ and other special characters
-		expandedPath, err := v.config.Networking.SSH.ExpandedPrivateKeyPath()
-		if err != nil {
-			v.errors = append(v.errors,
-				fmt.Sprintf("SSH private key path expansion failed: %s", err))
-		} else if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
-			v.errors = append(v.errors,
-				fmt.Sprintf("SSH private key not found: %s", v.config.Networking.SSH.PrivateKeyPath))
-		}
+	// A certificate from the agent needs no private key file
+	agentCertificate := v.config.Networking.SSH.UsesCertificates() && v.config.Networking.SSH.CertificatePath == ""
+	if !agentCertificate {
+		v.validateSSHPrivateKey()
 	}
 
 	// Validate SSH port
 	if v.config.Networking.SSH.Port < 1 || v.config.Networking.SSH.Port > 65535 {
 		v.errors = append(v.errors, "SSH port must be between 1 and 65535")
 	}
+
+	// Validate SSH user, which is created on the nodes
+	validUser := regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+	if user := v.config.Networking.SSH.User; user != "" && !validUser.MatchString(user) {
+		v.errors = append(v.errors,
+			fmt.Sprintf("SSH user %q is not a valid Linux user name", user))
+	}
+}
+
+// validateSSHPrivateKey validates the private key path
+func (v *Validator) validateSSHPrivateKey() {
+	if v.config.Networking.SSH.PrivateKeyPath == "" {
+		v.errors = append(v.errors, "SSH private_key_path is required")
+		return
+	}
+	// Expand path to handle ~ and other special characters
+	expandedPath, err := v.config.Networking.SSH.ExpandedPrivateKeyPath()
+	if err != nil {
+		v.errors = append(v.errors,
+			fmt.Sprintf("SSH private key path expansion failed: %s", err))
+	} else if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
+		v.errors = append(v.errors,
+			fmt.Sprintf("SSH private key not found: %s", v.config.Networking.SSH.PrivateKeyPath))
+	}
+}
+
+// validateSSHCertificates validates the user CA and certificate settings
+func (v *Validator) validateSSHCertificates() {
+	sshConfig := v.config.Networking.SSH
+	if _, err := sshConfig.UserCAPublicKey(); err != nil {
+		v.errors = append(v.errors,
+			fmt.Sprintf("SSH user CA public key %s: %s", sshConfig.UserCAPublicKeyPath, err))
+	}
+
+	if sshConfig.CertificatePath == "" {
+		if !sshConfig.UseAgent {
+			v.errors = append(v.errors, "SSH certificate_path is required with user_ca_public_key_path unless use_agent is enabled")
+		}
+		return
+	}
+	expandedPath, err := sshConfig.ExpandedCertificatePath()
+	if err != nil {
+		v.errors = append(v.errors,
+			fmt.Sprintf("SSH certificate path expansion failed: %s", err))
+	} else if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
+		v.errors = append(v.errors,
+			fmt.Sprintf("SSH certificate not found: %s", sshConfig.CertificatePath))
+	}
 }
 
 // validateNetworking validates network configuration
@@ -183,6 +230,95 @@ func (v *Validator) validateMasterPool() {
 	if len(v.config.MastersPool.Locations) == 0 {
 		v.errors = append(v.errors, "at least one master location is required")
 	}
+
+	v.validatePlacementGroup("master pool", v.config.MastersPool.NodePool, v.config.MastersPool.InstanceCount)
+	v.validatePrimaryIPs("master pool", v.config.MastersPool.NodePool)
+}
+
+// validatePrimaryIPs validates the primary IP settings of a pool
+func (v *Validator) validatePrimaryIPs(pool string, nodePool NodePool) {
+	if nodePool.PrimaryIPs == nil {
+		return
+	}
+
+	switch nodePool.PrimaryIPs.DeletePolicy {
+	case "", PrimaryIPDeletePolicyDelete, PrimaryIPDeletePolicyKeep:
+	default:
+		v.errors = append(v.errors, fmt.Sprintf("%s: primary_ips delete_policy must be %q or %q",
+			pool, PrimaryIPDeletePolicyDelete, PrimaryIPDeletePolicyKeep))
+	}
+
+	if !nodePool.PrimaryIPs.Enabled {
+		return
+	}
+	if nodePool.AutoscalingEnabled() {
+		v.errors = append(v.errors, fmt.Sprintf("%s: primary_ips cannot be used with autoscaling", pool))
+	}
+	privateNetwork := v.config.Networking.PrivateNetwork
+	if privateNetwork.Enabled && privateNetwork.NATGateway != nil && privateNetwork.NATGateway.Enabled {
+		v.errors = append(v.errors, fmt.Sprintf("%s: primary_ips cannot be used with a NAT gateway, nodes have no public IPs", pool))
+	}
+	if ipv4 := v.config.Networking.PublicNetwork.IPv4; ipv4 != nil && !ipv4.Enabled {
+		v.errors = append(v.errors, fmt.Sprintf("%s: primary_ips require public IPv4 to be enabled", pool))
+	}
+}
+
+// validateVolumes validates the volumes attached to the nodes of a worker pool
+func (v *Validator) validateVolumes(pool string, volumes []Volume) {
+	if len(volumes) > MaxServerVolumes {
+		v.errors = append(v.errors, fmt.Sprintf("%s: at most %d volumes can be attached to a server", pool, MaxServerVolumes))
+	}
+
+	validName := regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	names := make(map[string]bool)
+	mountPaths := make(map[string]bool)
+	for _, volume := range volumes {
+		if !validName.MatchString(volume.Name) {
+			v.errors = append(v.errors, fmt.Sprintf("%s: volume name %q must contain only lowercase letters, numbers, and hyphens", pool, volume.Name))
+		} else if names[volume.Name] {
+			v.errors = append(v.errors, fmt.Sprintf("%s: duplicate volume name: %s", pool, volume.Name))
+		}
+		names[volume.Name] = true
+
+		if volume.Size < MinVolumeSize || volume.Size > MaxVolumeSize {
+			v.errors = append(v.errors, fmt.Sprintf("%s: volume %s size must be between %d and %d GB", pool, volume.Name, MinVolumeSize, MaxVolumeSize))
+		}
+
+		switch volume.Format {
+		case "", VolumeFormatExt4, VolumeFormatXFS:
+		default:
+			v.errors = append(v.errors, fmt.Sprintf("%s: volume %s format must be %q or %q", pool, volume.Name, VolumeFormatExt4, VolumeFormatXFS))
+		}
+
+		switch {
+		case !strings.HasPrefix(volume.MountPath, "/") || volume.MountPath == "/" || strings.ContainsAny(volume.MountPath, " \t\n"):
+			v.errors = append(v.errors, fmt.Sprintf("%s: volume %s mount_path must be an absolute path other than /", pool, volume.Name))
+		case mountPaths[volume.MountPath]:
+			v.errors = append(v.errors, fmt.Sprintf("%s: duplicate volume mount_path: %s", pool, volume.MountPath))
+		}
+		mountPaths[volume.MountPath] = true
+
+		switch volume.DeletePolicy {
+		case "", VolumeDeletePolicyDelete, VolumeDeletePolicyKeep:
+		default:
+			v.errors = append(v.errors, fmt.Sprintf("%s: volume %s delete_policy must be %q or %q",
+				pool, volume.Name, VolumeDeletePolicyDelete, VolumeDeletePolicyKeep))
+		}
+	}
+}
+
+// validatePlacementGroup validates the placement group of a pool with up to maxServers servers
+func (v *Validator) validatePlacementGroup(pool string, nodePool NodePool, maxServers int) {
+	switch nodePool.PlacementGroup {
+	case "":
+	case PlacementGroupSpread:
+		if maxServers > MaxSpreadPlacementGroupServers {
+			v.errors = append(v.errors, fmt.Sprintf("%s: a spread placement group holds at most %d servers, but the pool can have %d",
+				pool, MaxSpreadPlacementGroupServers, maxServers))
+		}
+	default:
+		v.errors = append(v.errors, fmt.Sprintf("%s: placement_group must be %q", pool, PlacementGroupSpread))
+	}
 }
 
 // validateWorkerPools validates worker node pool configurations
@@ -202,6 +338,10 @@ func (v *Validator) validateWorkerPools() {
 				v.errors = append(v.errors, fmt.Sprintf("duplicate worker pool name: %s", *pool.Name))
 			}
 			poolNames[*pool.Name] = true
+			// The placement group of the pool would be the one of the masters
+			if *pool.Name == "masters" {
+				v.errors = append(v.errors, "worker pool masters: the name masters is reserved for the master pool")
+			}
 		}
 
 		if pool.InstanceType == "" {
@@ -240,6 +380,18 @@ func (v *Validator) validateWorkerPools() {
 			}
 		}
 
+		maxServers := pool.InstanceCount
+		if pool.AutoscalingEnabled() {
+			maxServers = pool.Autoscaling.MaxInstances
+		}
+		poolLabel := "worker pool unknown"
+		if pool.Name != nil {
+			poolLabel = "worker pool " + *pool.Name
+		}
+		v.validatePlacementGroup(poolLabel, pool.NodePool, maxServers)
+		v.validatePrimaryIPs(poolLabel, pool.NodePool)
+		v.validateVolumes(poolLabel, pool.Volumes)
+
 		if pool.Location == "" {
 			poolName := "unknown"
 			if pool.Name != nil {
@@ -250,6 +402,53 @@ func (v *Validator) validateWorkerPools() {
 	}
 }
 
+// validateUpgradeStrategy validates the upgrade strategy and per-pool upgrade settings
+func (v *Validator) validateUpgradeStrategy() {
+	strategy := v.config.UpgradeStrategy
+
+	if v.config.K3sUpgradeConcurrency < 0 {
+		v.errors = append(v.errors, "k3s_upgrade_concurrency cannot be negative")
+	}
+
+	if strategy.CanaryPool != "" {
+		found := false
+		for _, pool := range v.config.WorkerNodePools {
+			if pool.Name != nil && *pool.Name == strategy.CanaryPool {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.errors = append(v.errors, fmt.Sprintf("upgrade_strategy: canary_pool '%s' not found in worker_node_pools", strategy.CanaryPool))
+		}
+	} else if strategy.CanarySoakPeriod != "" || strategy.ConfirmAfterCanary {
+		v.warnings = append(v.warnings, "upgrade_strategy: canary_soak_period and confirm_after_canary have no effect without canary_pool")
+	}
+
+	if _, err := strategy.SoakPeriod(); err != nil {
+		v.errors = append(v.errors, fmt.Sprintf("upgrade_strategy: %v", err))
+	}
+
+	for _, pool := range v.config.WorkerNodePools {
+		if pool.Upgrade == nil {
+			continue
+		}
+		poolName := "unknown"
+		if pool.Name != nil {
+			poolName = *pool.Name
+		}
+
+		if pool.Upgrade.Concurrency < 0 {
+			v.errors = append(v.errors, fmt.Sprintf("worker pool %s: upgrade concurrency cannot be negative", poolName))
+		}
+		if pool.Upgrade.Drain != nil && pool.Upgrade.Drain.Timeout != "" {
+			if _, err := time.ParseDuration(pool.Upgrade.Drain.Timeout); err != nil {
+				v.errors = append(v.errors, fmt.Sprintf("worker pool %s: invalid upgrade drain timeout %q", poolName, pool.Upgrade.Drain.Timeout))
+			}
+		}
+	}
+}
+
 // validateDatastore validates datastore configuration including S3 settings
 func (v *Validator) validateDatastore() {
 	// Validate datastore mode
@@ -454,6 +653,37 @@ func (v *Validator) validateDomain() {
 	}
 }
 
+// validateAPIFloatingIP validates the floating IP used as the Kubernetes API endpoint
+func (v *Validator) validateAPIFloatingIP() {
+	floatingIP := v.config.APIFloatingIP
+	if !floatingIP.Enabled {
+		return
+	}
+
+	if v.config.CreateLoadBalancerForKubernetesAPI {
+		v.errors = append(v.errors,
+			"api_floating_ip cannot be used together with create_load_balancer_for_the_kubernetes_api")
+	}
+
+	privateNetwork := v.config.Networking.PrivateNetwork
+	if privateNetwork.Enabled && privateNetwork.NATGateway != nil && privateNetwork.NATGateway.Enabled {
+		v.errors = append(v.errors,
+			"api_floating_ip requires masters with public IPs and cannot be used with a NAT gateway")
+	}
+	if ipv4 := v.config.Networking.PublicNetwork.IPv4; ipv4 != nil && !ipv4.Enabled {
+		v.errors = append(v.errors, "api_floating_ip requires public IPv4 to be enabled")
+	}
+
+	if floatingIP.CheckInterval < 1 {
+		v.errors = append(v.errors, "api_floating_ip.check_interval must be at least 1 second")
+	}
+
+	if v.config.MastersPool.InstanceCount == 1 {
+		v.warnings = append(v.warnings,
+			"api_floating_ip is enabled with a single master, there is no master to fail over to")
+	}
+}
+
 // validateDNSZone validates DNS zone configuration
 func (v *Validator) validateDNSZone() {
 	if !v.config.DNSZone.Enabled {
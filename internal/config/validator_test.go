@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -300,6 +301,109 @@ func TestValidateSSHKeys_TildeExpansion(t *testing.T) {
 	}
 }
 
+func TestValidateSSHKeys_User(t *testing.T) {
+	tests := []struct {
+		user    string
+		wantErr bool
+	}{
+		{user: "", wantErr: false},
+		{user: "root", wantErr: false},
+		{user: "hek3ster", wantErr: false},
+		{user: "ops_admin-1", wantErr: false},
+		{user: "Ops", wantErr: true},
+		{user: "1ops", wantErr: true},
+		{user: "ops;reboot", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			config := &Main{Networking: Networking{SSH: SSH{User: tt.user, Port: 22}}}
+			validator := NewValidator(config)
+			validator.validateSSHKeys()
+
+			hasErr := false
+			for _, err := range validator.GetErrors() {
+				if strings.Contains(err, "SSH user") {
+					hasErr = true
+				}
+			}
+			if hasErr != tt.wantErr {
+				t.Errorf("user %q: expected error %v, got errors %v", tt.user, tt.wantErr, validator.GetErrors())
+			}
+		})
+	}
+}
+
+func TestValidateSSHKeys_Certificates(t *testing.T) {
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "user_ca.pub")
+	certPath := filepath.Join(dir, "id_ed25519-cert.pub")
+	privPath := filepath.Join(dir, "id_ed25519")
+	pubPath := filepath.Join(dir, "id_ed25519.pub")
+	for _, path := range []string{caPath, certPath, privPath, pubPath} {
+		if err := createDummyFile(path); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		ssh     SSH
+		wantErr string
+	}{
+		{
+			name: "certificate file",
+			ssh:  SSH{UserCAPublicKeyPath: caPath, CertificatePath: certPath, PrivateKeyPath: privPath},
+		},
+		{
+			name: "agent certificate needs no private key",
+			ssh:  SSH{UserCAPublicKeyPath: caPath, UseAgent: true},
+		},
+		{
+			name:    "public key still required",
+			ssh:     SSH{UserCAPublicKeyPath: caPath, CertificatePath: certPath, PrivateKeyPath: privPath, PublicKeyPath: filepath.Join(dir, "missing.pub")},
+			wantErr: "SSH public key not found",
+		},
+		{
+			name:    "no certificate",
+			ssh:     SSH{UserCAPublicKeyPath: caPath, PrivateKeyPath: privPath},
+			wantErr: "certificate_path is required",
+		},
+		{
+			name:    "missing certificate",
+			ssh:     SSH{UserCAPublicKeyPath: caPath, CertificatePath: filepath.Join(dir, "missing"), PrivateKeyPath: privPath},
+			wantErr: "SSH certificate not found",
+		},
+		{
+			name:    "missing CA",
+			ssh:     SSH{UserCAPublicKeyPath: filepath.Join(dir, "missing"), UseAgent: true},
+			wantErr: "SSH user CA public key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ssh.Port = 22
+			if tt.ssh.PublicKeyPath == "" {
+				tt.ssh.PublicKeyPath = pubPath
+			}
+			validator := NewValidator(&Main{Networking: Networking{SSH: tt.ssh}})
+			validator.validateSSHKeys()
+
+			errs := validator.GetErrors()
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Errorf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0], tt.wantErr) {
+				t.Errorf("expected one error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
 // Helper function to create a dummy file for testing
 func createDummyFile(path string) error {
 	file, err := os.Create(path)
@@ -321,3 +425,267 @@ func filterSSHKeyErrors(errors []string) []string {
 	}
 	return sshErrors
 }
+
+func TestValidatePlacementGroups(t *testing.T) {
+	poolName := "web"
+	mastersName := "masters"
+	tests := []struct {
+		name      string
+		masters   NodePool
+		workers   NodePool
+		wantError string
+	}{
+		{
+			name:    "spread masters and workers",
+			masters: NodePool{InstanceType: "cpx21", InstanceCount: 3, PlacementGroup: "spread"},
+			workers: NodePool{Name: &poolName, InstanceType: "cpx21", InstanceCount: 10, PlacementGroup: "spread"},
+		},
+		{
+			name:      "unknown type",
+			masters:   NodePool{InstanceType: "cpx21", InstanceCount: 3, PlacementGroup: "pack"},
+			workers:   NodePool{Name: &poolName, InstanceType: "cpx21", InstanceCount: 1},
+			wantError: `master pool: placement_group must be "spread"`,
+		},
+		{
+			name:      "static pool too large",
+			masters:   NodePool{InstanceType: "cpx21", InstanceCount: 3},
+			workers:   NodePool{Name: &poolName, InstanceType: "cpx21", InstanceCount: 11, PlacementGroup: "spread"},
+			wantError: "worker pool web: a spread placement group holds at most 10 servers, but the pool can have 11",
+		},
+		{
+			name:    "autoscaled pool too large",
+			masters: NodePool{InstanceType: "cpx21", InstanceCount: 3},
+			workers: NodePool{Name: &poolName, InstanceType: "cpx21", PlacementGroup: "spread",
+				Autoscaling: &Autoscaling{Enabled: true, MinInstances: 1, MaxInstances: 20}},
+			wantError: "worker pool web: a spread placement group holds at most 10 servers, but the pool can have 20",
+		},
+		{
+			name:      "pool named like the master placement group",
+			masters:   NodePool{InstanceType: "cpx21", InstanceCount: 3, PlacementGroup: "spread"},
+			workers:   NodePool{Name: &mastersName, InstanceType: "cpx21", InstanceCount: 3, PlacementGroup: "spread"},
+			wantError: "worker pool masters: the name masters is reserved for the master pool",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Main{
+				ClusterName:     "test-cluster",
+				MastersPool:     MasterNodePool{NodePool: tt.masters, Locations: []string{"fsn1"}},
+				WorkerNodePools: []WorkerNodePool{{NodePool: tt.workers, Location: "fsn1"}},
+			}
+
+			validator := NewValidator(config)
+			validator.validateMasterPool()
+			validator.validateWorkerPools()
+
+			errors := validator.GetErrors()
+			if tt.wantError == "" {
+				if len(errors) != 0 {
+					t.Errorf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			found := false
+			for _, err := range errors {
+				if err == tt.wantError {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("errors = %v, want %q", errors, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestValidatePrimaryIPs(t *testing.T) {
+	poolName := "web"
+	tests := []struct {
+		name       string
+		masters    NodePool
+		workers    NodePool
+		natGateway bool
+		wantError  string
+	}{
+		{
+			name:    "masters and workers keep their IPs",
+			masters: NodePool{InstanceType: "cpx21", InstanceCount: 3, PrimaryIPs: &PrimaryIPs{Enabled: true, DeletePolicy: "keep"}},
+			workers: NodePool{Name: &poolName, InstanceType: "cpx21", InstanceCount: 2, PrimaryIPs: &PrimaryIPs{Enabled: true}},
+		},
+		{
+			name:      "unknown delete policy",
+			masters:   NodePool{InstanceType: "cpx21", InstanceCount: 3, PrimaryIPs: &PrimaryIPs{Enabled: true, DeletePolicy: "retain"}},
+			workers:   NodePool{Name: &poolName, InstanceType: "cpx21", InstanceCount: 1},
+			wantError: `master pool: primary_ips delete_policy must be "delete" or "keep"`,
+		},
+		{
+			name:    "autoscaled pool",
+			masters: NodePool{InstanceType: "cpx21", InstanceCount: 3},
+			workers: NodePool{Name: &poolName, InstanceType: "cpx21", PrimaryIPs: &PrimaryIPs{Enabled: true},
+				Autoscaling: &Autoscaling{Enabled: true, MinInstances: 1, MaxInstances: 5}},
+			wantError: "worker pool web: primary_ips cannot be used with autoscaling",
+		},
+		{
+			name:       "NAT gateway",
+			masters:    NodePool{InstanceType: "cpx21", InstanceCount: 3, PrimaryIPs: &PrimaryIPs{Enabled: true}},
+			workers:    NodePool{Name: &poolName, InstanceType: "cpx21", InstanceCount: 1},
+			natGateway: true,
+			wantError:  "master pool: primary_ips cannot be used with a NAT gateway, nodes have no public IPs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Main{
+				ClusterName:     "test-cluster",
+				MastersPool:     MasterNodePool{NodePool: tt.masters, Locations: []string{"fsn1"}},
+				WorkerNodePools: []WorkerNodePool{{NodePool: tt.workers, Location: "fsn1"}},
+			}
+			if tt.natGateway {
+				config.Networking.PrivateNetwork.Enabled = true
+				config.Networking.PrivateNetwork.NATGateway = &NATGateway{Enabled: true}
+			}
+
+			validator := NewValidator(config)
+			validator.validateMasterPool()
+			validator.validateWorkerPools()
+
+			errors := validator.GetErrors()
+			if tt.wantError == "" {
+				if len(errors) != 0 {
+					t.Errorf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			found := false
+			for _, err := range errors {
+				if err == tt.wantError {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("errors = %v, want %q", errors, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestValidateAPIFloatingIP(t *testing.T) {
+	tests := []struct {
+		name        string
+		masters     int
+		apiLB       bool
+		natGateway  bool
+		interval    int
+		wantError   string
+		wantWarning string
+	}{
+		{name: "three masters", masters: 3, interval: 5},
+		{name: "load balancer", masters: 3, apiLB: true, interval: 5,
+			wantError: "api_floating_ip cannot be used together with create_load_balancer_for_the_kubernetes_api"},
+		{name: "NAT gateway", masters: 3, natGateway: true, interval: 5,
+			wantError: "api_floating_ip requires masters with public IPs and cannot be used with a NAT gateway"},
+		{name: "check interval", masters: 3, interval: 0,
+			wantError: "api_floating_ip.check_interval must be at least 1 second"},
+		{name: "single master", masters: 1, interval: 5,
+			wantWarning: "api_floating_ip is enabled with a single master, there is no master to fail over to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Main{
+				ClusterName:                        "test-cluster",
+				MastersPool:                        MasterNodePool{NodePool: NodePool{InstanceType: "cpx21", InstanceCount: tt.masters}, Locations: []string{"fsn1"}},
+				CreateLoadBalancerForKubernetesAPI: tt.apiLB,
+				APIFloatingIP:                      APIFloatingIP{Enabled: true, CheckInterval: tt.interval},
+			}
+			if tt.natGateway {
+				config.Networking.PrivateNetwork.Enabled = true
+				config.Networking.PrivateNetwork.NATGateway = &NATGateway{Enabled: true}
+			}
+
+			validator := NewValidator(config)
+			validator.validateAPIFloatingIP()
+
+			errors := validator.GetErrors()
+			if tt.wantError == "" && len(errors) != 0 {
+				t.Errorf("unexpected errors: %v", errors)
+			}
+			if tt.wantError != "" && (len(errors) != 1 || errors[0] != tt.wantError) {
+				t.Errorf("errors = %v, want %q", errors, tt.wantError)
+			}
+			warnings := validator.GetWarnings()
+			if tt.wantWarning != "" && (len(warnings) != 1 || warnings[0] != tt.wantWarning) {
+				t.Errorf("warnings = %v, want %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestValidateVolumes(t *testing.T) {
+	tests := []struct {
+		name      string
+		volumes   []Volume
+		wantError string
+	}{
+		{
+			name: "data and logs volumes",
+			volumes: []Volume{
+				{Name: "data", Size: 100, Format: "ext4", MountPath: "/var/lib/data", DeletePolicy: "keep"},
+				{Name: "logs", Size: 10, Format: "xfs", MountPath: "/var/log/app"},
+			},
+		},
+		{
+			name:      "invalid name",
+			volumes:   []Volume{{Name: "Data_1", Size: 10, MountPath: "/data"}},
+			wantError: `worker pool web: volume name "Data_1" must contain only lowercase letters, numbers, and hyphens`,
+		},
+		{
+			name:      "too small",
+			volumes:   []Volume{{Name: "data", Size: 5, MountPath: "/data"}},
+			wantError: "worker pool web: volume data size must be between 10 and 10240 GB",
+		},
+		{
+			name:      "unknown format",
+			volumes:   []Volume{{Name: "data", Size: 10, Format: "btrfs", MountPath: "/data"}},
+			wantError: `worker pool web: volume data format must be "ext4" or "xfs"`,
+		},
+		{
+			name:      "relative mount path",
+			volumes:   []Volume{{Name: "data", Size: 10, MountPath: "data"}},
+			wantError: "worker pool web: volume data mount_path must be an absolute path other than /",
+		},
+		{
+			name: "duplicate mount path",
+			volumes: []Volume{
+				{Name: "data", Size: 10, MountPath: "/data"},
+				{Name: "more", Size: 10, MountPath: "/data"},
+			},
+			wantError: "worker pool web: duplicate volume mount_path: /data",
+		},
+		{
+			name:      "unknown delete policy",
+			volumes:   []Volume{{Name: "data", Size: 10, MountPath: "/data", DeletePolicy: "retain"}},
+			wantError: `worker pool web: volume data delete_policy must be "delete" or "keep"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewValidator(&Main{})
+			validator.validateVolumes("worker pool web", tt.volumes)
+
+			errors := validator.GetErrors()
+			if tt.wantError == "" {
+				if len(errors) != 0 {
+					t.Errorf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 || errors[0] != tt.wantError {
+				t.Errorf("errors = %v, want %q", errors, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package util
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// knownHostServerPrefix prefixes the server ID in the comment of a known_hosts line
+const knownHostServerPrefix = "hek3ster-server="
+
+// KnownHosts pins SSH host keys in a known_hosts file
+// The host key of a server hek3ster creates is generated up front and pinned under the
+// server name with Expect, then moved to the server's address with Claim. A host without
+// a pinned key, e.g. a node of the cluster autoscaler, is trusted on first use: the key
+// it presents on the first connection is recorded. Every later connection must present
+// the pinned key. Lines are in OpenSSH format, with the ID of the server that owned the
+// address as comment, so the file also works with ssh -o UserKnownHostsFile.
+// KnownHosts is safe for concurrent use.
+type KnownHosts struct {
+	path string
+
+	mu      sync.Mutex
+	entries []knownHost
+	owners  map[string]int64 // normalized address -> ID of the server that has it
+}
+
+// knownHost is a pinned host key
+type knownHost struct {
+	address  string // normalized as in known_hosts, e.g. 10.0.0.2 or [10.0.0.2]:2222, or a server name
+	key      ssh.PublicKey
+	serverID int64 // 0 if the owner was unknown when the key was pinned
+}
+
+// HostKeyMismatchError is returned when a host presents another key than the pinned one
+type HostKeyMismatchError struct {
+	Address string
+	Got     ssh.PublicKey
+	Want    ssh.PublicKey
+	Path    string
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("SSH host key mismatch for %s: got %s %s, pinned %s %s in %s. "+
+		"The connection may be intercepted. If the server was rebuilt outside hek3ster, remove its line from the file",
+		e.Address, e.Got.Type(), ssh.FingerprintSHA256(e.Got), e.Want.Type(), ssh.FingerprintSHA256(e.Want), e.Path)
+}
+
+// LoadKnownHosts loads a known_hosts file; a missing file is an empty store
+func LoadKnownHosts(path string) (*KnownHosts, error) {
+	k := &KnownHosts{path: path, owners: make(map[string]int64)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+
+	for len(data) > 0 {
+		var hosts []string
+		var key ssh.PublicKey
+		var comment string
+		_, hosts, key, comment, data, err = ssh.ParseKnownHosts(data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse known hosts %s: %w", path, err)
+		}
+		serverID, _ := strconv.ParseInt(strings.TrimPrefix(comment, knownHostServerPrefix), 10, 64)
+		for _, host := range hosts {
+			k.entries = append(k.entries, knownHost{address: host, key: key, serverID: serverID})
+		}
+	}
+	return k, nil
+}
+
+// Path returns the path of the known_hosts file
+func (k *KnownHosts) Path() string {
+	return k.path
+}
+
+// Sync records which server owns each address of the cluster
+// Keys pinned for another server at an address, and keys of addresses no server owns
+// any more, are removed: the address was reassigned or released, so the next
+// connection pins the key of the server that has it now. Keys expected under a server
+// name are kept until the server claims them.
+func (k *KnownHosts) Sync(owners map[string]int64) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.owners = make(map[string]int64, len(owners))
+	for address, serverID := range owners {
+		k.owners[knownhosts.Normalize(address)] = serverID
+	}
+
+	var kept []knownHost
+	for _, entry := range k.entries {
+		if isServerName(entry.address) {
+			kept = append(kept, entry)
+			continue
+		}
+		owner, ok := k.owners[entry.address]
+		if !ok || (entry.serverID != 0 && entry.serverID != owner) {
+			continue
+		}
+		entry.serverID = owner
+		kept = append(kept, entry)
+	}
+	k.entries = kept
+	return k.save()
+}
+
+// Bind records the owner of a single address, e.g. of a server created during the run
+// Keys pinned at the address for another server are removed.
+func (k *KnownHosts) Bind(address string, serverID int64) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	address = knownhosts.Normalize(address)
+	k.owners[address] = serverID
+
+	var kept []knownHost
+	for _, entry := range k.entries {
+		if entry.address == address {
+			if entry.serverID != 0 && entry.serverID != serverID {
+				continue
+			}
+			entry.serverID = serverID
+		}
+		kept = append(kept, entry)
+	}
+	k.entries = kept
+	return k.save()
+}
+
+// Forget removes the keys pinned at an address, e.g. of a server that was deleted
+func (k *KnownHosts) Forget(address string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	address = knownhosts.Normalize(address)
+	delete(k.owners, address)
+
+	var kept []knownHost
+	for _, entry := range k.entries {
+		if entry.address != address {
+			kept = append(kept, entry)
+		}
+	}
+	k.entries = kept
+	return k.save()
+}
+
+// Expect pins the host key a server will present, before the server is created
+// The key is recorded under the server name, replacing a key expected before, until
+// Claim moves it to the address the server gets.
+func (k *KnownHosts) Expect(name string, key ssh.PublicKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var kept []knownHost
+	for _, entry := range k.entries {
+		if entry.address != name {
+			kept = append(kept, entry)
+		}
+	}
+	k.entries = append(kept, knownHost{address: name, key: key})
+	return k.save()
+}
+
+// Claim pins the host key expected for a server name at the address of the server
+// Keys pinned at the address before are replaced, as the expected key is the one
+// hek3ster installed on the server. Without an expected key, Claim does nothing.
+func (k *KnownHosts) Claim(name, address string, serverID int64) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	address = knownhosts.Normalize(address)
+	var expected ssh.PublicKey
+	var kept []knownHost
+	for _, entry := range k.entries {
+		if entry.address == name {
+			expected = entry.key
+			continue
+		}
+		if entry.address != address {
+			kept = append(kept, entry)
+		}
+	}
+	if expected == nil {
+		return nil
+	}
+
+	k.owners[address] = serverID
+	k.entries = append(kept, knownHost{address: address, key: expected, serverID: serverID})
+	return k.save()
+}
+
+// HostKeyCallback verifies host keys against the pinned keys and pins the key of unknown hosts
+func (k *KnownHosts) HostKeyCallback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		address := knownhosts.Normalize(hostname)
+		var pinned ssh.PublicKey
+		for _, entry := range k.entries {
+			if entry.address != address {
+				continue
+			}
+			if bytes.Equal(entry.key.Marshal(), key.Marshal()) {
+				return nil
+			}
+			pinned = entry.key
+		}
+		if pinned != nil {
+			return &HostKeyMismatchError{Address: address, Got: key, Want: pinned, Path: k.path}
+		}
+
+		k.entries = append(k.entries, knownHost{address: address, key: key, serverID: k.owners[address]})
+		if err := k.save(); err != nil {
+			return err
+		}
+		LogInfo(fmt.Sprintf("Pinned %s host key of %s on first use: %s", key.Type(), address, ssh.FingerprintSHA256(key)), "ssh")
+		return nil
+	}
+}
+
+// isServerName reports whether a known_hosts address is the name of a server whose
+// key is expected, rather than an IP address
+func isServerName(address string) bool {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	return net.ParseIP(host) == nil
+}
+
+// GenerateHostKey generates an ed25519 SSH host key
+// It returns the private key in OpenSSH PEM format, as sshd and cloud-init read it,
+// and the public key to pin.
+func GenerateHostKey() (string, ssh.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate host key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode host key: %w", err)
+	}
+	publicKey, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode host public key: %w", err)
+	}
+	return string(pem.EncodeToMemory(block)), publicKey, nil
+}
+
+// save writes the pinned keys to the file; the caller must hold the lock
+func (k *KnownHosts) save() error {
+	if len(k.entries) == 0 {
+		if _, err := os.Stat(k.path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return fmt.Errorf("failed to create known hosts directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range k.entries {
+		line := knownhosts.Line([]string{entry.address}, entry.key)
+		if entry.serverID != 0 {
+			line += fmt.Sprintf(" %s%d", knownHostServerPrefix, entry.serverID)
+		}
+		fmt.Fprintln(&buf, line)
+	}
+
+	if err := os.WriteFile(k.path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write known hosts: %w", err)
+	}
+	return nil
+}
@@ -248,3 +248,8 @@ func Retry(maxAttempts int, delay time.Duration, fn func() error) error {
 	}
 	return fmt.Errorf("max attempts (%d) reached: %w", maxAttempts, err)
 }
+
+// ShellQuote wraps a value in single quotes for safe use in remote shell commands
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
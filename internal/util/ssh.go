@@ -10,6 +10,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -31,10 +32,19 @@ var cloudInitWaitScript string
 
 // SSH represents an SSH client wrapper
 type SSH struct {
-	privateKeyPath string
-	publicKeyPath  string
-	bastionHost    string // Bastion/jump host for ProxyJump
-	bastionPort    int    // Bastion SSH port
+	privateKeyPath  string
+	publicKeyPath   string
+	user            string // Login user; commands of a non-root user run through sudo
+	useCertificate  bool   // Log in with a certificate signed by the user CA
+	certificatePath string // Certificate of the private key, empty to use the agent's
+	bastionHost     string // Bastion/jump host for ProxyJump
+	bastionPort     int    // Bastion SSH port
+	knownHosts      *KnownHosts
+
+	// Connections are pooled per host and reused by every command to it
+	poolMu  sync.Mutex
+	pool    map[string]*sshConn
+	bastion sshConn
 }
 
 // NewSSH creates a new SSH client
@@ -42,13 +52,39 @@ func NewSSH(privateKeyPath, publicKeyPath string) *SSH {
 	return &SSH{
 		privateKeyPath: privateKeyPath,
 		publicKeyPath:  publicKeyPath,
+		user:           "root",
 	}
 }
 
+// SetUser sets the user to log in as
+// Commands of a non-root user run through passwordless sudo, so they keep root privileges.
+func (s *SSH) SetUser(user string) {
+	if user == "" {
+		user = "root"
+	}
+	s.user = user
+}
+
+// User returns the user to log in as
+func (s *SSH) User() string {
+	return s.user
+}
+
 // SetBastion configures a bastion/jump host for SSH connections
 func (s *SSH) SetBastion(host string, port int) {
 	s.bastionHost = host
 	s.bastionPort = port
+	s.bastion.close()
+}
+
+// SetKnownHosts makes connections verify host keys against the pinned keys of the store
+func (s *SSH) SetKnownHosts(knownHosts *KnownHosts) {
+	s.knownHosts = knownHosts
+}
+
+// KnownHosts returns the store host keys are verified against, nil if none is set
+func (s *SSH) KnownHosts() *KnownHosts {
+	return s.knownHosts
 }
 
 // CalculateFingerprint calculates the MD5 fingerprint of a public key
@@ -90,107 +126,68 @@ func CalculateFingerprint(publicKeyPath string) (string, error) {
 func (s *SSH) getSSHConfig(useAgent bool) (*ssh.ClientConfig, error) {
 	var authMethods []ssh.AuthMethod
 
-	if useAgent {
-		// Try to use SSH agent
-		if agentConn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
-			authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	if s.useCertificate {
+		authMethod, err := s.certificateAuth(useAgent)
+		if err != nil {
+			return nil, err
+		}
+		authMethods = append(authMethods, authMethod)
+	} else {
+		if useAgent {
+			// Try to use SSH agent
+			if agentConn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
+				authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+			}
 		}
-	}
 
-	// Read private key
-	key, err := os.ReadFile(s.privateKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read private key: %w", err)
-	}
+		// Read private key
+		key, err := os.ReadFile(s.privateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
 
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
-	}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
 
-	authMethods = append(authMethods, ssh.PublicKeys(signer))
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
 
 	config := &ssh.ClientConfig{
-		User: "root",
-		Auth: authMethods,
-		// WARNING: InsecureIgnoreHostKey disables SSH host key verification.
-		// This is used for cluster provisioning where we're connecting to newly
-		// created servers with unknown host keys. This is a security trade-off
-		// for automation convenience.
-		//
-		// SECURITY CONSIDERATIONS:
-		// - Only use this for initial cluster provisioning
-		// - Ensure connection is over a trusted network or VPN
-		// - Consider implementing host key storage and verification for production
-		// - Be aware this is vulnerable to MITM attacks during initial connection
-		//
-		// For enhanced security, consider:
-		// 1. Using ssh.FixedHostKey() with known host keys
-		// 2. Implementing a known_hosts file system
-		// 3. Using certificate-based authentication
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:            s.user,
+		Auth:            authMethods,
+		HostKeyCallback: s.hostKeyCallback(),
 		Timeout:         DefaultConnectTimeout,
 	}
 
 	return config, nil
 }
 
-// getClient returns an SSH client, optionally through a bastion host
-func (s *SSH) getClient(config *ssh.ClientConfig, host string, port int) (*ssh.Client, error) {
-	addr := fmt.Sprintf("%s:%d", host, port)
-
-	// If bastion host is configured, connect through it
-	if s.bastionHost != "" {
-		// Connect to bastion
-		bastionAddr := fmt.Sprintf("%s:%d", s.bastionHost, s.bastionPort)
-		bastionClient, err := ssh.Dial("tcp", bastionAddr, config)
-		if err != nil {
-			return nil, fmt.Errorf("failed to dial bastion %s: %w", bastionAddr, err)
-		}
-
-		// Connect to target through bastion
-		conn, err := bastionClient.Dial("tcp", addr)
-		if err != nil {
-			bastionClient.Close()
-			return nil, fmt.Errorf("failed to dial %s through bastion: %w", addr, err)
-		}
-
-		// Create SSH connection over the tunneled connection
-		ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
-		if err != nil {
-			conn.Close()
-			bastionClient.Close()
-			return nil, fmt.Errorf("failed to create client connection through bastion: %w", err)
-		}
-
-		// Note: The bastion client connection is embedded in the returned SSH client
-		// and will be cleaned up when the client is closed
-		return ssh.NewClient(ncc, chans, reqs), nil
+// hostKeyCallback verifies host keys against the known hosts store
+// Without a store, host keys are not verified.
+func (s *SSH) hostKeyCallback() ssh.HostKeyCallback {
+	if s.knownHosts == nil {
+		return ssh.InsecureIgnoreHostKey()
 	}
+	return s.knownHosts.HostKeyCallback()
+}
 
-	// Direct connection (no bastion)
-	return ssh.Dial("tcp", addr, config)
+// remoteCommand returns the command as it is run on the host
+func (s *SSH) remoteCommand(command string) string {
+	if s.user == "root" {
+		return command
+	}
+	return "sudo -n -H -- bash -c " + ShellQuote(command)
 }
 
 // Run executes a command on a remote host via SSH
 func (s *SSH) Run(ctx context.Context, host string, port int, command string, useAgent bool) (string, error) {
-	config, err := s.getSSHConfig(useAgent)
-	if err != nil {
-		return "", err
-	}
-
-	// Connect to the remote host (possibly through bastion)
-	client, err := s.getClient(config, host, port)
+	// Open a session on the pooled connection (possibly through bastion)
+	session, err := s.newSession(host, port, useAgent)
 	if err != nil {
 		return "", err
 	}
-	defer client.Close()
-
-	// Create a session
-	session, err := client.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("failed to create session: %w", err)
-	}
 	defer session.Close()
 
 	// Execute the command with timeout
@@ -202,7 +199,7 @@ func (s *SSH) Run(ctx context.Context, host string, port int, command string, us
 	resultChan := make(chan result, 1)
 
 	go func() {
-		output, err := session.CombinedOutput(command)
+		output, err := session.CombinedOutput(s.remoteCommand(command))
 		resultChan <- result{output: string(output), err: err}
 	}()
 
@@ -216,23 +213,11 @@ func (s *SSH) Run(ctx context.Context, host string, port int, command string, us
 
 // RunWithOutput executes a command and streams output
 func (s *SSH) RunWithOutput(ctx context.Context, host string, port int, command string, useAgent bool, prefix string) error {
-	config, err := s.getSSHConfig(useAgent)
-	if err != nil {
-		return err
-	}
-
-	// Connect to the remote host (possibly through bastion)
-	client, err := s.getClient(config, host, port)
+	// Open a session on the pooled connection (possibly through bastion)
+	session, err := s.newSession(host, port, useAgent)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
-
-	// Create a session
-	session, err := client.NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
-	}
 	defer session.Close()
 
 	// Setup pipes
@@ -247,7 +232,7 @@ func (s *SSH) RunWithOutput(ctx context.Context, host string, port int, command
 	}
 
 	// Start the command
-	if err := session.Start(command); err != nil {
+	if err := session.Start(s.remoteCommand(command)); err != nil {
 		return fmt.Errorf("failed to start command: %w", err)
 	}
 
@@ -359,28 +344,16 @@ func (s *SSH) WaitForCloudInit(ctx context.Context, host string, port int, useAg
 
 // CopyFile copies a file to the remote host via SCP
 func (s *SSH) CopyFile(ctx context.Context, host string, port int, localPath string, remotePath string, useAgent bool) error {
-	config, err := s.getSSHConfig(useAgent)
-	if err != nil {
-		return err
-	}
-
-	// Connect to the remote host (possibly through bastion)
-	client, err := s.getClient(config, host, port)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
 	// Read local file
 	data, err := os.ReadFile(localPath)
 	if err != nil {
 		return fmt.Errorf("failed to read local file: %w", err)
 	}
 
-	// Create remote file
-	session, err := client.NewSession()
+	// Create remote file on the pooled connection (possibly through bastion)
+	session, err := s.newSession(host, port, useAgent)
 	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
+		return err
 	}
 	defer session.Close()
 
@@ -390,7 +363,7 @@ func (s *SSH) CopyFile(ctx context.Context, host string, port int, localPath str
 		return fmt.Errorf("failed to create stdin pipe: %w", err)
 	}
 
-	if err := session.Start(fmt.Sprintf("cat > %s", remotePath)); err != nil {
+	if err := session.Start(s.remoteCommand(fmt.Sprintf("cat > %s", remotePath))); err != nil {
 		return fmt.Errorf("failed to start command: %w", err)
 	}
 
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// UseCertificate makes the client log in with an SSH certificate signed by the user CA
+// The certificate of the private key is loaded from certificatePath. With the agent,
+// the certificates loaded into it are used as well; certificatePath may then be empty.
+func (s *SSH) UseCertificate(certificatePath string) {
+	s.useCertificate = true
+	s.certificatePath = certificatePath
+}
+
+// certificateAuth returns the auth method that logs in with certificates
+// Certificates that expired or are not valid for the user are skipped, so a stale
+// certificate fails with a clear error instead of a rejected login.
+func (s *SSH) certificateAuth(useAgent bool) (ssh.AuthMethod, error) {
+	var signers []ssh.Signer
+	var problems []string
+
+	if s.certificatePath != "" {
+		signer, err := s.fileCertificateSigner()
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	if useAgent {
+		if agentConn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
+			agentSigners, err := agent.NewClient(agentConn).Signers()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list agent keys: %w", err)
+			}
+			for _, signer := range agentSigners {
+				cert, ok := signer.PublicKey().(*ssh.Certificate)
+				if !ok {
+					continue
+				}
+				if err := checkCertificate(cert, s.user, time.Now()); err != nil {
+					problems = append(problems, fmt.Sprintf("agent certificate %q: %v", cert.KeyId, err))
+					continue
+				}
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	if len(signers) == 0 {
+		if len(problems) > 0 {
+			return nil, fmt.Errorf("no usable SSH certificate: %s", strings.Join(problems, "; "))
+		}
+		return nil, fmt.Errorf("no SSH certificate found: set certificate_path or load a certificate into the agent")
+	}
+	return ssh.PublicKeys(signers...), nil
+}
+
+// fileCertificateSigner pairs the private key with its certificate file
+func (s *SSH) fileCertificateSigner() (ssh.Signer, error) {
+	key, err := os.ReadFile(s.privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	data, err := os.ReadFile(s.certificatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH certificate: %w", err)
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH certificate %s: %w", s.certificatePath, err)
+	}
+	cert, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an SSH certificate", s.certificatePath)
+	}
+	if err := checkCertificate(cert, s.user, time.Now()); err != nil {
+		return nil, fmt.Errorf("SSH certificate %s: %w", s.certificatePath, err)
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("SSH certificate %s does not belong to the private key: %w", s.certificatePath, err)
+	}
+	return certSigner, nil
+}
+
+// checkCertificate checks that a user certificate is valid for the user at the given time
+func checkCertificate(cert *ssh.Certificate, user string, now time.Time) error {
+	if cert.CertType != ssh.UserCert {
+		return fmt.Errorf("not a user certificate")
+	}
+
+	unix := uint64(now.Unix())
+	if unix < cert.ValidAfter {
+		return fmt.Errorf("not valid before %s", time.Unix(int64(cert.ValidAfter), 0).Format(time.RFC3339))
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && unix >= cert.ValidBefore {
+		return fmt.Errorf("expired at %s, request a new certificate", time.Unix(int64(cert.ValidBefore), 0).Format(time.RFC3339))
+	}
+	if len(cert.ValidPrincipals) > 0 && !slices.Contains(cert.ValidPrincipals, user) {
+		return fmt.Errorf("not valid for user %s (principals: %s)", user, strings.Join(cert.ValidPrincipals, ", "))
+	}
+	return nil
+}
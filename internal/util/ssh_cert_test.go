@@ -0,0 +1,133 @@
+package util
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// signTestCertificate signs a user certificate for the key with a fresh CA
+func signTestCertificate(t *testing.T, key ssh.PublicKey, principals []string, validAfter, validBefore time.Time) *ssh.Certificate {
+	t.Helper()
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA signer: %v", err)
+	}
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(validAfter.Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatalf("failed to sign certificate: %v", err)
+	}
+	return cert
+}
+
+func TestCheckCertificate(t *testing.T) {
+	now := time.Now()
+	key := newHostKey(t)
+
+	tests := []struct {
+		name    string
+		cert    *ssh.Certificate
+		user    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			cert: signTestCertificate(t, key, []string{"root"}, now.Add(-time.Minute), now.Add(time.Hour)),
+			user: "root",
+		},
+		{
+			name: "any principal",
+			cert: signTestCertificate(t, key, nil, now.Add(-time.Minute), now.Add(time.Hour)),
+			user: "ops",
+		},
+		{
+			name:    "expired",
+			cert:    signTestCertificate(t, key, []string{"root"}, now.Add(-time.Hour), now.Add(-time.Minute)),
+			user:    "root",
+			wantErr: "expired",
+		},
+		{
+			name:    "not yet valid",
+			cert:    signTestCertificate(t, key, []string{"root"}, now.Add(time.Hour), now.Add(2*time.Hour)),
+			user:    "root",
+			wantErr: "not valid before",
+		},
+		{
+			name:    "other principal",
+			cert:    signTestCertificate(t, key, []string{"ops"}, now.Add(-time.Minute), now.Add(time.Hour)),
+			user:    "root",
+			wantErr: "not valid for user root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCertificate(tt.cert, tt.user, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	hostCert := signTestCertificate(t, key, nil, now.Add(-time.Minute), now.Add(time.Hour))
+	hostCert.CertType = ssh.HostCert
+	if err := checkCertificate(hostCert, "root", now); err == nil {
+		t.Error("host certificate should be rejected")
+	}
+}
+
+func TestSSH_LogsInWithCertificate(t *testing.T) {
+	client, clientKey := newTestSSHClient(t)
+	now := time.Now()
+	cert := signTestCertificate(t, clientKey, []string{"root"}, now.Add(-time.Minute), now.Add(time.Hour))
+
+	// The server only accepts the certificate, not the plain key
+	server := newTestSSHServer(t, cert)
+	host, port := server.hostPort(t)
+
+	certPath := client.privateKeyPath + "-cert.pub"
+	if err := os.WriteFile(certPath, ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+
+	if _, err := client.Run(context.Background(), host, port, "echo ready", false); err == nil {
+		t.Fatal("login with the plain key should be rejected")
+	}
+
+	client.UseCertificate(certPath)
+	if _, err := client.Run(context.Background(), host, port, "echo ready", false); err != nil {
+		t.Fatalf("login with certificate failed: %v", err)
+	}
+
+	// A certificate for another user is refused before connecting
+	client.SetUser("ops")
+	client.Close()
+	_, err := client.Run(context.Background(), host, port, "echo ready", false)
+	if err == nil || !strings.Contains(err.Error(), "not valid for user ops") {
+		t.Errorf("expected principal error, got %v", err)
+	}
+}
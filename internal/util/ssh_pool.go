@@ -0,0 +1,210 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultKeepAliveInterval = 30 * time.Second
+	DefaultKeepAliveTimeout  = 15 * time.Second
+)
+
+// sshConn is a pooled connection to a single host
+// Its lock is held while dialing, so concurrent commands to the same host share one
+// connection without holding up commands to other hosts.
+type sshConn struct {
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// get returns the pooled client, dialing a new one if there is none
+func (c *sshConn) get(dial func() (*ssh.Client, error)) (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+	client, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	go c.keepAlive(client)
+	return client, nil
+}
+
+// discard closes a client and removes it from the pool, unless it was replaced already
+func (c *sshConn) discard(client *ssh.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == client {
+		c.client = nil
+	}
+	client.Close()
+}
+
+// close closes the pooled client, if any
+func (c *sshConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+// keepAlive sends keepalives until the connection is closed
+// A connection that does not answer in time is closed, which also fails the commands
+// waiting on it, so the next command reconnects instead of hanging on a dead peer.
+func (c *sshConn) keepAlive(client *ssh.Client) {
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(DefaultKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			c.discard(client)
+			return
+		case <-ticker.C:
+			reply := make(chan error, 1)
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				reply <- err
+			}()
+
+			select {
+			case err := <-reply:
+				if err == nil {
+					continue
+				}
+			case <-time.After(DefaultKeepAliveTimeout):
+			}
+			c.discard(client)
+			return
+		}
+	}
+}
+
+// conn returns the pool entry of an address, creating it if needed
+func (s *SSH) conn(addr string) *sshConn {
+	s.poolMu.Lock()
+	defer s.poolMu.Unlock()
+
+	if s.pool == nil {
+		s.pool = make(map[string]*sshConn)
+	}
+	conn, ok := s.pool[addr]
+	if !ok {
+		conn = &sshConn{}
+		s.pool[addr] = conn
+	}
+	return conn
+}
+
+// newSession opens a session on the pooled connection to a host
+// A pooled connection that turns out to be dead is replaced once. A session refused by
+// the host, e.g. beyond sshd's MaxSessions, leaves the connection to the other commands.
+func (s *SSH) newSession(host string, port int, useAgent bool) (*ssh.Session, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn := s.conn(addr)
+
+	for attempt := 0; ; attempt++ {
+		client, err := conn.get(func() (*ssh.Client, error) {
+			return s.dial(addr, useAgent)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		session, err := client.NewSession()
+		if err == nil {
+			return session, nil
+		}
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+		conn.discard(client)
+		if attempt > 0 {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+	}
+}
+
+// dial connects to a host, through the pooled bastion connection if a bastion is set
+func (s *SSH) dial(addr string, useAgent bool) (*ssh.Client, error) {
+	config, err := s.getSSHConfig(useAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Direct connection (no bastion)
+	if s.bastionHost == "" {
+		return ssh.Dial("tcp", addr, config)
+	}
+
+	bastionAddr := net.JoinHostPort(s.bastionHost, strconv.Itoa(s.bastionPort))
+	for attempt := 0; ; attempt++ {
+		bastionClient, err := s.bastion.get(func() (*ssh.Client, error) {
+			client, err := ssh.Dial("tcp", bastionAddr, config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to dial bastion %s: %w", bastionAddr, err)
+			}
+			return client, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Connect to target through bastion
+		conn, err := bastionClient.Dial("tcp", addr)
+		if err != nil {
+			// The bastion refusing the channel means the target is unreachable; any other
+			// error means the bastion connection is dead and is replaced once
+			var openErr *ssh.OpenChannelError
+			if attempt > 0 || errors.As(err, &openErr) {
+				return nil, fmt.Errorf("failed to dial %s through bastion: %w", addr, err)
+			}
+			s.bastion.discard(bastionClient)
+			continue
+		}
+
+		// Create SSH connection over the tunneled connection
+		ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create client connection through bastion: %w", err)
+		}
+		return ssh.NewClient(ncc, chans, reqs), nil
+	}
+}
+
+// Close closes all pooled connections
+// The SSH client stays usable; the next command reconnects.
+func (s *SSH) Close() {
+	s.poolMu.Lock()
+	conns := s.pool
+	s.pool = nil
+	s.poolMu.Unlock()
+
+	for _, conn := range conns {
+		conn.close()
+	}
+	s.bastion.close()
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// Shell opens an interactive login shell on a host
+// With a terminal on stdin, the local terminal is put into raw mode and the remote shell
+// gets a PTY of the same size that follows window resizes. A non-root user gets its own
+// shell, not one through sudo.
+func (s *SSH) Shell(host string, port int, useAgent bool) error {
+	session, err := s.newSession(host, port, useAgent)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(termType, height, width, modes); err != nil {
+			return fmt.Errorf("failed to request PTY: %w", err)
+		}
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to put terminal into raw mode: %w", err)
+		}
+		defer term.Restore(fd, state)
+
+		stop := forwardWindowSize(fd, session)
+		defer stop()
+	}
+
+	if err := session.Shell(); err != nil {
+		return fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	// The exit status of the last command is not an error of hek3ster
+	var exitErr *ssh.ExitError
+	if err := session.Wait(); err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("shell session failed: %w", err)
+	}
+	return nil
+}
+
+// forwardWindowSize sends local terminal resizes to the remote PTY until stop is called
+func forwardWindowSize(fd int, session *ssh.Session) (stop func()) {
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-resized:
+				if width, height, err := term.GetSize(fd); err == nil {
+					session.WindowChange(height, width)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(resized)
+		close(done)
+	}
+}
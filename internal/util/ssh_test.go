@@ -0,0 +1,293 @@
+package util
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is an SSH server that answers every exec request with the command itself
+type testSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	accepted atomic.Int32
+	refuse   atomic.Bool // Refuse new sessions, as sshd does beyond MaxSessions
+
+	mu    sync.Mutex
+	conns []net.Conn
+	user  string
+}
+
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("failed to create host signer: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &testSSHServer{listener: listener}
+	server.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, os.ErrPermission
+			}
+			server.mu.Lock()
+			server.user = meta.User()
+			server.mu.Unlock()
+			return nil, nil
+		},
+	}
+	server.config.AddHostKey(hostSigner)
+	t.Cleanup(func() {
+		listener.Close()
+		server.dropConnections()
+	})
+	go server.serve()
+	return server
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.accepted.Add(1)
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *testSSHServer) handle(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if s.refuse.Load() {
+			newChannel.Reject(ssh.ResourceShortage, "too many sessions")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				// The payload is the command as an SSH string
+				command := req.Payload[4:]
+				channel.Write(command)
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, 0)
+				channel.SendRequest("exit-status", false, status)
+				return
+			}
+		}()
+	}
+}
+
+// lastUser returns the user of the last login
+func (s *testSSHServer) lastUser() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.user
+}
+
+// dropConnections closes every accepted connection, as a rebooted node would
+func (s *testSSHServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSSHServer) hostPort(t *testing.T) (string, int) {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split address: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
+
+// newTestSSHClient writes a client key pair and returns an SSH client using it
+func newTestSSHClient(t *testing.T) (*SSH, ssh.PublicKey) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatalf("failed to marshal client key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("failed to create client signer: %v", err)
+	}
+
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "id_ed25519")
+	pubPath := privPath + ".pub"
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write private key: %v", err)
+	}
+	if err := os.WriteFile(pubPath, ssh.MarshalAuthorizedKey(signer.PublicKey()), 0644); err != nil {
+		t.Fatalf("failed to write public key: %v", err)
+	}
+
+	client := NewSSH(privPath, pubPath)
+	t.Cleanup(client.Close)
+	return client, signer.PublicKey()
+}
+
+func TestSSH_ReusesConnectionPerHost(t *testing.T) {
+	client, clientKey := newTestSSHClient(t)
+	server := newTestSSHServer(t, clientKey)
+	host, port := server.hostPort(t)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		output, err := client.Run(ctx, host, port, "echo ready", false)
+		if err != nil {
+			t.Fatalf("Run %d failed: %v", i, err)
+		}
+		if output != "echo ready" {
+			t.Errorf("unexpected output %q", output)
+		}
+	}
+
+	// Concurrent commands share the connection as well
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Run(ctx, host, port, "uptime", false); err != nil {
+				t.Errorf("concurrent Run failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := server.accepted.Load(); got != 1 {
+		t.Errorf("expected 1 connection, got %d", got)
+	}
+}
+
+func TestSSH_ReconnectsAfterConnectionLoss(t *testing.T) {
+	client, clientKey := newTestSSHClient(t)
+	server := newTestSSHServer(t, clientKey)
+	host, port := server.hostPort(t)
+	ctx := context.Background()
+
+	if _, err := client.Run(ctx, host, port, "echo ready", false); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	server.dropConnections()
+
+	if _, err := client.Run(ctx, host, port, "echo ready", false); err != nil {
+		t.Fatalf("Run after connection loss failed: %v", err)
+	}
+	if got := server.accepted.Load(); got != 2 {
+		t.Errorf("expected 2 connections, got %d", got)
+	}
+
+	// Close drops the pool; the next command reconnects
+	client.Close()
+	if _, err := client.Run(ctx, host, port, "echo ready", false); err != nil {
+		t.Fatalf("Run after Close failed: %v", err)
+	}
+	if got := server.accepted.Load(); got != 3 {
+		t.Errorf("expected 3 connections, got %d", got)
+	}
+}
+
+func TestSSH_KeepsConnectionWhenSessionRefused(t *testing.T) {
+	client, clientKey := newTestSSHClient(t)
+	server := newTestSSHServer(t, clientKey)
+	host, port := server.hostPort(t)
+	ctx := context.Background()
+
+	if _, err := client.Run(ctx, host, port, "echo ready", false); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	server.refuse.Store(true)
+	if _, err := client.Run(ctx, host, port, "echo ready", false); err == nil {
+		t.Fatal("Run should fail while sessions are refused")
+	}
+
+	server.refuse.Store(false)
+	if _, err := client.Run(ctx, host, port, "echo ready", false); err != nil {
+		t.Fatalf("Run after refused session failed: %v", err)
+	}
+	if got := server.accepted.Load(); got != 1 {
+		t.Errorf("expected the connection to be kept, got %d connections", got)
+	}
+}
+
+func TestSSH_NonRootUserRunsThroughSudo(t *testing.T) {
+	client, clientKey := newTestSSHClient(t)
+	server := newTestSSHServer(t, clientKey)
+	host, port := server.hostPort(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		user    string
+		command string
+		want    string
+	}{
+		{user: "root", command: "echo ready", want: "echo ready"},
+		{user: "ops", command: "echo ready", want: "sudo -n -H -- bash -c 'echo ready'"},
+		{user: "ops", command: "echo 'it''s'", want: `sudo -n -H -- bash -c 'echo '"'"'it'"'"''"'"'s'"'"''`},
+	}
+
+	for _, tt := range tests {
+		client.SetUser(tt.user)
+		client.Close()
+		output, err := client.Run(ctx, host, port, tt.command, false)
+		if err != nil {
+			t.Fatalf("Run as %s failed: %v", tt.user, err)
+		}
+		if output != tt.want {
+			t.Errorf("Run as %s: expected remote command %q, got %q", tt.user, tt.want, output)
+		}
+		if got := server.lastUser(); got != tt.user {
+			t.Errorf("expected login as %s, got %s", tt.user, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package hetzner
+
+import (
+	"context"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// API is the set of Hetzner Cloud operations used to manage a cluster
+// Client implements it against the Hetzner Cloud API; hetznertest.Fake implements it
+// in memory so cluster operations can be tested offline.
+type API interface {
+	// Locations, server types and images
+	GetLocations(ctx context.Context) ([]*hcloud.Location, error)
+	GetLocation(ctx context.Context, name string) (*hcloud.Location, error)
+	GetServerTypes(ctx context.Context) ([]*hcloud.ServerType, error)
+	GetServerType(ctx context.Context, name string) (*hcloud.ServerType, error)
+	GetImage(ctx context.Context, nameOrID string, architecture hcloud.Architecture) (*hcloud.Image, error)
+	CreateImage(ctx context.Context, server *hcloud.Server, opts hcloud.ServerCreateImageOpts) (*hcloud.Image, error)
+
+	// Servers
+	ListServers(ctx context.Context, opts hcloud.ServerListOpts) ([]*hcloud.Server, error)
+	GetServer(ctx context.Context, name string) (*hcloud.Server, error)
+	CreateServer(ctx context.Context, opts hcloud.ServerCreateOpts) (*hcloud.Server, error)
+	DeleteServer(ctx context.Context, server *hcloud.Server) error
+	ShutdownServer(ctx context.Context, server *hcloud.Server) error
+	WaitForServerStatus(ctx context.Context, server *hcloud.Server, targetStatus hcloud.ServerStatus, timeout time.Duration) error
+
+	// Networks
+	CreateNetwork(ctx context.Context, opts hcloud.NetworkCreateOpts) (*hcloud.Network, error)
+	GetNetwork(ctx context.Context, name string) (*hcloud.Network, error)
+	AddRouteToNetwork(ctx context.Context, network *hcloud.Network, opts hcloud.NetworkAddRouteOpts) error
+	DeleteNetwork(ctx context.Context, network *hcloud.Network) error
+
+	// SSH keys
+	CreateSSHKey(ctx context.Context, opts hcloud.SSHKeyCreateOpts) (*hcloud.SSHKey, error)
+	GetSSHKey(ctx context.Context, name string) (*hcloud.SSHKey, error)
+	DeleteSSHKey(ctx context.Context, sshKey *hcloud.SSHKey) error
+
+	// Floating IPs
+	CreateFloatingIP(ctx context.Context, opts hcloud.FloatingIPCreateOpts) (*hcloud.FloatingIP, error)
+	GetFloatingIP(ctx context.Context, name string) (*hcloud.FloatingIP, error)
+	ListFloatingIPs(ctx context.Context, opts hcloud.FloatingIPListOpts) ([]*hcloud.FloatingIP, error)
+	AssignFloatingIP(ctx context.Context, floatingIP *hcloud.FloatingIP, server *hcloud.Server) error
+	DeleteFloatingIP(ctx context.Context, floatingIP *hcloud.FloatingIP) error
+
+	// Primary IPs
+	CreatePrimaryIP(ctx context.Context, opts hcloud.PrimaryIPCreateOpts) (*hcloud.PrimaryIP, error)
+	GetPrimaryIP(ctx context.Context, name string) (*hcloud.PrimaryIP, error)
+	ListPrimaryIPs(ctx context.Context, opts hcloud.PrimaryIPListOpts) ([]*hcloud.PrimaryIP, error)
+	DeletePrimaryIP(ctx context.Context, primaryIP *hcloud.PrimaryIP) error
+
+	// Volumes
+	CreateVolume(ctx context.Context, opts hcloud.VolumeCreateOpts) (*hcloud.Volume, error)
+	GetVolume(ctx context.Context, name string) (*hcloud.Volume, error)
+	ListVolumes(ctx context.Context, opts hcloud.VolumeListOpts) ([]*hcloud.Volume, error)
+	DeleteVolume(ctx context.Context, volume *hcloud.Volume) error
+
+	// Placement groups
+	CreatePlacementGroup(ctx context.Context, opts hcloud.PlacementGroupCreateOpts) (*hcloud.PlacementGroup, error)
+	GetPlacementGroup(ctx context.Context, name string) (*hcloud.PlacementGroup, error)
+	ListPlacementGroups(ctx context.Context, opts hcloud.PlacementGroupListOpts) ([]*hcloud.PlacementGroup, error)
+	DeletePlacementGroup(ctx context.Context, placementGroup *hcloud.PlacementGroup) error
+
+	// Firewalls
+	CreateFirewall(ctx context.Context, opts hcloud.FirewallCreateOpts) (*hcloud.Firewall, error)
+	GetFirewall(ctx context.Context, name string) (*hcloud.Firewall, error)
+	DeleteFirewall(ctx context.Context, firewall *hcloud.Firewall) error
+
+	// Load balancers
+	CreateLoadBalancer(ctx context.Context, opts hcloud.LoadBalancerCreateOpts) (*hcloud.LoadBalancer, error)
+	GetLoadBalancer(ctx context.Context, name string) (*hcloud.LoadBalancer, error)
+	DeleteLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer) error
+	AddServiceToLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer, opts hcloud.LoadBalancerAddServiceOpts) error
+	AddLabelSelectorTargetToLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer, opts hcloud.LoadBalancerAddLabelSelectorTargetOpts) error
+	AddServerTargetToLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer, opts hcloud.LoadBalancerAddServerTargetOpts) error
+
+	// DNS zones
+	GetZone(ctx context.Context, name string) (*hcloud.Zone, error)
+	CreateZone(ctx context.Context, opts hcloud.ZoneCreateOpts) (*hcloud.Zone, error)
+	DeleteZone(ctx context.Context, zone *hcloud.Zone) error
+	GetZoneRRSet(ctx context.Context, zone *hcloud.Zone, name string, rrType hcloud.ZoneRRSetType) (*hcloud.ZoneRRSet, error)
+	CreateZoneRRSet(ctx context.Context, zone *hcloud.Zone, opts hcloud.ZoneRRSetCreateOpts) (*hcloud.ZoneRRSet, error)
+	DeleteZoneRRSet(ctx context.Context, rrset *hcloud.ZoneRRSet) error
+
+	// Certificates
+	CreateManagedCertificate(ctx context.Context, opts hcloud.CertificateCreateOpts) (*hcloud.Certificate, error)
+	GetCertificate(ctx context.Context, name string) (*hcloud.Certificate, error)
+	DeleteCertificate(ctx context.Context, cert *hcloud.Certificate) error
+
+	// SetResourceTracker attaches a tracker that records every resource created through the API
+	SetResourceTracker(tracker *ResourceTracker)
+}
+
+// Client must keep implementing API
+var _ API = (*Client)(nil)
@@ -0,0 +1,56 @@
+package hetzner
+
+import (
+	"context"
+	"sync"
+)
+
+// lookupCache memoises lookups by name for the lifetime of a client
+// Concurrent lookups of the same name share a single API request. Failed lookups
+// are not cached, so they are repeated by the next caller.
+type lookupCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]*lookupEntry[T]
+}
+
+// lookupEntry is a finished or in-flight lookup
+type lookupEntry[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// newLookupCache creates an empty lookup cache
+func newLookupCache[T any]() *lookupCache[T] {
+	return &lookupCache[T]{entries: make(map[string]*lookupEntry[T])}
+}
+
+// get returns the cached value for a name, or calls lookup once to fetch it
+func (c *lookupCache[T]) get(ctx context.Context, name string, lookup func() (T, error)) (T, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	if !ok {
+		entry = &lookupEntry[T]{done: make(chan struct{})}
+		c.entries[name] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.done:
+			return entry.value, entry.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+
+	entry.value, entry.err = lookup()
+	if entry.err != nil {
+		c.mu.Lock()
+		delete(c.entries, name)
+		c.mu.Unlock()
+	}
+	close(entry.done)
+	return entry.value, entry.err
+}
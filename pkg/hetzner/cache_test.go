@@ -0,0 +1,106 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestLookupCache(t *testing.T) {
+	ctx := context.Background()
+	cache := newLookupCache[string]()
+
+	var calls atomic.Int32
+	lookup := func() (string, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "cx22", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := cache.get(ctx, "cx22", lookup); got != "cx22" || err != nil {
+				t.Errorf("get() = %q, %v, want cx22", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("lookups = %d, want 1 for concurrent gets of the same name", got)
+	}
+}
+
+func TestLookupCache_ErrorsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	cache := newLookupCache[string]()
+
+	if _, err := cache.get(ctx, "cx22", func() (string, error) { return "", errors.New("unavailable") }); err == nil {
+		t.Fatal("get() error = nil, want the lookup error")
+	}
+	got, err := cache.get(ctx, "cx22", func() (string, error) { return "cx22", nil })
+	if got != "cx22" || err != nil {
+		t.Errorf("get() after a failed lookup = %q, %v, want a new lookup", got, err)
+	}
+}
+
+func TestClient_CachesLookups(t *testing.T) {
+	requests := make(map[string]int)
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/server_types":
+			io.WriteString(w, `{"server_types":[{"id":1,"name":"cx22"}]}`)
+		case "/locations":
+			io.WriteString(w, `{"locations":[{"id":1,"name":"fsn1","network_zone":"eu-central"}]}`)
+		case "/images":
+			io.WriteString(w, `{"images":[{"id":1,"name":"ubuntu-24.04","type":"system"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient("test-token", WithEndpoint(server.URL))
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetServerType(ctx, "cx22"); err != nil {
+			t.Fatalf("GetServerType() error = %v", err)
+		}
+		if _, err := client.GetLocation(ctx, "fsn1"); err != nil {
+			t.Fatalf("GetLocation() error = %v", err)
+		}
+		if _, err := client.GetImage(ctx, "ubuntu-24.04", hcloud.ArchitectureX86); err != nil {
+			t.Fatalf("GetImage() error = %v", err)
+		}
+	}
+
+	for _, path := range []string{"/server_types", "/locations", "/images"} {
+		if requests[path] != 1 {
+			t.Errorf("requests to %s = %d, want 1", path, requests[path])
+		}
+	}
+
+	// The same image name is a different image on another architecture
+	if _, err := client.GetImage(ctx, "ubuntu-24.04", hcloud.ArchitectureARM); err != nil {
+		t.Fatalf("GetImage() error = %v", err)
+	}
+	if requests["/images"] != 2 {
+		t.Errorf("requests to /images = %d, want 2 after an arm lookup", requests["/images"])
+	}
+}
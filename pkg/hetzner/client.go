@@ -3,29 +3,117 @@ package hetzner
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sort"
 	"time"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/magenx/hek3ster/pkg/version"
 )
 
+const (
+	// defaultActionPollInterval is how often a pending action is checked
+	defaultActionPollInterval = 1 * time.Second
+	// defaultPollInterval is how often deletions and server status changes are checked
+	defaultPollInterval = 2 * time.Second
+)
+
 // Client wraps the Hetzner Cloud client
 type Client struct {
-	hcloud *hcloud.Client
-	token  string
+	hcloud  *hcloud.Client
+	token   string
+	tracker *ResourceTracker
+
+	actionPollInterval time.Duration
+	pollInterval       time.Duration
+	locationFallback   bool
+
+	// Server types, locations and images do not change during a run
+	serverTypes *lookupCache[*hcloud.ServerType]
+	locations   *lookupCache[*hcloud.Location]
+	images      *lookupCache[*hcloud.Image]
+}
+
+// clientOptions holds the settings applied by ClientOption
+type clientOptions struct {
+	endpoint         string
+	pollInterval     time.Duration
+	maxRetries       int
+	retryBaseDelay   time.Duration
+	locationFallback bool
+}
+
+// ClientOption configures a Client
+type ClientOption func(*clientOptions)
+
+// WithEndpoint points the client at a custom API endpoint, e.g. a local fake of the
+// Hetzner Cloud API. An empty endpoint keeps the default.
+func WithEndpoint(endpoint string) ClientOption {
+	return func(o *clientOptions) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithPollInterval sets how often actions, deletions and server status changes are polled
+// A zero interval keeps the defaults.
+func WithPollInterval(interval time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.pollInterval = interval
+	}
+}
+
+// WithRetries sets how often a rate-limited or failed request is retried and the delay
+// before the first retry, which doubles for every further retry
+func WithRetries(maxRetries int, baseDelay time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.maxRetries = maxRetries
+		o.retryBaseDelay = baseDelay
+	}
+}
+
+// WithLocationFallback makes CreateServer try the other locations of the same network
+// zone when the requested location has no capacity for the server
+func WithLocationFallback(enabled bool) ClientOption {
+	return func(o *clientOptions) {
+		o.locationFallback = enabled
+	}
 }
 
 // NewClient creates a new Hetzner client
-func NewClient(token string) *Client {
+// Requests are retried with jittered exponential backoff when they are rate limited,
+// fail with a 5xx response or hit a transient error such as resource_unavailable.
+func NewClient(token string, options ...ClientOption) *Client {
+	o := clientOptions{maxRetries: defaultMaxRetries, retryBaseDelay: defaultRetryBaseDelay}
+	for _, option := range options {
+		option(&o)
+	}
+
+	// Retries are handled by retryTransport, which covers more errors than hcloud
 	opts := []hcloud.ClientOption{
 		hcloud.WithToken(token),
 		hcloud.WithApplication("hek3ster", version.Get()),
+		hcloud.WithHTTPClient(&http.Client{Transport: newRetryTransport(nil, o.maxRetries, o.retryBaseDelay)}),
+		hcloud.WithRetryOpts(hcloud.RetryOpts{MaxRetries: 0}),
+	}
+	if o.endpoint != "" {
+		opts = append(opts, hcloud.WithEndpoint(o.endpoint))
 	}
 
-	return &Client{
-		hcloud: hcloud.NewClient(opts...),
-		token:  token,
+	client := &Client{
+		hcloud:             hcloud.NewClient(opts...),
+		token:              token,
+		actionPollInterval: defaultActionPollInterval,
+		pollInterval:       defaultPollInterval,
+		locationFallback:   o.locationFallback,
+		serverTypes:        newLookupCache[*hcloud.ServerType](),
+		locations:          newLookupCache[*hcloud.Location](),
+		images:             newLookupCache[*hcloud.Image](),
+	}
+	if o.pollInterval > 0 {
+		client.actionPollInterval = o.pollInterval
+		client.pollInterval = o.pollInterval
 	}
+	return client
 }
 
 // GetLocations returns all available locations
@@ -47,39 +135,67 @@ func (c *Client) GetServerTypes(ctx context.Context) ([]*hcloud.ServerType, erro
 }
 
 // GetServerType returns a specific server type by name
+// Server types are fetched once per client and then served from a cache.
 func (c *Client) GetServerType(ctx context.Context, name string) (*hcloud.ServerType, error) {
-	serverType, _, err := c.hcloud.ServerType.GetByName(ctx, name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch server type %s: %w", name, err)
-	}
-	if serverType == nil {
-		return nil, fmt.Errorf("server type %s not found", name)
-	}
-	return serverType, nil
+	return c.serverTypes.get(ctx, name, func() (*hcloud.ServerType, error) {
+		serverType, _, err := c.hcloud.ServerType.GetByName(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch server type %s: %w", name, err)
+		}
+		if serverType == nil {
+			return nil, fmt.Errorf("server type %s not found", name)
+		}
+		return serverType, nil
+	})
 }
 
 // GetLocation returns a specific location by name
+// Locations are fetched once per client and then served from a cache.
 func (c *Client) GetLocation(ctx context.Context, name string) (*hcloud.Location, error) {
-	location, _, err := c.hcloud.Location.GetByName(ctx, name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch location %s: %w", name, err)
-	}
-	if location == nil {
-		return nil, fmt.Errorf("location %s not found", name)
-	}
-	return location, nil
+	return c.locations.get(ctx, name, func() (*hcloud.Location, error) {
+		location, _, err := c.hcloud.Location.GetByName(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch location %s: %w", name, err)
+		}
+		if location == nil {
+			return nil, fmt.Errorf("location %s not found", name)
+		}
+		return location, nil
+	})
+}
+
+// GetImage returns a specific image by name or ID for an architecture
+// System images of the same name exist for every architecture, so a name is resolved
+// to the image that runs on servers of the architecture. Images are fetched once per
+// client and architecture and then served from a cache. Snapshots have no name, so
+// they are found by their ID.
+func (c *Client) GetImage(ctx context.Context, nameOrID string, architecture hcloud.Architecture) (*hcloud.Image, error) {
+	return c.images.get(ctx, nameOrID+"/"+string(architecture), func() (*hcloud.Image, error) {
+		image, _, err := c.hcloud.Image.GetForArchitecture(ctx, nameOrID, architecture)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch image %s: %w", nameOrID, err)
+		}
+		if image == nil {
+			return nil, fmt.Errorf("image %s not found for %s", nameOrID, architecture)
+		}
+		return image, nil
+	})
 }
 
-// GetImage returns a specific image by name or ID
-func (c *Client) GetImage(ctx context.Context, nameOrID string) (*hcloud.Image, error) {
-	image, _, err := c.hcloud.Image.GetByName(ctx, nameOrID)
+// CreateImage creates an image of a server and waits until it is available
+func (c *Client) CreateImage(ctx context.Context, server *hcloud.Server, opts hcloud.ServerCreateImageOpts) (*hcloud.Image, error) {
+	result, _, err := c.hcloud.Server.CreateImage(ctx, server, &opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch image %s: %w", nameOrID, err)
+		return nil, fmt.Errorf("failed to create image of server %s: %w", server.Name, err)
 	}
-	if image == nil {
-		return nil, fmt.Errorf("image %s not found", nameOrID)
+
+	if result.Action != nil {
+		if err := c.waitForAction(ctx, result.Action); err != nil {
+			return nil, fmt.Errorf("image creation action failed: %w", err)
+		}
 	}
-	return image, nil
+
+	return result.Image, nil
 }
 
 // ListServers returns all servers matching the label selector
@@ -101,11 +217,17 @@ func (c *Client) GetServer(ctx context.Context, name string) (*hcloud.Server, er
 }
 
 // CreateServer creates a new server
+// With location fallback enabled, a server that cannot be placed in its location is
+// created in another location of the same network zone instead.
 func (c *Client) CreateServer(ctx context.Context, opts hcloud.ServerCreateOpts) (*hcloud.Server, error) {
 	result, _, err := c.hcloud.Server.Create(ctx, opts)
+	if err != nil && c.locationFallback && opts.Location != nil && !isLocationBound(opts) && isCapacityError(err) {
+		result, err = c.createServerInFallbackLocation(ctx, opts, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server: %w", err)
 	}
+	c.track(ResourceServer, result.Server.ID, result.Server.Name)
 
 	// Wait for the action to complete
 	if result.Action != nil {
@@ -124,6 +246,65 @@ func (c *Client) CreateServer(ctx context.Context, opts hcloud.ServerCreateOpts)
 	return result.Server, nil
 }
 
+// isLocationBound reports whether the server is created with an existing primary IP or
+// volume. Both are bound to their location, so such servers cannot move elsewhere.
+func isLocationBound(opts hcloud.ServerCreateOpts) bool {
+	return len(opts.Volumes) > 0 || opts.PublicNet != nil && (opts.PublicNet.IPv4 != nil || opts.PublicNet.IPv6 != nil)
+}
+
+// createServerInFallbackLocation creates a server in the other locations of its network
+// zone in turn, until one has capacity. It returns the original error if none has.
+func (c *Client) createServerInFallbackLocation(ctx context.Context, opts hcloud.ServerCreateOpts, createErr error) (hcloud.ServerCreateResult, error) {
+	locations, err := c.GetLocations(ctx)
+	if err != nil {
+		return hcloud.ServerCreateResult{}, createErr
+	}
+
+	for _, location := range fallbackLocations(locations, opts.Location) {
+		opts.Location = location
+		result, _, err := c.hcloud.Server.Create(ctx, opts)
+		if err == nil {
+			return result, nil
+		}
+		if !isCapacityError(err) {
+			return hcloud.ServerCreateResult{}, err
+		}
+	}
+	return hcloud.ServerCreateResult{}, createErr
+}
+
+// fallbackLocations returns the other locations in the network zone of a location, sorted by name
+func fallbackLocations(locations []*hcloud.Location, requested *hcloud.Location) []*hcloud.Location {
+	isRequested := func(location *hcloud.Location) bool {
+		return (requested.Name != "" && location.Name == requested.Name) || (requested.ID != 0 && location.ID == requested.ID)
+	}
+
+	zone := requested.NetworkZone
+	if zone == "" {
+		for _, location := range locations {
+			if isRequested(location) {
+				zone = location.NetworkZone
+			}
+		}
+	}
+
+	var fallbacks []*hcloud.Location
+	for _, location := range locations {
+		if location.NetworkZone == zone && !isRequested(location) {
+			fallbacks = append(fallbacks, location)
+		}
+	}
+	sort.Slice(fallbacks, func(i, j int) bool {
+		return fallbacks[i].Name < fallbacks[j].Name
+	})
+	return fallbacks
+}
+
+// isCapacityError reports whether a server could not be created for lack of capacity in its location
+func isCapacityError(err error) bool {
+	return hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable, hcloud.ErrorCodePlacementError)
+}
+
 // DeleteServer deletes a server
 func (c *Client) DeleteServer(ctx context.Context, server *hcloud.Server) error {
 	result, _, err := c.hcloud.Server.DeleteWithResult(ctx, server)
@@ -141,12 +322,27 @@ func (c *Client) DeleteServer(ctx context.Context, server *hcloud.Server) error
 	return nil
 }
 
+// ShutdownServer asks the operating system of a server to shut down
+// The action completes once the request was sent; use WaitForServerStatus to wait
+// until the server is off.
+func (c *Client) ShutdownServer(ctx context.Context, server *hcloud.Server) error {
+	action, _, err := c.hcloud.Server.Shutdown(ctx, server)
+	if err != nil {
+		return fmt.Errorf("failed to shut down server %s: %w", server.Name, err)
+	}
+	if err := c.waitForAction(ctx, action); err != nil {
+		return fmt.Errorf("server shutdown action failed: %w", err)
+	}
+	return nil
+}
+
 // CreateNetwork creates a new network
 func (c *Client) CreateNetwork(ctx context.Context, opts hcloud.NetworkCreateOpts) (*hcloud.Network, error) {
 	network, _, err := c.hcloud.Network.Create(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create network: %w", err)
 	}
+	c.track(ResourceNetwork, network.ID, network.Name)
 	return network, nil
 }
 
@@ -183,7 +379,7 @@ func (c *Client) DeleteNetwork(ctx context.Context, network *hcloud.Network) err
 
 	// Wait for network to actually be deleted
 	// Poll with timeout to prevent infinite loops on persistent API issues
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(c.pollInterval)
 	defer ticker.Stop()
 
 	timeout := time.NewTimer(2 * time.Minute)
@@ -221,6 +417,7 @@ func (c *Client) CreateSSHKey(ctx context.Context, opts hcloud.SSHKeyCreateOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SSH key: %w", err)
 	}
+	c.track(ResourceSSHKey, sshKey.ID, sshKey.Name)
 	return sshKey, nil
 }
 
@@ -242,12 +439,194 @@ func (c *Client) DeleteSSHKey(ctx context.Context, sshKey *hcloud.SSHKey) error
 	return nil
 }
 
+// CreateFloatingIP creates a new floating IP
+func (c *Client) CreateFloatingIP(ctx context.Context, opts hcloud.FloatingIPCreateOpts) (*hcloud.FloatingIP, error) {
+	result, _, err := c.hcloud.FloatingIP.Create(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create floating IP: %w", err)
+	}
+	c.track(ResourceFloatingIP, result.FloatingIP.ID, result.FloatingIP.Name)
+
+	if result.Action != nil {
+		if err := c.waitForAction(ctx, result.Action); err != nil {
+			return nil, fmt.Errorf("floating IP creation action failed: %w", err)
+		}
+	}
+
+	return result.FloatingIP, nil
+}
+
+// GetFloatingIP returns a specific floating IP by name
+func (c *Client) GetFloatingIP(ctx context.Context, name string) (*hcloud.FloatingIP, error) {
+	floatingIP, _, err := c.hcloud.FloatingIP.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch floating IP %s: %w", name, err)
+	}
+	return floatingIP, nil
+}
+
+// ListFloatingIPs returns all floating IPs matching the label selector
+func (c *Client) ListFloatingIPs(ctx context.Context, opts hcloud.FloatingIPListOpts) ([]*hcloud.FloatingIP, error) {
+	floatingIPs, err := c.hcloud.FloatingIP.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list floating IPs: %w", err)
+	}
+	return floatingIPs, nil
+}
+
+// AssignFloatingIP assigns a floating IP to a server
+func (c *Client) AssignFloatingIP(ctx context.Context, floatingIP *hcloud.FloatingIP, server *hcloud.Server) error {
+	action, _, err := c.hcloud.FloatingIP.Assign(ctx, floatingIP, server)
+	if err != nil {
+		return fmt.Errorf("failed to assign floating IP %s to %s: %w", floatingIP.Name, server.Name, err)
+	}
+	if err := c.waitForAction(ctx, action); err != nil {
+		return fmt.Errorf("floating IP assign action failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteFloatingIP deletes a floating IP
+func (c *Client) DeleteFloatingIP(ctx context.Context, floatingIP *hcloud.FloatingIP) error {
+	_, err := c.hcloud.FloatingIP.Delete(ctx, floatingIP)
+	if err != nil {
+		return fmt.Errorf("failed to delete floating IP %s: %w", floatingIP.Name, err)
+	}
+	return nil
+}
+
+// CreatePrimaryIP creates a new primary IP
+func (c *Client) CreatePrimaryIP(ctx context.Context, opts hcloud.PrimaryIPCreateOpts) (*hcloud.PrimaryIP, error) {
+	result, _, err := c.hcloud.PrimaryIP.Create(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create primary IP: %w", err)
+	}
+	c.track(ResourcePrimaryIP, result.PrimaryIP.ID, result.PrimaryIP.Name)
+
+	if result.Action != nil {
+		if err := c.waitForAction(ctx, result.Action); err != nil {
+			return nil, fmt.Errorf("primary IP creation action failed: %w", err)
+		}
+	}
+
+	return result.PrimaryIP, nil
+}
+
+// GetPrimaryIP returns a specific primary IP by name
+func (c *Client) GetPrimaryIP(ctx context.Context, name string) (*hcloud.PrimaryIP, error) {
+	primaryIP, _, err := c.hcloud.PrimaryIP.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch primary IP %s: %w", name, err)
+	}
+	return primaryIP, nil
+}
+
+// ListPrimaryIPs returns all primary IPs matching the label selector
+func (c *Client) ListPrimaryIPs(ctx context.Context, opts hcloud.PrimaryIPListOpts) ([]*hcloud.PrimaryIP, error) {
+	primaryIPs, err := c.hcloud.PrimaryIP.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list primary IPs: %w", err)
+	}
+	return primaryIPs, nil
+}
+
+// DeletePrimaryIP deletes a primary IP
+func (c *Client) DeletePrimaryIP(ctx context.Context, primaryIP *hcloud.PrimaryIP) error {
+	_, err := c.hcloud.PrimaryIP.Delete(ctx, primaryIP)
+	if err != nil {
+		return fmt.Errorf("failed to delete primary IP %s: %w", primaryIP.Name, err)
+	}
+	return nil
+}
+
+// CreateVolume creates a new volume
+func (c *Client) CreateVolume(ctx context.Context, opts hcloud.VolumeCreateOpts) (*hcloud.Volume, error) {
+	result, _, err := c.hcloud.Volume.Create(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	c.track(ResourceVolume, result.Volume.ID, result.Volume.Name)
+
+	if result.Action != nil {
+		if err := c.waitForAction(ctx, result.Action); err != nil {
+			return nil, fmt.Errorf("volume creation action failed: %w", err)
+		}
+	}
+
+	return result.Volume, nil
+}
+
+// GetVolume returns a specific volume by name
+func (c *Client) GetVolume(ctx context.Context, name string) (*hcloud.Volume, error) {
+	volume, _, err := c.hcloud.Volume.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch volume %s: %w", name, err)
+	}
+	return volume, nil
+}
+
+// ListVolumes returns all volumes matching the label selector
+func (c *Client) ListVolumes(ctx context.Context, opts hcloud.VolumeListOpts) ([]*hcloud.Volume, error) {
+	volumes, err := c.hcloud.Volume.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	return volumes, nil
+}
+
+// DeleteVolume deletes a volume
+func (c *Client) DeleteVolume(ctx context.Context, volume *hcloud.Volume) error {
+	_, err := c.hcloud.Volume.Delete(ctx, volume)
+	if err != nil {
+		return fmt.Errorf("failed to delete volume %s: %w", volume.Name, err)
+	}
+	return nil
+}
+
+// CreatePlacementGroup creates a new placement group
+func (c *Client) CreatePlacementGroup(ctx context.Context, opts hcloud.PlacementGroupCreateOpts) (*hcloud.PlacementGroup, error) {
+	result, _, err := c.hcloud.PlacementGroup.Create(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create placement group: %w", err)
+	}
+	c.track(ResourcePlacementGroup, result.PlacementGroup.ID, result.PlacementGroup.Name)
+	return result.PlacementGroup, nil
+}
+
+// GetPlacementGroup returns a specific placement group by name
+func (c *Client) GetPlacementGroup(ctx context.Context, name string) (*hcloud.PlacementGroup, error) {
+	placementGroup, _, err := c.hcloud.PlacementGroup.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch placement group %s: %w", name, err)
+	}
+	return placementGroup, nil
+}
+
+// ListPlacementGroups returns all placement groups matching the label selector
+func (c *Client) ListPlacementGroups(ctx context.Context, opts hcloud.PlacementGroupListOpts) ([]*hcloud.PlacementGroup, error) {
+	placementGroups, err := c.hcloud.PlacementGroup.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list placement groups: %w", err)
+	}
+	return placementGroups, nil
+}
+
+// DeletePlacementGroup deletes a placement group
+func (c *Client) DeletePlacementGroup(ctx context.Context, placementGroup *hcloud.PlacementGroup) error {
+	_, err := c.hcloud.PlacementGroup.Delete(ctx, placementGroup)
+	if err != nil {
+		return fmt.Errorf("failed to delete placement group %s: %w", placementGroup.Name, err)
+	}
+	return nil
+}
+
 // CreateFirewall creates a new firewall
 func (c *Client) CreateFirewall(ctx context.Context, opts hcloud.FirewallCreateOpts) (*hcloud.Firewall, error) {
 	result, _, err := c.hcloud.Firewall.Create(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create firewall: %w", err)
 	}
+	c.track(ResourceFirewall, result.Firewall.ID, result.Firewall.Name)
 
 	// Wait for any actions to complete
 	if len(result.Actions) > 0 {
@@ -277,7 +656,7 @@ func (c *Client) DeleteFirewall(ctx context.Context, firewall *hcloud.Firewall)
 
 	// Wait for firewall to actually be deleted
 	// Poll with timeout to prevent infinite loops on persistent API issues
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(c.pollInterval)
 	defer ticker.Stop()
 
 	timeout := time.NewTimer(2 * time.Minute)
@@ -315,6 +694,7 @@ func (c *Client) CreateLoadBalancer(ctx context.Context, opts hcloud.LoadBalance
 	if err != nil {
 		return nil, fmt.Errorf("failed to create load balancer: %w", err)
 	}
+	c.track(ResourceLoadBalancer, result.LoadBalancer.ID, result.LoadBalancer.Name)
 
 	// Wait for the action to complete
 	if err := c.waitForAction(ctx, result.Action); err != nil {
@@ -342,7 +722,7 @@ func (c *Client) DeleteLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer
 
 	// Wait for load balancer to actually be deleted
 	// Poll with timeout to prevent infinite loops on persistent API issues
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(c.pollInterval)
 	defer ticker.Stop()
 
 	timeout := time.NewTimer(2 * time.Minute)
@@ -425,7 +805,7 @@ func (c *Client) waitForAction(ctx context.Context, action *hcloud.Action) error
 		return nil
 	}
 
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(c.actionPollInterval)
 	defer ticker.Stop()
 
 	for {
@@ -464,7 +844,7 @@ func (c *Client) WaitForServerStatus(ctx context.Context, server *hcloud.Server,
 		return fmt.Errorf("server is nil")
 	}
 
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(c.pollInterval)
 	defer ticker.Stop()
 
 	timeoutTimer := time.NewTimer(timeout)
@@ -513,6 +893,7 @@ func (c *Client) CreateZone(ctx context.Context, opts hcloud.ZoneCreateOpts) (*h
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zone: %w", err)
 	}
+	c.track(ResourceZone, result.Zone.ID, result.Zone.Name)
 
 	// Wait for the action to complete if present
 	if result.Action != nil {
@@ -539,7 +920,7 @@ func (c *Client) DeleteZone(ctx context.Context, zone *hcloud.Zone) error {
 	}
 
 	// Wait for zone to actually be deleted
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(c.pollInterval)
 	defer ticker.Stop()
 
 	timeout := time.NewTimer(2 * time.Minute)
@@ -631,6 +1012,7 @@ func (c *Client) CreateManagedCertificate(ctx context.Context, opts hcloud.Certi
 	if err != nil {
 		return nil, fmt.Errorf("failed to create certificate: %w", err)
 	}
+	c.track(ResourceCertificate, result.Certificate.ID, result.Certificate.Name)
 
 	// Note: We don't wait for certificate to be issued as it can take up to 5 minutes
 	// Hetzner will issue it in the background using DNS validation
@@ -654,7 +1036,7 @@ func (c *Client) DeleteCertificate(ctx context.Context, cert *hcloud.Certificate
 	}
 
 	// Wait for certificate to actually be deleted
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(c.pollInterval)
 	defer ticker.Stop()
 
 	timeout := time.NewTimer(2 * time.Minute)
@@ -2,6 +2,7 @@ package hetzner
 
 import (
 	"testing"
+	"time"
 
 	"github.com/magenx/hek3ster/pkg/version"
 )
@@ -47,3 +48,42 @@ func TestNewClient_WithEmptyToken(t *testing.T) {
 		t.Errorf("expected empty token, got '%s'", client.token)
 	}
 }
+
+func TestNewClient_Options(t *testing.T) {
+	tests := []struct {
+		name               string
+		options            []ClientOption
+		wantActionInterval time.Duration
+		wantPollInterval   time.Duration
+	}{
+		{
+			name:               "defaults",
+			wantActionInterval: defaultActionPollInterval,
+			wantPollInterval:   defaultPollInterval,
+		},
+		{
+			name:               "empty endpoint and zero interval keep the defaults",
+			options:            []ClientOption{WithEndpoint(""), WithPollInterval(0)},
+			wantActionInterval: defaultActionPollInterval,
+			wantPollInterval:   defaultPollInterval,
+		},
+		{
+			name:               "custom endpoint and poll interval",
+			options:            []ClientOption{WithEndpoint("http://127.0.0.1:8080"), WithPollInterval(10 * time.Millisecond)},
+			wantActionInterval: 10 * time.Millisecond,
+			wantPollInterval:   10 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("test-token", tt.options...)
+			if client.actionPollInterval != tt.wantActionInterval {
+				t.Errorf("actionPollInterval = %v, want %v", client.actionPollInterval, tt.wantActionInterval)
+			}
+			if client.pollInterval != tt.wantPollInterval {
+				t.Errorf("pollInterval = %v, want %v", client.pollInterval, tt.wantPollInterval)
+			}
+		})
+	}
+}
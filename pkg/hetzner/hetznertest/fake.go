@@ -0,0 +1,1882 @@
+// Package hetznertest provides test doubles for the Hetzner Cloud API
+package hetznertest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+// Fake is an in-memory implementation of hetzner.API
+// It keeps servers, networks, SSH keys, primary and floating IPs, placement groups, firewalls,
+// load balancers, DNS zones and certificates in memory and records a completed action for every change. Lookups by
+// name return nil without an error for missing resources, like the Hetzner client.
+// Fake is safe for concurrent use.
+type Fake struct {
+	mu sync.Mutex
+
+	nextID   int64
+	nextIPv4 int
+
+	locations   []*hcloud.Location
+	serverTypes []*hcloud.ServerType
+	images      []*hcloud.Image
+
+	servers       map[int64]*hcloud.Server
+	networks      map[int64]*hcloud.Network
+	sshKeys       map[int64]*hcloud.SSHKey
+	primaryIPs    map[int64]*hcloud.PrimaryIP
+	floatingIPs   map[int64]*hcloud.FloatingIP
+	volumes       map[int64]*hcloud.Volume
+	placements    map[int64]*hcloud.PlacementGroup
+	firewalls     map[int64]*hcloud.Firewall
+	loadBalancers map[int64]*hcloud.LoadBalancer
+	zones         map[int64]*hcloud.Zone
+	rrsets        map[string]*hcloud.ZoneRRSet
+	certificates  map[int64]*hcloud.Certificate
+
+	// networkIPs counts the private IPs handed out per network
+	networkIPs map[int64]int
+
+	actions  []*hcloud.Action
+	failures map[string]error
+	tracker  *hetzner.ResourceTracker
+
+	// unavailableLocations have no capacity left for new servers
+	unavailableLocations map[string]bool
+}
+
+// maxSpreadGroupServers is the number of servers a spread placement group holds
+const maxSpreadGroupServers = 10
+
+// Fake must keep implementing hetzner.API
+var _ hetzner.API = (*Fake)(nil)
+
+// NewFake creates an empty fake with the common locations, server types and images
+func NewFake() *Fake {
+	f := &Fake{
+		servers:       make(map[int64]*hcloud.Server),
+		networks:      make(map[int64]*hcloud.Network),
+		sshKeys:       make(map[int64]*hcloud.SSHKey),
+		primaryIPs:    make(map[int64]*hcloud.PrimaryIP),
+		floatingIPs:   make(map[int64]*hcloud.FloatingIP),
+		volumes:       make(map[int64]*hcloud.Volume),
+		placements:    make(map[int64]*hcloud.PlacementGroup),
+		firewalls:     make(map[int64]*hcloud.Firewall),
+		loadBalancers: make(map[int64]*hcloud.LoadBalancer),
+		zones:         make(map[int64]*hcloud.Zone),
+		rrsets:        make(map[string]*hcloud.ZoneRRSet),
+		certificates:  make(map[int64]*hcloud.Certificate),
+		networkIPs:    make(map[int64]int),
+		failures:      make(map[string]error),
+
+		unavailableLocations: make(map[string]bool),
+	}
+
+	for _, location := range []struct {
+		name string
+		zone hcloud.NetworkZone
+	}{
+		{"fsn1", hcloud.NetworkZoneEUCentral},
+		{"nbg1", hcloud.NetworkZoneEUCentral},
+		{"hel1", hcloud.NetworkZoneEUCentral},
+		{"ash", hcloud.NetworkZoneUSEast},
+		{"hil", hcloud.NetworkZoneUSWest},
+		{"sin", hcloud.NetworkZoneAPSouthEast},
+	} {
+		f.AddLocation(&hcloud.Location{Name: location.name, NetworkZone: location.zone})
+	}
+
+	for _, serverType := range []struct {
+		name         string
+		cores        int
+		memory       float32
+		architecture hcloud.Architecture
+	}{
+		{"cx22", 2, 4, hcloud.ArchitectureX86},
+		{"cx32", 4, 8, hcloud.ArchitectureX86},
+		{"cpx11", 2, 2, hcloud.ArchitectureX86},
+		{"cpx21", 3, 4, hcloud.ArchitectureX86},
+		{"cpx31", 4, 8, hcloud.ArchitectureX86},
+		{"cax11", 2, 4, hcloud.ArchitectureARM},
+		{"cax21", 4, 8, hcloud.ArchitectureARM},
+	} {
+		f.AddServerType(&hcloud.ServerType{Name: serverType.name, Cores: serverType.cores,
+			Memory: serverType.memory, Architecture: serverType.architecture})
+	}
+
+	for _, architecture := range []hcloud.Architecture{hcloud.ArchitectureX86, hcloud.ArchitectureARM} {
+		for _, image := range []string{"ubuntu-24.04", "ubuntu-22.04", "debian-12"} {
+			f.AddImage(&hcloud.Image{Name: image, Type: hcloud.ImageTypeSystem, Status: hcloud.ImageStatusAvailable, Architecture: architecture})
+		}
+	}
+
+	return f
+}
+
+// AddLocation makes a location available
+func (f *Fake) AddLocation(location *hcloud.Location) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if location.ID == 0 {
+		location.ID = f.newID()
+	}
+	f.locations = append(f.locations, location)
+}
+
+// AddServerType makes a server type available
+func (f *Fake) AddServerType(serverType *hcloud.ServerType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if serverType.ID == 0 {
+		serverType.ID = f.newID()
+	}
+	f.serverTypes = append(f.serverTypes, serverType)
+}
+
+// AddImage makes an image available
+func (f *Fake) AddImage(image *hcloud.Image) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if image.ID == 0 {
+		image.ID = f.newID()
+	}
+	f.images = append(f.images, image)
+}
+
+// FailOn makes every call of an API method fail with err, e.g. FailOn("CreateServer", err)
+// Passing a nil error clears the failure.
+func (f *Fake) FailOn(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.failures, method)
+		return
+	}
+	f.failures[method] = err
+}
+
+// SetLocationUnavailable makes server creation in a location fail with resource_unavailable
+func (f *Fake) SetLocationUnavailable(name string, unavailable bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unavailableLocations[name] = unavailable
+}
+
+// Servers returns the servers sorted by name
+func (f *Fake) Servers() []*hcloud.Server {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sortedServers()
+}
+
+// Networks returns the networks sorted by name
+func (f *Fake) Networks() []*hcloud.Network {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.networks, func(n *hcloud.Network) string { return n.Name })
+}
+
+// SSHKeys returns the SSH keys sorted by name
+func (f *Fake) SSHKeys() []*hcloud.SSHKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.sshKeys, func(k *hcloud.SSHKey) string { return k.Name })
+}
+
+// PrimaryIPs returns the primary IPs sorted by name
+func (f *Fake) PrimaryIPs() []*hcloud.PrimaryIP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.primaryIPs, func(p *hcloud.PrimaryIP) string { return p.Name })
+}
+
+// Volumes returns the volumes sorted by name
+func (f *Fake) Volumes() []*hcloud.Volume {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.volumes, func(v *hcloud.Volume) string { return v.Name })
+}
+
+// FloatingIPs returns the floating IPs sorted by name
+func (f *Fake) FloatingIPs() []*hcloud.FloatingIP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.floatingIPs, func(fip *hcloud.FloatingIP) string { return fip.Name })
+}
+
+// PlacementGroups returns the placement groups sorted by name
+func (f *Fake) PlacementGroups() []*hcloud.PlacementGroup {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.placements, func(p *hcloud.PlacementGroup) string { return p.Name })
+}
+
+// Firewalls returns the firewalls sorted by name
+func (f *Fake) Firewalls() []*hcloud.Firewall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.firewalls, func(fw *hcloud.Firewall) string { return fw.Name })
+}
+
+// LoadBalancers returns the load balancers sorted by name
+func (f *Fake) LoadBalancers() []*hcloud.LoadBalancer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.loadBalancers, func(lb *hcloud.LoadBalancer) string { return lb.Name })
+}
+
+// Zones returns the DNS zones sorted by name
+func (f *Fake) Zones() []*hcloud.Zone {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.zones, func(z *hcloud.Zone) string { return z.Name })
+}
+
+// Certificates returns the certificates sorted by name
+func (f *Fake) Certificates() []*hcloud.Certificate {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedCopies(f.certificates, func(c *hcloud.Certificate) string { return c.Name })
+}
+
+// Actions returns the recorded actions in the order they ran
+func (f *Fake) Actions() []*hcloud.Action {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	actions := make([]*hcloud.Action, len(f.actions))
+	for i, action := range f.actions {
+		a := *action
+		actions[i] = &a
+	}
+	return actions
+}
+
+// ActionCommands returns the commands of the recorded actions, e.g. "create_server"
+func (f *Fake) ActionCommands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commands := make([]string, len(f.actions))
+	for i, action := range f.actions {
+		commands[i] = action.Command
+	}
+	return commands
+}
+
+// GetLocations returns all available locations
+func (f *Fake) GetLocations(ctx context.Context) ([]*hcloud.Location, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetLocations"); err != nil {
+		return nil, err
+	}
+	return append([]*hcloud.Location(nil), f.locations...), nil
+}
+
+// GetLocation returns a specific location by name
+func (f *Fake) GetLocation(ctx context.Context, name string) (*hcloud.Location, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetLocation"); err != nil {
+		return nil, err
+	}
+	if location := f.location(name); location != nil {
+		return location, nil
+	}
+	return nil, fmt.Errorf("location %s not found", name)
+}
+
+// GetServerTypes returns all available server types
+func (f *Fake) GetServerTypes(ctx context.Context) ([]*hcloud.ServerType, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetServerTypes"); err != nil {
+		return nil, err
+	}
+	return append([]*hcloud.ServerType(nil), f.serverTypes...), nil
+}
+
+// GetServerType returns a specific server type by name
+func (f *Fake) GetServerType(ctx context.Context, name string) (*hcloud.ServerType, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetServerType"); err != nil {
+		return nil, err
+	}
+	if serverType := f.serverType(name); serverType != nil {
+		return serverType, nil
+	}
+	return nil, fmt.Errorf("server type %s not found", name)
+}
+
+// GetImage returns a specific image by ID, or by name for an architecture
+func (f *Fake) GetImage(ctx context.Context, nameOrID string, architecture hcloud.Architecture) (*hcloud.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetImage"); err != nil {
+		return nil, err
+	}
+	if image := f.image(nameOrID, architecture); image != nil {
+		return image, nil
+	}
+	return nil, fmt.Errorf("image %s not found for %s", nameOrID, architecture)
+}
+
+// CreateImage creates a snapshot of a server; fake snapshots are available at once
+func (f *Fake) CreateImage(ctx context.Context, server *hcloud.Server, opts hcloud.ServerCreateImageOpts) (*hcloud.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateImage"); err != nil {
+		return nil, fmt.Errorf("failed to create image of server %s: %w", server.Name, err)
+	}
+	current, ok := f.servers[server.ID]
+	if !ok {
+		return nil, fmt.Errorf("failed to create image of server %s: %w", server.Name, notFound("server not found"))
+	}
+	if opts.Type != "" && opts.Type != hcloud.ImageTypeSnapshot && opts.Type != hcloud.ImageTypeBackup {
+		return nil, fmt.Errorf("failed to create image of server %s: %w", server.Name, invalidInput("invalid image type "+string(opts.Type)))
+	}
+
+	image := &hcloud.Image{
+		ID:          f.newID(),
+		Type:        hcloud.ImageTypeSnapshot,
+		Status:      hcloud.ImageStatusAvailable,
+		Created:     time.Now(),
+		CreatedFrom: &hcloud.Server{ID: current.ID, Name: current.Name},
+		Labels:      copyLabels(opts.Labels),
+	}
+	if opts.Type != "" {
+		image.Type = opts.Type
+	}
+	if opts.Description != nil {
+		image.Description = *opts.Description
+	}
+	if current.Image != nil {
+		image.OSFlavor = current.Image.OSFlavor
+		image.OSVersion = current.Image.OSVersion
+	}
+	if current.ServerType != nil {
+		image.Architecture = current.ServerType.Architecture
+	}
+
+	f.images = append(f.images, image)
+	f.record("create_image", current.ID, hcloud.ActionResourceTypeServer)
+	return copyImage(image), nil
+}
+
+// ListServers returns all servers matching the label selector, sorted by name
+func (f *Fake) ListServers(ctx context.Context, opts hcloud.ServerListOpts) ([]*hcloud.Server, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ListServers"); err != nil {
+		return nil, fmt.Errorf("failed to list servers: %w", err)
+	}
+
+	var servers []*hcloud.Server
+	for _, server := range f.sortedServers() {
+		if opts.Name != "" && server.Name != opts.Name {
+			continue
+		}
+		if !MatchLabelSelector(opts.LabelSelector, server.Labels) {
+			continue
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// GetServer returns a specific server by name
+func (f *Fake) GetServer(ctx context.Context, name string) (*hcloud.Server, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetServer"); err != nil {
+		return nil, fmt.Errorf("failed to fetch server %s: %w", name, err)
+	}
+	for _, server := range f.servers {
+		if server.Name == name {
+			return copyServer(server), nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateServer creates a running server with a public IPv4 address
+// Servers attached to networks get the next free private IP of each network.
+func (f *Fake) CreateServer(ctx context.Context, opts hcloud.ServerCreateOpts) (*hcloud.Server, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateServer"); err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	if opts.Name == "" {
+		return nil, fmt.Errorf("failed to create server: %w", invalidInput("missing name"))
+	}
+	for _, server := range f.servers {
+		if server.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create server: %w", uniquenessError("server name is already used"))
+		}
+	}
+
+	server := &hcloud.Server{
+		ID:      f.newID(),
+		Name:    opts.Name,
+		Status:  hcloud.ServerStatusRunning,
+		Created: time.Now(),
+		Labels:  copyLabels(opts.Labels),
+	}
+	if opts.StartAfterCreate != nil && !*opts.StartAfterCreate {
+		server.Status = hcloud.ServerStatusOff
+	}
+
+	if opts.ServerType != nil {
+		server.ServerType = f.serverType(opts.ServerType.Name)
+		if server.ServerType == nil {
+			return nil, fmt.Errorf("failed to create server: %w", invalidInput("unknown server type "+opts.ServerType.Name))
+		}
+	}
+	if opts.Location != nil {
+		server.Location = f.location(opts.Location.Name)
+		if server.Location == nil {
+			return nil, fmt.Errorf("failed to create server: %w", invalidInput("unknown location "+opts.Location.Name))
+		}
+		if f.unavailableLocations[server.Location.Name] {
+			return nil, fmt.Errorf("failed to create server: %w", hcloud.Error{
+				Code: hcloud.ErrorCodeResourceUnavailable, Message: "server location disabled or out of capacity"})
+		}
+	}
+	if opts.Image != nil {
+		var architecture hcloud.Architecture
+		if server.ServerType != nil {
+			architecture = server.ServerType.Architecture
+		}
+		nameOrID := opts.Image.Name
+		if opts.Image.ID != 0 {
+			nameOrID = fmt.Sprint(opts.Image.ID)
+		}
+		server.Image = f.image(nameOrID, architecture)
+		if server.Image == nil {
+			return nil, fmt.Errorf("failed to create server: %w", invalidInput("unknown image "+nameOrID))
+		}
+		if server.Image.Architecture != "" && architecture != "" && server.Image.Architecture != architecture {
+			return nil, fmt.Errorf("failed to create server: %w", invalidInput(fmt.Sprintf(
+				"image %s is for %s, server type %s is %s", nameOrID, server.Image.Architecture, server.ServerType.Name, architecture)))
+		}
+	}
+
+	if opts.PlacementGroup != nil {
+		placementGroup, ok := f.placements[opts.PlacementGroup.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to create server: %w", notFound(fmt.Sprintf("placement group %d not found", opts.PlacementGroup.ID)))
+		}
+		if len(placementGroup.Servers) >= maxSpreadGroupServers {
+			return nil, fmt.Errorf("failed to create server: %w", hcloud.Error{
+				Code: hcloud.ErrorCodePlacementError, Message: "spread placement group is full"})
+		}
+		placementGroup.Servers = append(placementGroup.Servers, server.ID)
+		server.PlacementGroup = &hcloud.PlacementGroup{ID: placementGroup.ID, Name: placementGroup.Name, Type: placementGroup.Type}
+	}
+
+	if opts.PublicNet != nil && opts.PublicNet.IPv4 != nil {
+		primaryIP, ok := f.primaryIPs[opts.PublicNet.IPv4.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to create server: %w", notFound(fmt.Sprintf("primary IP %d not found", opts.PublicNet.IPv4.ID)))
+		}
+		if primaryIP.AssigneeID != 0 {
+			return nil, fmt.Errorf("failed to create server: %w", hcloud.Error{
+				Code: hcloud.ErrorCodePrimaryIPAssigned, Message: "primary IP is already assigned"})
+		}
+		if server.Location != nil && primaryIP.Location != nil && primaryIP.Location.Name != server.Location.Name {
+			return nil, fmt.Errorf("failed to create server: %w", hcloud.Error{
+				Code: hcloud.ErrorCodePrimaryIPDatacenterMismatch, Message: "primary IP is in another location"})
+		}
+		primaryIP.AssigneeID = server.ID
+		primaryIP.AssigneeType = "server"
+		server.PublicNet.IPv4 = hcloud.ServerPublicNetIPv4{ID: primaryIP.ID, IP: primaryIP.IP}
+	} else if opts.PublicNet == nil || opts.PublicNet.EnableIPv4 {
+		f.nextIPv4++
+		server.PublicNet.IPv4 = hcloud.ServerPublicNetIPv4{IP: net.IPv4(203, 0, 113, byte(f.nextIPv4))}
+	}
+
+	for _, opt := range opts.Volumes {
+		volume, ok := f.volumes[opt.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to create server: %w", notFound(fmt.Sprintf("volume %d not found", opt.ID)))
+		}
+		if volume.Server != nil {
+			return nil, fmt.Errorf("failed to create server: %w", hcloud.Error{
+				Code: hcloud.ErrorCodeVolumeAlreadyAttached, Message: "volume is already attached to a server"})
+		}
+		if server.Location != nil && volume.Location != nil && volume.Location.Name != server.Location.Name {
+			return nil, fmt.Errorf("failed to create server: %w", invalidInput("volume is in another location"))
+		}
+	}
+	for _, opt := range opts.Volumes {
+		volume := f.volumes[opt.ID]
+		volume.Server = &hcloud.Server{ID: server.ID}
+		server.Volumes = append(server.Volumes, &hcloud.Volume{ID: volume.ID})
+	}
+
+	for _, opt := range opts.Networks {
+		network, ok := f.networks[opt.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to create server: %w", notFound(fmt.Sprintf("network %d not found", opt.ID)))
+		}
+		server.PrivateNet = append(server.PrivateNet, hcloud.ServerPrivateNet{Network: network, IP: f.nextPrivateIP(network)})
+		network.Servers = append(network.Servers, &hcloud.Server{ID: server.ID})
+	}
+
+	for _, opt := range opts.Firewalls {
+		if firewall, ok := f.firewalls[opt.Firewall.ID]; ok {
+			firewall.AppliedTo = append(firewall.AppliedTo, hcloud.FirewallResource{
+				Type:   hcloud.FirewallResourceTypeServer,
+				Server: &hcloud.FirewallResourceServer{ID: server.ID},
+			})
+		}
+	}
+
+	f.servers[server.ID] = server
+	f.record("create_server", server.ID, hcloud.ActionResourceTypeServer)
+	f.track(hetzner.ResourceServer, server.ID, server.Name)
+	return copyServer(server), nil
+}
+
+// DeleteServer deletes a server and detaches it from its networks and load balancers
+func (f *Fake) DeleteServer(ctx context.Context, server *hcloud.Server) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteServer"); err != nil {
+		return fmt.Errorf("failed to delete server %s: %w", server.Name, err)
+	}
+	if _, ok := f.servers[server.ID]; !ok {
+		return fmt.Errorf("failed to delete server %s: %w", server.Name, notFound("server not found"))
+	}
+
+	delete(f.servers, server.ID)
+	for id, primaryIP := range f.primaryIPs {
+		if primaryIP.AssigneeID != server.ID {
+			continue
+		}
+		if primaryIP.AutoDelete {
+			delete(f.primaryIPs, id)
+			continue
+		}
+		primaryIP.AssigneeID = 0
+	}
+	for _, floatingIP := range f.floatingIPs {
+		if floatingIP.Server != nil && floatingIP.Server.ID == server.ID {
+			floatingIP.Server = nil
+		}
+	}
+	for _, volume := range f.volumes {
+		if volume.Server != nil && volume.Server.ID == server.ID {
+			volume.Server = nil
+		}
+	}
+	for _, placementGroup := range f.placements {
+		placementGroup.Servers = slices.DeleteFunc(placementGroup.Servers, func(id int64) bool { return id == server.ID })
+	}
+	for _, network := range f.networks {
+		network.Servers = removeServer(network.Servers, server.ID)
+	}
+	for _, lb := range f.loadBalancers {
+		var targets []hcloud.LoadBalancerTarget
+		for _, target := range lb.Targets {
+			if target.Server == nil || target.Server.Server.ID != server.ID {
+				targets = append(targets, target)
+			}
+		}
+		lb.Targets = targets
+	}
+	for _, firewall := range f.firewalls {
+		var applied []hcloud.FirewallResource
+		for _, resource := range firewall.AppliedTo {
+			if resource.Server == nil || resource.Server.ID != server.ID {
+				applied = append(applied, resource)
+			}
+		}
+		firewall.AppliedTo = applied
+	}
+
+	f.record("delete_server", server.ID, hcloud.ActionResourceTypeServer)
+	return nil
+}
+
+// ShutdownServer shuts a server down; fake servers are off at once
+func (f *Fake) ShutdownServer(ctx context.Context, server *hcloud.Server) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ShutdownServer"); err != nil {
+		return fmt.Errorf("failed to shut down server %s: %w", server.Name, err)
+	}
+	current, ok := f.servers[server.ID]
+	if !ok {
+		return fmt.Errorf("failed to shut down server %s: %w", server.Name, notFound("server not found"))
+	}
+	current.Status = hcloud.ServerStatusOff
+	f.record("shutdown_server", current.ID, hcloud.ActionResourceTypeServer)
+	return nil
+}
+
+// WaitForServerStatus returns once the server has the status; fake servers only change state when shut down
+func (f *Fake) WaitForServerStatus(ctx context.Context, server *hcloud.Server, targetStatus hcloud.ServerStatus, timeout time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("WaitForServerStatus"); err != nil {
+		return err
+	}
+	if server == nil {
+		return fmt.Errorf("server is nil")
+	}
+	current, ok := f.servers[server.ID]
+	if !ok {
+		return fmt.Errorf("server %s not found", server.Name)
+	}
+	if current.Status != targetStatus {
+		return fmt.Errorf("timeout waiting for server %s to reach status %s", server.Name, targetStatus)
+	}
+	return nil
+}
+
+// CreateNetwork creates a network
+func (f *Fake) CreateNetwork(ctx context.Context, opts hcloud.NetworkCreateOpts) (*hcloud.Network, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateNetwork"); err != nil {
+		return nil, fmt.Errorf("failed to create network: %w", err)
+	}
+	for _, network := range f.networks {
+		if network.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create network: %w", uniquenessError("network name is already used"))
+		}
+	}
+
+	network := &hcloud.Network{
+		ID:      f.newID(),
+		Name:    opts.Name,
+		Created: time.Now(),
+		IPRange: opts.IPRange,
+		Subnets: append([]hcloud.NetworkSubnet(nil), opts.Subnets...),
+		Routes:  append([]hcloud.NetworkRoute(nil), opts.Routes...),
+		Labels:  copyLabels(opts.Labels),
+	}
+	f.networks[network.ID] = network
+	f.track(hetzner.ResourceNetwork, network.ID, network.Name)
+	return copyNetwork(network), nil
+}
+
+// GetNetwork returns a specific network by name
+func (f *Fake) GetNetwork(ctx context.Context, name string) (*hcloud.Network, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetNetwork"); err != nil {
+		return nil, fmt.Errorf("failed to fetch network %s: %w", name, err)
+	}
+	for _, network := range f.networks {
+		if network.Name == name {
+			return copyNetwork(network), nil
+		}
+	}
+	return nil, nil
+}
+
+// AddRouteToNetwork adds a route to a network
+func (f *Fake) AddRouteToNetwork(ctx context.Context, network *hcloud.Network, opts hcloud.NetworkAddRouteOpts) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("AddRouteToNetwork"); err != nil {
+		return fmt.Errorf("failed to add route to network %s: %w", network.Name, err)
+	}
+	current, ok := f.networks[network.ID]
+	if !ok {
+		return fmt.Errorf("failed to add route to network %s: %w", network.Name, notFound("network not found"))
+	}
+	current.Routes = append(current.Routes, opts.Route)
+	f.record("add_route", current.ID, hcloud.ActionResourceType("network"))
+	return nil
+}
+
+// DeleteNetwork deletes a network
+func (f *Fake) DeleteNetwork(ctx context.Context, network *hcloud.Network) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteNetwork"); err != nil {
+		return fmt.Errorf("failed to delete network %s: %w", network.Name, err)
+	}
+	if _, ok := f.networks[network.ID]; !ok {
+		return fmt.Errorf("failed to delete network %s: %w", network.Name, notFound("network not found"))
+	}
+	delete(f.networks, network.ID)
+	return nil
+}
+
+// CreateSSHKey creates an SSH key
+func (f *Fake) CreateSSHKey(ctx context.Context, opts hcloud.SSHKeyCreateOpts) (*hcloud.SSHKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateSSHKey"); err != nil {
+		return nil, fmt.Errorf("failed to create SSH key: %w", err)
+	}
+	for _, key := range f.sshKeys {
+		if key.Name == opts.Name || key.PublicKey == opts.PublicKey {
+			return nil, fmt.Errorf("failed to create SSH key: %w", uniquenessError("SSH key not unique"))
+		}
+	}
+
+	key := &hcloud.SSHKey{
+		ID:        f.newID(),
+		Name:      opts.Name,
+		PublicKey: opts.PublicKey,
+		Labels:    copyLabels(opts.Labels),
+		Created:   time.Now(),
+	}
+	f.sshKeys[key.ID] = key
+	f.track(hetzner.ResourceSSHKey, key.ID, key.Name)
+	copied := *key
+	return &copied, nil
+}
+
+// GetSSHKey returns a specific SSH key by name
+func (f *Fake) GetSSHKey(ctx context.Context, name string) (*hcloud.SSHKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetSSHKey"); err != nil {
+		return nil, fmt.Errorf("failed to fetch SSH key %s: %w", name, err)
+	}
+	for _, key := range f.sshKeys {
+		if key.Name == name {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteSSHKey deletes an SSH key
+func (f *Fake) DeleteSSHKey(ctx context.Context, sshKey *hcloud.SSHKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteSSHKey"); err != nil {
+		return fmt.Errorf("failed to delete SSH key %s: %w", sshKey.Name, err)
+	}
+	if _, ok := f.sshKeys[sshKey.ID]; !ok {
+		return fmt.Errorf("failed to delete SSH key %s: %w", sshKey.Name, notFound("SSH key not found"))
+	}
+	delete(f.sshKeys, sshKey.ID)
+	return nil
+}
+
+// CreateFloatingIP creates a floating IP, assigned to a server if one is given
+func (f *Fake) CreateFloatingIP(ctx context.Context, opts hcloud.FloatingIPCreateOpts) (*hcloud.FloatingIP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateFloatingIP"); err != nil {
+		return nil, fmt.Errorf("failed to create floating IP: %w", err)
+	}
+
+	floatingIP := &hcloud.FloatingIP{
+		ID:      f.newID(),
+		Labels:  copyLabels(opts.Labels),
+		Created: time.Now(),
+		Type:    opts.Type,
+	}
+	if opts.Name != nil {
+		for _, existing := range f.floatingIPs {
+			if existing.Name == *opts.Name {
+				return nil, fmt.Errorf("failed to create floating IP: %w", uniquenessError("floating IP name is already used"))
+			}
+		}
+		floatingIP.Name = *opts.Name
+	}
+	if opts.Description != nil {
+		floatingIP.Description = *opts.Description
+	}
+	switch opts.Type {
+	case hcloud.FloatingIPTypeIPv4:
+		f.nextIPv4++
+		floatingIP.IP = net.IPv4(198, 51, 100, byte(f.nextIPv4))
+	case hcloud.FloatingIPTypeIPv6:
+		floatingIP.IP = net.ParseIP(fmt.Sprintf("2001:db8:%x::", floatingIP.ID))
+		floatingIP.Network = &net.IPNet{IP: floatingIP.IP, Mask: net.CIDRMask(64, 128)}
+	default:
+		return nil, fmt.Errorf("failed to create floating IP: %w", invalidInput("unknown floating IP type "+string(opts.Type)))
+	}
+
+	switch {
+	case opts.Server != nil:
+		server, ok := f.servers[opts.Server.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to create floating IP: %w", notFound(fmt.Sprintf("server %d not found", opts.Server.ID)))
+		}
+		floatingIP.Server = &hcloud.Server{ID: server.ID}
+		floatingIP.HomeLocation = server.Location
+	case opts.HomeLocation != nil:
+		floatingIP.HomeLocation = f.location(opts.HomeLocation.Name)
+		if floatingIP.HomeLocation == nil {
+			return nil, fmt.Errorf("failed to create floating IP: %w", invalidInput("unknown location "+opts.HomeLocation.Name))
+		}
+	default:
+		return nil, fmt.Errorf("failed to create floating IP: %w", invalidInput("home location or server is required"))
+	}
+
+	f.floatingIPs[floatingIP.ID] = floatingIP
+	f.record("create_floating_ip", floatingIP.ID, hcloud.ActionResourceTypeFloatingIP)
+	f.track(hetzner.ResourceFloatingIP, floatingIP.ID, floatingIP.Name)
+	return copyFloatingIP(floatingIP), nil
+}
+
+// GetFloatingIP returns a specific floating IP by name
+func (f *Fake) GetFloatingIP(ctx context.Context, name string) (*hcloud.FloatingIP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetFloatingIP"); err != nil {
+		return nil, fmt.Errorf("failed to fetch floating IP %s: %w", name, err)
+	}
+	for _, floatingIP := range f.floatingIPs {
+		if floatingIP.Name == name {
+			return copyFloatingIP(floatingIP), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListFloatingIPs returns the floating IPs matching the label selector
+func (f *Fake) ListFloatingIPs(ctx context.Context, opts hcloud.FloatingIPListOpts) ([]*hcloud.FloatingIP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ListFloatingIPs"); err != nil {
+		return nil, fmt.Errorf("failed to list floating IPs: %w", err)
+	}
+
+	var floatingIPs []*hcloud.FloatingIP
+	for _, floatingIP := range sortedCopies(f.floatingIPs, func(fip *hcloud.FloatingIP) string { return fip.Name }) {
+		if opts.Name != "" && floatingIP.Name != opts.Name {
+			continue
+		}
+		if !MatchLabelSelector(opts.LabelSelector, floatingIP.Labels) {
+			continue
+		}
+		floatingIPs = append(floatingIPs, copyFloatingIP(floatingIP))
+	}
+	return floatingIPs, nil
+}
+
+// AssignFloatingIP assigns a floating IP to a server, moving it from its current server
+func (f *Fake) AssignFloatingIP(ctx context.Context, floatingIP *hcloud.FloatingIP, server *hcloud.Server) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("AssignFloatingIP"); err != nil {
+		return fmt.Errorf("failed to assign floating IP %s to %s: %w", floatingIP.Name, server.Name, err)
+	}
+	stored, ok := f.floatingIPs[floatingIP.ID]
+	if !ok {
+		return fmt.Errorf("failed to assign floating IP %s to %s: %w", floatingIP.Name, server.Name, notFound("floating IP not found"))
+	}
+	if _, ok := f.servers[server.ID]; !ok {
+		return fmt.Errorf("failed to assign floating IP %s to %s: %w", floatingIP.Name, server.Name, notFound("server not found"))
+	}
+	stored.Server = &hcloud.Server{ID: server.ID}
+	f.record("assign_floating_ip", stored.ID, hcloud.ActionResourceTypeFloatingIP)
+	return nil
+}
+
+// DeleteFloatingIP deletes a floating IP
+func (f *Fake) DeleteFloatingIP(ctx context.Context, floatingIP *hcloud.FloatingIP) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteFloatingIP"); err != nil {
+		return fmt.Errorf("failed to delete floating IP %s: %w", floatingIP.Name, err)
+	}
+	if _, ok := f.floatingIPs[floatingIP.ID]; !ok {
+		return fmt.Errorf("failed to delete floating IP %s: %w", floatingIP.Name, notFound("floating IP not found"))
+	}
+	delete(f.floatingIPs, floatingIP.ID)
+	return nil
+}
+
+// CreateVolume creates a volume in a location, or attached to a server
+func (f *Fake) CreateVolume(ctx context.Context, opts hcloud.VolumeCreateOpts) (*hcloud.Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateVolume"); err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", invalidInput(err.Error()))
+	}
+	for _, volume := range f.volumes {
+		if volume.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create volume: %w", uniquenessError("volume name is already used"))
+		}
+	}
+
+	volume := &hcloud.Volume{
+		ID:      f.newID(),
+		Name:    opts.Name,
+		Status:  hcloud.VolumeStatusAvailable,
+		Size:    opts.Size,
+		Format:  opts.Format,
+		Labels:  copyLabels(opts.Labels),
+		Created: time.Now(),
+	}
+	volume.LinuxDevice = fmt.Sprintf("/dev/disk/by-id/scsi-0HC_Volume_%d", volume.ID)
+	if opts.Server != nil {
+		server, ok := f.servers[opts.Server.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to create volume: %w", notFound(fmt.Sprintf("server %d not found", opts.Server.ID)))
+		}
+		volume.Location = server.Location
+		volume.Server = &hcloud.Server{ID: server.ID}
+		server.Volumes = append(server.Volumes, &hcloud.Volume{ID: volume.ID})
+	} else {
+		volume.Location = f.location(opts.Location.Name)
+		if volume.Location == nil {
+			return nil, fmt.Errorf("failed to create volume: %w", invalidInput("unknown location "+opts.Location.Name))
+		}
+	}
+
+	f.volumes[volume.ID] = volume
+	f.record("create_volume", volume.ID, hcloud.ActionResourceTypeVolume)
+	f.track(hetzner.ResourceVolume, volume.ID, volume.Name)
+	return copyVolume(volume), nil
+}
+
+// GetVolume returns a specific volume by name
+func (f *Fake) GetVolume(ctx context.Context, name string) (*hcloud.Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetVolume"); err != nil {
+		return nil, fmt.Errorf("failed to fetch volume %s: %w", name, err)
+	}
+	for _, volume := range f.volumes {
+		if volume.Name == name {
+			return copyVolume(volume), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListVolumes returns the volumes matching the label selector
+func (f *Fake) ListVolumes(ctx context.Context, opts hcloud.VolumeListOpts) ([]*hcloud.Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ListVolumes"); err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	var volumes []*hcloud.Volume
+	for _, volume := range sortedCopies(f.volumes, func(v *hcloud.Volume) string { return v.Name }) {
+		if opts.Name != "" && volume.Name != opts.Name {
+			continue
+		}
+		if !MatchLabelSelector(opts.LabelSelector, volume.Labels) {
+			continue
+		}
+		volumes = append(volumes, copyVolume(volume))
+	}
+	return volumes, nil
+}
+
+// DeleteVolume deletes a volume that is not attached to a server
+func (f *Fake) DeleteVolume(ctx context.Context, volume *hcloud.Volume) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteVolume"); err != nil {
+		return fmt.Errorf("failed to delete volume %s: %w", volume.Name, err)
+	}
+	stored, ok := f.volumes[volume.ID]
+	if !ok {
+		return fmt.Errorf("failed to delete volume %s: %w", volume.Name, notFound("volume not found"))
+	}
+	if stored.Server != nil {
+		return fmt.Errorf("failed to delete volume %s: %w", volume.Name, hcloud.Error{
+			Code: hcloud.ErrorCodeConflict, Message: "volume is attached to a server"})
+	}
+	delete(f.volumes, volume.ID)
+	return nil
+}
+
+// CreatePrimaryIP creates a primary IPv4 or IPv6 address in a location
+// Primary IPs created with an assignee are assigned when the server is created with them.
+func (f *Fake) CreatePrimaryIP(ctx context.Context, opts hcloud.PrimaryIPCreateOpts) (*hcloud.PrimaryIP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreatePrimaryIP"); err != nil {
+		return nil, fmt.Errorf("failed to create primary IP: %w", err)
+	}
+	if opts.Name == "" {
+		return nil, fmt.Errorf("failed to create primary IP: %w", invalidInput("missing name"))
+	}
+	for _, primaryIP := range f.primaryIPs {
+		if primaryIP.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create primary IP: %w", uniquenessError("primary IP name is already used"))
+		}
+	}
+
+	primaryIP := &hcloud.PrimaryIP{
+		ID:           f.newID(),
+		Name:         opts.Name,
+		Labels:       copyLabels(opts.Labels),
+		Created:      time.Now(),
+		Type:         opts.Type,
+		AssigneeType: opts.AssigneeType,
+		AutoDelete:   boolValue(opts.AutoDelete),
+	}
+	switch opts.Type {
+	case hcloud.PrimaryIPTypeIPv4:
+		f.nextIPv4++
+		primaryIP.IP = net.IPv4(203, 0, 113, byte(f.nextIPv4))
+	case hcloud.PrimaryIPTypeIPv6:
+		primaryIP.IP = net.ParseIP(fmt.Sprintf("2001:db8:%x::", primaryIP.ID))
+		primaryIP.Network = &net.IPNet{IP: primaryIP.IP, Mask: net.CIDRMask(64, 128)}
+	default:
+		return nil, fmt.Errorf("failed to create primary IP: %w", invalidInput("unknown primary IP type "+string(opts.Type)))
+	}
+	if opts.Location != "" {
+		location := f.location(opts.Location)
+		if location == nil {
+			return nil, fmt.Errorf("failed to create primary IP: %w", invalidInput("unknown location "+opts.Location))
+		}
+		primaryIP.Location = location
+	}
+
+	f.primaryIPs[primaryIP.ID] = primaryIP
+	f.record("create_primary_ip", primaryIP.ID, hcloud.ActionResourceType("primary_ip"))
+	f.track(hetzner.ResourcePrimaryIP, primaryIP.ID, primaryIP.Name)
+	return copyPrimaryIP(primaryIP), nil
+}
+
+// GetPrimaryIP returns a specific primary IP by name
+func (f *Fake) GetPrimaryIP(ctx context.Context, name string) (*hcloud.PrimaryIP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetPrimaryIP"); err != nil {
+		return nil, fmt.Errorf("failed to fetch primary IP %s: %w", name, err)
+	}
+	for _, primaryIP := range f.primaryIPs {
+		if primaryIP.Name == name {
+			return copyPrimaryIP(primaryIP), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListPrimaryIPs returns the primary IPs matching the label selector
+func (f *Fake) ListPrimaryIPs(ctx context.Context, opts hcloud.PrimaryIPListOpts) ([]*hcloud.PrimaryIP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ListPrimaryIPs"); err != nil {
+		return nil, fmt.Errorf("failed to list primary IPs: %w", err)
+	}
+
+	var primaryIPs []*hcloud.PrimaryIP
+	for _, primaryIP := range sortedCopies(f.primaryIPs, func(p *hcloud.PrimaryIP) string { return p.Name }) {
+		if opts.Name != "" && primaryIP.Name != opts.Name {
+			continue
+		}
+		if !MatchLabelSelector(opts.LabelSelector, primaryIP.Labels) {
+			continue
+		}
+		primaryIPs = append(primaryIPs, copyPrimaryIP(primaryIP))
+	}
+	return primaryIPs, nil
+}
+
+// DeletePrimaryIP deletes a primary IP that is not assigned to a server
+func (f *Fake) DeletePrimaryIP(ctx context.Context, primaryIP *hcloud.PrimaryIP) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeletePrimaryIP"); err != nil {
+		return fmt.Errorf("failed to delete primary IP %s: %w", primaryIP.Name, err)
+	}
+	stored, ok := f.primaryIPs[primaryIP.ID]
+	if !ok {
+		return fmt.Errorf("failed to delete primary IP %s: %w", primaryIP.Name, notFound("primary IP not found"))
+	}
+	if stored.AssigneeID != 0 {
+		return fmt.Errorf("failed to delete primary IP %s: %w", primaryIP.Name, hcloud.Error{
+			Code: hcloud.ErrorCodePrimaryIPAssigned, Message: "primary IP is assigned to a server"})
+	}
+	delete(f.primaryIPs, primaryIP.ID)
+	return nil
+}
+
+// CreatePlacementGroup creates a placement group
+func (f *Fake) CreatePlacementGroup(ctx context.Context, opts hcloud.PlacementGroupCreateOpts) (*hcloud.PlacementGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreatePlacementGroup"); err != nil {
+		return nil, fmt.Errorf("failed to create placement group: %w", err)
+	}
+	if opts.Type != hcloud.PlacementGroupTypeSpread {
+		return nil, fmt.Errorf("failed to create placement group: %w", invalidInput("unknown placement group type "+string(opts.Type)))
+	}
+	for _, placementGroup := range f.placements {
+		if placementGroup.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create placement group: %w", uniquenessError("placement group name is already used"))
+		}
+	}
+
+	placementGroup := &hcloud.PlacementGroup{
+		ID:      f.newID(),
+		Name:    opts.Name,
+		Labels:  copyLabels(opts.Labels),
+		Created: time.Now(),
+		Servers: []int64{},
+		Type:    opts.Type,
+	}
+	f.placements[placementGroup.ID] = placementGroup
+	f.track(hetzner.ResourcePlacementGroup, placementGroup.ID, placementGroup.Name)
+	return copyPlacementGroup(placementGroup), nil
+}
+
+// GetPlacementGroup returns a specific placement group by name
+func (f *Fake) GetPlacementGroup(ctx context.Context, name string) (*hcloud.PlacementGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetPlacementGroup"); err != nil {
+		return nil, fmt.Errorf("failed to fetch placement group %s: %w", name, err)
+	}
+	for _, placementGroup := range f.placements {
+		if placementGroup.Name == name {
+			return copyPlacementGroup(placementGroup), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListPlacementGroups returns the placement groups matching the label selector
+func (f *Fake) ListPlacementGroups(ctx context.Context, opts hcloud.PlacementGroupListOpts) ([]*hcloud.PlacementGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ListPlacementGroups"); err != nil {
+		return nil, fmt.Errorf("failed to list placement groups: %w", err)
+	}
+
+	var placementGroups []*hcloud.PlacementGroup
+	for _, placementGroup := range sortedCopies(f.placements, func(p *hcloud.PlacementGroup) string { return p.Name }) {
+		if opts.Name != "" && placementGroup.Name != opts.Name {
+			continue
+		}
+		if !MatchLabelSelector(opts.LabelSelector, placementGroup.Labels) {
+			continue
+		}
+		placementGroups = append(placementGroups, copyPlacementGroup(placementGroup))
+	}
+	return placementGroups, nil
+}
+
+// DeletePlacementGroup deletes a placement group
+func (f *Fake) DeletePlacementGroup(ctx context.Context, placementGroup *hcloud.PlacementGroup) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeletePlacementGroup"); err != nil {
+		return fmt.Errorf("failed to delete placement group %s: %w", placementGroup.Name, err)
+	}
+	if _, ok := f.placements[placementGroup.ID]; !ok {
+		return fmt.Errorf("failed to delete placement group %s: %w", placementGroup.Name, notFound("placement group not found"))
+	}
+	for _, server := range f.servers {
+		if server.PlacementGroup != nil && server.PlacementGroup.ID == placementGroup.ID {
+			server.PlacementGroup = nil
+		}
+	}
+	delete(f.placements, placementGroup.ID)
+	return nil
+}
+
+// CreateFirewall creates a firewall
+func (f *Fake) CreateFirewall(ctx context.Context, opts hcloud.FirewallCreateOpts) (*hcloud.Firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateFirewall"); err != nil {
+		return nil, fmt.Errorf("failed to create firewall: %w", err)
+	}
+	for _, firewall := range f.firewalls {
+		if firewall.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create firewall: %w", uniquenessError("firewall name is already used"))
+		}
+	}
+
+	firewall := &hcloud.Firewall{
+		ID:        f.newID(),
+		Name:      opts.Name,
+		Labels:    copyLabels(opts.Labels),
+		Created:   time.Now(),
+		Rules:     append([]hcloud.FirewallRule(nil), opts.Rules...),
+		AppliedTo: append([]hcloud.FirewallResource(nil), opts.ApplyTo...),
+	}
+	f.firewalls[firewall.ID] = firewall
+	f.record("create_firewall", firewall.ID, hcloud.ActionResourceType("firewall"))
+	f.track(hetzner.ResourceFirewall, firewall.ID, firewall.Name)
+	return copyFirewall(firewall), nil
+}
+
+// GetFirewall returns a specific firewall by name
+func (f *Fake) GetFirewall(ctx context.Context, name string) (*hcloud.Firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetFirewall"); err != nil {
+		return nil, fmt.Errorf("failed to fetch firewall %s: %w", name, err)
+	}
+	for _, firewall := range f.firewalls {
+		if firewall.Name == name {
+			return copyFirewall(firewall), nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteFirewall deletes a firewall
+// Like the Hetzner API, it refuses to delete a firewall still applied to a server.
+func (f *Fake) DeleteFirewall(ctx context.Context, firewall *hcloud.Firewall) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteFirewall"); err != nil {
+		return fmt.Errorf("failed to delete firewall %s: %w", firewall.Name, err)
+	}
+	current, ok := f.firewalls[firewall.ID]
+	if !ok {
+		return fmt.Errorf("failed to delete firewall %s: %w", firewall.Name, notFound("firewall not found"))
+	}
+	for _, resource := range current.AppliedTo {
+		if resource.Type == hcloud.FirewallResourceTypeServer {
+			return fmt.Errorf("failed to delete firewall %s: %w", firewall.Name,
+				hcloud.Error{Code: hcloud.ErrorCodeResourceInUse, Message: "firewall is still in use"})
+		}
+	}
+	delete(f.firewalls, firewall.ID)
+	return nil
+}
+
+// CreateLoadBalancer creates a load balancer with a public IPv4 address
+func (f *Fake) CreateLoadBalancer(ctx context.Context, opts hcloud.LoadBalancerCreateOpts) (*hcloud.LoadBalancer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateLoadBalancer"); err != nil {
+		return nil, fmt.Errorf("failed to create load balancer: %w", err)
+	}
+	for _, lb := range f.loadBalancers {
+		if lb.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create load balancer: %w", uniquenessError("load balancer name is already used"))
+		}
+	}
+
+	f.nextIPv4++
+	lb := &hcloud.LoadBalancer{
+		ID:               f.newID(),
+		Name:             opts.Name,
+		LoadBalancerType: opts.LoadBalancerType,
+		Labels:           copyLabels(opts.Labels),
+		Created:          time.Now(),
+		PublicNet: hcloud.LoadBalancerPublicNet{
+			Enabled: opts.PublicInterface == nil || *opts.PublicInterface,
+			IPv4:    hcloud.LoadBalancerPublicNetIPv4{IP: net.IPv4(198, 51, 100, byte(f.nextIPv4))},
+		},
+	}
+	if opts.Location != nil {
+		lb.Location = f.location(opts.Location.Name)
+	}
+	if opts.Network != nil {
+		if network, ok := f.networks[opts.Network.ID]; ok {
+			lb.PrivateNet = []hcloud.LoadBalancerPrivateNet{{Network: network, IP: f.nextPrivateIP(network)}}
+			network.LoadBalancers = append(network.LoadBalancers, &hcloud.LoadBalancer{ID: lb.ID})
+		}
+	}
+	for _, service := range opts.Services {
+		lb.Services = append(lb.Services, loadBalancerService(service.Protocol, service.ListenPort, service.DestinationPort, service.Proxyprotocol))
+	}
+	for _, target := range opts.Targets {
+		switch target.Type {
+		case hcloud.LoadBalancerTargetTypeServer:
+			lb.Targets = append(lb.Targets, hcloud.LoadBalancerTarget{Type: target.Type,
+				Server: &hcloud.LoadBalancerTargetServer{Server: target.Server.Server}, UsePrivateIP: boolValue(target.UsePrivateIP)})
+		case hcloud.LoadBalancerTargetTypeLabelSelector:
+			lb.Targets = append(lb.Targets, hcloud.LoadBalancerTarget{Type: target.Type,
+				LabelSelector: &hcloud.LoadBalancerTargetLabelSelector{Selector: target.LabelSelector.Selector}, UsePrivateIP: boolValue(target.UsePrivateIP)})
+		}
+	}
+
+	f.loadBalancers[lb.ID] = lb
+	f.record("create_load_balancer", lb.ID, hcloud.ActionResourceType("load_balancer"))
+	f.track(hetzner.ResourceLoadBalancer, lb.ID, lb.Name)
+	return copyLoadBalancer(lb), nil
+}
+
+// GetLoadBalancer returns a specific load balancer by name
+func (f *Fake) GetLoadBalancer(ctx context.Context, name string) (*hcloud.LoadBalancer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetLoadBalancer"); err != nil {
+		return nil, fmt.Errorf("failed to fetch load balancer %s: %w", name, err)
+	}
+	for _, lb := range f.loadBalancers {
+		if lb.Name == name {
+			return copyLoadBalancer(lb), nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteLoadBalancer deletes a load balancer
+func (f *Fake) DeleteLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteLoadBalancer"); err != nil {
+		return fmt.Errorf("failed to delete load balancer %s: %w", lb.Name, err)
+	}
+	if _, ok := f.loadBalancers[lb.ID]; !ok {
+		return fmt.Errorf("failed to delete load balancer %s: %w", lb.Name, notFound("load balancer not found"))
+	}
+	delete(f.loadBalancers, lb.ID)
+	for _, network := range f.networks {
+		var lbs []*hcloud.LoadBalancer
+		for _, attached := range network.LoadBalancers {
+			if attached.ID != lb.ID {
+				lbs = append(lbs, attached)
+			}
+		}
+		network.LoadBalancers = lbs
+	}
+	return nil
+}
+
+// AddServiceToLoadBalancer adds a service to a load balancer
+func (f *Fake) AddServiceToLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer, opts hcloud.LoadBalancerAddServiceOpts) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("AddServiceToLoadBalancer"); err != nil {
+		return fmt.Errorf("failed to add service to load balancer %s: %w", lb.Name, err)
+	}
+	current, ok := f.loadBalancers[lb.ID]
+	if !ok {
+		return fmt.Errorf("failed to add service to load balancer %s: %w", lb.Name, notFound("load balancer not found"))
+	}
+	service := loadBalancerService(opts.Protocol, opts.ListenPort, opts.DestinationPort, opts.Proxyprotocol)
+	for _, existing := range current.Services {
+		if existing.ListenPort == service.ListenPort {
+			return fmt.Errorf("failed to add service to load balancer %s: %w", lb.Name,
+				hcloud.Error{Code: hcloud.ErrorCodeConflict, Message: "listen port already in use"})
+		}
+	}
+	current.Services = append(current.Services, service)
+	f.record("add_service", current.ID, hcloud.ActionResourceType("load_balancer"))
+	return nil
+}
+
+// AddLabelSelectorTargetToLoadBalancer adds a label selector target to a load balancer
+func (f *Fake) AddLabelSelectorTargetToLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer, opts hcloud.LoadBalancerAddLabelSelectorTargetOpts) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("AddLabelSelectorTargetToLoadBalancer"); err != nil {
+		return fmt.Errorf("failed to add label selector target to load balancer %s: %w", lb.Name, err)
+	}
+	current, ok := f.loadBalancers[lb.ID]
+	if !ok {
+		return fmt.Errorf("failed to add label selector target to load balancer %s: %w", lb.Name, notFound("load balancer not found"))
+	}
+	current.Targets = append(current.Targets, hcloud.LoadBalancerTarget{
+		Type:          hcloud.LoadBalancerTargetTypeLabelSelector,
+		LabelSelector: &hcloud.LoadBalancerTargetLabelSelector{Selector: opts.Selector},
+		UsePrivateIP:  boolValue(opts.UsePrivateIP),
+	})
+	f.record("add_target", current.ID, hcloud.ActionResourceType("load_balancer"))
+	return nil
+}
+
+// AddServerTargetToLoadBalancer adds a server target to a load balancer
+func (f *Fake) AddServerTargetToLoadBalancer(ctx context.Context, lb *hcloud.LoadBalancer, opts hcloud.LoadBalancerAddServerTargetOpts) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("AddServerTargetToLoadBalancer"); err != nil {
+		return fmt.Errorf("failed to add server target to load balancer %s: %w", lb.Name, err)
+	}
+	current, ok := f.loadBalancers[lb.ID]
+	if !ok {
+		return fmt.Errorf("failed to add server target to load balancer %s: %w", lb.Name, notFound("load balancer not found"))
+	}
+	if _, ok := f.servers[opts.Server.ID]; !ok {
+		return fmt.Errorf("failed to add server target to load balancer %s: %w", lb.Name, notFound("server not found"))
+	}
+	current.Targets = append(current.Targets, hcloud.LoadBalancerTarget{
+		Type:         hcloud.LoadBalancerTargetTypeServer,
+		Server:       &hcloud.LoadBalancerTargetServer{Server: &hcloud.Server{ID: opts.Server.ID, Name: opts.Server.Name}},
+		UsePrivateIP: boolValue(opts.UsePrivateIP),
+	})
+	f.record("add_target", current.ID, hcloud.ActionResourceType("load_balancer"))
+	return nil
+}
+
+// GetZone returns a specific DNS zone by name
+func (f *Fake) GetZone(ctx context.Context, name string) (*hcloud.Zone, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetZone"); err != nil {
+		return nil, fmt.Errorf("failed to fetch zone %s: %w", name, err)
+	}
+	for _, zone := range f.zones {
+		if zone.Name == name {
+			copied := *zone
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateZone creates a DNS zone
+func (f *Fake) CreateZone(ctx context.Context, opts hcloud.ZoneCreateOpts) (*hcloud.Zone, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateZone"); err != nil {
+		return nil, fmt.Errorf("failed to create zone: %w", err)
+	}
+	for _, zone := range f.zones {
+		if zone.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create zone: %w", uniquenessError("zone already exists"))
+		}
+	}
+
+	zone := &hcloud.Zone{
+		ID:      f.newID(),
+		Name:    opts.Name,
+		Mode:    opts.Mode,
+		Labels:  copyLabels(opts.Labels),
+		Created: time.Now(),
+	}
+	if opts.TTL != nil {
+		zone.TTL = *opts.TTL
+	}
+	f.zones[zone.ID] = zone
+	f.record("create_zone", zone.ID, hcloud.ActionResourceType("zone"))
+	f.track(hetzner.ResourceZone, zone.ID, zone.Name)
+	copied := *zone
+	return &copied, nil
+}
+
+// DeleteZone deletes a DNS zone and its record sets
+func (f *Fake) DeleteZone(ctx context.Context, zone *hcloud.Zone) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteZone"); err != nil {
+		return fmt.Errorf("failed to delete zone %s: %w", zone.Name, err)
+	}
+	if _, ok := f.zones[zone.ID]; !ok {
+		return fmt.Errorf("failed to delete zone %s: %w", zone.Name, notFound("zone not found"))
+	}
+	delete(f.zones, zone.ID)
+	for key, rrset := range f.rrsets {
+		if rrset.Zone.ID == zone.ID {
+			delete(f.rrsets, key)
+		}
+	}
+	f.record("delete_zone", zone.ID, hcloud.ActionResourceType("zone"))
+	return nil
+}
+
+// GetZoneRRSet returns a DNS record set by zone, name and type
+func (f *Fake) GetZoneRRSet(ctx context.Context, zone *hcloud.Zone, name string, rrType hcloud.ZoneRRSetType) (*hcloud.ZoneRRSet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetZoneRRSet"); err != nil {
+		return nil, fmt.Errorf("failed to list zone RRSets: %w", err)
+	}
+	if rrset, ok := f.rrsets[rrsetKey(zone.ID, name, rrType)]; ok {
+		copied := *rrset
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+// CreateZoneRRSet creates a DNS record set
+func (f *Fake) CreateZoneRRSet(ctx context.Context, zone *hcloud.Zone, opts hcloud.ZoneRRSetCreateOpts) (*hcloud.ZoneRRSet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateZoneRRSet"); err != nil {
+		return nil, fmt.Errorf("failed to create zone RRSet: %w", err)
+	}
+	current, ok := f.zones[zone.ID]
+	if !ok {
+		return nil, fmt.Errorf("failed to create zone RRSet: %w", notFound("zone not found"))
+	}
+	key := rrsetKey(zone.ID, opts.Name, opts.Type)
+	if _, ok := f.rrsets[key]; ok {
+		return nil, fmt.Errorf("failed to create zone RRSet: %w", uniquenessError("RRSet already exists"))
+	}
+
+	rrset := &hcloud.ZoneRRSet{
+		Zone:    current,
+		ID:      opts.Name + "/" + string(opts.Type),
+		Name:    opts.Name,
+		Type:    opts.Type,
+		TTL:     opts.TTL,
+		Labels:  copyLabels(opts.Labels),
+		Records: append([]hcloud.ZoneRRSetRecord(nil), opts.Records...),
+	}
+	f.rrsets[key] = rrset
+	current.RecordCount++
+	f.record("create_rrset", current.ID, hcloud.ActionResourceType("zone"))
+	copied := *rrset
+	return &copied, nil
+}
+
+// DeleteZoneRRSet deletes a DNS record set
+func (f *Fake) DeleteZoneRRSet(ctx context.Context, rrset *hcloud.ZoneRRSet) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteZoneRRSet"); err != nil {
+		return fmt.Errorf("failed to delete zone RRSet: %w", err)
+	}
+	if rrset.Zone == nil {
+		return fmt.Errorf("failed to delete zone RRSet: %w", invalidInput("missing zone"))
+	}
+	key := rrsetKey(rrset.Zone.ID, rrset.Name, rrset.Type)
+	if _, ok := f.rrsets[key]; !ok {
+		return fmt.Errorf("failed to delete zone RRSet: %w", notFound("RRSet not found"))
+	}
+	delete(f.rrsets, key)
+	if zone, ok := f.zones[rrset.Zone.ID]; ok {
+		zone.RecordCount--
+	}
+	f.record("delete_rrset", rrset.Zone.ID, hcloud.ActionResourceType("zone"))
+	return nil
+}
+
+// CreateManagedCertificate creates a managed certificate in the pending state
+func (f *Fake) CreateManagedCertificate(ctx context.Context, opts hcloud.CertificateCreateOpts) (*hcloud.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateManagedCertificate"); err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	for _, cert := range f.certificates {
+		if cert.Name == opts.Name {
+			return nil, fmt.Errorf("failed to create certificate: %w", uniquenessError("certificate name is already used"))
+		}
+	}
+
+	cert := &hcloud.Certificate{
+		ID:          f.newID(),
+		Name:        opts.Name,
+		Type:        hcloud.CertificateTypeManaged,
+		Labels:      copyLabels(opts.Labels),
+		DomainNames: append([]string(nil), opts.DomainNames...),
+		Created:     time.Now(),
+		Status:      &hcloud.CertificateStatus{Issuance: hcloud.CertificateStatusTypePending},
+	}
+	f.certificates[cert.ID] = cert
+	f.record("create_certificate", cert.ID, hcloud.ActionResourceType("certificate"))
+	f.track(hetzner.ResourceCertificate, cert.ID, cert.Name)
+	copied := *cert
+	return &copied, nil
+}
+
+// GetCertificate returns a specific certificate by name
+func (f *Fake) GetCertificate(ctx context.Context, name string) (*hcloud.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetCertificate"); err != nil {
+		return nil, fmt.Errorf("failed to fetch certificate %s: %w", name, err)
+	}
+	for _, cert := range f.certificates {
+		if cert.Name == name {
+			copied := *cert
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteCertificate deletes a certificate
+func (f *Fake) DeleteCertificate(ctx context.Context, cert *hcloud.Certificate) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteCertificate"); err != nil {
+		return fmt.Errorf("failed to delete certificate %s: %w", cert.Name, err)
+	}
+	if _, ok := f.certificates[cert.ID]; !ok {
+		return fmt.Errorf("failed to delete certificate %s: %w", cert.Name, notFound("certificate not found"))
+	}
+	delete(f.certificates, cert.ID)
+	return nil
+}
+
+// SetResourceTracker attaches a tracker that records every resource created through the fake
+func (f *Fake) SetResourceTracker(tracker *hetzner.ResourceTracker) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tracker = tracker
+}
+
+// sortedServers returns copies of the servers sorted by name
+func (f *Fake) sortedServers() []*hcloud.Server {
+	servers := make([]*hcloud.Server, 0, len(f.servers))
+	for _, server := range f.servers {
+		servers = append(servers, copyServer(server))
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Name < servers[j].Name
+	})
+	return servers
+}
+
+// failure returns the error configured with FailOn for a method
+func (f *Fake) failure(method string) error {
+	return f.failures[method]
+}
+
+// newID returns the next resource ID
+func (f *Fake) newID() int64 {
+	f.nextID++
+	return f.nextID
+}
+
+// record records a completed action on a resource
+func (f *Fake) record(command string, id int64, resourceType hcloud.ActionResourceType) {
+	now := time.Now()
+	f.actions = append(f.actions, &hcloud.Action{
+		ID:        f.newID(),
+		Status:    hcloud.ActionStatusSuccess,
+		Command:   command,
+		Progress:  100,
+		Started:   now,
+		Finished:  now,
+		Resources: []*hcloud.ActionResource{{ID: id, Type: resourceType}},
+	})
+}
+
+// track records a created resource if a tracker is attached
+func (f *Fake) track(kind hetzner.ResourceKind, id int64, name string) {
+	if f.tracker != nil {
+		f.tracker.Record(kind, id, name)
+	}
+}
+
+// nextPrivateIP returns the next free private IP of a network's first subnet
+// Addresses start at .2, since .1 is the subnet gateway.
+func (f *Fake) nextPrivateIP(network *hcloud.Network) net.IP {
+	ipRange := network.IPRange
+	if len(network.Subnets) > 0 && network.Subnets[0].IPRange != nil {
+		ipRange = network.Subnets[0].IPRange
+	}
+	if ipRange == nil {
+		return nil
+	}
+
+	f.networkIPs[network.ID]++
+	base := ipRange.IP.To4()
+	if base == nil {
+		return nil
+	}
+	offset := f.networkIPs[network.ID] + 1
+	return net.IPv4(base[0], base[1], base[2]+byte(offset/256), base[3]+byte(offset%256))
+}
+
+func (f *Fake) location(name string) *hcloud.Location {
+	for _, location := range f.locations {
+		if location.Name == name || fmt.Sprint(location.ID) == name {
+			return location
+		}
+	}
+	return nil
+}
+
+func (f *Fake) serverType(name string) *hcloud.ServerType {
+	for _, serverType := range f.serverTypes {
+		if serverType.Name == name || fmt.Sprint(serverType.ID) == name {
+			return serverType
+		}
+	}
+	return nil
+}
+
+// image returns an image by ID, or by name for an architecture
+// Images without an architecture match any architecture.
+func (f *Fake) image(nameOrID string, architecture hcloud.Architecture) *hcloud.Image {
+	for _, image := range f.images {
+		if fmt.Sprint(image.ID) == nameOrID {
+			return image
+		}
+	}
+	for _, image := range f.images {
+		if image.Name == nameOrID && (architecture == "" || image.Architecture == "" || image.Architecture == architecture) {
+			return image
+		}
+	}
+	return nil
+}
+
+// MatchLabelSelector reports whether labels match a Hetzner label selector
+// Supported expressions are k=v, k==v, k!=v, k and !k, separated by commas.
+func MatchLabelSelector(selector string, labels map[string]string) bool {
+	for _, expr := range strings.Split(selector, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+
+		switch {
+		case strings.Contains(expr, "!="):
+			key, value, _ := strings.Cut(expr, "!=")
+			if actual, ok := labels[strings.TrimSpace(key)]; ok && actual == strings.TrimSpace(value) {
+				return false
+			}
+		case strings.Contains(expr, "=="):
+			key, value, _ := strings.Cut(expr, "==")
+			if actual, ok := labels[strings.TrimSpace(key)]; !ok || actual != strings.TrimSpace(value) {
+				return false
+			}
+		case strings.Contains(expr, "="):
+			key, value, _ := strings.Cut(expr, "=")
+			if actual, ok := labels[strings.TrimSpace(key)]; !ok || actual != strings.TrimSpace(value) {
+				return false
+			}
+		case strings.HasPrefix(expr, "!"):
+			if _, ok := labels[strings.TrimSpace(expr[1:])]; ok {
+				return false
+			}
+		default:
+			if _, ok := labels[expr]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func notFound(message string) error {
+	return hcloud.Error{Code: hcloud.ErrorCodeNotFound, Message: message}
+}
+
+func uniquenessError(message string) error {
+	return hcloud.Error{Code: hcloud.ErrorCodeUniquenessError, Message: message}
+}
+
+func invalidInput(message string) error {
+	return hcloud.Error{Code: hcloud.ErrorCodeInvalidInput, Message: message}
+}
+
+func rrsetKey(zoneID int64, name string, rrType hcloud.ZoneRRSetType) string {
+	return fmt.Sprintf("%d/%s/%s", zoneID, name, rrType)
+}
+
+func loadBalancerService(protocol hcloud.LoadBalancerServiceProtocol, listenPort, destinationPort *int, proxyProtocol *bool) hcloud.LoadBalancerService {
+	service := hcloud.LoadBalancerService{Protocol: protocol, Proxyprotocol: boolValue(proxyProtocol)}
+	if listenPort != nil {
+		service.ListenPort = *listenPort
+	}
+	if destinationPort != nil {
+		service.DestinationPort = *destinationPort
+	}
+	return service
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+func copyFloatingIP(floatingIP *hcloud.FloatingIP) *hcloud.FloatingIP {
+	copied := *floatingIP
+	copied.Labels = copyLabels(floatingIP.Labels)
+	return &copied
+}
+
+func copyImage(image *hcloud.Image) *hcloud.Image {
+	copied := *image
+	copied.Labels = copyLabels(image.Labels)
+	return &copied
+}
+
+func copyVolume(volume *hcloud.Volume) *hcloud.Volume {
+	copied := *volume
+	copied.Labels = copyLabels(volume.Labels)
+	return &copied
+}
+
+func copyPrimaryIP(primaryIP *hcloud.PrimaryIP) *hcloud.PrimaryIP {
+	copied := *primaryIP
+	copied.Labels = copyLabels(primaryIP.Labels)
+	return &copied
+}
+
+func copyPlacementGroup(placementGroup *hcloud.PlacementGroup) *hcloud.PlacementGroup {
+	copied := *placementGroup
+	copied.Labels = copyLabels(placementGroup.Labels)
+	copied.Servers = append([]int64{}, placementGroup.Servers...)
+	return &copied
+}
+
+func removeServer(servers []*hcloud.Server, id int64) []*hcloud.Server {
+	var kept []*hcloud.Server
+	for _, server := range servers {
+		if server.ID != id {
+			kept = append(kept, server)
+		}
+	}
+	return kept
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+// The copy functions return shallow copies with their own slices and labels, so callers
+// can read resources while the fake keeps changing them.
+
+func copyServer(server *hcloud.Server) *hcloud.Server {
+	copied := *server
+	copied.Labels = copyLabels(server.Labels)
+	copied.PrivateNet = append([]hcloud.ServerPrivateNet(nil), server.PrivateNet...)
+	copied.Volumes = append([]*hcloud.Volume(nil), server.Volumes...)
+	return &copied
+}
+
+func copyNetwork(network *hcloud.Network) *hcloud.Network {
+	copied := *network
+	copied.Labels = copyLabels(network.Labels)
+	copied.Subnets = append([]hcloud.NetworkSubnet(nil), network.Subnets...)
+	copied.Routes = append([]hcloud.NetworkRoute(nil), network.Routes...)
+	copied.Servers = append([]*hcloud.Server(nil), network.Servers...)
+	copied.LoadBalancers = append([]*hcloud.LoadBalancer(nil), network.LoadBalancers...)
+	return &copied
+}
+
+func copyFirewall(firewall *hcloud.Firewall) *hcloud.Firewall {
+	copied := *firewall
+	copied.Labels = copyLabels(firewall.Labels)
+	copied.Rules = append([]hcloud.FirewallRule(nil), firewall.Rules...)
+	copied.AppliedTo = append([]hcloud.FirewallResource(nil), firewall.AppliedTo...)
+	return &copied
+}
+
+func copyLoadBalancer(lb *hcloud.LoadBalancer) *hcloud.LoadBalancer {
+	copied := *lb
+	copied.Labels = copyLabels(lb.Labels)
+	copied.Services = append([]hcloud.LoadBalancerService(nil), lb.Services...)
+	copied.Targets = append([]hcloud.LoadBalancerTarget(nil), lb.Targets...)
+	copied.PrivateNet = append([]hcloud.LoadBalancerPrivateNet(nil), lb.PrivateNet...)
+	return &copied
+}
+
+// sortedCopies returns shallow copies of the resources sorted by name
+func sortedCopies[T any](resources map[int64]*T, name func(*T) string) []*T {
+	copies := make([]*T, 0, len(resources))
+	for _, resource := range resources {
+		copied := *resource
+		copies = append(copies, &copied)
+	}
+	sort.Slice(copies, func(i, j int) bool {
+		return name(copies[i]) < name(copies[j])
+	})
+	return copies
+}
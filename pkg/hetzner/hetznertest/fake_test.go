@@ -0,0 +1,418 @@
+package hetznertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+func TestFake_ServerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	_, ipRange, _ := net.ParseCIDR("10.0.0.0/16")
+	network, err := fake.CreateNetwork(ctx, hcloud.NetworkCreateOpts{Name: "test", IPRange: ipRange})
+	if err != nil {
+		t.Fatalf("CreateNetwork() error = %v", err)
+	}
+
+	server, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{
+		Name:       "test-master-1",
+		ServerType: &hcloud.ServerType{Name: "cpx21"},
+		Location:   &hcloud.Location{Name: "fsn1"},
+		Image:      &hcloud.Image{Name: "ubuntu-24.04"},
+		Labels:     map[string]string{"cluster": "test", "role": "master"},
+		Networks:   []*hcloud.Network{network},
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if server.Status != hcloud.ServerStatusRunning || server.PublicNet.IPv4.IP == nil {
+		t.Errorf("CreateServer() = %+v, want a running server with a public IP", server)
+	}
+	if len(server.PrivateNet) != 1 || server.PrivateNet[0].IP.String() != "10.0.0.2" {
+		t.Errorf("CreateServer() private net = %+v, want 10.0.0.2", server.PrivateNet)
+	}
+
+	if _, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-1"}); !hcloud.IsError(err, hcloud.ErrorCodeUniquenessError) {
+		t.Errorf("CreateServer() with a duplicate name error = %v, want uniqueness error", err)
+	}
+
+	got, err := fake.GetServer(ctx, "test-master-1")
+	if err != nil || got == nil || got.ID != server.ID {
+		t.Fatalf("GetServer() = %v, %v", got, err)
+	}
+	if missing, err := fake.GetServer(ctx, "missing"); missing != nil || err != nil {
+		t.Errorf("GetServer() for a missing server = %v, %v, want nil, nil", missing, err)
+	}
+
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if err := fake.DeleteServer(ctx, server); !hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+		t.Errorf("DeleteServer() twice error = %v, want not found", err)
+	}
+	if networks := fake.Networks(); len(networks[0].Servers) != 0 {
+		t.Errorf("network still lists %d server(s) after deletion", len(networks[0].Servers))
+	}
+
+	want := []string{"create_server", "delete_server"}
+	if commands := fake.ActionCommands(); len(commands) != len(want) || commands[0] != want[0] || commands[1] != want[1] {
+		t.Errorf("ActionCommands() = %v, want %v", commands, want)
+	}
+}
+
+func TestFake_ListServersByLabel(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	for name, labels := range map[string]map[string]string{
+		"a-master":  {"cluster": "a", "role": "master"},
+		"a-worker":  {"cluster": "a", "role": "worker"},
+		"b-master":  {"cluster": "b", "role": "master"},
+		"autoscale": {"hcloud/node-group": "a-pool"},
+	} {
+		if _, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: name, Labels: labels}); err != nil {
+			t.Fatalf("CreateServer() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"cluster=a", []string{"a-master", "a-worker"}},
+		{"cluster=a,role=master", []string{"a-master"}},
+		{"role!=master", []string{"a-worker", "autoscale"}},
+		{"hcloud/node-group", []string{"autoscale"}},
+		{"!role", []string{"autoscale"}},
+		{"", []string{"a-master", "a-worker", "autoscale", "b-master"}},
+	}
+
+	for _, tt := range tests {
+		servers, err := fake.ListServers(ctx, hcloud.ServerListOpts{ListOpts: hcloud.ListOpts{LabelSelector: tt.selector}})
+		if err != nil {
+			t.Fatalf("ListServers(%q) error = %v", tt.selector, err)
+		}
+		var names []string
+		for _, server := range servers {
+			names = append(names, server.Name)
+		}
+		if len(names) != len(tt.want) {
+			t.Errorf("ListServers(%q) = %v, want %v", tt.selector, names, tt.want)
+			continue
+		}
+		for i := range names {
+			if names[i] != tt.want[i] {
+				t.Errorf("ListServers(%q) = %v, want %v", tt.selector, names, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFake_FirewallInUse(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	firewall, err := fake.CreateFirewall(ctx, hcloud.FirewallCreateOpts{Name: "test-firewall"})
+	if err != nil {
+		t.Fatalf("CreateFirewall() error = %v", err)
+	}
+	server, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{
+		Name:      "test-master-1",
+		Firewalls: []*hcloud.ServerCreateFirewall{{Firewall: *firewall}},
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+
+	if err := fake.DeleteFirewall(ctx, firewall); !hcloud.IsError(err, hcloud.ErrorCodeResourceInUse) {
+		t.Errorf("DeleteFirewall() while applied error = %v, want resource in use", err)
+	}
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if err := fake.DeleteFirewall(ctx, firewall); err != nil {
+		t.Errorf("DeleteFirewall() after server deletion error = %v", err)
+	}
+}
+
+func TestFake_FailOnAndTracker(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	tracker := hetzner.NewResourceTracker()
+	fake.SetResourceTracker(tracker)
+
+	if _, err := fake.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{Name: "test-ssh-key", PublicKey: "ssh-ed25519 AAAA"}); err != nil {
+		t.Fatalf("CreateSSHKey() error = %v", err)
+	}
+
+	injected := errors.New("service unavailable")
+	fake.FailOn("CreateServer", injected)
+	if _, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-1"}); !errors.Is(err, injected) {
+		t.Errorf("CreateServer() error = %v, want injected error", err)
+	}
+	fake.FailOn("CreateServer", nil)
+	if _, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-1"}); err != nil {
+		t.Errorf("CreateServer() after clearing the failure error = %v", err)
+	}
+
+	resources := tracker.Resources()
+	if len(resources) != 2 || resources[0].Kind != hetzner.ResourceSSHKey || resources[1].Kind != hetzner.ResourceServer {
+		t.Errorf("tracked resources = %+v, want the SSH key and the server", resources)
+	}
+}
+
+func TestFake_ZoneRecords(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	zone, err := fake.CreateZone(ctx, hcloud.ZoneCreateOpts{Name: "example.com", Mode: hcloud.ZoneModePrimary})
+	if err != nil {
+		t.Fatalf("CreateZone() error = %v", err)
+	}
+	if _, err := fake.CreateZoneRRSet(ctx, zone, hcloud.ZoneRRSetCreateOpts{
+		Name: "api", Type: hcloud.ZoneRRSetTypeA, Records: []hcloud.ZoneRRSetRecord{{Value: "198.51.100.1"}},
+	}); err != nil {
+		t.Fatalf("CreateZoneRRSet() error = %v", err)
+	}
+
+	rrset, err := fake.GetZoneRRSet(ctx, zone, "api", hcloud.ZoneRRSetTypeA)
+	if err != nil || rrset == nil || rrset.Records[0].Value != "198.51.100.1" {
+		t.Fatalf("GetZoneRRSet() = %v, %v", rrset, err)
+	}
+	if missing, _ := fake.GetZoneRRSet(ctx, zone, "api", hcloud.ZoneRRSetTypeAAAA); missing != nil {
+		t.Errorf("GetZoneRRSet() for another type = %v, want nil", missing)
+	}
+
+	if err := fake.DeleteZone(ctx, zone); err != nil {
+		t.Fatalf("DeleteZone() error = %v", err)
+	}
+	if missing, _ := fake.GetZoneRRSet(ctx, zone, "api", hcloud.ZoneRRSetTypeA); missing != nil {
+		t.Error("record set still exists after zone deletion")
+	}
+}
+
+func TestFake_SpreadPlacementGroup(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	placementGroup, err := fake.CreatePlacementGroup(ctx, hcloud.PlacementGroupCreateOpts{Name: "test-masters", Type: hcloud.PlacementGroupTypeSpread})
+	if err != nil {
+		t.Fatalf("CreatePlacementGroup() error = %v", err)
+	}
+
+	var first *hcloud.Server
+	for i := 0; i < maxSpreadGroupServers; i++ {
+		server, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: fmt.Sprintf("test-%d", i), PlacementGroup: placementGroup})
+		if err != nil {
+			t.Fatalf("CreateServer() error = %v", err)
+		}
+		if server.PlacementGroup == nil || server.PlacementGroup.ID != placementGroup.ID {
+			t.Fatalf("server placement group = %+v, want %d", server.PlacementGroup, placementGroup.ID)
+		}
+		if first == nil {
+			first = server
+		}
+	}
+
+	// A spread group holds at most ten servers
+	_, err = fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-full", PlacementGroup: placementGroup})
+	if !hcloud.IsError(err, hcloud.ErrorCodePlacementError) {
+		t.Errorf("CreateServer() in a full group error = %v, want placement_error", err)
+	}
+
+	if err := fake.DeleteServer(ctx, first); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if got, _ := fake.GetPlacementGroup(ctx, "test-masters"); got == nil || len(got.Servers) != maxSpreadGroupServers-1 {
+		t.Errorf("GetPlacementGroup() = %+v, want %d servers after a deletion", got, maxSpreadGroupServers-1)
+	}
+}
+
+func TestFake_PrimaryIP(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	primaryIP, err := fake.CreatePrimaryIP(ctx, hcloud.PrimaryIPCreateOpts{
+		Name: "test-master-1-ipv4", Type: hcloud.PrimaryIPTypeIPv4, AssigneeType: "server",
+		AutoDelete: hcloud.Ptr(false), Location: "fsn1",
+	})
+	if err != nil {
+		t.Fatalf("CreatePrimaryIP() error = %v", err)
+	}
+
+	opts := hcloud.ServerCreateOpts{
+		Name:      "test-master-1",
+		Location:  &hcloud.Location{Name: "fsn1"},
+		PublicNet: &hcloud.ServerCreatePublicNet{EnableIPv4: true, IPv4: primaryIP},
+	}
+	server, err := fake.CreateServer(ctx, opts)
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if !server.PublicNet.IPv4.IP.Equal(primaryIP.IP) {
+		t.Errorf("server IPv4 = %s, want the primary IP %s", server.PublicNet.IPv4.IP, primaryIP.IP)
+	}
+	if err := fake.DeletePrimaryIP(ctx, primaryIP); !hcloud.IsError(err, hcloud.ErrorCodePrimaryIPAssigned) {
+		t.Errorf("DeletePrimaryIP() of an assigned IP error = %v, want primary_ip_assigned", err)
+	}
+
+	// The IP outlives its server and is assigned to the recreated one
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if got, _ := fake.GetPrimaryIP(ctx, "test-master-1-ipv4"); got == nil || got.AssigneeID != 0 {
+		t.Fatalf("GetPrimaryIP() after server deletion = %+v, want an unassigned IP", got)
+	}
+	recreated, err := fake.CreateServer(ctx, opts)
+	if err != nil {
+		t.Fatalf("CreateServer() with a reused primary IP error = %v", err)
+	}
+	if !recreated.PublicNet.IPv4.IP.Equal(primaryIP.IP) {
+		t.Errorf("recreated server IPv4 = %s, want %s", recreated.PublicNet.IPv4.IP, primaryIP.IP)
+	}
+
+	opts.Name, opts.Location = "test-master-2", &hcloud.Location{Name: "nbg1"}
+	if _, err := fake.CreateServer(ctx, opts); !hcloud.IsError(err, hcloud.ErrorCodePrimaryIPAssigned) {
+		t.Errorf("CreateServer() with an assigned primary IP error = %v, want primary_ip_assigned", err)
+	}
+}
+
+func TestFake_FloatingIP(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	if _, err := fake.CreateFloatingIP(ctx, hcloud.FloatingIPCreateOpts{Type: hcloud.FloatingIPTypeIPv4, Name: hcloud.Ptr("test-api-ip")}); !hcloud.IsError(err, hcloud.ErrorCodeInvalidInput) {
+		t.Errorf("CreateFloatingIP() without a home location error = %v, want invalid_input", err)
+	}
+	floatingIP, err := fake.CreateFloatingIP(ctx, hcloud.FloatingIPCreateOpts{
+		Type: hcloud.FloatingIPTypeIPv4, Name: hcloud.Ptr("test-api-ip"), HomeLocation: &hcloud.Location{Name: "fsn1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateFloatingIP() error = %v", err)
+	}
+	if floatingIP.IP == nil || floatingIP.Server != nil || floatingIP.HomeLocation.Name != "fsn1" {
+		t.Errorf("CreateFloatingIP() = %+v, want an unassigned IPv4 in fsn1", floatingIP)
+	}
+
+	server, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-1", Location: &hcloud.Location{Name: "fsn1"}})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if err := fake.AssignFloatingIP(ctx, floatingIP, server); err != nil {
+		t.Fatalf("AssignFloatingIP() error = %v", err)
+	}
+	if got, _ := fake.GetFloatingIP(ctx, "test-api-ip"); got == nil || got.Server == nil || got.Server.ID != server.ID {
+		t.Fatalf("GetFloatingIP() = %+v, want the IP assigned to server %d", got, server.ID)
+	}
+
+	// Deleting the holder unassigns the IP
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if got, _ := fake.GetFloatingIP(ctx, "test-api-ip"); got == nil || got.Server != nil {
+		t.Errorf("GetFloatingIP() after server deletion = %+v, want an unassigned IP", got)
+	}
+	if err := fake.DeleteFloatingIP(ctx, floatingIP); err != nil {
+		t.Fatalf("DeleteFloatingIP() error = %v", err)
+	}
+	if len(fake.FloatingIPs()) != 0 {
+		t.Error("floating IP left after deletion")
+	}
+}
+
+func TestFake_Volume(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	volume, err := fake.CreateVolume(ctx, hcloud.VolumeCreateOpts{Name: "test-worker-1-data", Size: 10, Location: &hcloud.Location{Name: "fsn1"}})
+	if err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if volume.Server != nil || volume.LinuxDevice == "" {
+		t.Errorf("CreateVolume() = %+v, want a detached volume with a device", volume)
+	}
+
+	opts := hcloud.ServerCreateOpts{Name: "test-worker-1", Location: &hcloud.Location{Name: "fsn1"}, Volumes: []*hcloud.Volume{volume}}
+	server, err := fake.CreateServer(ctx, opts)
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if len(server.Volumes) != 1 || server.Volumes[0].ID != volume.ID {
+		t.Errorf("server volumes = %v, want volume %d", server.Volumes, volume.ID)
+	}
+	if err := fake.DeleteVolume(ctx, volume); !hcloud.IsError(err, hcloud.ErrorCodeConflict) {
+		t.Errorf("DeleteVolume() of an attached volume error = %v, want conflict", err)
+	}
+	opts.Name = "test-worker-2"
+	if _, err := fake.CreateServer(ctx, opts); !hcloud.IsError(err, hcloud.ErrorCodeVolumeAlreadyAttached) {
+		t.Errorf("CreateServer() with an attached volume error = %v, want volume_already_attached", err)
+	}
+
+	// The volume outlives its server
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if got, _ := fake.GetVolume(ctx, "test-worker-1-data"); got == nil || got.Server != nil {
+		t.Fatalf("GetVolume() after server deletion = %+v, want a detached volume", got)
+	}
+	opts.Location = &hcloud.Location{Name: "nbg1"}
+	if _, err := fake.CreateServer(ctx, opts); !hcloud.IsError(err, hcloud.ErrorCodeInvalidInput) {
+		t.Errorf("CreateServer() with a volume in another location error = %v, want invalid_input", err)
+	}
+	if err := fake.DeleteVolume(ctx, volume); err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+}
+
+func TestFake_CreateImage(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	server, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-image-builder",
+		ServerType: &hcloud.ServerType{Name: "cx22"}, Image: &hcloud.Image{Name: "ubuntu-24.04"}})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if err := fake.WaitForServerStatus(ctx, server, hcloud.ServerStatusOff, time.Second); err == nil {
+		t.Error("WaitForServerStatus(off) of a running server error = nil, want a timeout")
+	}
+	if err := fake.ShutdownServer(ctx, server); err != nil {
+		t.Fatalf("ShutdownServer() error = %v", err)
+	}
+	if err := fake.WaitForServerStatus(ctx, server, hcloud.ServerStatusOff, time.Second); err != nil {
+		t.Errorf("WaitForServerStatus(off) after shutdown error = %v", err)
+	}
+
+	image, err := fake.CreateImage(ctx, server, hcloud.ServerCreateImageOpts{
+		Type: hcloud.ImageTypeSnapshot, Description: hcloud.Ptr("node image"), Labels: map[string]string{"cluster": "test"}})
+	if err != nil {
+		t.Fatalf("CreateImage() error = %v", err)
+	}
+	if image.Type != hcloud.ImageTypeSnapshot || image.Description != "node image" || image.CreatedFrom.ID != server.ID {
+		t.Errorf("CreateImage() = %+v, want a snapshot of server %d", image, server.ID)
+	}
+
+	// Snapshots have no name and are found by their ID
+	if got, err := fake.GetImage(ctx, fmt.Sprint(image.ID), ""); err != nil || got.Labels["cluster"] != "test" {
+		t.Errorf("GetImage(%d) = %+v, %v, want the snapshot", image.ID, got, err)
+	}
+	if _, err := fake.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-worker-1", Image: &hcloud.Image{Name: fmt.Sprint(image.ID)}}); err != nil {
+		t.Errorf("CreateServer() from the snapshot error = %v", err)
+	}
+
+	if err := fake.DeleteServer(ctx, server); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if _, err := fake.CreateImage(ctx, server, hcloud.ServerCreateImageOpts{}); !hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+		t.Errorf("CreateImage() of a deleted server error = %v, want not_found", err)
+	}
+}
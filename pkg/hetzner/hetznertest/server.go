@@ -0,0 +1,1406 @@
+package hetznertest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+// Server is a fake Hetzner Cloud API served over HTTP
+// It implements the subset of the REST API used by hek3ster (servers, networks,
+// primary and floating IPs, placement groups, firewalls, load balancers, SSH keys, certificates, DNS zones and record sets, and
+// actions) on top of a Fake, so the real hetzner.Client can be tested end to end.
+// Actions can be made slow or failing, and any request can answer with an API error.
+type Server struct {
+	*httptest.Server
+
+	// Fake holds the resources served by the API
+	Fake *Fake
+
+	mu           sync.Mutex
+	nextActionID int64
+	actions      map[int64]*pendingAction
+	actionPolls  int
+	actionErrors map[string]string
+	failures     map[string]*requestFailure
+	requests     []string
+}
+
+// pendingAction is an action that completes after a number of polls
+type pendingAction struct {
+	action schema.Action
+	polls  int
+}
+
+// requestFailure is an API error returned for a request instead of its response
+type requestFailure struct {
+	status int
+	code   hcloud.ErrorCode
+	times  int
+}
+
+// NewServer starts a fake Hetzner Cloud API backed by a new Fake
+// The caller must call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		Fake:         NewFake(),
+		actions:      make(map[int64]*pendingAction),
+		actionErrors: make(map[string]string),
+		failures:     make(map[string]*requestFailure),
+	}
+
+	mux := http.NewServeMux()
+	s.route(mux, "GET /locations", s.listLocations)
+	s.route(mux, "GET /server_types", s.listServerTypes)
+	s.route(mux, "GET /images", s.listImages)
+	s.route(mux, "GET /images/{id}", s.getImage)
+	s.route(mux, "GET /actions/{id}", s.getAction)
+
+	s.route(mux, "GET /servers", s.listServers)
+	s.route(mux, "GET /servers/{id}", s.getServer)
+	s.route(mux, "POST /servers", s.createServer)
+	s.route(mux, "POST /servers/{id}/actions/shutdown", s.shutdownServer)
+	s.route(mux, "POST /servers/{id}/actions/create_image", s.createImage)
+	s.route(mux, "DELETE /servers/{id}", s.deleteServer)
+
+	s.route(mux, "GET /networks", s.listNetworks)
+	s.route(mux, "GET /networks/{id}", s.getNetwork)
+	s.route(mux, "POST /networks", s.createNetwork)
+	s.route(mux, "POST /networks/{id}/actions/add_route", s.addRoute)
+	s.route(mux, "DELETE /networks/{id}", s.deleteNetwork)
+
+	s.route(mux, "GET /ssh_keys", s.listSSHKeys)
+	s.route(mux, "GET /ssh_keys/{id}", s.getSSHKey)
+	s.route(mux, "POST /ssh_keys", s.createSSHKey)
+	s.route(mux, "DELETE /ssh_keys/{id}", s.deleteSSHKey)
+
+	s.route(mux, "GET /floating_ips", s.listFloatingIPs)
+	s.route(mux, "GET /floating_ips/{id}", s.getFloatingIP)
+	s.route(mux, "POST /floating_ips", s.createFloatingIP)
+	s.route(mux, "POST /floating_ips/{id}/actions/assign", s.assignFloatingIP)
+	s.route(mux, "DELETE /floating_ips/{id}", s.deleteFloatingIP)
+
+	s.route(mux, "GET /volumes", s.listVolumes)
+	s.route(mux, "GET /volumes/{id}", s.getVolume)
+	s.route(mux, "POST /volumes", s.createVolume)
+	s.route(mux, "DELETE /volumes/{id}", s.deleteVolume)
+
+	s.route(mux, "GET /primary_ips", s.listPrimaryIPs)
+	s.route(mux, "GET /primary_ips/{id}", s.getPrimaryIP)
+	s.route(mux, "POST /primary_ips", s.createPrimaryIP)
+	s.route(mux, "DELETE /primary_ips/{id}", s.deletePrimaryIP)
+
+	s.route(mux, "GET /placement_groups", s.listPlacementGroups)
+	s.route(mux, "GET /placement_groups/{id}", s.getPlacementGroup)
+	s.route(mux, "POST /placement_groups", s.createPlacementGroup)
+	s.route(mux, "DELETE /placement_groups/{id}", s.deletePlacementGroup)
+
+	s.route(mux, "GET /firewalls", s.listFirewalls)
+	s.route(mux, "GET /firewalls/{id}", s.getFirewall)
+	s.route(mux, "POST /firewalls", s.createFirewall)
+	s.route(mux, "DELETE /firewalls/{id}", s.deleteFirewall)
+
+	s.route(mux, "GET /load_balancers", s.listLoadBalancers)
+	s.route(mux, "GET /load_balancers/{id}", s.getLoadBalancer)
+	s.route(mux, "POST /load_balancers", s.createLoadBalancer)
+	s.route(mux, "POST /load_balancers/{id}/actions/add_service", s.addService)
+	s.route(mux, "POST /load_balancers/{id}/actions/add_target", s.addTarget)
+	s.route(mux, "DELETE /load_balancers/{id}", s.deleteLoadBalancer)
+
+	s.route(mux, "GET /zones", s.listZones)
+	s.route(mux, "GET /zones/{zone}", s.getZone)
+	s.route(mux, "POST /zones", s.createZone)
+	s.route(mux, "DELETE /zones/{zone}", s.deleteZone)
+	s.route(mux, "GET /zones/{zone}/rrsets", s.listRRSets)
+	s.route(mux, "POST /zones/{zone}/rrsets", s.createRRSet)
+	s.route(mux, "DELETE /zones/{zone}/rrsets/{name}/{type}", s.deleteRRSet)
+
+	s.route(mux, "GET /certificates", s.listCertificates)
+	s.route(mux, "GET /certificates/{id}", s.getCertificate)
+	s.route(mux, "POST /certificates", s.createCertificate)
+	s.route(mux, "DELETE /certificates/{id}", s.deleteCertificate)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// HetznerClient returns a client for the fake API that polls and retries every few milliseconds
+// Further options, e.g. hetzner.WithLocationFallback, are applied after the defaults.
+func (s *Server) HetznerClient(options ...hetzner.ClientOption) *hetzner.Client {
+	options = append([]hetzner.ClientOption{
+		hetzner.WithEndpoint(s.URL),
+		hetzner.WithPollInterval(10 * time.Millisecond),
+		hetzner.WithRetries(2, time.Millisecond),
+	}, options...)
+	return hetzner.NewClient("test-token", options...)
+}
+
+// SetActionPolls makes actions run until they have been polled n times
+// With the default of 0, actions complete immediately.
+func (s *Server) SetActionPolls(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actionPolls = n
+}
+
+// FailAction makes actions with the command, e.g. "create_server", end in an error
+// An empty message clears the failure.
+func (s *Server) FailAction(command, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if message == "" {
+		delete(s.actionErrors, command)
+		return
+	}
+	s.actionErrors[command] = message
+}
+
+// FailRequest answers requests for the method and path, e.g. "POST", "/servers", with
+// an API error. The failure is returned times times, or always if times is 0.
+func (s *Server) FailRequest(method, path string, status int, code hcloud.ErrorCode, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method+" "+path] = &requestFailure{status: status, code: code, times: times}
+}
+
+// Requests returns the method and path of every request served, in order
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// handlerFunc serves a request and returns the status code and the response body
+type handlerFunc func(r *http.Request) (int, any, error)
+
+// route registers a handler that records the request, applies injected failures and
+// writes the response or the API error as JSON
+func (s *Server) route(mux *http.ServeMux, pattern string, handler handlerFunc) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if failure := s.recordRequest(r); failure != nil {
+			writeError(w, failure.status, hcloud.Error{Code: failure.code, Message: "injected failure"})
+			return
+		}
+
+		status, body, err := handler(r)
+		if err != nil {
+			var apiErr hcloud.Error
+			if !errors.As(err, &apiErr) {
+				apiErr = hcloud.Error{Code: hcloud.ErrorCodeServerError, Message: err.Error()}
+			}
+			writeError(w, errorStatus(apiErr.Code), apiErr)
+			return
+		}
+
+		if body == nil {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// recordRequest records a request and returns the failure injected for it, if any
+func (s *Server) recordRequest(r *http.Request) *requestFailure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Method + " " + r.URL.Path
+	s.requests = append(s.requests, key)
+
+	failure, ok := s.failures[key]
+	if !ok {
+		return nil
+	}
+	if failure.times > 0 {
+		failure.times--
+		if failure.times == 0 {
+			delete(s.failures, key)
+		}
+	}
+	return failure
+}
+
+// newAction returns a new action on a resource, running if actions are slow
+func (s *Server) newAction(command string, id int64, resourceType string) schema.Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextActionID++
+	pending := &pendingAction{
+		action: schema.Action{
+			ID:        s.nextActionID,
+			Status:    string(hcloud.ActionStatusRunning),
+			Command:   command,
+			Started:   time.Now(),
+			Resources: []schema.ActionResourceReference{{ID: id, Type: resourceType}},
+		},
+		polls: s.actionPolls,
+	}
+	s.actions[pending.action.ID] = pending
+	s.advance(pending)
+	return pending.action
+}
+
+// advance finishes an action once it has no polls left
+func (s *Server) advance(pending *pendingAction) {
+	if pending.action.Status != string(hcloud.ActionStatusRunning) {
+		return
+	}
+	if pending.polls > 0 {
+		pending.polls--
+		pending.action.Progress = 50
+		return
+	}
+
+	finished := time.Now()
+	pending.action.Finished = &finished
+	pending.action.Progress = 100
+	pending.action.Status = string(hcloud.ActionStatusSuccess)
+	if message, ok := s.actionErrors[pending.action.Command]; ok {
+		pending.action.Status = string(hcloud.ActionStatusError)
+		pending.action.Error = &schema.ActionError{Code: "action_failed", Message: message}
+	}
+}
+
+func (s *Server) getAction(r *http.Request) (int, any, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.actions[id]
+	if !ok {
+		return 0, nil, notFound("action not found")
+	}
+	s.advance(pending)
+	return http.StatusOK, schema.ActionGetResponse{Action: pending.action}, nil
+}
+
+// Locations, server types and images
+
+func (s *Server) listLocations(r *http.Request) (int, any, error) {
+	locations, _ := s.Fake.GetLocations(r.Context())
+	resp := schema.LocationListResponse{Locations: []schema.Location{}}
+	for _, location := range locations {
+		if matchQuery(r.URL.Query(), location.Name, nil) {
+			resp.Locations = append(resp.Locations, hcloud.SchemaFromLocation(location))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) listServerTypes(r *http.Request) (int, any, error) {
+	serverTypes, _ := s.Fake.GetServerTypes(r.Context())
+	resp := schema.ServerTypeListResponse{ServerTypes: []schema.ServerType{}}
+	for _, serverType := range serverTypes {
+		if matchQuery(r.URL.Query(), serverType.Name, nil) {
+			resp.ServerTypes = append(resp.ServerTypes, hcloud.SchemaFromServerType(serverType))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) listImages(r *http.Request) (int, any, error) {
+	s.Fake.mu.Lock()
+	images := append([]*hcloud.Image(nil), s.Fake.images...)
+	s.Fake.mu.Unlock()
+
+	architecture := hcloud.Architecture(r.URL.Query().Get("architecture"))
+	resp := schema.ImageListResponse{Images: []schema.Image{}}
+	for _, image := range images {
+		if architecture != "" && image.Architecture != "" && image.Architecture != architecture {
+			continue
+		}
+		if matchQuery(r.URL.Query(), image.Name, image.Labels) {
+			resp.Images = append(resp.Images, hcloud.SchemaFromImage(image))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getImage(r *http.Request) (int, any, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return 0, nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	for _, image := range s.Fake.images {
+		if image.ID == id {
+			return http.StatusOK, schema.ImageGetResponse{Image: hcloud.SchemaFromImage(image)}, nil
+		}
+	}
+	return 0, nil, notFound("image not found")
+}
+
+// Servers
+
+func (s *Server) listServers(r *http.Request) (int, any, error) {
+	resp := schema.ServerListResponse{Servers: []schema.Server{}}
+	for _, server := range s.Fake.Servers() {
+		if matchQuery(r.URL.Query(), server.Name, server.Labels) {
+			resp.Servers = append(resp.Servers, hcloud.SchemaFromServer(server))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getServer(r *http.Request) (int, any, error) {
+	server, err := s.server(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.ServerGetResponse{Server: hcloud.SchemaFromServer(server)}, nil
+}
+
+func (s *Server) createServer(r *http.Request) (int, any, error) {
+	var req schema.ServerCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.ServerCreateOpts{
+		Name:             req.Name,
+		ServerType:       &hcloud.ServerType{Name: idOrName(req.ServerType)},
+		Image:            &hcloud.Image{Name: idOrName(req.Image)},
+		UserData:         req.UserData,
+		StartAfterCreate: req.StartAfterCreate,
+		Labels:           labels(req.Labels),
+	}
+	if req.Location != "" {
+		opts.Location = &hcloud.Location{Name: req.Location}
+	}
+	for _, id := range req.SSHKeys {
+		opts.SSHKeys = append(opts.SSHKeys, &hcloud.SSHKey{ID: id})
+	}
+	for _, id := range req.Networks {
+		opts.Networks = append(opts.Networks, &hcloud.Network{ID: id})
+	}
+	for _, id := range req.Volumes {
+		opts.Volumes = append(opts.Volumes, &hcloud.Volume{ID: id})
+	}
+	if req.PlacementGroup != 0 {
+		opts.PlacementGroup = &hcloud.PlacementGroup{ID: req.PlacementGroup}
+	}
+	for _, firewall := range req.Firewalls {
+		opts.Firewalls = append(opts.Firewalls, &hcloud.ServerCreateFirewall{Firewall: hcloud.Firewall{ID: firewall.Firewall}})
+	}
+	if req.PublicNet != nil {
+		opts.PublicNet = &hcloud.ServerCreatePublicNet{EnableIPv4: req.PublicNet.EnableIPv4, EnableIPv6: req.PublicNet.EnableIPv6}
+		if req.PublicNet.IPv4ID != 0 {
+			opts.PublicNet.IPv4 = &hcloud.PrimaryIP{ID: req.PublicNet.IPv4ID}
+		}
+		if req.PublicNet.IPv6ID != 0 {
+			opts.PublicNet.IPv6 = &hcloud.PrimaryIP{ID: req.PublicNet.IPv6ID}
+		}
+	}
+
+	server, err := s.Fake.CreateServer(r.Context(), opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.ServerCreateResponse{
+		Server:      hcloud.SchemaFromServer(server),
+		Action:      s.newAction("create_server", server.ID, "server"),
+		NextActions: []schema.Action{},
+	}, nil
+}
+
+func (s *Server) shutdownServer(r *http.Request) (int, any, error) {
+	server, err := s.server(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := s.Fake.ShutdownServer(r.Context(), server); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.ServerActionShutdownResponse{Action: s.newAction("shutdown_server", server.ID, "server")}, nil
+}
+
+func (s *Server) createImage(r *http.Request) (int, any, error) {
+	server, err := s.server(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var req schema.ServerActionCreateImageRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.ServerCreateImageOpts{
+		Description: req.Description,
+		Labels:      labels(req.Labels),
+	}
+	if req.Type != nil {
+		opts.Type = hcloud.ImageType(*req.Type)
+	}
+	image, err := s.Fake.CreateImage(r.Context(), server, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.ServerActionCreateImageResponse{
+		Image:  hcloud.SchemaFromImage(image),
+		Action: s.newAction("create_image", server.ID, "server"),
+	}, nil
+}
+
+func (s *Server) deleteServer(r *http.Request) (int, any, error) {
+	server, err := s.server(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := s.Fake.DeleteServer(r.Context(), server); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.ServerDeleteResponse{Action: s.newAction("delete_server", server.ID, "server")}, nil
+}
+
+func (s *Server) server(r *http.Request) (*hcloud.Server, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if server, ok := s.Fake.servers[id]; ok {
+		return copyServer(server), nil
+	}
+	return nil, notFound("server not found")
+}
+
+// Networks
+
+func (s *Server) listNetworks(r *http.Request) (int, any, error) {
+	resp := schema.NetworkListResponse{Networks: []schema.Network{}}
+	for _, network := range s.Fake.Networks() {
+		if matchQuery(r.URL.Query(), network.Name, network.Labels) {
+			resp.Networks = append(resp.Networks, hcloud.SchemaFromNetwork(network))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getNetwork(r *http.Request) (int, any, error) {
+	network, err := s.network(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.NetworkGetResponse{Network: hcloud.SchemaFromNetwork(network)}, nil
+}
+
+func (s *Server) createNetwork(r *http.Request) (int, any, error) {
+	var req schema.NetworkCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.NetworkCreateOpts{Name: req.Name, Labels: labels(req.Labels)}
+	if req.IPRange != "" {
+		_, ipRange, err := net.ParseCIDR(req.IPRange)
+		if err != nil {
+			return 0, nil, invalidInput("invalid ip_range " + req.IPRange)
+		}
+		opts.IPRange = ipRange
+	}
+	for _, subnet := range req.Subnets {
+		opts.Subnets = append(opts.Subnets, hcloud.NetworkSubnetFromSchema(subnet))
+	}
+	for _, route := range req.Routes {
+		opts.Routes = append(opts.Routes, hcloud.NetworkRouteFromSchema(route))
+	}
+
+	network, err := s.Fake.CreateNetwork(r.Context(), opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.NetworkCreateResponse{Network: hcloud.SchemaFromNetwork(network)}, nil
+}
+
+func (s *Server) addRoute(r *http.Request) (int, any, error) {
+	network, err := s.network(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var req schema.NetworkActionAddRouteRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	route := hcloud.NetworkRouteFromSchema(schema.NetworkRoute{Destination: req.Destination, Gateway: req.Gateway})
+	if err := s.Fake.AddRouteToNetwork(r.Context(), network, hcloud.NetworkAddRouteOpts{Route: route}); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.NetworkActionAddRouteResponse{Action: s.newAction("add_route", network.ID, "network")}, nil
+}
+
+func (s *Server) deleteNetwork(r *http.Request) (int, any, error) {
+	network, err := s.network(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeleteNetwork(r.Context(), network)
+}
+
+func (s *Server) network(r *http.Request) (*hcloud.Network, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if network, ok := s.Fake.networks[id]; ok {
+		return copyNetwork(network), nil
+	}
+	return nil, notFound("network not found")
+}
+
+// SSH keys
+
+func (s *Server) listSSHKeys(r *http.Request) (int, any, error) {
+	resp := schema.SSHKeyListResponse{SSHKeys: []schema.SSHKey{}}
+	for _, key := range s.Fake.SSHKeys() {
+		if matchQuery(r.URL.Query(), key.Name, key.Labels) {
+			resp.SSHKeys = append(resp.SSHKeys, hcloud.SchemaFromSSHKey(key))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getSSHKey(r *http.Request) (int, any, error) {
+	key, err := s.sshKey(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.SSHKeyGetResponse{SSHKey: hcloud.SchemaFromSSHKey(key)}, nil
+}
+
+func (s *Server) createSSHKey(r *http.Request) (int, any, error) {
+	var req schema.SSHKeyCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	key, err := s.Fake.CreateSSHKey(r.Context(), hcloud.SSHKeyCreateOpts{Name: req.Name, PublicKey: req.PublicKey, Labels: labels(req.Labels)})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.SSHKeyCreateResponse{SSHKey: hcloud.SchemaFromSSHKey(key)}, nil
+}
+
+func (s *Server) deleteSSHKey(r *http.Request) (int, any, error) {
+	key, err := s.sshKey(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeleteSSHKey(r.Context(), key)
+}
+
+func (s *Server) sshKey(r *http.Request) (*hcloud.SSHKey, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if key, ok := s.Fake.sshKeys[id]; ok {
+		copied := *key
+		return &copied, nil
+	}
+	return nil, notFound("SSH key not found")
+}
+
+// Floating IPs
+
+func (s *Server) listFloatingIPs(r *http.Request) (int, any, error) {
+	resp := schema.FloatingIPListResponse{FloatingIPs: []schema.FloatingIP{}}
+	for _, floatingIP := range s.Fake.FloatingIPs() {
+		if matchQuery(r.URL.Query(), floatingIP.Name, floatingIP.Labels) {
+			resp.FloatingIPs = append(resp.FloatingIPs, hcloud.SchemaFromFloatingIP(floatingIP))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getFloatingIP(r *http.Request) (int, any, error) {
+	floatingIP, err := s.floatingIP(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.FloatingIPGetResponse{FloatingIP: hcloud.SchemaFromFloatingIP(floatingIP)}, nil
+}
+
+func (s *Server) createFloatingIP(r *http.Request) (int, any, error) {
+	var req schema.FloatingIPCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.FloatingIPCreateOpts{
+		Type:        hcloud.FloatingIPType(req.Type),
+		Name:        req.Name,
+		Description: req.Description,
+		Labels:      labels(req.Labels),
+	}
+	if req.HomeLocation != nil {
+		opts.HomeLocation = &hcloud.Location{Name: *req.HomeLocation}
+	}
+	if req.Server != nil {
+		opts.Server = &hcloud.Server{ID: *req.Server}
+	}
+	floatingIP, err := s.Fake.CreateFloatingIP(r.Context(), opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.FloatingIPCreateResponse{FloatingIP: hcloud.SchemaFromFloatingIP(floatingIP)}, nil
+}
+
+func (s *Server) assignFloatingIP(r *http.Request) (int, any, error) {
+	floatingIP, err := s.floatingIP(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var req schema.FloatingIPActionAssignRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if err := s.Fake.AssignFloatingIP(r.Context(), floatingIP, &hcloud.Server{ID: req.Server}); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.FloatingIPActionAssignResponse{
+		Action: s.newAction("assign_floating_ip", floatingIP.ID, "floating_ip"),
+	}, nil
+}
+
+func (s *Server) deleteFloatingIP(r *http.Request) (int, any, error) {
+	floatingIP, err := s.floatingIP(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeleteFloatingIP(r.Context(), floatingIP)
+}
+
+func (s *Server) floatingIP(r *http.Request) (*hcloud.FloatingIP, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if floatingIP, ok := s.Fake.floatingIPs[id]; ok {
+		return copyFloatingIP(floatingIP), nil
+	}
+	return nil, notFound("floating IP not found")
+}
+
+// Volumes
+
+func (s *Server) listVolumes(r *http.Request) (int, any, error) {
+	resp := schema.VolumeListResponse{Volumes: []schema.Volume{}}
+	for _, volume := range s.Fake.Volumes() {
+		if matchQuery(r.URL.Query(), volume.Name, volume.Labels) {
+			resp.Volumes = append(resp.Volumes, hcloud.SchemaFromVolume(volume))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getVolume(r *http.Request) (int, any, error) {
+	volume, err := s.volume(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.VolumeGetResponse{Volume: hcloud.SchemaFromVolume(volume)}, nil
+}
+
+func (s *Server) createVolume(r *http.Request) (int, any, error) {
+	var req schema.VolumeCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.VolumeCreateOpts{
+		Name:      req.Name,
+		Size:      req.Size,
+		Labels:    labels(req.Labels),
+		Automount: req.Automount,
+		Format:    req.Format,
+	}
+	if req.Server != nil {
+		opts.Server = &hcloud.Server{ID: *req.Server}
+	}
+	if req.Location != nil {
+		opts.Location = &hcloud.Location{Name: idOrName(*req.Location)}
+	}
+	volume, err := s.Fake.CreateVolume(r.Context(), opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	action := s.newAction("create_volume", volume.ID, "volume")
+	return http.StatusCreated, schema.VolumeCreateResponse{
+		Volume:      hcloud.SchemaFromVolume(volume),
+		Action:      &action,
+		NextActions: []schema.Action{},
+	}, nil
+}
+
+func (s *Server) deleteVolume(r *http.Request) (int, any, error) {
+	volume, err := s.volume(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeleteVolume(r.Context(), volume)
+}
+
+func (s *Server) volume(r *http.Request) (*hcloud.Volume, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if volume, ok := s.Fake.volumes[id]; ok {
+		return copyVolume(volume), nil
+	}
+	return nil, notFound("volume not found")
+}
+
+// Primary IPs
+
+func (s *Server) listPrimaryIPs(r *http.Request) (int, any, error) {
+	resp := schema.PrimaryIPListResponse{PrimaryIPs: []schema.PrimaryIP{}}
+	for _, primaryIP := range s.Fake.PrimaryIPs() {
+		if matchQuery(r.URL.Query(), primaryIP.Name, primaryIP.Labels) {
+			resp.PrimaryIPs = append(resp.PrimaryIPs, hcloud.SchemaFromPrimaryIP(primaryIP))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getPrimaryIP(r *http.Request) (int, any, error) {
+	primaryIP, err := s.primaryIP(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.PrimaryIPGetResponse{PrimaryIP: hcloud.SchemaFromPrimaryIP(primaryIP)}, nil
+}
+
+func (s *Server) createPrimaryIP(r *http.Request) (int, any, error) {
+	var req schema.PrimaryIPCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.PrimaryIPCreateOpts{
+		Name:         req.Name,
+		Type:         hcloud.PrimaryIPType(req.Type),
+		AssigneeType: req.AssigneeType,
+		AutoDelete:   req.AutoDelete,
+		Location:     req.Location,
+		Labels:       labels(req.Labels),
+	}
+	primaryIP, err := s.Fake.CreatePrimaryIP(r.Context(), opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.PrimaryIPCreateResponse{PrimaryIP: hcloud.SchemaFromPrimaryIP(primaryIP)}, nil
+}
+
+func (s *Server) deletePrimaryIP(r *http.Request) (int, any, error) {
+	primaryIP, err := s.primaryIP(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeletePrimaryIP(r.Context(), primaryIP)
+}
+
+func (s *Server) primaryIP(r *http.Request) (*hcloud.PrimaryIP, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if primaryIP, ok := s.Fake.primaryIPs[id]; ok {
+		return copyPrimaryIP(primaryIP), nil
+	}
+	return nil, notFound("primary IP not found")
+}
+
+// Placement groups
+
+func (s *Server) listPlacementGroups(r *http.Request) (int, any, error) {
+	resp := schema.PlacementGroupListResponse{PlacementGroups: []schema.PlacementGroup{}}
+	for _, placementGroup := range s.Fake.PlacementGroups() {
+		if matchQuery(r.URL.Query(), placementGroup.Name, placementGroup.Labels) {
+			resp.PlacementGroups = append(resp.PlacementGroups, hcloud.SchemaFromPlacementGroup(placementGroup))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getPlacementGroup(r *http.Request) (int, any, error) {
+	placementGroup, err := s.placementGroup(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.PlacementGroupGetResponse{PlacementGroup: hcloud.SchemaFromPlacementGroup(placementGroup)}, nil
+}
+
+func (s *Server) createPlacementGroup(r *http.Request) (int, any, error) {
+	var req schema.PlacementGroupCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	placementGroup, err := s.Fake.CreatePlacementGroup(r.Context(), hcloud.PlacementGroupCreateOpts{
+		Name: req.Name, Labels: labels(req.Labels), Type: hcloud.PlacementGroupType(req.Type),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.PlacementGroupCreateResponse{PlacementGroup: hcloud.SchemaFromPlacementGroup(placementGroup)}, nil
+}
+
+func (s *Server) deletePlacementGroup(r *http.Request) (int, any, error) {
+	placementGroup, err := s.placementGroup(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeletePlacementGroup(r.Context(), placementGroup)
+}
+
+func (s *Server) placementGroup(r *http.Request) (*hcloud.PlacementGroup, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if placementGroup, ok := s.Fake.placements[id]; ok {
+		return copyPlacementGroup(placementGroup), nil
+	}
+	return nil, notFound("placement group not found")
+}
+
+// Firewalls
+
+func (s *Server) listFirewalls(r *http.Request) (int, any, error) {
+	resp := schema.FirewallListResponse{Firewalls: []schema.Firewall{}}
+	for _, firewall := range s.Fake.Firewalls() {
+		if matchQuery(r.URL.Query(), firewall.Name, firewall.Labels) {
+			resp.Firewalls = append(resp.Firewalls, hcloud.SchemaFromFirewall(firewall))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getFirewall(r *http.Request) (int, any, error) {
+	firewall, err := s.firewall(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.FirewallGetResponse{Firewall: hcloud.SchemaFromFirewall(firewall)}, nil
+}
+
+func (s *Server) createFirewall(r *http.Request) (int, any, error) {
+	var req schema.FirewallCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.FirewallCreateOpts{Name: req.Name, Labels: labels(req.Labels)}
+	for _, rule := range req.Rules {
+		converted := hcloud.FirewallRule{
+			Direction:   hcloud.FirewallRuleDirection(rule.Direction),
+			Protocol:    hcloud.FirewallRuleProtocol(rule.Protocol),
+			Port:        rule.Port,
+			Description: rule.Description,
+		}
+		var err error
+		if converted.SourceIPs, err = parseCIDRs(rule.SourceIPs); err != nil {
+			return 0, nil, err
+		}
+		if converted.DestinationIPs, err = parseCIDRs(rule.DestinationIPs); err != nil {
+			return 0, nil, err
+		}
+		opts.Rules = append(opts.Rules, converted)
+	}
+	for _, resource := range req.ApplyTo {
+		applied := hcloud.FirewallResource{Type: hcloud.FirewallResourceType(resource.Type)}
+		if resource.Server != nil {
+			applied.Server = &hcloud.FirewallResourceServer{ID: resource.Server.ID}
+		}
+		if resource.LabelSelector != nil {
+			applied.LabelSelector = &hcloud.FirewallResourceLabelSelector{Selector: resource.LabelSelector.Selector}
+		}
+		opts.ApplyTo = append(opts.ApplyTo, applied)
+	}
+
+	firewall, err := s.Fake.CreateFirewall(r.Context(), opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp := schema.FirewallCreateResponse{Firewall: hcloud.SchemaFromFirewall(firewall), Actions: []schema.Action{}}
+	if len(opts.ApplyTo) > 0 {
+		resp.Actions = append(resp.Actions, s.newAction("apply_firewall", firewall.ID, "firewall"))
+	}
+	return http.StatusCreated, resp, nil
+}
+
+func (s *Server) deleteFirewall(r *http.Request) (int, any, error) {
+	firewall, err := s.firewall(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeleteFirewall(r.Context(), firewall)
+}
+
+func (s *Server) firewall(r *http.Request) (*hcloud.Firewall, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if firewall, ok := s.Fake.firewalls[id]; ok {
+		return copyFirewall(firewall), nil
+	}
+	return nil, notFound("firewall not found")
+}
+
+// Load balancers
+
+func (s *Server) listLoadBalancers(r *http.Request) (int, any, error) {
+	resp := schema.LoadBalancerListResponse{LoadBalancers: []schema.LoadBalancer{}}
+	for _, lb := range s.Fake.LoadBalancers() {
+		if matchQuery(r.URL.Query(), lb.Name, lb.Labels) {
+			resp.LoadBalancers = append(resp.LoadBalancers, hcloud.SchemaFromLoadBalancer(lb))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getLoadBalancer(r *http.Request) (int, any, error) {
+	lb, err := s.loadBalancer(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.LoadBalancerGetResponse{LoadBalancer: hcloud.SchemaFromLoadBalancer(lb)}, nil
+}
+
+func (s *Server) createLoadBalancer(r *http.Request) (int, any, error) {
+	var req schema.LoadBalancerCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.LoadBalancerCreateOpts{
+		Name:             req.Name,
+		LoadBalancerType: &hcloud.LoadBalancerType{Name: idOrName(req.LoadBalancerType)},
+		Labels:           labels(req.Labels),
+		PublicInterface:  req.PublicInterface,
+	}
+	if req.Location != nil {
+		opts.Location = &hcloud.Location{Name: *req.Location}
+	}
+	if req.Network != nil {
+		opts.Network = &hcloud.Network{ID: *req.Network}
+	}
+	for _, service := range req.Services {
+		opts.Services = append(opts.Services, hcloud.LoadBalancerCreateOptsService{
+			Protocol:        hcloud.LoadBalancerServiceProtocol(service.Protocol),
+			ListenPort:      service.ListenPort,
+			DestinationPort: service.DestinationPort,
+			Proxyprotocol:   service.Proxyprotocol,
+		})
+	}
+	for _, target := range req.Targets {
+		converted := hcloud.LoadBalancerCreateOptsTarget{Type: hcloud.LoadBalancerTargetType(target.Type), UsePrivateIP: target.UsePrivateIP}
+		if target.Server != nil {
+			converted.Server.Server = &hcloud.Server{ID: target.Server.ID}
+		}
+		if target.LabelSelector != nil {
+			converted.LabelSelector.Selector = target.LabelSelector.Selector
+		}
+		opts.Targets = append(opts.Targets, converted)
+	}
+
+	lb, err := s.Fake.CreateLoadBalancer(r.Context(), opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.LoadBalancerCreateResponse{
+		LoadBalancer: hcloud.SchemaFromLoadBalancer(lb),
+		Action:       s.newAction("create_load_balancer", lb.ID, "load_balancer"),
+	}, nil
+}
+
+func (s *Server) addService(r *http.Request) (int, any, error) {
+	lb, err := s.loadBalancer(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var req schema.LoadBalancerActionAddServiceRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	err = s.Fake.AddServiceToLoadBalancer(r.Context(), lb, hcloud.LoadBalancerAddServiceOpts{
+		Protocol:        hcloud.LoadBalancerServiceProtocol(req.Protocol),
+		ListenPort:      req.ListenPort,
+		DestinationPort: req.DestinationPort,
+		Proxyprotocol:   req.Proxyprotocol,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.LoadBalancerActionAddServiceResponse{Action: s.newAction("add_service", lb.ID, "load_balancer")}, nil
+}
+
+func (s *Server) addTarget(r *http.Request) (int, any, error) {
+	lb, err := s.loadBalancer(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var req schema.LoadBalancerActionAddTargetRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	switch {
+	case req.Type == string(hcloud.LoadBalancerTargetTypeServer) && req.Server != nil:
+		err = s.Fake.AddServerTargetToLoadBalancer(r.Context(), lb, hcloud.LoadBalancerAddServerTargetOpts{
+			Server: &hcloud.Server{ID: req.Server.ID}, UsePrivateIP: req.UsePrivateIP})
+	case req.Type == string(hcloud.LoadBalancerTargetTypeLabelSelector) && req.LabelSelector != nil:
+		err = s.Fake.AddLabelSelectorTargetToLoadBalancer(r.Context(), lb, hcloud.LoadBalancerAddLabelSelectorTargetOpts{
+			Selector: req.LabelSelector.Selector, UsePrivateIP: req.UsePrivateIP})
+	default:
+		err = invalidInput("unsupported target type " + req.Type)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.LoadBalancerActionAddTargetResponse{Action: s.newAction("add_target", lb.ID, "load_balancer")}, nil
+}
+
+func (s *Server) deleteLoadBalancer(r *http.Request) (int, any, error) {
+	lb, err := s.loadBalancer(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeleteLoadBalancer(r.Context(), lb)
+}
+
+func (s *Server) loadBalancer(r *http.Request) (*hcloud.LoadBalancer, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if lb, ok := s.Fake.loadBalancers[id]; ok {
+		return copyLoadBalancer(lb), nil
+	}
+	return nil, notFound("load balancer not found")
+}
+
+// DNS zones and record sets
+
+func (s *Server) listZones(r *http.Request) (int, any, error) {
+	resp := schema.ZoneListResponse{Zones: []schema.Zone{}}
+	for _, zone := range s.Fake.Zones() {
+		if matchQuery(r.URL.Query(), zone.Name, zone.Labels) {
+			resp.Zones = append(resp.Zones, hcloud.SchemaFromZone(zone))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getZone(r *http.Request) (int, any, error) {
+	zone, err := s.zone(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.ZoneGetResponse{Zone: hcloud.SchemaFromZone(zone)}, nil
+}
+
+func (s *Server) createZone(r *http.Request) (int, any, error) {
+	var req schema.ZoneCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	zone, err := s.Fake.CreateZone(r.Context(), hcloud.ZoneCreateOpts{
+		Name:   req.Name,
+		Mode:   hcloud.ZoneMode(req.Mode),
+		TTL:    req.TTL,
+		Labels: labels(req.Labels),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.ZoneCreateResponse{
+		Zone:   hcloud.SchemaFromZone(zone),
+		Action: s.newAction("create_zone", zone.ID, "zone"),
+	}, nil
+}
+
+func (s *Server) deleteZone(r *http.Request) (int, any, error) {
+	zone, err := s.zone(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := s.Fake.DeleteZone(r.Context(), zone); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.ActionGetResponse{Action: s.newAction("delete_zone", zone.ID, "zone")}, nil
+}
+
+func (s *Server) listRRSets(r *http.Request) (int, any, error) {
+	zone, err := s.zone(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	query := r.URL.Query()
+	types := query["type"]
+	if len(types) == 0 {
+		types = []string{""}
+	}
+
+	resp := schema.ZoneRRSetListResponse{RRSets: []schema.ZoneRRSet{}}
+	for _, rrType := range types {
+		rrset, err := s.Fake.GetZoneRRSet(r.Context(), zone, query.Get("name"), hcloud.ZoneRRSetType(rrType))
+		if err != nil {
+			return 0, nil, err
+		}
+		if rrset != nil {
+			resp.RRSets = append(resp.RRSets, hcloud.SchemaFromZoneRRSet(rrset))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) createRRSet(r *http.Request) (int, any, error) {
+	zone, err := s.zone(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var req schema.ZoneRRSetCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	opts := hcloud.ZoneRRSetCreateOpts{
+		Name:   req.Name,
+		Type:   hcloud.ZoneRRSetType(req.Type),
+		TTL:    req.TTL,
+		Labels: labels(req.Labels),
+	}
+	for _, record := range req.Records {
+		opts.Records = append(opts.Records, hcloud.ZoneRRSetRecord{Value: record.Value, Comment: record.Comment})
+	}
+
+	rrset, err := s.Fake.CreateZoneRRSet(r.Context(), zone, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.ZoneRRSetCreateResponse{
+		RRSet:  hcloud.SchemaFromZoneRRSet(rrset),
+		Action: s.newAction("create_rrset", zone.ID, "zone"),
+	}, nil
+}
+
+func (s *Server) deleteRRSet(r *http.Request) (int, any, error) {
+	zone, err := s.zone(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rrset := &hcloud.ZoneRRSet{Zone: zone, Name: r.PathValue("name"), Type: hcloud.ZoneRRSetType(r.PathValue("type"))}
+	if err := s.Fake.DeleteZoneRRSet(r.Context(), rrset); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, schema.ActionGetResponse{Action: s.newAction("delete_rrset", zone.ID, "zone")}, nil
+}
+
+// zone returns the zone of a request by ID or name, like the Hetzner API
+func (s *Server) zone(r *http.Request) (*hcloud.Zone, error) {
+	idOrName := r.PathValue("zone")
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	for _, zone := range s.Fake.zones {
+		if zone.Name == idOrName || strconv.FormatInt(zone.ID, 10) == idOrName {
+			copied := *zone
+			return &copied, nil
+		}
+	}
+	return nil, notFound("zone not found")
+}
+
+// Certificates
+
+func (s *Server) listCertificates(r *http.Request) (int, any, error) {
+	resp := schema.CertificateListResponse{Certificates: []schema.Certificate{}}
+	for _, cert := range s.Fake.Certificates() {
+		if matchQuery(r.URL.Query(), cert.Name, cert.Labels) {
+			resp.Certificates = append(resp.Certificates, hcloud.SchemaFromCertificate(cert))
+		}
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) getCertificate(r *http.Request) (int, any, error) {
+	cert, err := s.certificate(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.CertificateGetResponse{Certificate: hcloud.SchemaFromCertificate(cert)}, nil
+}
+
+func (s *Server) createCertificate(r *http.Request) (int, any, error) {
+	var req schema.CertificateCreateRequest
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if req.Type != string(hcloud.CertificateTypeManaged) {
+		return 0, nil, invalidInput("only managed certificates are supported")
+	}
+
+	cert, err := s.Fake.CreateManagedCertificate(r.Context(), hcloud.CertificateCreateOpts{
+		Name:        req.Name,
+		Type:        hcloud.CertificateTypeManaged,
+		DomainNames: req.DomainNames,
+		Labels:      labels(req.Labels),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	action := s.newAction("create_certificate", cert.ID, "certificate")
+	return http.StatusCreated, schema.CertificateCreateResponse{Certificate: hcloud.SchemaFromCertificate(cert), Action: &action}, nil
+}
+
+func (s *Server) deleteCertificate(r *http.Request) (int, any, error) {
+	cert, err := s.certificate(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.Fake.DeleteCertificate(r.Context(), cert)
+}
+
+func (s *Server) certificate(r *http.Request) (*hcloud.Certificate, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	s.Fake.mu.Lock()
+	defer s.Fake.mu.Unlock()
+	if cert, ok := s.Fake.certificates[id]; ok {
+		copied := *cert
+		return &copied, nil
+	}
+	return nil, notFound("certificate not found")
+}
+
+// writeError writes an API error response
+func writeError(w http.ResponseWriter, status int, apiErr hcloud.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(schema.ErrorResponse{Error: hcloud.SchemaFromError(apiErr)})
+}
+
+// errorStatus returns the HTTP status the Hetzner API uses for an error code
+func errorStatus(code hcloud.ErrorCode) int {
+	switch code {
+	case hcloud.ErrorCodeInvalidInput, hcloud.ErrorCodeJSONError:
+		return http.StatusBadRequest
+	case hcloud.ErrorCodeUnauthorized:
+		return http.StatusUnauthorized
+	case hcloud.ErrorCodeForbidden, hcloud.ErrorCodeResourceLimitExceeded:
+		return http.StatusForbidden
+	case hcloud.ErrorCodeNotFound:
+		return http.StatusNotFound
+	case hcloud.ErrorCodeUniquenessError, hcloud.ErrorCodeConflict:
+		return http.StatusConflict
+	case hcloud.ErrorCodeLocked:
+		return http.StatusLocked
+	case hcloud.ErrorCodeRateLimitExceeded:
+		return http.StatusTooManyRequests
+	case hcloud.ErrorCodeServerError:
+		return http.StatusInternalServerError
+	case hcloud.ErrorCodeServiceError, hcloud.ErrorCodeMaintenance:
+		return http.StatusServiceUnavailable
+	case hcloud.ErrorCodeTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// decode reads a JSON request body
+func decode(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return hcloud.Error{Code: hcloud.ErrorCodeJSONError, Message: err.Error()}
+	}
+	return nil
+}
+
+// pathID parses a numeric path parameter
+func pathID(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		return 0, invalidInput(fmt.Sprintf("invalid %s %q", name, r.PathValue(name)))
+	}
+	return id, nil
+}
+
+// matchQuery reports whether a resource matches the name and label_selector query parameters
+func matchQuery(query url.Values, name string, labels map[string]string) bool {
+	if want := query.Get("name"); want != "" && want != name {
+		return false
+	}
+	return MatchLabelSelector(query.Get("label_selector"), labels)
+}
+
+// idOrName returns the ID or name of a resource reference as a string
+func idOrName(ref schema.IDOrName) string {
+	if ref.ID != 0 {
+		return strconv.FormatInt(ref.ID, 10)
+	}
+	return ref.Name
+}
+
+func labels(labels *map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	return *labels
+}
+
+func parseCIDRs(cidrs []string) ([]net.IPNet, error) {
+	var parsed []net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, invalidInput("invalid CIDR " + cidr)
+		}
+		parsed = append(parsed, *ipNet)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,468 @@
+package hetznertest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/magenx/hek3ster/pkg/hetzner"
+)
+
+func TestServer_ClusterLifecycle(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+
+	_, ipRange, _ := net.ParseCIDR("10.0.0.0/16")
+	_, subnetRange, _ := net.ParseCIDR("10.0.0.0/24")
+	network, err := client.CreateNetwork(ctx, hcloud.NetworkCreateOpts{
+		Name:    "test",
+		IPRange: ipRange,
+		Subnets: []hcloud.NetworkSubnet{{Type: hcloud.NetworkSubnetTypeCloud, IPRange: subnetRange, NetworkZone: hcloud.NetworkZoneEUCentral}},
+	})
+	if err != nil {
+		t.Fatalf("CreateNetwork() error = %v", err)
+	}
+
+	sshKey, err := client.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{Name: "test-ssh-key", PublicKey: "ssh-ed25519 AAAA"})
+	if err != nil {
+		t.Fatalf("CreateSSHKey() error = %v", err)
+	}
+
+	port := "22"
+	firewall, err := client.CreateFirewall(ctx, hcloud.FirewallCreateOpts{
+		Name: "test-firewall",
+		Rules: []hcloud.FirewallRule{{
+			Direction: hcloud.FirewallRuleDirectionIn,
+			Protocol:  hcloud.FirewallRuleProtocolTCP,
+			Port:      &port,
+			SourceIPs: []net.IPNet{*ipRange},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFirewall() error = %v", err)
+	}
+
+	serverType, err := client.GetServerType(ctx, "cx22")
+	if err != nil {
+		t.Fatalf("GetServerType() error = %v", err)
+	}
+	location, err := client.GetLocation(ctx, "fsn1")
+	if err != nil {
+		t.Fatalf("GetLocation() error = %v", err)
+	}
+	image, err := client.GetImage(ctx, "ubuntu-24.04", serverType.Architecture)
+	if err != nil {
+		t.Fatalf("GetImage() error = %v", err)
+	}
+
+	master, err := client.CreateServer(ctx, hcloud.ServerCreateOpts{
+		Name:       "test-master-1",
+		ServerType: serverType,
+		Location:   location,
+		Image:      image,
+		SSHKeys:    []*hcloud.SSHKey{sshKey},
+		Networks:   []*hcloud.Network{network},
+		Firewalls:  []*hcloud.ServerCreateFirewall{{Firewall: *firewall}},
+		Labels:     map[string]string{"cluster": "test", "role": "master"},
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if master.PublicNet.IPv4.IP == nil || len(master.PrivateNet) != 1 || master.PrivateNet[0].IP.String() != "10.0.0.2" {
+		t.Errorf("CreateServer() public IP %v, private networks %+v, want a public IP and 10.0.0.2", master.PublicNet.IPv4.IP, master.PrivateNet)
+	}
+	if master.ServerType == nil || master.ServerType.Name != "cx22" {
+		t.Errorf("CreateServer() server type = %+v, want cx22", master.ServerType)
+	}
+
+	lb, err := client.CreateLoadBalancer(ctx, hcloud.LoadBalancerCreateOpts{
+		Name:             "test-api-lb",
+		LoadBalancerType: &hcloud.LoadBalancerType{Name: "lb11"},
+		Location:         location,
+		Network:          network,
+	})
+	if err != nil {
+		t.Fatalf("CreateLoadBalancer() error = %v", err)
+	}
+	listenPort := 6443
+	if err := client.AddServiceToLoadBalancer(ctx, lb, hcloud.LoadBalancerAddServiceOpts{
+		Protocol: hcloud.LoadBalancerServiceProtocolTCP, ListenPort: &listenPort, DestinationPort: &listenPort,
+	}); err != nil {
+		t.Fatalf("AddServiceToLoadBalancer() error = %v", err)
+	}
+	if err := client.AddLabelSelectorTargetToLoadBalancer(ctx, lb, hcloud.LoadBalancerAddLabelSelectorTargetOpts{
+		Selector: "cluster=test,role=master",
+	}); err != nil {
+		t.Fatalf("AddLabelSelectorTargetToLoadBalancer() error = %v", err)
+	}
+
+	zone, err := client.CreateZone(ctx, hcloud.ZoneCreateOpts{Name: "example.com", Mode: hcloud.ZoneModePrimary})
+	if err != nil {
+		t.Fatalf("CreateZone() error = %v", err)
+	}
+	if _, err := client.CreateZoneRRSet(ctx, zone, hcloud.ZoneRRSetCreateOpts{
+		Name: "api", Type: hcloud.ZoneRRSetTypeA, Records: []hcloud.ZoneRRSetRecord{{Value: "198.51.100.1"}},
+	}); err != nil {
+		t.Fatalf("CreateZoneRRSet() error = %v", err)
+	}
+	rrset, err := client.GetZoneRRSet(ctx, zone, "api", hcloud.ZoneRRSetTypeA)
+	if err != nil || rrset == nil || len(rrset.Records) != 1 {
+		t.Fatalf("GetZoneRRSet() = %+v, %v, want the api A record", rrset, err)
+	}
+
+	cert, err := client.CreateManagedCertificate(ctx, hcloud.CertificateCreateOpts{
+		Name: "example.com", Type: hcloud.CertificateTypeManaged, DomainNames: []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatalf("CreateManagedCertificate() error = %v", err)
+	}
+
+	// Status: list the cluster servers by label
+	servers, err := client.ListServers(ctx, hcloud.ServerListOpts{ListOpts: hcloud.ListOpts{LabelSelector: "cluster=test"}})
+	if err != nil || len(servers) != 1 || servers[0].Name != "test-master-1" {
+		t.Fatalf("ListServers() = %v, %v, want test-master-1", servers, err)
+	}
+	if got, _ := client.GetLoadBalancer(ctx, "test-api-lb"); got == nil || len(got.Services) != 1 || len(got.Targets) != 1 {
+		t.Errorf("GetLoadBalancer() = %+v, want one service and one target", got)
+	}
+
+	// Delete in the order the deleter uses
+	for _, step := range []struct {
+		name string
+		run  func() error
+	}{
+		{"DeleteCertificate", func() error { return client.DeleteCertificate(ctx, cert) }},
+		{"DeleteZoneRRSet", func() error { return client.DeleteZoneRRSet(ctx, rrset) }},
+		{"DeleteZone", func() error { return client.DeleteZone(ctx, zone) }},
+		{"DeleteLoadBalancer", func() error { return client.DeleteLoadBalancer(ctx, lb) }},
+		{"DeleteServer", func() error { return client.DeleteServer(ctx, master) }},
+		{"DeleteFirewall", func() error { return client.DeleteFirewall(ctx, firewall) }},
+		{"DeleteNetwork", func() error { return client.DeleteNetwork(ctx, network) }},
+		{"DeleteSSHKey", func() error { return client.DeleteSSHKey(ctx, sshKey) }},
+	} {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s() error = %v", step.name, err)
+		}
+	}
+
+	f := server.Fake
+	if n := len(f.Servers()) + len(f.Networks()) + len(f.SSHKeys()) + len(f.Firewalls()) +
+		len(f.LoadBalancers()) + len(f.Zones()) + len(f.Certificates()); n != 0 {
+		t.Errorf("%d resource(s) left after deletion, want 0", n)
+	}
+}
+
+func TestServer_SlowAndFailingActions(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+
+	opts := func(name string) hcloud.ServerCreateOpts {
+		return hcloud.ServerCreateOpts{Name: name, ServerType: &hcloud.ServerType{Name: "cx22"}, Image: &hcloud.Image{Name: "ubuntu-24.04"}}
+	}
+
+	server.SetActionPolls(3)
+	if _, err := client.CreateServer(ctx, opts("slow")); err != nil {
+		t.Fatalf("CreateServer() with slow actions error = %v", err)
+	}
+	polls := 0
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, "GET /actions/") {
+			polls++
+		}
+	}
+	if polls != 3 {
+		t.Errorf("action polled %d times, want 3", polls)
+	}
+
+	server.FailAction("create_server", "server creation failed")
+	_, err := client.CreateServer(ctx, opts("failing"))
+	if err == nil || !strings.Contains(err.Error(), "server creation failed") {
+		t.Errorf("CreateServer() error = %v, want the action error", err)
+	}
+}
+
+func TestServer_APIErrors(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+
+	_, ipRange, _ := net.ParseCIDR("10.0.0.0/16")
+
+	tests := []struct {
+		name string
+		run  func() error
+		code hcloud.ErrorCode
+	}{
+		{
+			name: "duplicate name",
+			run: func() error {
+				if _, err := client.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{Name: "key", PublicKey: "a"}); err != nil {
+					return err
+				}
+				_, err := client.CreateSSHKey(ctx, hcloud.SSHKeyCreateOpts{Name: "key", PublicKey: "b"})
+				return err
+			},
+			code: hcloud.ErrorCodeUniquenessError,
+		},
+		{
+			name: "unknown server type",
+			run: func() error {
+				_, err := client.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "bad",
+					ServerType: &hcloud.ServerType{Name: "cx999"}, Image: &hcloud.Image{Name: "ubuntu-24.04"}})
+				return err
+			},
+			code: hcloud.ErrorCodeInvalidInput,
+		},
+		{
+			name: "injected failure",
+			run: func() error {
+				server.FailRequest(http.MethodPost, "/networks", http.StatusForbidden, hcloud.ErrorCodeResourceLimitExceeded, 1)
+				_, err := client.CreateNetwork(ctx, hcloud.NetworkCreateOpts{Name: "limited", IPRange: ipRange})
+				return err
+			},
+			code: hcloud.ErrorCodeResourceLimitExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if !hcloud.IsError(err, tt.code) {
+				t.Errorf("error = %v, want %s", err, tt.code)
+			}
+		})
+	}
+
+	// The injected failure is returned only once
+	if _, err := client.CreateNetwork(ctx, hcloud.NetworkCreateOpts{Name: "limited", IPRange: ipRange}); err != nil {
+		t.Errorf("CreateNetwork() after the injected failure error = %v", err)
+	}
+}
+
+func TestServer_Retries(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+
+	server.FailRequest(http.MethodGet, "/ssh_keys", http.StatusTooManyRequests, hcloud.ErrorCodeRateLimitExceeded, 2)
+	if _, err := client.GetSSHKey(ctx, "missing"); err != nil {
+		t.Errorf("GetSSHKey() after two rate-limited requests error = %v", err)
+	}
+
+	server.FailRequest(http.MethodGet, "/networks", http.StatusServiceUnavailable, hcloud.ErrorCodeMaintenance, 0)
+	if _, err := client.GetNetwork(ctx, "missing"); !hcloud.IsError(err, hcloud.ErrorCodeMaintenance) {
+		t.Errorf("GetNetwork() error = %v, want maintenance once the retries are used up", err)
+	}
+}
+
+func TestServer_LocationFallback(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	server.Fake.SetLocationUnavailable("fsn1", true)
+
+	opts := func(name string) hcloud.ServerCreateOpts {
+		return hcloud.ServerCreateOpts{Name: name, ServerType: &hcloud.ServerType{Name: "cx22"},
+			Image: &hcloud.Image{Name: "ubuntu-24.04"}, Location: &hcloud.Location{Name: "fsn1"}}
+	}
+
+	if _, err := server.HetznerClient().CreateServer(ctx, opts("pinned")); !hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) {
+		t.Errorf("CreateServer() without fallback error = %v, want resource_unavailable", err)
+	}
+
+	client := server.HetznerClient(hetzner.WithLocationFallback(true))
+	created, err := client.CreateServer(ctx, opts("moved"))
+	if err != nil {
+		t.Fatalf("CreateServer() with fallback error = %v", err)
+	}
+	if created.Location == nil || created.Location.Name != "hel1" {
+		t.Errorf("CreateServer() location = %+v, want hel1, the first other location in eu-central", created.Location)
+	}
+
+	server.Fake.SetLocationUnavailable("hel1", true)
+	server.Fake.SetLocationUnavailable("nbg1", true)
+	if _, err := client.CreateServer(ctx, opts("nowhere")); !hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) {
+		t.Errorf("CreateServer() with every location full error = %v, want resource_unavailable", err)
+	}
+}
+
+func TestServer_PrimaryIPs(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient(hetzner.WithLocationFallback(true))
+
+	primaryIP, err := client.CreatePrimaryIP(ctx, hcloud.PrimaryIPCreateOpts{
+		Name: "test-master-1-ipv4", Type: hcloud.PrimaryIPTypeIPv4, AssigneeType: "server",
+		AutoDelete: hcloud.Ptr(false), Location: "fsn1", Labels: map[string]string{"cluster": "test"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePrimaryIP() error = %v", err)
+	}
+
+	opts := hcloud.ServerCreateOpts{Name: "test-master-1", ServerType: &hcloud.ServerType{Name: "cx22"},
+		Image: &hcloud.Image{Name: "ubuntu-24.04"}, Location: &hcloud.Location{Name: "fsn1"},
+		PublicNet: &hcloud.ServerCreatePublicNet{EnableIPv4: true, EnableIPv6: true, IPv4: primaryIP}}
+
+	// A server with a primary IP cannot move to another location
+	server.Fake.SetLocationUnavailable("fsn1", true)
+	if _, err := client.CreateServer(ctx, opts); !hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) {
+		t.Errorf("CreateServer() with a primary IP in a full location error = %v, want resource_unavailable", err)
+	}
+	server.Fake.SetLocationUnavailable("fsn1", false)
+
+	created, err := client.CreateServer(ctx, opts)
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if !created.PublicNet.IPv4.IP.Equal(primaryIP.IP) {
+		t.Errorf("server IPv4 = %s, want the primary IP %s", created.PublicNet.IPv4.IP, primaryIP.IP)
+	}
+
+	primaryIPs, err := client.ListPrimaryIPs(ctx, hcloud.PrimaryIPListOpts{ListOpts: hcloud.ListOpts{LabelSelector: "cluster=test"}})
+	if err != nil || len(primaryIPs) != 1 || primaryIPs[0].AssigneeID != created.ID {
+		t.Fatalf("ListPrimaryIPs() = %+v, %v, want the IP assigned to server %d", primaryIPs, err, created.ID)
+	}
+
+	if err := client.DeleteServer(ctx, created); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if err := client.DeletePrimaryIP(ctx, primaryIP); err != nil {
+		t.Fatalf("DeletePrimaryIP() error = %v", err)
+	}
+	if got, err := client.GetPrimaryIP(ctx, "test-master-1-ipv4"); got != nil || err != nil {
+		t.Errorf("GetPrimaryIP() after deletion = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestServer_FloatingIPs(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+
+	floatingIP, err := client.CreateFloatingIP(ctx, hcloud.FloatingIPCreateOpts{
+		Type: hcloud.FloatingIPTypeIPv4, Name: hcloud.Ptr("test-api-ip"), HomeLocation: &hcloud.Location{Name: "fsn1"},
+		Labels: map[string]string{"cluster": "test"},
+	})
+	if err != nil {
+		t.Fatalf("CreateFloatingIP() error = %v", err)
+	}
+
+	master, err := client.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-master-1", ServerType: &hcloud.ServerType{Name: "cx22"},
+		Image: &hcloud.Image{Name: "ubuntu-24.04"}, Location: &hcloud.Location{Name: "fsn1"}})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if err := client.AssignFloatingIP(ctx, floatingIP, master); err != nil {
+		t.Fatalf("AssignFloatingIP() error = %v", err)
+	}
+
+	floatingIPs, err := client.ListFloatingIPs(ctx, hcloud.FloatingIPListOpts{ListOpts: hcloud.ListOpts{LabelSelector: "cluster=test"}})
+	if err != nil || len(floatingIPs) != 1 || floatingIPs[0].Server == nil || floatingIPs[0].Server.ID != master.ID {
+		t.Fatalf("ListFloatingIPs() = %+v, %v, want the IP assigned to server %d", floatingIPs, err, master.ID)
+	}
+	if !floatingIPs[0].IP.Equal(floatingIP.IP) {
+		t.Errorf("listed IP = %s, want %s", floatingIPs[0].IP, floatingIP.IP)
+	}
+
+	if err := client.DeleteFloatingIP(ctx, floatingIP); err != nil {
+		t.Fatalf("DeleteFloatingIP() error = %v", err)
+	}
+	if got, err := client.GetFloatingIP(ctx, "test-api-ip"); got != nil || err != nil {
+		t.Errorf("GetFloatingIP() after deletion = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestServer_Volumes(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient(hetzner.WithLocationFallback(true))
+
+	volume, err := client.CreateVolume(ctx, hcloud.VolumeCreateOpts{
+		Name: "test-worker-1-data", Size: 20, Location: &hcloud.Location{Name: "fsn1"},
+		Labels: map[string]string{"cluster": "test", "volume": "data"},
+	})
+	if err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+
+	opts := hcloud.ServerCreateOpts{Name: "test-worker-1", ServerType: &hcloud.ServerType{Name: "cx22"},
+		Image: &hcloud.Image{Name: "ubuntu-24.04"}, Location: &hcloud.Location{Name: "fsn1"},
+		Volumes: []*hcloud.Volume{volume}, Automount: hcloud.Ptr(false)}
+
+	// A server with a volume cannot move to another location
+	server.Fake.SetLocationUnavailable("fsn1", true)
+	if _, err := client.CreateServer(ctx, opts); !hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) {
+		t.Errorf("CreateServer() with a volume in a full location error = %v, want resource_unavailable", err)
+	}
+	server.Fake.SetLocationUnavailable("fsn1", false)
+
+	created, err := client.CreateServer(ctx, opts)
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	volumes, err := client.ListVolumes(ctx, hcloud.VolumeListOpts{ListOpts: hcloud.ListOpts{LabelSelector: "cluster=test"}})
+	if err != nil || len(volumes) != 1 || volumes[0].Server == nil || volumes[0].Server.ID != created.ID {
+		t.Fatalf("ListVolumes() = %+v, %v, want the volume attached to server %d", volumes, err, created.ID)
+	}
+	if volumes[0].Size != 20 || volumes[0].Labels["volume"] != "data" {
+		t.Errorf("listed volume = %+v, want 20 GB with label volume=data", volumes[0])
+	}
+
+	if err := client.DeleteServer(ctx, created); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if err := client.DeleteVolume(ctx, volume); err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+	if got, err := client.GetVolume(ctx, "test-worker-1-data"); got != nil || err != nil {
+		t.Errorf("GetVolume() after deletion = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestServer_CreateImage(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer()
+	defer server.Close()
+	client := server.HetznerClient()
+
+	created, err := client.CreateServer(ctx, hcloud.ServerCreateOpts{Name: "test-image-builder", ServerType: &hcloud.ServerType{Name: "cx22"},
+		Image: &hcloud.Image{Name: "ubuntu-24.04"}, Location: &hcloud.Location{Name: "fsn1"}})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if err := client.ShutdownServer(ctx, created); err != nil {
+		t.Fatalf("ShutdownServer() error = %v", err)
+	}
+	if err := client.WaitForServerStatus(ctx, created, hcloud.ServerStatusOff, time.Second); err != nil {
+		t.Fatalf("WaitForServerStatus(off) error = %v", err)
+	}
+
+	server.SetActionPolls(2)
+	image, err := client.CreateImage(ctx, created, hcloud.ServerCreateImageOpts{
+		Type: hcloud.ImageTypeSnapshot, Description: hcloud.Ptr("node image"), Labels: map[string]string{"role": "node-image"}})
+	if err != nil {
+		t.Fatalf("CreateImage() error = %v", err)
+	}
+	if image.Type != hcloud.ImageTypeSnapshot || image.Labels["role"] != "node-image" {
+		t.Errorf("CreateImage() = %+v, want a labelled snapshot", image)
+	}
+
+	got, err := client.GetImage(ctx, fmt.Sprint(image.ID), "")
+	if err != nil || got.ID != image.ID || got.Description != "node image" {
+		t.Errorf("GetImage(%d) = %+v, %v, want the snapshot", image.ID, got, err)
+	}
+}
@@ -0,0 +1,228 @@
+package hetzner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+const (
+	// defaultMaxRetries is how often a failed request is retried
+	defaultMaxRetries = 5
+	// defaultRetryBaseDelay is the delay before the first retry, doubled for every further retry
+	defaultRetryBaseDelay = 1 * time.Second
+	// maxRetryDelay caps the delay between retries
+	maxRetryDelay = 1 * time.Minute
+)
+
+// retryableErrorCodes are the API errors that go away when the request is repeated later
+var retryableErrorCodes = map[hcloud.ErrorCode]bool{
+	hcloud.ErrorCodeRateLimitExceeded:   true,
+	hcloud.ErrorCodeConflict:            true,
+	hcloud.ErrorCodeLocked:              true,
+	hcloud.ErrorCodeTimeout:             true,
+	hcloud.ErrorCodeResourceUnavailable: true,
+	hcloud.ErrorCodePlacementError:      true,
+}
+
+// rejectedErrorCodes are the API errors returned before a request changed anything,
+// so even a non-idempotent request such as a create can safely be repeated
+var rejectedErrorCodes = map[hcloud.ErrorCode]bool{
+	hcloud.ErrorCodeRateLimitExceeded:   true,
+	hcloud.ErrorCodeLocked:              true,
+	hcloud.ErrorCodeResourceUnavailable: true,
+	hcloud.ErrorCodePlacementError:      true,
+}
+
+// retryTransport retries rate-limited, failed and transiently rejected API requests
+// with jittered exponential backoff. It reads the RateLimit headers of every response
+// and holds back requests while the rate limit is used up, so parallel callers do not
+// all run into 429 responses.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+
+	mu        sync.Mutex
+	notBefore time.Time
+}
+
+// newRetryTransport wraps a transport with retries
+func newRetryTransport(next http.RoundTripper, maxRetries int, baseDelay time.Duration) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &retryTransport{next: next, maxRetries: maxRetries, baseDelay: baseDelay}
+}
+
+// RoundTrip sends a request and repeats it while the response is retryable
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.waitForRateLimit(req); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if err == nil {
+			t.observeRateLimit(resp)
+		}
+
+		if attempt >= t.maxRetries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil && resp.Body != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := t.backoff(attempt)
+		if wait := retryAfter(resp); wait > delay {
+			delay = wait
+		}
+		if err := sleep(req, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForRateLimit waits until the rate limit allows another request
+func (t *retryTransport) waitForRateLimit(req *http.Request) error {
+	t.mu.Lock()
+	wait := time.Until(t.notBefore)
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	return sleep(req, wait)
+}
+
+// observeRateLimit reads the RateLimit headers of a response. Once no requests are
+// left, further requests wait until the API has refilled one.
+func (t *retryTransport) observeRateLimit(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("RateLimit-Remaining"))
+	if err != nil || remaining > 0 {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.notBefore = time.Now().Add(refillInterval(limit, remaining, time.Until(time.Unix(reset, 0))))
+}
+
+// refillInterval estimates how long the API takes to refill a single request
+// The rate limit refills evenly until all requests are available again at reset.
+func refillInterval(limit, remaining int, untilReset time.Duration) time.Duration {
+	if untilReset <= 0 || limit <= remaining {
+		return 0
+	}
+	interval := untilReset / time.Duration(limit-remaining)
+	return min(interval, maxRetryDelay)
+}
+
+// backoff returns the jittered exponential delay before a retry
+// The delay doubles with every attempt and a random half of it is jitter, so parallel
+// callers spread their retries.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << attempt
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) // #nosec G404
+}
+
+// retryable reports whether a request should be repeated
+// 429 responses are always retried. Reads and deletes are also retried on network
+// timeouts, 5xx responses and API errors with a retryable error code. Other requests,
+// such as creates, may have taken effect even though no reply arrived, so they are
+// only retried when the API rejected them before doing anything.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodDelete
+	if err != nil {
+		var netErr net.Error
+		return idempotent && errors.As(err, &netErr) && netErr.Timeout()
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode >= 500 {
+		return idempotent
+	}
+	if resp.StatusCode < 400 {
+		return false
+	}
+	if idempotent {
+		return retryableErrorCodes[errorCode(resp)]
+	}
+	return rejectedErrorCodes[errorCode(resp)]
+}
+
+// errorCode reads the API error code of a response and restores its body
+func errorCode(resp *http.Response) hcloud.ErrorCode {
+	if resp.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var errResp schema.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return ""
+	}
+	return hcloud.ErrorCode(errResp.Error.Code)
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a response
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryDelay)
+}
+
+// sleep waits for the delay unless the request is canceled first
+func sleep(req *http.Request, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
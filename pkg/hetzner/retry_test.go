@@ -0,0 +1,176 @@
+package hetzner
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		status     int
+		body       string
+		failures   int
+		wantStatus int
+		wantCalls  int
+	}{
+		{"rate limited", http.MethodPost, http.StatusTooManyRequests, `{"error":{"code":"rate_limit_exceeded"}}`, 2, http.StatusOK, 3},
+		{"server error on read", http.MethodGet, http.StatusServiceUnavailable, `{"error":{"code":"maintenance"}}`, 1, http.StatusOK, 2},
+		{"server error on delete", http.MethodDelete, http.StatusInternalServerError, `{"error":{"code":"server_error"}}`, 1, http.StatusOK, 2},
+		{"server error on create", http.MethodPost, http.StatusInternalServerError, `{"error":{"code":"server_error"}}`, 1, http.StatusInternalServerError, 1},
+		{"conflict on read", http.MethodGet, http.StatusConflict, `{"error":{"code":"conflict"}}`, 1, http.StatusOK, 2},
+		{"conflict on create", http.MethodPost, http.StatusConflict, `{"error":{"code":"conflict"}}`, 1, http.StatusConflict, 1},
+		{"locked", http.MethodPost, http.StatusLocked, `{"error":{"code":"locked"}}`, 1, http.StatusOK, 2},
+		{"resource unavailable", http.MethodPost, http.StatusUnprocessableEntity, `{"error":{"code":"resource_unavailable"}}`, 1, http.StatusOK, 2},
+		{"placement error", http.MethodPost, http.StatusUnprocessableEntity, `{"error":{"code":"placement_error"}}`, 1, http.StatusOK, 2},
+		{"retries exhausted", http.MethodPost, http.StatusTooManyRequests, `{"error":{"code":"rate_limit_exceeded"}}`, 10, http.StatusTooManyRequests, 4},
+		{"invalid input", http.MethodPost, http.StatusBadRequest, `{"error":{"code":"invalid_input"}}`, 1, http.StatusBadRequest, 1},
+		{"not found", http.MethodGet, http.StatusNotFound, `{"error":{"code":"not_found"}}`, 1, http.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"name":"test"}` {
+					t.Errorf("request body = %q, want the original body on every attempt", body)
+				}
+				if int(calls.Add(1)) <= tt.failures {
+					w.WriteHeader(tt.status)
+					io.WriteString(w, tt.body)
+					return
+				}
+				io.WriteString(w, `{}`)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: newRetryTransport(nil, 3, time.Millisecond)}
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader(`{"name":"test"}`))
+			if err != nil {
+				t.Fatalf("NewRequest() error = %v", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := int(calls.Load()); got != tt.wantCalls {
+				t.Errorf("requests = %d, want %d", got, tt.wantCalls)
+			}
+			if tt.wantStatus != http.StatusOK {
+				// The error body is still readable for hcloud
+				if body, _ := io.ReadAll(resp.Body); string(body) != tt.body {
+					t.Errorf("response body = %q, want %q", body, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransport_RateLimitHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Limit", "3600")
+		w.Header().Set("RateLimit-Remaining", "0")
+		// One request is refilled every 50ms
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(time.Now().Add(180*time.Second).Unix(), 10))
+		io.WriteString(w, `{}`)
+	}))
+	defer server.Close()
+
+	transport := newRetryTransport(nil, 0, time.Millisecond)
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	transport.mu.Lock()
+	wait := time.Until(transport.notBefore)
+	transport.mu.Unlock()
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait after an exhausted rate limit = %v, want about 50ms", wait)
+	}
+}
+
+func TestRefillInterval(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		remaining  int
+		untilReset time.Duration
+		want       time.Duration
+	}{
+		{"hourly limit exhausted", 3600, 0, time.Hour, time.Second},
+		{"reset passed", 3600, 0, -time.Second, 0},
+		{"requests left", 3600, 3600, time.Hour, 0},
+		{"capped", 1, 0, time.Hour, maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refillInterval(tt.limit, tt.remaining, tt.untilReset); got != tt.want {
+				t.Errorf("refillInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_Backoff(t *testing.T) {
+	transport := newRetryTransport(nil, defaultMaxRetries, time.Second)
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, maxRetryDelay} {
+		if attempt == 4 {
+			attempt = 20
+		}
+		for i := 0; i < 10; i++ {
+			if got := transport.backoff(attempt); got < want/2 || got > want {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, got, want/2, want)
+			}
+		}
+	}
+}
+
+func TestFallbackLocations(t *testing.T) {
+	locations := []*hcloud.Location{
+		{ID: 1, Name: "nbg1", NetworkZone: hcloud.NetworkZoneEUCentral},
+		{ID: 2, Name: "fsn1", NetworkZone: hcloud.NetworkZoneEUCentral},
+		{ID: 3, Name: "hel1", NetworkZone: hcloud.NetworkZoneEUCentral},
+		{ID: 4, Name: "ash", NetworkZone: hcloud.NetworkZoneUSEast},
+	}
+
+	tests := []struct {
+		name      string
+		requested *hcloud.Location
+		want      []string
+	}{
+		{"same network zone", &hcloud.Location{Name: "fsn1"}, []string{"hel1", "nbg1"}},
+		{"by ID", &hcloud.Location{ID: 3}, []string{"fsn1", "nbg1"}},
+		{"no other location", &hcloud.Location{Name: "ash", NetworkZone: hcloud.NetworkZoneUSEast}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, location := range fallbackLocations(locations, tt.requested) {
+				got = append(got, location.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("fallbackLocations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package hetzner
+
+import "sync"
+
+// ResourceKind identifies the type of a Hetzner resource created through the client
+type ResourceKind string
+
+// Resource kinds recorded by ResourceTracker
+const (
+	ResourceServer         ResourceKind = "server"
+	ResourceNetwork        ResourceKind = "network"
+	ResourceSSHKey         ResourceKind = "ssh key"
+	ResourcePrimaryIP      ResourceKind = "primary ip"
+	ResourceFloatingIP     ResourceKind = "floating ip"
+	ResourceVolume         ResourceKind = "volume"
+	ResourcePlacementGroup ResourceKind = "placement group"
+	ResourceFirewall       ResourceKind = "firewall"
+	ResourceLoadBalancer   ResourceKind = "load balancer"
+	ResourceZone           ResourceKind = "zone"
+	ResourceCertificate    ResourceKind = "certificate"
+)
+
+// CreatedResource is a resource created through the client while a tracker was attached
+type CreatedResource struct {
+	Kind ResourceKind
+	ID   int64
+	Name string
+}
+
+// ResourceTracker records the resources created through a Client, in creation order
+// It is safe for concurrent use, since servers are created in parallel.
+type ResourceTracker struct {
+	mu        sync.Mutex
+	resources []CreatedResource
+}
+
+// NewResourceTracker creates an empty resource tracker
+func NewResourceTracker() *ResourceTracker {
+	return &ResourceTracker{}
+}
+
+// Record adds a created resource to the tracker
+func (t *ResourceTracker) Record(kind ResourceKind, id int64, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resources = append(t.resources, CreatedResource{Kind: kind, ID: id, Name: name})
+}
+
+// Resources returns a copy of the recorded resources in creation order
+func (t *ResourceTracker) Resources() []CreatedResource {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	resources := make([]CreatedResource, len(t.resources))
+	copy(resources, t.resources)
+	return resources
+}
+
+// SetResourceTracker attaches a tracker that records every resource created through the client
+// Passing nil detaches the current tracker.
+func (c *Client) SetResourceTracker(tracker *ResourceTracker) {
+	c.tracker = tracker
+}
+
+// track records a created resource if a tracker is attached
+func (c *Client) track(kind ResourceKind, id int64, name string) {
+	if c.tracker != nil {
+		c.tracker.Record(kind, id, name)
+	}
+}
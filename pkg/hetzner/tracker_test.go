@@ -0,0 +1,61 @@
+package hetzner
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestResourceTracker_RecordsInOrder(t *testing.T) {
+	tracker := NewResourceTracker()
+	tracker.Record(ResourceSSHKey, 1, "test-ssh-key")
+	tracker.Record(ResourceNetwork, 2, "test")
+
+	resources := tracker.Resources()
+	if len(resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(resources))
+	}
+	if resources[0].Kind != ResourceSSHKey || resources[1].Name != "test" {
+		t.Errorf("unexpected resources: %+v", resources)
+	}
+
+	// Returned slice must be a copy
+	resources[0].Name = "changed"
+	if tracker.Resources()[0].Name != "test-ssh-key" {
+		t.Error("Resources() returned the internal slice")
+	}
+}
+
+func TestResourceTracker_Concurrent(t *testing.T) {
+	tracker := NewResourceTracker()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			tracker.Record(ResourceServer, id, "server")
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if len(tracker.Resources()) != 50 {
+		t.Errorf("expected 50 resources, got %d", len(tracker.Resources()))
+	}
+}
+
+func TestClient_TrackWithoutTracker(t *testing.T) {
+	client := NewClient("test-token")
+
+	// Must not panic without a tracker
+	client.track(ResourceServer, 1, "server")
+
+	tracker := NewResourceTracker()
+	client.SetResourceTracker(tracker)
+	client.track(ResourceServer, 1, "server")
+	client.SetResourceTracker(nil)
+	client.track(ResourceServer, 2, "other")
+
+	if len(tracker.Resources()) != 1 {
+		t.Errorf("expected 1 tracked resource, got %d", len(tracker.Resources()))
+	}
+}